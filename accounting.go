@@ -0,0 +1,102 @@
+package gaestore
+
+import (
+	"context"
+	"sync"
+)
+
+// RPCSummary counts the operations and RPCs made through stores with a
+// context returned by WithAccounting. It is useful for spotting N+1 access
+// patterns and estimating the datastore cost of a request.
+type RPCSummary struct {
+	Operations int
+
+	// DatastoreReads, DatastoreWrites, DatastoreDeletes and
+	// DatastoreQueries count RPCs, each of which may be made for many
+	// entities
+	DatastoreReads   int
+	DatastoreWrites  int
+	DatastoreDeletes int
+	DatastoreQueries int
+
+	// EntityReads, EntityWrites and EntityDeletes count the entities the
+	// datastore RPCs were made for, and QueryResults the keys returned by
+	// queries, which is what the datastore bills for
+	EntityReads   int
+	EntityWrites  int
+	EntityDeletes int
+	QueryResults  int
+
+	MemcacheGets    int
+	MemcacheHits    int
+	MemcacheSets    int
+	MemcacheDeletes int
+}
+
+type accounting struct {
+	mu      sync.Mutex
+	summary RPCSummary
+}
+
+type accountingKey struct{}
+
+// WithAccounting returns a context that counts every operation and RPC
+// made through a store using it, or a context derived from it. Call it
+// once at the start of a request and read the counts with Summary.
+func WithAccounting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, accountingKey{}, &accounting{})
+}
+
+// Summary returns the counts for a context returned by WithAccounting.
+// The summary is empty if accounting is not enabled for ctx.
+func Summary(ctx context.Context) RPCSummary {
+	a, ok := ctx.Value(accountingKey{}).(*accounting)
+	if !ok {
+		return RPCSummary{}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.summary
+}
+
+func accountingFromContext(ctx context.Context) *accounting {
+	a, _ := ctx.Value(accountingKey{}).(*accounting)
+	return a
+}
+
+func (a *accounting) recordOperation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.summary.Operations++
+}
+
+// recordRPC counts an RPC made for n entities, or returning n keys for a
+// query
+func (a *accounting) recordRPC(rpc string, n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sum := &a.summary
+	switch rpc {
+	case RPCDatastoreGet:
+		sum.DatastoreReads++
+		sum.EntityReads += n
+	case RPCDatastorePut:
+		sum.DatastoreWrites++
+		sum.EntityWrites += n
+	case RPCDatastoreDelete:
+		sum.DatastoreDeletes++
+		sum.EntityDeletes += n
+	case RPCDatastoreQuery:
+		sum.DatastoreQueries++
+		sum.QueryResults += n
+	case RPCMemcacheGet:
+		sum.MemcacheGets++
+		if err == nil {
+			sum.MemcacheHits++
+		}
+	case RPCMemcacheSet, RPCMemcacheIncrement:
+		sum.MemcacheSets++
+	case RPCMemcacheDelete:
+		sum.MemcacheDeletes++
+	}
+}
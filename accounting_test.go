@@ -0,0 +1,39 @@
+package gaestore
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+)
+
+func TestSummary(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	ctx = WithAccounting(ctx)
+	s := NewStoreWithCache()
+	o := &object{ID: "1", Name: "John"}
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Get(ctx, &object{ID: o.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := RPCSummary{
+		Operations:      3,
+		DatastoreWrites: 1,
+		EntityWrites:    1,
+		MemcacheSets:    1,
+		MemcacheGets:    2,
+		MemcacheHits:    2,
+	}
+	if got := Summary(ctx); got != expected {
+		t.Fatalf("Expected summary [%+v] but got [%+v]", expected, got)
+	}
+}
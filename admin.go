@@ -0,0 +1,237 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+	"google.golang.org/appengine/user"
+)
+
+// AdminOptions configures an AdminHandler
+type AdminOptions struct {
+	// Store is inspected, by default the store returned by FromContext,
+	// which must then be a *Client
+	Store *Client
+
+	// Context returns the context of a request, by default
+	// appengine.NewContext(r)
+	Context func(r *http.Request) context.Context
+
+	// Authorize is called before each request, which fails with 403
+	// Forbidden when it returns an error. By default requests must be
+	// made by an admin of the app.
+	Authorize func(r *http.Request) error
+
+	// PageSize is the number of entities listed per page, and the most a
+	// request can ask for with the limit parameter. It is 20 by default.
+	PageSize int
+}
+
+// ErrNotAdmin is returned by the default authorization of AdminHandler
+// requests not made by an admin of the app
+var ErrNotAdmin = errors.New("Admin access required")
+
+// AdminHandler returns a handler serving JSON to inspect the entities and
+// cache of a store:
+//
+//	GET  /entity?key={key}               gets the properties of an entity
+//	GET  /cache?key={key}                gets the cached value of an entity
+//	POST /evict?key={key}&key={key}...   evicts entities from the cache
+//	GET  /kinds/{kind}                   lists entities, with cursor and limit parameters
+//
+// Keys are encoded as by datastore.Key.Encode. Entities are read as
+// DynamicEntity values, so any kind can be inspected, and bypass the
+// cache. Paths are relative to the handler, so mount it with
+// http.StripPrefix:
+//
+//	http.Handle("/_gaestore/", http.StripPrefix("/_gaestore", gaestore.AdminHandler(gaestore.AdminOptions{})))
+func AdminHandler(opts AdminOptions) http.Handler {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 20
+	}
+	if opts.Context == nil {
+		opts.Context = appengine.NewContext
+	}
+	if opts.Authorize == nil {
+		opts.Authorize = requireAdmin
+	}
+	return &adminHandler{opts: opts}
+}
+
+func requireAdmin(r *http.Request) error {
+	if !user.IsAdmin(appengine.NewContext(r)) {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+type adminHandler struct {
+	opts AdminOptions
+}
+
+// adminEntity is an entity in the responses of an AdminHandler
+type adminEntity struct {
+	Key        string         `json:"key"`
+	Properties *DynamicEntity `json:"properties"`
+}
+
+// adminCacheEntry is the response to a cache lookup
+type adminCacheEntry struct {
+	Key       string `json:"key"`
+	Found     bool   `json:"found"`
+	Tombstone bool   `json:"tombstone,omitempty"`
+	Size      int    `json:"size"`
+	Value     []byte `json:"value,omitempty"`
+}
+
+// adminPage is the response to a list
+type adminPage struct {
+	Entities []adminEntity `json:"entities"`
+	Cursor   string        `json:"cursor"`
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := h.opts.Context(r)
+	path := strings.Trim(r.URL.Path, "/")
+	var (
+		v   interface{}
+		err error
+	)
+	if aerr := h.opts.Authorize(r); aerr != nil {
+		err = &httpError{http.StatusForbidden, aerr}
+	} else if s, serr := h.store(ctx); serr != nil {
+		err = serr
+	} else {
+		switch {
+		case path == "entity" && r.Method == http.MethodGet:
+			v, err = h.entity(ctx, s, r)
+		case path == "cache" && r.Method == http.MethodGet:
+			v, err = h.cache(ctx, s, r)
+		case path == "evict" && r.Method == http.MethodPost:
+			err = h.evict(ctx, s, r)
+		case strings.HasPrefix(path, "kinds/") && r.Method == http.MethodGet:
+			v, err = h.list(ctx, s, r, strings.TrimPrefix(path, "kinds/"))
+		default:
+			err = &httpError{http.StatusNotFound, fmt.Errorf("No admin page [%s %s]", r.Method, r.URL.Path)}
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (h *adminHandler) store(ctx context.Context) (*Client, error) {
+	if h.opts.Store != nil {
+		return h.opts.Store, nil
+	}
+	if s, ok := FromContext(ctx).(*Client); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("Store [%T] is not a *Client", FromContext(ctx))
+}
+
+func keyParam(r *http.Request) (*datastore.Key, error) {
+	key, err := datastore.DecodeKey(r.FormValue("key"))
+	if err != nil {
+		return nil, &httpError{http.StatusBadRequest, fmt.Errorf("Invalid key [%s]", r.FormValue("key"))}
+	}
+	return key, nil
+}
+
+func (h *adminHandler) entity(ctx context.Context, s *Client, r *http.Request) (interface{}, error) {
+	key, err := keyParam(r)
+	if err != nil {
+		return nil, err
+	}
+	e := NewDynamicEntity(key)
+	if err := s.WithoutCache().Get(ctx, e); err != nil {
+		return nil, err
+	}
+	return adminEntity{Key: key.Encode(), Properties: e}, nil
+}
+
+func (h *adminHandler) cache(ctx context.Context, s *Client, r *http.Request) (interface{}, error) {
+	key, err := keyParam(r)
+	if err != nil {
+		return nil, err
+	}
+	if ctx, err = s.withNamespace(ctx); err != nil {
+		return nil, err
+	}
+	value, err := s.mc().Get(ctx, key.Encode())
+	entry := adminCacheEntry{Key: key.Encode()}
+	switch {
+	case err == memcache.ErrCacheMiss:
+		return entry, nil
+	case err != nil:
+		return nil, err
+	}
+	entry.Found, entry.Size = true, len(value)
+	if entry.Tombstone = bytes.Equal(value, tombstone); !entry.Tombstone {
+		entry.Value = value
+	}
+	return entry, nil
+}
+
+func (h *adminHandler) evict(ctx context.Context, s *Client, r *http.Request) error {
+	r.ParseForm()
+	var keys []*datastore.Key
+	for _, k := range r.Form["key"] {
+		key, err := datastore.DecodeKey(k)
+		if err != nil {
+			return &httpError{http.StatusBadRequest, fmt.Errorf("Invalid key [%s]", k)}
+		}
+		keys = append(keys, key)
+	}
+	return s.Evict(ctx, keys...)
+}
+
+func (h *adminHandler) list(ctx context.Context, s *Client, r *http.Request, kind string) (interface{}, error) {
+	limit := h.opts.PageSize
+	if l := r.FormValue("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, &httpError{http.StatusBadRequest, fmt.Errorf("Invalid limit [%s]", l)}
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	q := datastore.NewQuery(kind).Limit(limit)
+	if c := r.FormValue("cursor"); c != "" {
+		cursor, err := DecodeCursor(c, nil, "")
+		if err != nil {
+			return nil, &httpError{http.StatusBadRequest, fmt.Errorf("Invalid cursor [%s]", c)}
+		}
+		q = q.Start(cursor)
+	}
+	var entities []*DynamicEntity
+	cursor, err := s.WithoutCache().Query(ctx, q, &entities)
+	if err != nil {
+		return nil, err
+	}
+	page := adminPage{Entities: make([]adminEntity, len(entities))}
+	for i, e := range entities {
+		page.Entities[i] = adminEntity{Key: e.Key(ctx).Encode(), Properties: e}
+	}
+	if len(entities) == limit {
+		page.Cursor = EncodeCursor(cursor, nil, "")
+	}
+	return page, nil
+}
@@ -0,0 +1,110 @@
+package gaestore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestAdminHandler(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	denied := false
+	h := gaestore.AdminHandler(gaestore.AdminOptions{
+		Store:    s,
+		Context:  func(r *http.Request) context.Context { return ctx },
+		PageSize: 2,
+		Authorize: func(r *http.Request) error {
+			if denied {
+				return errors.New("denied")
+			}
+			return nil
+		},
+	})
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := s.Put(ctx, &widget{ID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Apply()
+	key := (&widget{ID: "a"}).Key(ctx).Encode()
+
+	var e struct {
+		Key        string
+		Properties []struct{ Name, Value string }
+	}
+	w := serve("GET", "/entity?key="+url.QueryEscape(key))
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatalf("Expected an entity but got [%d] %s", w.Code, w.Body)
+	}
+	if e.Key != key || len(e.Properties) != 1 || e.Properties[0].Value != "a" {
+		t.Fatalf("Expected the properties of [%s] but got %s", key, w.Body)
+	}
+
+	var entry struct {
+		Found bool
+		Size  int
+	}
+	if err := s.Get(ctx, &widget{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	w = serve("GET", "/cache?key="+url.QueryEscape(key))
+	if err := json.Unmarshal(w.Body.Bytes(), &entry); err != nil || !entry.Found || entry.Size == 0 {
+		t.Fatalf("Expected a cache entry but got [%d] %s", w.Code, w.Body)
+	}
+	if w := serve("POST", "/evict?key="+url.QueryEscape(key)); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected [204] but got [%d] %s", w.Code, w.Body)
+	}
+	w = serve("GET", "/cache?key="+url.QueryEscape(key))
+	if err := json.Unmarshal(w.Body.Bytes(), &entry); err != nil || entry.Found {
+		t.Fatalf("Expected no cache entry but got [%d] %s", w.Code, w.Body)
+	}
+
+	var page struct {
+		Entities []json.RawMessage
+		Cursor   string
+	}
+	var listed int
+	for path := "/kinds/widget"; ; {
+		w := serve("GET", path)
+		page.Cursor = ""
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("Expected a page but got [%d] %s", w.Code, w.Body)
+		}
+		listed += len(page.Entities)
+		if page.Cursor == "" {
+			break
+		}
+		path = "/kinds/widget?cursor=" + url.QueryEscape(page.Cursor)
+	}
+	if listed != 3 {
+		t.Fatalf("Expected [3] entities but got [%d]", listed)
+	}
+
+	for path, code := range map[string]int{
+		"/entity?key=bad": http.StatusBadRequest,
+		"/unknown":        http.StatusNotFound,
+	} {
+		if w := serve("GET", path); w.Code != code {
+			t.Fatalf("Expected [%d] for [%s] but got [%d]", code, path, w.Code)
+		}
+	}
+	denied = true
+	if w := serve("GET", "/kinds/widget"); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected [403] but got [%d]", w.Code)
+	}
+}
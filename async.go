@@ -0,0 +1,58 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/log"
+)
+
+// AsyncAfterPutter is implemented by entities with after put side effects
+// that are too slow to run inline. The hook is run from a task queue task
+// once the Put has completed, and is retried for as long as it returns an
+// error. The entity's kind must be registered with Register.
+type AsyncAfterPutter interface {
+	AsyncAfterPut(ctx context.Context, key *datastore.Key) error
+}
+
+var asyncAfterPutFunc = delay.Func("gaestore.AsyncAfterPut", runAsyncAfterPut)
+
+// enqueueAfterPut snapshots e and adds a task that will run its
+// AsyncAfterPut hook
+func enqueueAfterPut(ctx context.Context, key *datastore.Key, e Entity) error {
+	if _, ok := e.(AsyncAfterPutter); !ok {
+		return nil
+	}
+	snapshot, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return asyncAfterPutFunc.Call(ctx, key.Kind(), key.Encode(), snapshot)
+}
+
+func runAsyncAfterPut(ctx context.Context, kind, encodedKey string, snapshot []byte) (err error) {
+	key, err := datastore.DecodeKey(encodedKey)
+	if err != nil {
+		// Retrying will never decode the key so drop the task
+		log.Errorf(ctx, "gaestore: dropping AsyncAfterPut, invalid key [%v]", err)
+		return nil
+	}
+	e, err := newEntity(kind)
+	if err != nil {
+		log.Errorf(ctx, "gaestore: dropping AsyncAfterPut for [%v]: %v", key, err)
+		return nil
+	}
+	if err := json.Unmarshal(snapshot, e); err != nil {
+		log.Errorf(ctx, "gaestore: dropping AsyncAfterPut for [%v]: %v", key, err)
+		return nil
+	}
+	putter, ok := e.(AsyncAfterPutter)
+	if !ok {
+		log.Errorf(ctx, "gaestore: dropping AsyncAfterPut for [%v], [%T] is not an AsyncAfterPutter", key, e)
+		return nil
+	}
+	defer recoverHook(ctx, "AsyncAfterPut", key, e, &err)
+	return putter.AsyncAfterPut(ctx, key)
+}
@@ -0,0 +1,51 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+var asyncCalls = make(chan *asyncObject, 1)
+
+type asyncObject struct {
+	ID   string
+	Name string
+}
+
+func (o asyncObject) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "asyncObject", o.ID, 0, nil)
+}
+
+func (o *asyncObject) AsyncAfterPut(ctx context.Context, key *datastore.Key) error {
+	asyncCalls <- o
+	return nil
+}
+
+func init() {
+	Register("asyncObject", asyncObject{})
+}
+
+func TestRunAsyncAfterPut(t *testing.T) {
+	ctx := keyContext(t)
+	o := asyncObject{ID: "1", Name: "John"}
+	snapshot, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := o.Key(ctx)
+	err = runAsyncAfterPut(ctx, key.Kind(), key.Encode(), snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-asyncCalls:
+		if *got != o {
+			t.Fatalf("Expected hook to run with [%v] but got [%v]", o, *got)
+		}
+	default:
+		t.Fatal("Expected AsyncAfterPut to be called")
+	}
+}
@@ -0,0 +1,104 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/user"
+)
+
+// AuditKind is the kind audit entries are stored under
+const AuditKind = "GaestoreAudit"
+
+// AuditEntry is an immutable record of a Put or Delete made through a
+// store with auditing enabled. Entries are stored as children of the
+// entity's key so they are written in the same transaction as the change.
+type AuditEntry struct {
+	Op   string
+	Kind string
+	Key  *datastore.Key
+	User string
+
+	// Snapshot is the JSON encoded entity as written by a Put, without
+	// the fields tagged as sensitive
+	Snapshot  []byte `datastore:",noindex"`
+	Timestamp time.Time
+}
+
+// EnableAudit makes every Put and Delete through the store write an
+// AuditEntry in the same transaction as the change. who identifies the
+// user making the change, when nil the signed in App Engine user is used.
+func (s *Client) EnableAudit(who func(ctx context.Context) string) {
+	if who == nil {
+		who = currentUser
+	}
+	s.auditUser = who
+}
+
+func currentUser(ctx context.Context) string {
+	if u := user.Current(ctx); u != nil {
+		return u.String()
+	}
+	return ""
+}
+
+// writeEntity puts e, along with an audit entry when auditing is enabled
+// and the chunks of its SplitBytes fields, in the transaction ctx is in or
+// a new one
+func (s *Client) writeEntity(ctx context.Context, key *datastore.Key, e Entity) (*datastore.Key, error) {
+	split := hasSplitFields(e)
+	if s.auditUser == nil && !split {
+		return s.datastorePut(ctx, key, e)
+	}
+	var k *datastore.Key
+	err := s.transact(ctx, func(tc context.Context) (err error) {
+		if split {
+			k, err = s.putSplit(tc, key, e)
+		} else {
+			k, err = s.datastorePut(tc, key, e)
+		}
+		if err != nil || s.auditUser == nil {
+			return err
+		}
+		return s.putAuditEntry(tc, OpPut, k, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// removeEntity deletes key, along with writing an audit entry when
+// auditing is enabled, in the transaction ctx is in or a new one
+func (s *Client) removeEntity(ctx context.Context, key *datastore.Key) error {
+	if s.auditUser == nil {
+		return s.datastoreDelete(ctx, key)
+	}
+	return s.transact(ctx, func(tc context.Context) error {
+		if err := s.datastoreDelete(tc, key); err != nil {
+			return err
+		}
+		return s.putAuditEntry(tc, OpDelete, key, nil)
+	})
+}
+
+func (s *Client) putAuditEntry(ctx context.Context, op string, key *datastore.Key, e Entity) error {
+	entry := &AuditEntry{
+		Op:        op,
+		Kind:      key.Kind(),
+		Key:       key,
+		User:      s.auditUser(ctx),
+		Timestamp: s.now(),
+	}
+	if e != nil {
+		snapshot, err := json.Marshal(redacted(e))
+		if err != nil {
+			return err
+		}
+		entry.Snapshot = snapshot
+	}
+	_, err := s.datastorePut(ctx, datastore.NewIncompleteKey(ctx, AuditKind, key), entry)
+	return err
+}
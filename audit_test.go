@@ -0,0 +1,88 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestAudit(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	s := NewStore()
+	s.EnableAudit(func(ctx context.Context) string {
+		return "auditor"
+	})
+	o := &object{ID: "1", Name: "John"}
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []AuditEntry
+	q := datastore.NewQuery(AuditKind).Ancestor(o.Key(ctx)).Order("Timestamp")
+	if _, err := q.GetAll(ctx, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected [2] audit entries but got [%v]", len(entries))
+	}
+	if entries[0].Op != OpPut || entries[1].Op != OpDelete || entries[0].User != "auditor" {
+		t.Fatalf("Unexpected audit entries [%+v]", entries)
+	}
+	var snapshot object
+	if err := json.Unmarshal(entries[0].Snapshot, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := compare(o, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flatBackend is a mapBackend that fails nested transactions, as the
+// datastore does
+type flatBackend struct {
+	mapBackend
+}
+
+func (b flatBackend) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	if ctx.Value(inTransaction{}) != nil {
+		return errors.New("nested transactions are not supported")
+	}
+	return b.mapBackend.RunInTransaction(ctx, fn, opts)
+}
+
+func TestAuditInTransaction(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(flatBackend{backend}), WithLogger(nopLogger{}))
+	s.EnableAudit(func(ctx context.Context) string {
+		return "auditor"
+	})
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		_, err := s.Put(tc, &object{ID: "1", Name: "John"})
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := 0
+	for k := range backend {
+		if key, _ := datastore.DecodeKey(k); key.Kind() == AuditKind {
+			entries++
+		}
+	}
+	if entries != 1 {
+		t.Fatalf("Expected [1] audit entry but got [%d]", entries)
+	}
+}
@@ -0,0 +1,108 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Authorizer decides whether an operation may be made through a store,
+// such as allowing users to read and write only their own entities. Can is
+// called before each operation with the entity it's made on, and the
+// operation fails with the error returned. For a PutMulti it's called once
+// per entity, and for a Query e is nil and op.Query holds the query.
+//
+// Reads are authorized before the entity is loaded, so e then only holds
+// the fields its Key method uses. Authorizers deciding on the fields of
+// the entities read implement ReadAuthorizer.
+type Authorizer interface {
+	Can(ctx context.Context, op *Operation, e Entity) error
+}
+
+// ReadAuthorizer is an Authorizer also deciding whether entities may be
+// read once they're loaded, such as to let users read only their own
+// entities. CanRead is called with the entity loaded by a Get, Reload or
+// Touch, and with each result of a Query. A refused entity is cleared, and
+// a refused result drops every result of the query, which then fails with
+// the error returned.
+type ReadAuthorizer interface {
+	Authorizer
+	CanRead(ctx context.Context, op *Operation, e Entity) error
+}
+
+// AuthorizerFunc adapts a function to an Authorizer
+type AuthorizerFunc func(ctx context.Context, op *Operation, e Entity) error
+
+func (f AuthorizerFunc) Can(ctx context.Context, op *Operation, e Entity) error {
+	return f(ctx, op, e)
+}
+
+// WithAuthorizer consults a for every operation made through the store,
+// including those made with SkipHooks
+func WithAuthorizer(a Authorizer) Option {
+	return func(c *Client) {
+		c.authorizer = a
+	}
+}
+
+// authorize asks the store's Authorizer whether op may be made
+func (s *Client) authorize(ctx context.Context, op *Operation) error {
+	if s.authorizer == nil {
+		return nil
+	}
+	if op.Name != OpPutMulti {
+		return s.authorizer.Can(ctx, op, op.Entity)
+	}
+	for _, e := range op.Entities {
+		if err := s.authorizer.Can(ctx, op, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorizeRead asks the store's ReadAuthorizer whether e, just loaded by
+// op, may be read, clearing it when it may not
+func (s *Client) authorizeRead(ctx context.Context, op *Operation, e Entity) error {
+	ra, ok := s.authorizer.(ReadAuthorizer)
+	if !ok {
+		return nil
+	}
+	if err := ra.CanRead(ctx, op, e); err != nil {
+		if v := reflect.ValueOf(e); v.Kind() == reflect.Ptr && !v.IsNil() {
+			v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		}
+		return err
+	}
+	return nil
+}
+
+// authorizeResults asks the store's ReadAuthorizer whether the results of
+// the query op, appended to its Dst from index start, may be read,
+// dropping them all when one may not
+func (s *Client) authorizeResults(ctx context.Context, op *Operation, start int, err error) error {
+	ra, ok := s.authorizer.(ReadAuthorizer)
+	var terr *ErrResultTruncated
+	if !ok || err != nil && !errors.As(err, &terr) {
+		return err
+	}
+	dv := reflect.ValueOf(op.Dst).Elem()
+	for i := start; i < dv.Len(); i++ {
+		ev := dv.Index(i)
+		if ev.Kind() != reflect.Ptr {
+			ev = ev.Addr()
+		}
+		e, ok := ev.Interface().(Entity)
+		if !ok {
+			continue
+		}
+		if rerr := ra.CanRead(ctx, op, e); rerr != nil {
+			for j := start; j < dv.Len(); j++ {
+				dv.Index(j).Set(reflect.Zero(dv.Type().Elem()))
+			}
+			dv.Set(dv.Slice(0, start))
+			return rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestAuthorizer(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	var ops []string
+	s := gaestoretest.NewStore(t, gaestore.WithAuthorizer(gaestore.AuthorizerFunc(func(ctx context.Context, op *gaestore.Operation, e gaestore.Entity) error {
+		ops = append(ops, op.Name)
+		if e, ok := e.(*exported); ok && e.ID == "secret" {
+			return gaestore.ErrUnauthorized
+		}
+		return nil
+	})))
+
+	if _, err := s.Put(ctx, &exported{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, &exported{ID: "secret"}); !errors.Is(err, gaestore.ErrUnauthorized) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrUnauthorized, err)
+	}
+	if err := s.Get(ctx, &exported{ID: "secret"}, gaestore.SkipHooks); !errors.Is(err, gaestore.ErrUnauthorized) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrUnauthorized, err)
+	}
+	if _, err := s.PutMulti(ctx, []gaestore.Entity{&exported{ID: "2"}, &exported{ID: "secret"}}); !errors.Is(err, gaestore.ErrUnauthorized) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrUnauthorized, err)
+	}
+	if err := s.Get(ctx, &exported{ID: "2"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected no entities to be written but got [%v]", err)
+	}
+
+	var entities []*exported
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 7 || ops[6] != gaestore.OpQuery {
+		t.Fatalf("Expected every operation to be authorized but got %v", ops)
+	}
+}
+
+// privateReads refuses reads of entities named private
+type privateReads struct{}
+
+func (privateReads) Can(ctx context.Context, op *gaestore.Operation, e gaestore.Entity) error {
+	return nil
+}
+
+func (privateReads) CanRead(ctx context.Context, op *gaestore.Operation, e gaestore.Entity) error {
+	if e.(*exported).Name == "private" {
+		return gaestore.ErrUnauthorized
+	}
+	return nil
+}
+
+func TestReadAuthorizer(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithAuthorizer(privateReads{}))
+	for _, e := range []*exported{{ID: "1", Name: "public"}, {ID: "2", Name: "private"}} {
+		if _, err := s.Put(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := &exported{ID: "1"}
+	if err := s.Get(ctx, got); err != nil || got.Name != "public" {
+		t.Fatalf("Expected [public] but got [%+v] %v", got, err)
+	}
+	for _, read := range []func(gaestore.Entity) error{
+		func(e gaestore.Entity) error { return s.Get(ctx, e) },
+		func(e gaestore.Entity) error { return s.Reload(ctx, e) },
+		func(e gaestore.Entity) error { return s.Touch(ctx, e) },
+	} {
+		got := &exported{ID: "2"}
+		if err := read(got); !errors.Is(err, gaestore.ErrUnauthorized) || got.Name != "" {
+			t.Fatalf("Expected [%v] but got [%+v] %v", gaestore.ErrUnauthorized, got, err)
+		}
+	}
+
+	var entities []*exported
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities); !errors.Is(err, gaestore.ErrUnauthorized) || len(entities) != 0 {
+		t.Fatalf("Expected [%v] and no results but got [%d] %v", gaestore.ErrUnauthorized, len(entities), err)
+	}
+	q := datastore.NewQuery("exported").Filter("Name =", "public")
+	if _, err := s.Query(ctx, q, &entities); err != nil || len(entities) != 1 {
+		t.Fatalf("Expected [1] result but got [%d] %v", len(entities), err)
+	}
+}
+
+func TestAuthorizerPutLater(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithAuthorizer(gaestore.AuthorizerFunc(func(ctx context.Context, op *gaestore.Operation, e gaestore.Entity) error {
+		if op.Name == gaestore.OpPutLater {
+			return gaestore.ErrUnauthorized
+		}
+		return nil
+	})))
+	queued := 0
+	putLater := *gaestore.CallPutLaterTask
+	defer func() { *gaestore.CallPutLaterTask = putLater }()
+	*gaestore.CallPutLaterTask = func(ctx context.Context, key *datastore.Key, props datastore.PropertyList) error {
+		queued++
+		return nil
+	}
+	if err := s.PutLater(ctx, &exported{ID: "1"}); !errors.Is(err, gaestore.ErrUnauthorized) || queued != 0 {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrUnauthorized, err)
+	}
+}
@@ -0,0 +1,244 @@
+package gaestore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// Backend performs the datastore calls made by a store. The default
+// backend uses the App Engine datastore API; see the cloudds package for
+// one using cloud.google.com/go/datastore.
+type Backend interface {
+	Get(ctx context.Context, key *datastore.Key, dst interface{}) error
+	Put(ctx context.Context, key *datastore.Key, src interface{}) (*datastore.Key, error)
+	Delete(ctx context.Context, key *datastore.Key) error
+	RunQuery(ctx context.Context, q *datastore.Query) Iterator
+
+	// RunInTransaction runs fn in a transaction. Calls made through the
+	// backend with the context passed to fn are part of the transaction.
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error
+}
+
+// Iterator is the result of running a query, as returned by
+// *datastore.Iterator
+type Iterator interface {
+	Next(dst interface{}) (*datastore.Key, error)
+	Cursor() (datastore.Cursor, error)
+}
+
+// Cacher caches encoded entities for a store. Get returns
+// memcache.ErrCacheMiss when key is not cached. The default cacher uses
+// App Engine memcache; see the rediscache package for one using Redis.
+type Cacher interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Adder is implemented by Cachers that can store a value only when the
+// key isn't already cached. Values read from the datastore are then added
+// rather than set, so they can't replace a value or tombstone written by
+// a concurrent Put or Delete.
+type Adder interface {
+	// Add stores value under key, returning memcache.ErrNotStored when
+	// key is already cached
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// ValueCopier is implemented by Cachers that are done with the value
+// passed to Set once it returns, having copied or sent it. The store then
+// encodes values with the default codec into buffers it reuses, rather
+// than allocating each one.
+type ValueCopier interface {
+	CopiesValues() bool
+}
+
+// SetBackend replaces the backend the store reads and writes entities with
+func (s *Client) SetBackend(b Backend) {
+	s.backend = b
+}
+
+// RunInTransaction runs fn in a transaction on the store's backend.
+// Operations made through the store with the context passed to fn are part
+// of the transaction. They read from the datastore rather than the cache,
+// and the cached copies of the entities they write are only replaced once
+// the transaction commits.
+func (s *Client) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.runInTransaction(ctx, fn, opts)
+}
+
+type transactionKey struct{}
+
+// transaction is the state of a transaction run through a store
+type transaction struct {
+	mu          sync.Mutex
+	afterCommit []func(ctx context.Context)
+}
+
+// runInTransaction runs fn in a transaction on the store's backend, with a
+// context transactional reports as being in one, then runs the functions
+// passed to afterCommit by the attempt that committed
+func (s *Client) runInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	var tx *transaction
+	err := s.ds().RunInTransaction(ctx, func(tc context.Context) error {
+		// Each attempt starts afresh, as a retried fn runs again
+		tx = &transaction{}
+		return fn(context.WithValue(tc, transactionKey{}, tx))
+	}, opts)
+	if err != nil || tx == nil {
+		return err
+	}
+	for _, f := range tx.afterCommit {
+		f(ctx)
+	}
+	return nil
+}
+
+// transactional reports whether ctx is that of a transaction run through
+// a store
+func transactional(ctx context.Context) bool {
+	_, in := ctx.Value(transactionKey{}).(*transaction)
+	return in
+}
+
+// afterCommit runs f once the transaction ctx is in commits, dropping it
+// if the transaction fails, or straight away when ctx isn't in one. f is
+// passed the context the transaction was started with.
+func afterCommit(ctx context.Context, f func(ctx context.Context)) {
+	tx, ok := ctx.Value(transactionKey{}).(*transaction)
+	if !ok {
+		f(ctx)
+		return
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.afterCommit = append(tx.afterCommit, f)
+}
+
+// transact runs fn as part of the transaction ctx is in, or in a new
+// transaction when it isn't in one, as transactions can't be nested
+func (s *Client) transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	if transactional(ctx) {
+		return fn(ctx)
+	}
+	return s.runInTransaction(ctx, fn, nil)
+}
+
+// SetCacher replaces the cacher used when caching is enabled
+func (s *Client) SetCacher(c Cacher) {
+	s.cacher = c
+}
+
+// Backend returns the backend the store reads and writes entities with
+func (s *Client) Backend() Backend {
+	return s.ds()
+}
+
+func (s *Client) ds() Backend {
+	if s.backend == nil {
+		return appengineBackend{}
+	}
+	return s.backend
+}
+
+func (s *Client) mc() Cacher {
+	if s.cacher == nil {
+		return memcacheCacher{}
+	}
+	return s.cacher
+}
+
+type appengineBackend struct{}
+
+func (appengineBackend) Get(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	return datastore.Get(ctx, key, dst)
+}
+
+func (appengineBackend) GetMulti(ctx context.Context, keys []*datastore.Key, dst interface{}) error {
+	return datastore.GetMulti(ctx, keys, dst)
+}
+
+func (appengineBackend) Put(ctx context.Context, key *datastore.Key, src interface{}) (*datastore.Key, error) {
+	return datastore.Put(ctx, key, src)
+}
+
+func (appengineBackend) PutMulti(ctx context.Context, keys []*datastore.Key, src interface{}) ([]*datastore.Key, error) {
+	return datastore.PutMulti(ctx, keys, src)
+}
+
+func (appengineBackend) Delete(ctx context.Context, key *datastore.Key) error {
+	return datastore.Delete(ctx, key)
+}
+
+func (appengineBackend) DeleteMulti(ctx context.Context, keys []*datastore.Key) error {
+	return datastore.DeleteMulti(ctx, keys)
+}
+
+func (appengineBackend) RunQuery(ctx context.Context, q *datastore.Query) Iterator {
+	return q.Run(ctx)
+}
+
+func (appengineBackend) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	return datastore.RunInTransaction(ctx, fn, opts)
+}
+
+type memcacheCacher struct{}
+
+func (memcacheCacher) Get(ctx context.Context, key string) ([]byte, error) {
+	item, err := memcache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return item.Value, nil
+}
+
+func (memcacheCacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return memcache.Set(ctx, &memcache.Item{
+		Key:        key,
+		Value:      value,
+		Expiration: ttl,
+	})
+}
+
+func (memcacheCacher) GetForSwap(ctx context.Context, key string) ([]byte, interface{}, error) {
+	item, err := memcache.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return item.Value, item, nil
+}
+
+func (memcacheCacher) CompareAndSwap(ctx context.Context, key string, value []byte, ttl time.Duration, token interface{}) error {
+	item := token.(*memcache.Item)
+	item.Value, item.Expiration = value, ttl
+	return memcache.CompareAndSwap(ctx, item)
+}
+
+func (memcacheCacher) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return memcache.Add(ctx, &memcache.Item{
+		Key:        key,
+		Value:      value,
+		Expiration: ttl,
+	})
+}
+
+// CopiesValues is true as the value is encoded into the RPC request
+func (memcacheCacher) CopiesValues() bool {
+	return true
+}
+
+func (memcacheCacher) Delete(ctx context.Context, key string) error {
+	return memcache.Delete(ctx, key)
+}
+
+func (memcacheCacher) Increment(ctx context.Context, key string, delta int64, initial uint64) (uint64, error) {
+	return memcache.Increment(ctx, key, delta, initial)
+}
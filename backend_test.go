@@ -0,0 +1,347 @@
+package gaestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// mapBackend stores entities as property lists keyed by encoded key
+type mapBackend map[string][]datastore.Property
+
+func (b mapBackend) Get(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	props, ok := b[key.Encode()]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	if pls, ok := dst.(datastore.PropertyLoadSaver); ok {
+		return pls.Load(props)
+	}
+	return datastore.LoadStruct(dst, props)
+}
+
+func (b mapBackend) Put(ctx context.Context, key *datastore.Key, src interface{}) (*datastore.Key, error) {
+	var props []datastore.Property
+	var err error
+	if pls, ok := src.(datastore.PropertyLoadSaver); ok {
+		props, err = pls.Save()
+	} else {
+		props, err = datastore.SaveStruct(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b[key.Encode()] = props
+	return key, nil
+}
+
+func (b mapBackend) Delete(ctx context.Context, key *datastore.Key) error {
+	delete(b, key.Encode())
+	return nil
+}
+
+func (b mapBackend) RunQuery(ctx context.Context, q *datastore.Query) Iterator {
+	return nil
+}
+
+// RunInTransaction records the transaction on ctx rather than isolating fn
+func (b mapBackend) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	return fn(context.WithValue(ctx, inTransaction{}, true))
+}
+
+type inTransaction struct{}
+
+type mapCacher map[string][]byte
+
+func (c mapCacher) Get(ctx context.Context, key string) ([]byte, error) {
+	value, ok := c[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (c mapCacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c[key] = value
+	return nil
+}
+
+func (c mapCacher) Delete(ctx context.Context, key string) error {
+	delete(c, key)
+	return nil
+}
+
+func TestBackend(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	o := &object{ID: "1", Name: "John"}
+	key, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backend[key.Encode()]; !ok {
+		t.Fatalf("Expected [%v] to be written to the backend", key)
+	}
+	if _, ok := cacher[key.Encode()]; !ok {
+		t.Fatalf("Expected [%v] to be cached", key)
+	}
+
+	// Reads are served from the cacher first
+	delete(backend, key.Encode())
+	got := &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := compare(o, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &object{ID: "1"}); !IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+}
+
+func TestBackendTransaction(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(backend), WithLogger(nopLogger{}), WithAudit(func(context.Context) string {
+		return "tester"
+	}))
+
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if tc.Value(inTransaction{}) == nil {
+			t.Fatalf("Expected fn to run in the backend's transaction")
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Audited writes use the backend's transactions too
+	if _, err := s.Put(ctx, &object{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend) != 2 {
+		t.Fatalf("Expected the entity and its audit entry to be written but got [%d] entities", len(backend))
+	}
+}
+
+// countingBackend counts the reads made through it
+type countingBackend struct {
+	mapBackend
+	gets int
+}
+
+func (b *countingBackend) Get(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	b.gets++
+	return b.mapBackend.Get(ctx, key, dst)
+}
+
+func TestCorruptCacheEntry(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := &countingBackend{mapBackend: mapBackend{}}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	o := &object{ID: "1", Name: "John"}
+	key, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacher[key.Encode()] = []byte("{corrupt")
+
+	got := &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "John" || backend.gets != 1 {
+		t.Fatalf("Expected [John] from 1 datastore read but got [%s] from [%d]", got.Name, backend.gets)
+	}
+	// The entry was replaced, so the next read is a hit
+	if err := s.Get(ctx, &object{ID: "1"}); err != nil || backend.gets != 1 {
+		t.Fatalf("Expected a cache hit but got [%d] datastore reads %v", backend.gets, err)
+	}
+
+	// An entry for a deleted entity is removed
+	delete(backend.mapBackend, key.Encode())
+	cacher[key.Encode()] = []byte("{corrupt")
+	if err := s.Get(ctx, &object{ID: "1"}); !IsNotFound(err) {
+		t.Fatalf("Expected not found but got [%v]", err)
+	}
+	if _, ok := cacher[key.Encode()]; ok {
+		t.Fatalf("Expected the corrupt entry to be deleted")
+	}
+}
+
+// copyingCacher keeps a copy of each value it is given
+type copyingCacher struct {
+	mapCacher
+	copies bool
+}
+
+func (c copyingCacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.mapCacher.Set(ctx, key, append([]byte(nil), value...), ttl)
+}
+
+func (c copyingCacher) CopiesValues() bool {
+	return c.copies
+}
+
+func TestPooledCacheEncoding(t *testing.T) {
+	ctx := keyContext(t)
+	cacher := copyingCacher{mapCacher{}, true}
+	s := NewStore(WithBackend(mapBackend{}), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	// Values encoded into reused buffers match those of the default codec
+	objects := []*object{{ID: "1", Name: "<John & Jane>"}, {ID: "2", Name: "Al"}}
+	for _, o := range objects {
+		if _, err := s.Put(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, o := range objects {
+		want, err := memcache.JSON.Marshal(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cacher.mapCacher[o.Key(ctx).Encode()]; string(got) != string(want) {
+			t.Fatalf("Expected [%s] but got [%s]", want, got)
+		}
+	}
+
+	allocs := func(c Cacher) float64 {
+		s := NewStore(WithBackend(mapBackend{}), WithCache(0), WithCacher(c), WithLogger(nopLogger{}))
+		o := &object{ID: "1", Name: "John"}
+		return testing.AllocsPerRun(100, func() {
+			s.cacheSet(ctx, o.Key(ctx), o)
+		})
+	}
+	if pooled, copied := allocs(copyingCacher{mapCacher{}, true}), allocs(copyingCacher{mapCacher{}, false}); pooled >= copied {
+		t.Fatalf("Expected fewer than [%v] allocations but got [%v]", copied, pooled)
+	}
+}
+
+// addingCacher is a mapCacher that can add values
+type addingCacher struct {
+	mapCacher
+}
+
+func (c addingCacher) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if _, ok := c.mapCacher[key]; ok {
+		return memcache.ErrNotStored
+	}
+	return c.Set(ctx, key, value, ttl)
+}
+
+// racingBackend calls during with the first Get, after reading the entity
+type racingBackend struct {
+	mapBackend
+	during func()
+}
+
+func (b *racingBackend) Get(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	err := b.mapBackend.Get(ctx, key, dst)
+	if during := b.during; during != nil {
+		b.during = nil
+		during()
+	}
+	return err
+}
+
+func TestDeleteTombstone(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := &racingBackend{mapBackend: mapBackend{}}, addingCacher{mapCacher{}}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	o := &object{ID: "1", Name: "John"}
+	key, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacher.Delete(ctx, key.Encode())
+
+	// A read racing the delete doesn't cache the deleted entity
+	backend.during = func() {
+		if err := s.Delete(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Get(ctx, &object{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &object{ID: "1"}); !IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+	if string(cacher.mapCacher[key.Encode()]) != string(tombstone) {
+		t.Fatalf("Expected a tombstone but got [%s]", cacher.mapCacher[key.Encode()])
+	}
+
+	// Writing the entity again replaces the tombstone
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	delete(backend.mapBackend, key.Encode())
+	if err := s.Get(ctx, &object{ID: "1"}); err != nil {
+		t.Fatalf("Expected a cache hit but got [%v]", err)
+	}
+}
+
+// versionedObject is cached with its version
+type versionedObject struct {
+	ID  string `datastore:"-"`
+	Rev int64
+}
+
+func (o *versionedObject) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "versionedObject", o.ID, 0, nil)
+}
+
+func (o *versionedObject) Version() int64 {
+	return o.Rev
+}
+
+func TestVersionedCache(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	key, err := s.Put(ctx, &versionedObject{ID: "1", Rev: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, _, ok := splitVersion(cacher[key.Encode()]); !ok || version != 2 {
+		t.Fatalf("Expected version [2] to be cached but got [%d]", version)
+	}
+
+	// An older value read before the update doesn't replace it
+	if err := s.cacheFill(ctx, key, &versionedObject{ID: "1", Rev: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.cacheSet(ctx, key, &versionedObject{ID: "1", Rev: 1}); err != nil {
+		t.Fatal(err)
+	}
+	delete(backend, key.Encode())
+	got := &versionedObject{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Rev != 2 {
+		t.Fatalf("Expected version [2] but got [%d]", got.Rev)
+	}
+
+	// Newer values do
+	if err := s.cacheSet(ctx, key, &versionedObject{ID: "1", Rev: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, got); err != nil || got.Rev != 3 {
+		t.Fatalf("Expected version [3] but got [%d] %v", got.Rev, err)
+	}
+}
@@ -0,0 +1,297 @@
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// MultiPutter is implemented by Backends that can write several entities
+// in a single call. PutMulti makes a Put for each entity with other
+// backends.
+type MultiPutter interface {
+	// PutMulti saves src, a slice of entities, with keys, returning an
+	// appengine.MultiError when only some of them fail
+	PutMulti(ctx context.Context, keys []*datastore.Key, src interface{}) ([]*datastore.Key, error)
+}
+
+// MultiGetter is implemented by Backends that can read several entities in
+// a single call. Reads of query results in batches make a Get for each
+// entity with other backends.
+type MultiGetter interface {
+	// GetMulti loads the entities for keys into dst, a slice of entities,
+	// returning an appengine.MultiError when only some of them fail
+	GetMulti(ctx context.Context, keys []*datastore.Key, dst interface{}) error
+}
+
+// PutMulti saves entities like Put in a single datastore call, and
+// returns their keys in order. Hooks run and entities are cached as for
+// Put. When auditing is enabled each entity is written with its own audit
+// entry instead.
+func (s *Client) PutMulti(ctx context.Context, entities []Entity, opts ...CallOption) ([]*datastore.Key, error) {
+	op := &Operation{Name: OpPutMulti, Entities: entities, opts: newCallOptions(opts)}
+	err := s.run(ctx, op)
+	return op.Keys, err
+}
+
+func (s *Client) execPutMulti(ctx context.Context, op *Operation) (err error) {
+	if op.Keys == nil {
+		op.Keys = make([]*datastore.Key, len(op.Entities))
+	}
+	types := make([]EventType, len(op.Entities))
+	befores := make([]map[string]interface{}, len(op.Entities))
+	own := make([]bool, len(op.Entities))
+	for i, e := range op.Entities {
+		if op.Keys[i] == nil {
+			op.Keys[i], own[i] = e.Key(ctx), true
+		}
+		if op.Keys[i] == nil {
+			return ErrNilKey
+		}
+		types[i] = EventUpdate
+		if len(s.subscribed(op.Keys[i].Kind())) > 0 {
+			if types[i], err = s.putEventType(ctx, op.Keys[i]); err != nil {
+				return err
+			}
+		}
+		if befores[i], err = s.before(ctx, op.Keys[i]); err != nil {
+			return err
+		}
+	}
+	keys, err := s.putMulti(ctx, op.Keys, own, op.Entities, op.opts)
+	recordWrites(ctx, keys...)
+	for i, key := range keys {
+		if key != nil {
+			s.publish(ctx, types[i], key, op.Entities[i])
+			s.publishChange(ctx, OpPut, key, befores[i], op.Entities[i])
+		}
+	}
+	op.Keys = keys
+	return err
+}
+
+// putMulti writes entities with keys. The keys the entities' own Key
+// methods returned, as reported by own, are read again after their
+// BeforePut hooks, while those set by the caller are kept. Once the
+// entities are written the remaining hooks and cache writes are made for
+// every entity, returning the first error.
+func (s *Client) putMulti(ctx context.Context, keys []*datastore.Key, own []bool, entities []Entity, opts callOptions) ([]*datastore.Key, error) {
+	if !opts.skipHooks {
+		for i, e := range entities {
+			if _, ok := e.(BeforePutter); !ok {
+				continue
+			}
+			if err := beforePut(ctx, e); err != nil {
+				return nil, err
+			}
+			if !own[i] {
+				continue
+			}
+			// The hook may set the fields the key is built from
+			if keys[i] = e.Key(ctx); keys[i] == nil {
+				return nil, ErrNilKey
+			}
+		}
+	}
+	for i, e := range entities {
+		if err := s.uploadBlobs(ctx, keys[i], e); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := s.writeEntities(ctx, keys, entities)
+	if err != nil {
+		return keys, err
+	}
+	var (
+		first  error
+		cached []<-chan error
+	)
+	for i, e := range entities {
+		if s.useCache && s.concurrentCacheSet {
+			cached = append(cached, s.startCacheSet(ctx, keys[i], e))
+		}
+		if !opts.skipHooks {
+			if err := afterPut(ctx, keys[i], e); err != nil && first == nil {
+				first = err
+			}
+			if err := enqueueAfterPut(ctx, keys[i], e); err != nil && first == nil {
+				first = err
+			}
+			if err := enqueueHookTasks(ctx, s.afterPutFuncs, keys[i], e); err != nil && first == nil {
+				first = err
+			}
+		}
+		if s.useCache && !s.concurrentCacheSet {
+			if err := s.cacheSet(ctx, keys[i], e); err != nil && err != errCacheOpen && first == nil {
+				first = err
+			}
+		}
+	}
+	if s.waitCacheSet {
+		for _, c := range cached {
+			if err := <-c; err != nil && err != errCacheOpen && first == nil {
+				first = err
+			}
+		}
+	}
+	return keys, first
+}
+
+// writeEntities puts entities, each with its own audit entry when auditing
+// is enabled. Entities with SplitBytes fields are put one at a time.
+func (s *Client) writeEntities(ctx context.Context, keys []*datastore.Key, entities []Entity) ([]*datastore.Key, error) {
+	split := false
+	for _, e := range entities {
+		split = split || hasSplitFields(e)
+	}
+	if s.auditUser == nil && !split {
+		return s.datastorePutMulti(ctx, keys, entities)
+	}
+	written := make([]*datastore.Key, len(keys))
+	for i, e := range entities {
+		k, err := s.writeEntity(ctx, keys[i], e)
+		if err != nil {
+			return nil, err
+		}
+		written[i] = k
+	}
+	return written, nil
+}
+
+func (s *Client) datastorePutMulti(ctx context.Context, keys []*datastore.Key, src []Entity) (ks []*datastore.Key, err error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	mp, ok := s.ds().(MultiPutter)
+	if !ok {
+		return s.putEach(ctx, keys, src)
+	}
+	wrapped := make([]Entity, len(src))
+	for i, e := range src {
+		w, restore, err := encodeEntity(e)
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+		wrapped[i] = w.(Entity)
+	}
+	err = s.retry(ctx, func() error {
+		ctx, done := s.startBatchRPC(ctx, RPCDatastorePut, keys[0].Kind(), len(keys))
+		ks, err = mp.PutMulti(ctx, keys, wrapped)
+		done(err)
+		return err
+	})
+	return ks, err
+}
+
+// putEach puts src one entity at a time, failing like a single PutMulti
+func (s *Client) putEach(ctx context.Context, keys []*datastore.Key, src []Entity) ([]*datastore.Key, error) {
+	written := make([]*datastore.Key, len(keys))
+	var merr appengine.MultiError
+	for i, e := range src {
+		k, err := s.datastorePut(ctx, keys[i], e)
+		if err != nil {
+			if merr == nil {
+				merr = make(appengine.MultiError, len(keys))
+			}
+			merr[i] = err
+			continue
+		}
+		written[i] = k
+	}
+	if merr != nil {
+		return nil, merr
+	}
+	return written, nil
+}
+
+// getBatch loads the entities for keys into dst like getByKey, reading
+// those not cached with a single datastore call, and returns the error
+// for each entity
+func (s *Client) getBatch(ctx context.Context, keys []*datastore.Key, dst []Entity, useCache bool, opts callOptions) []error {
+	errs := make([]error, len(keys))
+	var (
+		missed           []int
+		refresh, corrupt = make([]bool, len(keys)), make([]bool, len(keys))
+	)
+	useCache = useCache && !transactional(ctx)
+	for i, key := range keys {
+		if !useCache {
+			recordCache(ctx, cacheBypass)
+			missed = append(missed, i)
+			continue
+		}
+		decision, bad := s.lookupCache(ctx, key, dst[i])
+		if decision == cacheHit {
+			continue
+		}
+		refresh[i], corrupt[i] = decision == cacheMiss, bad
+		missed = append(missed, i)
+	}
+
+	if len(missed) > 0 {
+		mkeys := make([]*datastore.Key, len(missed))
+		mdst := make([]Entity, len(missed))
+		for j, i := range missed {
+			mkeys[j], mdst[j] = keys[i], dst[i]
+		}
+		merrs := s.datastoreGetMulti(ctx, mkeys, mdst)
+		for j, i := range missed {
+			switch errs[i] = merrs[j]; {
+			case errs[i] == nil && corrupt[i]:
+				s.refreshCache(ctx, keys[i], dst[i])
+			case errs[i] == nil && refresh[i]:
+				s.fillCache(ctx, keys[i], dst[i])
+			case corrupt[i] && IsNotFound(errs[i]):
+				s.evictCorrupt(ctx, keys[i])
+			}
+		}
+	}
+
+	for i, key := range keys {
+		if errs[i] != nil {
+			continue
+		}
+		if errs[i] = s.signBlobs(ctx, dst[i]); errs[i] == nil && !opts.skipHooks {
+			errs[i] = afterGet(ctx, key, dst[i])
+		}
+	}
+	return errs
+}
+
+// datastoreGetMulti reads keys into dst, returning the error for each
+func (s *Client) datastoreGetMulti(ctx context.Context, keys []*datastore.Key, dst []Entity) []error {
+	errs := make([]error, len(keys))
+	mg, ok := s.ds().(MultiGetter)
+	if !ok || len(keys) == 1 {
+		for i, key := range keys {
+			errs[i] = s.datastoreGet(ctx, key, dst[i])
+		}
+		return errs
+	}
+	wrapped := make([]Entity, len(dst))
+	for i, e := range dst {
+		wrapped[i] = withNormalized(e).(Entity)
+	}
+	err := s.retry(ctx, func() error {
+		ctx, done := s.startBatchRPC(ctx, RPCDatastoreGet, keys[0].Kind(), len(keys))
+		err := mg.GetMulti(ctx, keys, wrapped)
+		done(err)
+		return err
+	})
+	merr, ok := err.(appengine.MultiError)
+	for i := range errs {
+		if ok {
+			errs[i] = merr[i]
+		} else {
+			errs[i] = err
+		}
+		if errs[i] == nil || IsFieldMismatch(errs[i]) {
+			if derr := s.decodeEntity(ctx, keys[i], dst[i]); derr != nil {
+				errs[i] = derr
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,98 @@
+package gaestore
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type putHooked struct {
+	ID    string   `datastore:"-"`
+	Hooks []string `datastore:"-" json:"-"`
+}
+
+func (o *putHooked) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "putHooked", o.ID, 0, nil)
+}
+
+func (o *putHooked) BeforePut(ctx context.Context) error {
+	o.Hooks = append(o.Hooks, "BeforePut")
+	return nil
+}
+
+func (o *putHooked) AfterPut(ctx context.Context, key *datastore.Key) error {
+	o.Hooks = append(o.Hooks, "AfterPut")
+	return nil
+}
+
+func TestPutMulti(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	entities := []Entity{&object{ID: "1", Name: "John"}, &putHooked{ID: "2"}}
+	keys, err := s.PutMulti(ctx, entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].StringID() != "1" || keys[1].StringID() != "2" {
+		t.Fatalf("Expected keys [1] and [2] but got [%v]", keys)
+	}
+	for _, key := range keys {
+		if _, ok := backend[key.Encode()]; !ok {
+			t.Fatalf("Expected [%v] to be written to the backend", key)
+		}
+		if _, ok := cacher[key.Encode()]; !ok {
+			t.Fatalf("Expected [%v] to be cached", key)
+		}
+	}
+	if hooks := entities[1].(*putHooked).Hooks; !reflect.DeepEqual(hooks, []string{"BeforePut", "AfterPut"}) {
+		t.Fatalf("Expected the put hooks to run but got [%v]", hooks)
+	}
+
+	if _, err := s.PutMulti(ctx, []Entity{&object{ID: "3"}, &nilKeyObject{}}); err == nil {
+		t.Fatal("Expected an entity with a nil key to fail the put")
+	}
+	if len(backend) != 2 {
+		t.Fatalf("Expected nothing to be written but got [%d] entities", len(backend))
+	}
+}
+
+// slugged sets its ID from its name before it's put
+type slugged struct {
+	ID   string `datastore:"-"`
+	Name string
+}
+
+func (o *slugged) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "slugged", o.ID, 0, nil)
+}
+
+func (o *slugged) BeforePut(ctx context.Context) error {
+	if o.ID == "" {
+		o.ID = strings.ToLower(o.Name)
+	}
+	return nil
+}
+
+func TestPutMultiKeyFromHook(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(backend), WithLogger(nopLogger{}))
+
+	keys, err := s.PutMulti(ctx, []Entity{&slugged{Name: "John"}, &slugged{Name: "Jane"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].StringID() != "john" || keys[1].StringID() != "jane" {
+		t.Fatalf("Expected the keys set by the hooks but got [%v]", keys)
+	}
+	for _, key := range keys {
+		if _, ok := backend[key.Encode()]; !ok {
+			t.Fatalf("Expected [%v] to be written to the backend", key)
+		}
+	}
+}
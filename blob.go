@@ -0,0 +1,170 @@
+package gaestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// BlobRef is a field holding a payload too large for an entity, which is
+// stored as an object in the store's blob bucket with only its name and
+// metadata saved in the datastore. Setting Data uploads it on the next
+// Put, after which Data is cleared. Get sets URL when the bucket can sign
+// URLs, and Delete removes the object along with the entity.
+//
+//	type Document struct {
+//		ID   string `datastore:"-"`
+//		File gaestore.BlobRef
+//	}
+//
+// Objects are uploaded before the entity is written, so a failed write
+// can leave an unreferenced object behind.
+type BlobRef struct {
+	Object      string `datastore:",noindex"`
+	Size        int64  `datastore:",noindex"`
+	ContentType string `datastore:",noindex"`
+
+	Data []byte `datastore:"-" json:"-"`
+	URL  string `datastore:"-" json:"-"`
+}
+
+// BlobBucket stores the objects of BlobRef fields. The gcs package
+// provides one for a Cloud Storage bucket.
+type BlobBucket interface {
+	Bucket
+
+	// Delete deletes the object name
+	Delete(ctx context.Context, name string) error
+}
+
+// URLSigner is implemented by BlobBuckets that can sign URLs granting
+// temporary access to an object
+type URLSigner interface {
+	SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error)
+}
+
+// WithBlobBucket stores the objects of BlobRef fields in b, signing URLs
+// valid for ttl when b is a URLSigner
+func WithBlobBucket(b BlobBucket, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.blobBucket = b
+		c.blobURLTTL = ttl
+	}
+}
+
+var blobRefType = reflect.TypeOf(BlobRef{})
+
+// blobRefs returns the BlobRef fields of e, which are found only at the
+// top level of a struct
+func blobRefs(e interface{}) []*BlobRef {
+	info := structInfo(e)
+	if info == nil || len(info.blobRefs) == 0 {
+		return nil
+	}
+	v := reflect.ValueOf(e).Elem()
+	refs := make([]*BlobRef, 0, len(info.blobRefs))
+	for _, bf := range info.blobRefs {
+		f := v.Field(bf.index)
+		switch {
+		case !bf.ptr:
+			refs = append(refs, f.Addr().Interface().(*BlobRef))
+		case !f.IsNil():
+			refs = append(refs, f.Interface().(*BlobRef))
+		}
+	}
+	return refs
+}
+
+// hasBlobRefs reports whether the type of e has BlobRef fields
+func hasBlobRefs(e interface{}) bool {
+	info := structInfo(e)
+	return info != nil && len(info.blobRefs) > 0
+}
+
+// uploadBlobs uploads the data set on the BlobRefs of e, naming new
+// objects after key's kind
+func (s *Client) uploadBlobs(ctx context.Context, key *datastore.Key, e Entity) error {
+	if s.blobBucket == nil {
+		return nil
+	}
+	for _, ref := range blobRefs(e) {
+		if ref.Data == nil {
+			continue
+		}
+		if ref.Object == "" {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+			ref.Object = key.Kind() + "/" + hex.EncodeToString(b)
+		}
+		w := s.blobBucket.NewWriter(ctx, ref.Object)
+		if _, err := w.Write(ref.Data); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		ref.Size = int64(len(ref.Data))
+		ref.Data = nil
+	}
+	return nil
+}
+
+// signBlobs sets the URLs of the BlobRefs of e
+func (s *Client) signBlobs(ctx context.Context, e Entity) error {
+	signer, ok := s.blobBucket.(URLSigner)
+	if !ok {
+		return nil
+	}
+	for _, ref := range blobRefs(e) {
+		if ref.Object == "" {
+			continue
+		}
+		url, err := signer.SignedURL(ctx, ref.Object, s.blobURLTTL)
+		if err != nil {
+			return err
+		}
+		ref.URL = url
+	}
+	return nil
+}
+
+// storedBlobs returns the objects referenced by the stored entity for key,
+// read into a new value of e's type, ahead of deleting it
+func (s *Client) storedBlobs(ctx context.Context, key *datastore.Key, e Entity) ([]string, error) {
+	if s.blobBucket == nil || !hasBlobRefs(e) {
+		return nil, nil
+	}
+	stored := reflect.New(reflect.TypeOf(e).Elem()).Interface()
+	err := s.datastoreGet(ctx, key, stored)
+	switch {
+	case err == datastore.ErrNoSuchEntity:
+		return nil, nil
+	case err != nil && !IsFieldMismatch(err):
+		return nil, err
+	}
+	var names []string
+	for _, ref := range blobRefs(stored) {
+		if ref.Object != "" {
+			names = append(names, ref.Object)
+		}
+	}
+	return names, nil
+}
+
+// deleteBlobs deletes the objects of a deleted entity. Failures are logged
+// and reported rather than returned, as the entity has been deleted.
+func (s *Client) deleteBlobs(ctx context.Context, key *datastore.Key, names []string) {
+	for _, name := range names {
+		if err := s.blobBucket.Delete(ctx, name); err != nil {
+			s.log().Errorf(ctx, "gaestore: unable to delete object [%s] of [%v]: %v", name, key, err)
+			s.reportError(ctx, "DeleteBlob", key, err)
+		}
+	}
+}
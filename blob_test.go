@@ -0,0 +1,56 @@
+package gaestore_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type document struct {
+	ID   string `datastore:"-"`
+	File gaestore.BlobRef
+}
+
+func (d *document) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "document", d.ID, 0, nil)
+}
+
+func TestBlobRef(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBucket()
+	s := gaestoretest.NewStore(t, gaestore.WithBlobBucket(b, time.Hour))
+
+	d := &document{ID: "a", File: gaestore.BlobRef{ContentType: "text/plain", Data: []byte("hello")}}
+	if _, err := s.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if d.File.Data != nil || d.File.Size != 5 || !strings.HasPrefix(d.File.Object, "document/") {
+		t.Fatalf("Expected an uploaded blob but got [%+v]", d.File)
+	}
+	if data, ok := b.Object(d.File.Object); !ok || string(data) != "hello" {
+		t.Fatalf("Expected object [hello] but got [%s]", data)
+	}
+
+	got := &document{ID: "a"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.File.Object != d.File.Object || got.File.ContentType != "text/plain" {
+		t.Fatalf("Expected [%+v] but got [%+v]", d.File, got.File)
+	}
+	if want := "https://storage.test/" + d.File.Object + "?ttl=1h0m0s"; got.File.URL != want {
+		t.Fatalf("Expected URL [%s] but got [%s]", want, got.File.URL)
+	}
+
+	if err := s.Delete(ctx, &document{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if names := b.Names(); len(names) != 0 {
+		t.Fatalf("Expected no objects but got [%v]", names)
+	}
+}
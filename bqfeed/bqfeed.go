@@ -0,0 +1,174 @@
+// Package bqfeed streams the changes made through a store to a BigQuery
+// table with streaming inserts, as a gaestore.ChangeFeed, and can stream
+// snapshots of whole kinds to the same table.
+//
+//	client, err := bigquery.NewClient(ctx, projectID)
+//	feed := bqfeed.New(client.Dataset("datastore").Table("changes").Inserter())
+//	s := gaestore.NewStore(gaestore.WithChangeFeed(feed))
+//
+// The table must have the columns of Schema. Entities are scrubbed of
+// personal data as in exports; see gaestore.RegisterScrubber.
+package bqfeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+// OpSnapshot is the op of the rows streamed by Snapshot
+const OpSnapshot = "Snapshot"
+
+// Schema is the schema of the table rows are streamed to. Entities are
+// stored as JSON strings.
+var Schema = bigquery.Schema{
+	{Name: "kind", Type: bigquery.StringFieldType, Required: true},
+	{Name: "key", Type: bigquery.StringFieldType, Required: true},
+	{Name: "op", Type: bigquery.StringFieldType, Required: true},
+	{Name: "before", Type: bigquery.StringFieldType},
+	{Name: "after", Type: bigquery.StringFieldType},
+	{Name: "time", Type: bigquery.TimestampFieldType, Required: true},
+}
+
+// Inserter streams rows to a table, as *bigquery.Inserter does
+type Inserter interface {
+	Put(ctx context.Context, src interface{}) error
+}
+
+// Feed is a gaestore.ChangeFeed streaming to a BigQuery table
+type Feed struct {
+	// HashKey keys the hashes of fields tagged `pii:"hash"`
+	HashKey []byte
+
+	ins Inserter
+}
+
+var _ gaestore.ChangeFeed = (*Feed)(nil)
+
+// New returns a feed streaming rows with ins
+func New(ins Inserter) *Feed {
+	return &Feed{ins: ins}
+}
+
+// PublishChange streams c as a row
+func (f *Feed) PublishChange(ctx context.Context, c *gaestore.Change) error {
+	r, err := f.row(ctx, c.Key, c.Op, c.Before, c.After, c.Time)
+	if err != nil {
+		return err
+	}
+	return f.ins.Put(ctx, r)
+}
+
+// Snapshot streams every entity of kind in the namespace of ctx as a row
+// with the op OpSnapshot, read through the store's backend, and returns
+// the number streamed
+func (f *Feed) Snapshot(ctx context.Context, s *gaestore.Client, kind string) (int, error) {
+	const batchSize = 500
+	now := time.Now()
+	it := s.Backend().RunQuery(ctx, datastore.NewQuery(kind))
+	var rows []*row
+	n := 0
+	for {
+		var props datastore.PropertyList
+		key, err := it.Next(&props)
+		if err == datastore.Done {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		r, err := f.row(ctx, key, OpSnapshot, nil, entity(kind, props), now)
+		if err != nil {
+			return n, err
+		}
+		if rows = append(rows, r); len(rows) == batchSize {
+			if err := f.ins.Put(ctx, rows); err != nil {
+				return n, err
+			}
+			n += len(rows)
+			rows = nil
+		}
+	}
+	if len(rows) > 0 {
+		if err := f.ins.Put(ctx, rows); err != nil {
+			return n, err
+		}
+		n += len(rows)
+	}
+	return n, nil
+}
+
+// entity returns props as the registered type of kind, or a map of them
+// when it isn't registered or they don't fit
+func entity(kind string, props datastore.PropertyList) interface{} {
+	if e, err := gaestore.NewEntity(kind); err == nil {
+		if err := datastore.LoadStruct(e, props); err == nil || gaestore.IsFieldMismatch(err) {
+			return e
+		}
+	}
+	m := map[string]interface{}{}
+	for _, p := range props {
+		if !p.Multiple {
+			m[p.Name] = p.Value
+			continue
+		}
+		values, _ := m[p.Name].([]interface{})
+		m[p.Name] = append(values, p.Value)
+	}
+	return m
+}
+
+func (f *Feed) row(ctx context.Context, key *datastore.Key, op string, before map[string]interface{}, after interface{}, t time.Time) (*row, error) {
+	r := &row{kind: key.Kind(), key: key.Encode(), op: op, time: t}
+	var err error
+	if before != nil {
+		if r.before, err = f.encode(ctx, key, before); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if r.after, err = f.encode(ctx, key, after); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// encode returns a scrubbed copy of e as JSON
+func (f *Feed) encode(ctx context.Context, key *datastore.Key, e interface{}) (string, error) {
+	e, err := gaestore.Scrub(ctx, key, e, f.HashKey)
+	if err != nil {
+		return "", fmt.Errorf("bqfeed: unable to scrub [%v]: %v", key, err)
+	}
+	b, err := json.Marshal(e)
+	return string(b), err
+}
+
+// row is a row of the table, saved with an insert ID so rows retried by
+// the client aren't duplicated
+type row struct {
+	kind, key, op string
+	before, after string
+	time          time.Time
+}
+
+func (r *row) Save() (map[string]bigquery.Value, string, error) {
+	values := map[string]bigquery.Value{
+		"kind": r.kind,
+		"key":  r.key,
+		"op":   r.op,
+		"time": r.time,
+	}
+	if r.before != "" {
+		values["before"] = r.before
+	}
+	if r.after != "" {
+		values["after"] = r.after
+	}
+	return values, fmt.Sprintf("%s:%s:%d", r.op, r.key, r.time.UnixNano()), nil
+}
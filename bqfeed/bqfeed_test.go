@@ -0,0 +1,83 @@
+package bqfeed
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type user struct {
+	ID    string `datastore:"-" json:"-"`
+	Name  string
+	Email string `pii:"redact"`
+}
+
+func (u *user) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "user", u.ID, 0, nil)
+}
+
+func init() {
+	gaestore.Register("user", &user{})
+}
+
+// table holds the rows streamed to it
+type table []*row
+
+func (t *table) Put(ctx context.Context, src interface{}) error {
+	switch src := src.(type) {
+	case *row:
+		*t = append(*t, src)
+	case []*row:
+		*t = append(*t, src...)
+	}
+	return nil
+}
+
+func TestFeed(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	var rows table
+	f := New(&rows)
+	s := gaestoretest.NewStore(t, gaestore.WithChangeFeed(f))
+
+	if _, err := s.Put(ctx, &user{ID: "1", Name: "John", Email: "john@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, &user{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].op != gaestore.OpPut || rows[1].op != gaestore.OpDelete {
+		t.Fatalf("Expected a put and a delete but got [%+v]", rows)
+	}
+	var after user
+	if err := json.Unmarshal([]byte(rows[0].after), &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != "John" || after.Email != "" {
+		t.Fatalf("Expected a scrubbed entity but got [%+v]", after)
+	}
+	if rows[1].before == "" || rows[1].after != "" {
+		t.Fatalf("Expected a delete to have only a before but got [%+v]", rows[1])
+	}
+
+	for _, id := range []string{"2", "3"} {
+		if _, err := s.Put(ctx, &user{ID: id, Name: "Jane"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows = nil
+	n, err := f.Snapshot(ctx, s, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(rows) != 2 || rows[0].op != OpSnapshot {
+		t.Fatalf("Expected [2] snapshot rows but got [%d, %+v]", n, rows)
+	}
+	values, id, err := rows[0].Save()
+	if err != nil || id == "" || values["kind"] != "user" {
+		t.Fatalf("Unexpected row [%v, %s, %v]", values, id, err)
+	}
+}
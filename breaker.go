@@ -0,0 +1,98 @@
+package gaestore
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+// errCacheOpen is returned by the store's cache calls while its circuit
+// breaker is open and memcache is being bypassed
+var errCacheOpen = errors.New("gaestore: memcache circuit breaker is open")
+
+// breaker stops a store calling memcache after a run of consecutive
+// failures. Once the cooldown has passed a single call is let through to
+// probe memcache, closing the breaker if it succeeds.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+// SetCacheBreaker makes the store bypass memcache for cooldown, timed by
+// the store's clock, after failures consecutive memcache errors. A zero
+// failures disables the breaker.
+func (s *Client) SetCacheBreaker(failures int, cooldown time.Duration) {
+	if failures <= 0 {
+		s.breaker = nil
+		return
+	}
+	s.breaker = &breaker{threshold: failures, cooldown: cooldown, now: s.now}
+}
+
+// allow reports whether a call to memcache should be made
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// done records the result of a call allowed by the breaker
+func (b *breaker) done(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !isCacheFailure(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// cancel releases a call allowed by the breaker that didn't reach
+// memcache, leaving the breaker as it was
+func (b *breaker) cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// isCacheFailure reports whether err means memcache itself failed, as
+// opposed to a miss or a value that could not be decoded
+func isCacheFailure(err error) bool {
+	switch err {
+	case nil, memcache.ErrCacheMiss, memcache.ErrNotStored, memcache.ErrCASConflict:
+		return false
+	}
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package gaestore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+func TestBreaker(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	now := time.Unix(0, 0)
+	b := &breaker{threshold: 2, cooldown: 10 * time.Millisecond, now: func() time.Time { return now }}
+
+	b.done(errUnavailable)
+	b.done(memcache.ErrCacheMiss)
+	b.done(errUnavailable)
+	if !b.allow() {
+		t.Fatal("Expected a miss to reset the consecutive failures")
+	}
+	b.done(errUnavailable)
+	if b.allow() {
+		t.Fatal("Expected breaker to open after consecutive failures")
+	}
+
+	now = now.Add(9 * time.Millisecond)
+	if b.allow() {
+		t.Fatal("Expected breaker to stay open during the cooldown")
+	}
+	now = now.Add(time.Millisecond)
+	if !b.allow() {
+		t.Fatal("Expected a probe once the cooldown passed")
+	}
+	if b.allow() {
+		t.Fatal("Expected only a single probe at a time")
+	}
+	b.done(errUnavailable)
+	if b.allow() {
+		t.Fatal("Expected breaker to reopen after a failed probe")
+	}
+
+	now = now.Add(10 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("Expected a probe once the cooldown passed")
+	}
+	b.done(nil)
+	if !b.allow() || !b.allow() {
+		t.Fatal("Expected breaker to close after a successful probe")
+	}
+}
+
+func TestBreakerCancel(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := &breaker{threshold: 1, cooldown: 10 * time.Millisecond, now: func() time.Time { return now }}
+	b.done(errors.New("unavailable"))
+
+	now = now.Add(10 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("Expected a probe once the cooldown passed")
+	}
+	// A probe that never reached memcache neither closes the breaker nor
+	// holds on to the probe
+	b.cancel()
+	if !b.allow() {
+		t.Fatal("Expected the probe to be released")
+	}
+	if b.allow() {
+		t.Fatal("Expected breaker to stay open after a cancelled probe")
+	}
+}
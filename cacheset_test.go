@@ -0,0 +1,60 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// blockingCacher holds cache writes until released
+type blockingCacher struct {
+	mapCacher
+	release chan struct{}
+	set     chan string
+	err     error
+}
+
+func (c *blockingCacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	<-c.release
+	defer func() { c.set <- key }()
+	if c.err != nil {
+		return c.err
+	}
+	return c.mapCacher.Set(ctx, key, value, ttl)
+}
+
+func TestConcurrentCacheSet(t *testing.T) {
+	ctx := keyContext(t)
+	cacher := &blockingCacher{mapCacher: mapCacher{}, release: make(chan struct{}), set: make(chan string, 1)}
+	reported := make(chan error, 1)
+	s := NewStore(WithBackend(mapBackend{}), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}),
+		WithConcurrentCacheSet(false),
+		WithErrorHandler(func(ctx context.Context, op string, key *datastore.Key, err error) { reported <- err }))
+
+	// Put returns while the cache write is blocked
+	key, err := s.Put(ctx, &object{ID: "1", Name: "John"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(cacher.release)
+	if got := <-cacher.set; got != key.Encode() {
+		t.Fatalf("Expected [%v] to be cached but got [%s]", key, got)
+	}
+
+	errFull := errors.New("full")
+	cacher.err = errFull
+	if _, err := s.Put(ctx, &object{ID: "2"}); err != nil {
+		t.Fatalf("Expected the cache error to be reported but got [%v]", err)
+	}
+	if err := <-reported; !errors.Is(err, errFull) {
+		t.Fatalf("Expected [%v] to be reported but got [%v]", errFull, err)
+	}
+	<-cacher.set
+	if _, err := s.With(WithConcurrentCacheSet(true)).Put(ctx, &object{ID: "3"}); !errors.Is(err, errFull) {
+		t.Fatalf("Expected [%v] but got [%v]", errFull, err)
+	}
+	<-cacher.set
+}
@@ -0,0 +1,98 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// ChangeLogKind is the kind change log entries are stored under
+const ChangeLogKind = "GaestoreChangeLog"
+
+// ChangeLog is an entry of the change log kept by a store created with
+// WithChangeLog, recording a single write or delete
+type ChangeLog struct {
+	// Seq orders the entries, starting from 1
+	Seq int64
+
+	Kind string
+	Key  *datastore.Key `datastore:",noindex"`
+
+	// Op is OpPut for writes, including touches and increments, or
+	// OpDelete
+	Op   string    `datastore:",noindex"`
+	Time time.Time `datastore:",noindex"`
+}
+
+// WithChangeLog writes a ChangeLog entry after every successful write or
+// delete made through the store, for ChangesSince to follow them. Entries
+// are numbered by a strictly increasing Sequence, which bounds the rate of
+// writes to that of its transactions. As the Sequence isn't advanced within
+// other transactions, the entries of changes made in one are written once
+// it commits. Failing to write an entry is logged and reported rather than
+// failing the write.
+func WithChangeLog() Option {
+	return func(c *Client) {
+		c.changeLog = c.NewSequence(ChangeLogKind, 1)
+	}
+}
+
+// logChange writes the change log entry of a change made to key
+func (s *Client) logChange(ctx context.Context, t EventType, key *datastore.Key) {
+	if s.changeLog == nil {
+		return
+	}
+	op := OpPut
+	if t == EventDelete {
+		op = OpDelete
+	}
+	now := s.now()
+	afterCommit(ctx, func(ctx context.Context) {
+		if err := s.writeChangeLog(ctx, op, key, now); err != nil {
+			s.log().Errorf(ctx, "gaestore: unable to log change to [%v]: %v", key, err)
+			s.reportError(ctx, "ChangeLog", key, err)
+		}
+	})
+}
+
+// writeChangeLog writes the change log entry of a change made to key at t
+func (s *Client) writeChangeLog(ctx context.Context, op string, key *datastore.Key, t time.Time) error {
+	seq, err := s.changeLog.Next(ctx)
+	if err != nil {
+		return err
+	}
+	entry := &ChangeLog{Seq: seq, Kind: key.Kind(), Key: key, Op: op, Time: t}
+	_, err = s.datastorePut(ctx, datastore.NewKey(ctx, ChangeLogKind, "", seq, nil), entry)
+	return err
+}
+
+// ChangesSince returns up to limit change log entries after since, in
+// order, or all of them when limit isn't positive. Start from 0 and pass
+// the Seq of the last entry returned to follow the log. As the entries are
+// queried, one may be returned a moment after those following it, so
+// readers needing every entry should check for gaps in Seq, which are
+// otherwise left by entries that failed to be written.
+func (s *Client) ChangesSince(ctx context.Context, since int64, limit int) ([]*ChangeLog, error) {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := datastore.NewQuery(ChangeLogKind).Filter("Seq >", since).Order("Seq")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var entries []*ChangeLog
+	it := s.ds().RunQuery(ctx, q)
+	for {
+		var e ChangeLog
+		_, err := it.Next(&e)
+		if err == datastore.Done {
+			return entries, nil
+		}
+		if err != nil && !IsFieldMismatch(err) {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+}
@@ -0,0 +1,87 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestChangeLog(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithChangeLog())
+
+	a, b := &exported{ID: "a"}, &exported{ID: "b"}
+	if _, err := s.Put(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutMulti(ctx, []gaestore.Entity{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.ChangesSince(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		id, op string
+	}{
+		{"a", gaestore.OpPut},
+		{"a", gaestore.OpPut},
+		{"b", gaestore.OpPut},
+		{"a", gaestore.OpDelete},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected [%d] entries but got [%d]", len(expected), len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != int64(i+1) || e.Kind != "exported" || e.Key.StringID() != expected[i].id || e.Op != expected[i].op {
+			t.Fatalf("Expected entry [%d] to be a [%s] of [%s] but got [%+v]", i+1, expected[i].op, expected[i].id, e)
+		}
+	}
+
+	// Follow the log from the last entry read
+	entries, err = s.ChangesSince(ctx, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Seq != 3 {
+		t.Fatalf("Expected entry [3] but got [%+v]", entries)
+	}
+}
+
+func TestChangeLogInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithChangeLog())
+
+	// Changes of a transaction are logged once it commits
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := s.Put(tc, &exported{ID: "a"}); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		_, err := s.Put(tc, &exported{ID: "b"})
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.ChangesSince(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Seq != 1 || entries[0].Key.StringID() != "b" {
+		t.Fatalf("Expected an entry for [b] only but got [%+v]", entries)
+	}
+}
@@ -0,0 +1,73 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// Change describes an entity written or deleted through a store, for a
+// ChangeFeed
+type Change struct {
+	Kind string         `json:"kind"`
+	Key  *datastore.Key `json:"key"`
+
+	// Op is OpPut or OpDelete
+	Op string `json:"op"`
+
+	// Before holds the properties stored before the change, nil when the
+	// entity is created
+	Before map[string]interface{} `json:"before"`
+
+	// After is the entity written, nil for a delete
+	After Entity `json:"after"`
+
+	Time time.Time `json:"time"`
+}
+
+// ChangeFeed receives the changes made through a store once they succeed,
+// so downstream services can follow them without polling the datastore.
+// The pubsubfeed package provides one publishing to a Pub/Sub topic.
+type ChangeFeed interface {
+	PublishChange(ctx context.Context, c *Change) error
+}
+
+// WithChangeFeed publishes every successful Put and Delete to f. Reading
+// the state before each change costs an extra datastore read per entity,
+// and publishing happens during the write.
+func WithChangeFeed(f ChangeFeed) Option {
+	return func(c *Client) {
+		c.changeFeed = f
+	}
+}
+
+// before returns the properties stored for key ahead of a change, when
+// there is a change feed
+func (s *Client) before(ctx context.Context, key *datastore.Key) (map[string]interface{}, error) {
+	if s.changeFeed == nil || key.Incomplete() {
+		return nil, nil
+	}
+	props := propertyMap{}
+	err := s.datastoreGet(ctx, key, props)
+	switch {
+	case err == datastore.ErrNoSuchEntity:
+		return nil, nil
+	case err != nil && !IsFieldMismatch(err):
+		return nil, err
+	}
+	return props, nil
+}
+
+// publishChange publishes a change made to key. Failures are logged and
+// reported rather than returned, as the change has been made.
+func (s *Client) publishChange(ctx context.Context, op string, key *datastore.Key, before map[string]interface{}, after Entity) {
+	if s.changeFeed == nil {
+		return
+	}
+	c := &Change{Kind: key.Kind(), Key: key, Op: op, Before: before, After: after, Time: s.now()}
+	if err := s.changeFeed.PublishChange(ctx, c); err != nil {
+		s.log().Errorf(ctx, "gaestore: unable to publish change to [%v]: %v", key, err)
+		s.reportError(ctx, "PublishChange", key, err)
+	}
+}
@@ -0,0 +1,44 @@
+package gaestore
+
+import (
+	"context"
+	"testing"
+)
+
+// changeLog records the changes published to it
+type changeLog []*Change
+
+func (l *changeLog) PublishChange(ctx context.Context, c *Change) error {
+	*l = append(*l, c)
+	return nil
+}
+
+func TestChangeFeed(t *testing.T) {
+	ctx := keyContext(t)
+	var changes changeLog
+	s := NewStore(WithBackend(mapBackend{}), WithChangeFeed(&changes), WithLogger(nopLogger{}))
+
+	if _, err := s.Put(ctx, &object{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutMulti(ctx, []Entity{&object{ID: "1", Name: "Jane"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, &object{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("Expected [3] changes but got [%d]", len(changes))
+	}
+	created, updated, deleted := changes[0], changes[1], changes[2]
+	if created.Op != OpPut || created.Before != nil || created.After.(*object).Name != "John" {
+		t.Fatalf("Unexpected change for a create [%+v]", created)
+	}
+	if updated.Before["Name"] != "John" || updated.After.(*object).Name != "Jane" {
+		t.Fatalf("Unexpected change for an update [%+v]", updated)
+	}
+	if deleted.Op != OpDelete || deleted.Before["Name"] != "Jane" || deleted.After != nil || deleted.Kind != "object" {
+		t.Fatalf("Unexpected change for a delete [%+v]", deleted)
+	}
+}
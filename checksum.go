@@ -0,0 +1,43 @@
+package gaestore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// checksumHeader starts cached values holding a checksum, which follows it
+// as 4 big endian bytes before the encoded entity
+var checksumHeader = []byte("\x00gaestore:c:")
+
+const checksumLen = 4
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errChecksum is returned for a cached value not matching its checksum
+var errChecksum = errors.New("gaestore: cached value does not match its checksum")
+
+// withChecksum returns value prefixed with its checksum
+func withChecksum(value []byte) []byte {
+	v := make([]byte, len(checksumHeader)+checksumLen, len(checksumHeader)+checksumLen+len(value))
+	copy(v, checksumHeader)
+	binary.BigEndian.PutUint32(v[len(checksumHeader):], crc32.Checksum(value, crcTable))
+	return append(v, value...)
+}
+
+// verifyChecksum returns the encoded entity of a cached value, checking it
+// against its checksum when it has one
+func verifyChecksum(value []byte) ([]byte, error) {
+	n := len(checksumHeader) + checksumLen
+	if !bytes.HasPrefix(value, checksumHeader) {
+		return value, nil
+	}
+	if len(value) < n {
+		return nil, errChecksum
+	}
+	if binary.BigEndian.Uint32(value[len(checksumHeader):n]) != crc32.Checksum(value[n:], crcTable) {
+		return nil, errChecksum
+	}
+	return value[n:], nil
+}
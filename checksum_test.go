@@ -0,0 +1,35 @@
+package gaestore
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCacheChecksums(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := &countingBackend{mapBackend: mapBackend{}}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}), WithCacheChecksums())
+
+	key, err := s.Put(ctx, &object{ID: "1", Name: "John"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := cacher[key.Encode()]
+	if !bytes.HasPrefix(value, checksumHeader) {
+		t.Fatalf("Expected a checksum to be cached but got [%q]", value)
+	}
+	got := &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil || got.Name != "John" || backend.gets != 0 {
+		t.Fatalf("Expected a cache hit but got [%s] from [%d] datastore reads %v", got.Name, backend.gets, err)
+	}
+
+	// A truncated value that still decodes is refetched and replaced
+	cacher[key.Encode()] = bytes.Replace(value, []byte(`,"Name":"John"`), nil, 1)
+	got = &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil || got.Name != "John" || backend.gets != 1 {
+		t.Fatalf("Expected [John] from 1 datastore read but got [%s] from [%d] %v", got.Name, backend.gets, err)
+	}
+	if !bytes.Equal(cacher[key.Encode()], value) {
+		t.Fatalf("Expected the cached value to be replaced but got [%q]", cacher[key.Encode()])
+	}
+}
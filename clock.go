@@ -0,0 +1,55 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time. Stores use it for the times they write, such as
+// lease and audit timestamps, and to decide what has expired, so tests
+// can control time rather than sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock sets the clock of the store, the system clock by default.
+// Durations measured for logs and metrics always use the system clock.
+func WithClock(c Clock) Option {
+	return func(cl *Client) {
+		cl.clock = c
+	}
+}
+
+type clockKey struct{}
+
+// Now returns the time of the clock of the store making the operation ctx
+// is for, for hooks writing timestamps such as a CreatedAt field, or the
+// system time outside of an operation
+func Now(ctx context.Context) time.Time {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// now returns the time of the store's clock
+func (s *Client) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// withClock makes the store's clock available to Now
+func (s *Client) withClock(ctx context.Context) context.Context {
+	if s.clock == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clockKey{}, s.clock)
+}
@@ -0,0 +1,55 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type stamped struct {
+	ID        string `datastore:"-"`
+	CreatedAt time.Time
+}
+
+func (s *stamped) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "stamped", s.ID, 0, nil)
+}
+
+func (s *stamped) BeforePut(ctx context.Context) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = gaestore.Now(ctx)
+	}
+	return nil
+}
+
+func TestClock(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := gaestoretest.NewClock(start)
+	s := gaestoretest.NewStore(t, gaestore.WithClock(clock))
+
+	e := &stamped{ID: "1"}
+	if _, err := s.Put(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if !e.CreatedAt.Equal(start) {
+		t.Fatalf("Expected [%v] but got [%v]", start, e.CreatedAt)
+	}
+
+	if _, err := s.AcquireLease(ctx, "job", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+	if _, err := s.AcquireLease(ctx, "job", time.Minute); !errors.Is(err, gaestore.ErrLeaseHeld) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrLeaseHeld, err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := s.AcquireLease(ctx, "job", time.Minute); err != nil {
+		t.Fatalf("Expected the lease to have expired but got [%v]", err)
+	}
+}
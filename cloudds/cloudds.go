@@ -0,0 +1,248 @@
+// Package cloudds stores gaestore entities with
+// cloud.google.com/go/datastore, for applications moving off the legacy
+// App Engine APIs. Entities, hooks and caching work unchanged; pair it
+// with rediscache in place of memcache, and a logger that doesn't need an
+// App Engine context.
+//
+//	client, err := datastore.NewClient(ctx, projectID)
+//	s := gaestore.NewStore(
+//		gaestore.WithBackend(cloudds.New(client, appID)),
+//		gaestore.WithCache(time.Hour),
+//		gaestore.WithCacher(&rediscache.Cacher{Pool: pool}),
+//		gaestore.WithLogger(logger),
+//	)
+//
+// Entity Key methods keep building keys with the App Engine
+// datastore.NewKey, which outside App Engine reads the application ID from
+// the GAE_APPLICATION environment variable.
+package cloudds
+
+import (
+	"context"
+
+	"cloud.google.com/go/datastore"
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/api/iterator"
+	"google.golang.org/appengine"
+	aeds "google.golang.org/appengine/datastore"
+)
+
+// Backend is a gaestore.Backend using a cloud datastore client
+type Backend struct {
+	client *datastore.Client
+	appID  string
+}
+
+var _ gaestore.Backend = (*Backend)(nil)
+
+// New returns a backend using client. appID is set on the keys the
+// backend returns, and should match GAE_APPLICATION so they compare equal
+// to keys built by entities.
+func New(client *datastore.Client, appID string) *Backend {
+	return &Backend{client: client, appID: appID}
+}
+
+type txKey struct{}
+
+// transaction returns the transaction started by RunInTransaction, if any
+func transaction(ctx context.Context) *datastore.Transaction {
+	tx, _ := ctx.Value(txKey{}).(*datastore.Transaction)
+	return tx
+}
+
+func (b *Backend) Get(ctx context.Context, key *aeds.Key, dst interface{}) error {
+	var props datastore.PropertyList
+	var err error
+	if tx := transaction(ctx); tx != nil {
+		err = tx.Get(toCloudKey(key), &props)
+	} else {
+		err = b.client.Get(ctx, toCloudKey(key), &props)
+	}
+	if err != nil {
+		return convertError(err)
+	}
+	return b.load(dst, props)
+}
+
+func (b *Backend) Put(ctx context.Context, key *aeds.Key, src interface{}) (*aeds.Key, error) {
+	props, err := save(src)
+	if err != nil {
+		return nil, err
+	}
+	list := datastore.PropertyList(toCloudProperties(props))
+	k := toCloudKey(key)
+	if tx := transaction(ctx); tx != nil {
+		// Keys put in a transaction aren't known until it commits, so
+		// allocate one up front
+		if k.Incomplete() {
+			keys, err := b.client.AllocateIDs(ctx, []*datastore.Key{k})
+			if err != nil {
+				return nil, convertError(err)
+			}
+			k = keys[0]
+		}
+		if _, err := tx.Put(k, &list); err != nil {
+			return nil, convertError(err)
+		}
+		return b.fromCloudKey(k)
+	}
+	if k, err = b.client.Put(ctx, k, &list); err != nil {
+		return nil, convertError(err)
+	}
+	return b.fromCloudKey(k)
+}
+
+func (b *Backend) Delete(ctx context.Context, key *aeds.Key) error {
+	if tx := transaction(ctx); tx != nil {
+		return convertError(tx.Delete(toCloudKey(key)))
+	}
+	return convertError(b.client.Delete(ctx, toCloudKey(key)))
+}
+
+func (b *Backend) RunQuery(ctx context.Context, q *aeds.Query) gaestore.Iterator {
+	pq, err := appds.ParseQuery(q)
+	if err != nil {
+		return errIterator{err}
+	}
+	namespace := appds.Namespace(ctx)
+	if pq.Ancestor != nil {
+		namespace = pq.Ancestor.Namespace()
+	}
+	cq := datastore.NewQuery(pq.Kind).Namespace(namespace)
+	if pq.Ancestor != nil {
+		cq = cq.Ancestor(toCloudKey(pq.Ancestor))
+	}
+	for _, f := range pq.Filters {
+		cq = cq.Filter(f.Field+" "+f.Op, toCloudValue(f.Value))
+	}
+	for _, o := range pq.Orders {
+		if o.Descending {
+			cq = cq.Order("-" + o.Field)
+		} else {
+			cq = cq.Order(o.Field)
+		}
+	}
+	if len(pq.Projection) > 0 {
+		cq = cq.Project(pq.Projection...)
+	}
+	if pq.Distinct {
+		cq = cq.Distinct()
+	}
+	if len(pq.DistinctOn) > 0 {
+		cq = cq.DistinctOn(pq.DistinctOn...)
+	}
+	if pq.KeysOnly {
+		cq = cq.KeysOnly()
+	}
+	if pq.Eventual {
+		cq = cq.EventualConsistency()
+	}
+	if pq.Limit >= 0 {
+		cq = cq.Limit(int(pq.Limit))
+	}
+	if pq.Offset > 0 {
+		cq = cq.Offset(int(pq.Offset))
+	}
+	if start, err := toCloudCursor(pq.Start); err != nil {
+		return errIterator{err}
+	} else if start != nil {
+		cq = cq.Start(*start)
+	}
+	if end, err := toCloudCursor(pq.End); err != nil {
+		return errIterator{err}
+	} else if end != nil {
+		cq = cq.End(*end)
+	}
+	if tx := transaction(ctx); tx != nil {
+		cq = cq.Transaction(tx)
+	}
+	return &queryIterator{b: b, t: b.client.Run(ctx, cq)}
+}
+
+// RunInTransaction runs fn in a cloud datastore transaction. Calls made
+// through the backend with the context passed to fn are part of the
+// transaction.
+func (b *Backend) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *aeds.TransactionOptions) error {
+	var txOpts []datastore.TransactionOption
+	if opts != nil {
+		if opts.Attempts > 0 {
+			txOpts = append(txOpts, datastore.MaxAttempts(opts.Attempts))
+		}
+		if opts.ReadOnly {
+			txOpts = append(txOpts, datastore.ReadOnly)
+		}
+	}
+	_, err := b.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	}, txOpts...)
+	return convertError(err)
+}
+
+type queryIterator struct {
+	b *Backend
+	t *datastore.Iterator
+}
+
+func (it *queryIterator) Next(dst interface{}) (*aeds.Key, error) {
+	var props datastore.PropertyList
+	var k *datastore.Key
+	var err error
+	if dst == nil {
+		k, err = it.t.Next(nil)
+	} else {
+		k, err = it.t.Next(&props)
+	}
+	if err == iterator.Done {
+		return nil, aeds.Done
+	}
+	if err != nil {
+		return nil, convertError(err)
+	}
+	key, err := it.b.fromCloudKey(k)
+	if err != nil || dst == nil {
+		return key, err
+	}
+	return key, it.b.load(dst, props)
+}
+
+func (it *queryIterator) Cursor() (aeds.Cursor, error) {
+	c, err := it.t.Cursor()
+	if err != nil {
+		return aeds.Cursor{}, convertError(err)
+	}
+	return appds.WrapCursor(c.String())
+}
+
+type errIterator struct {
+	err error
+}
+
+func (it errIterator) Next(dst interface{}) (*aeds.Key, error) {
+	return nil, it.err
+}
+
+func (it errIterator) Cursor() (aeds.Cursor, error) {
+	return aeds.Cursor{}, it.err
+}
+
+func convertError(err error) error {
+	switch err {
+	case datastore.ErrNoSuchEntity:
+		return aeds.ErrNoSuchEntity
+	case datastore.ErrInvalidKey:
+		return aeds.ErrInvalidKey
+	case datastore.ErrInvalidEntityType:
+		return aeds.ErrInvalidEntityType
+	case datastore.ErrConcurrentTransaction:
+		return aeds.ErrConcurrentTransaction
+	}
+	if merr, ok := err.(datastore.MultiError); ok {
+		aerr := make(appengine.MultiError, len(merr))
+		for i, err := range merr {
+			aerr[i] = convertError(err)
+		}
+		return aerr
+	}
+	return err
+}
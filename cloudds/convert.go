@@ -0,0 +1,146 @@
+package cloudds
+
+import (
+	"cloud.google.com/go/datastore"
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine"
+	aeds "google.golang.org/appengine/datastore"
+)
+
+// Entities are saved and loaded with the App Engine datastore package, so
+// they keep its struct tags and PropertyLoadSaver semantics, and their
+// properties converted to and from cloud datastore properties
+
+func save(src interface{}) ([]aeds.Property, error) {
+	if pls, ok := src.(aeds.PropertyLoadSaver); ok {
+		return pls.Save()
+	}
+	return aeds.SaveStruct(src)
+}
+
+func (b *Backend) load(dst interface{}, props datastore.PropertyList) error {
+	p, err := b.fromCloudProperties(props)
+	if err != nil {
+		return err
+	}
+	if pls, ok := dst.(aeds.PropertyLoadSaver); ok {
+		return pls.Load(p)
+	}
+	return aeds.LoadStruct(dst, p)
+}
+
+func toCloudKey(k *aeds.Key) *datastore.Key {
+	if k == nil {
+		return nil
+	}
+	return &datastore.Key{
+		Kind:      k.Kind(),
+		ID:        k.IntID(),
+		Name:      k.StringID(),
+		Parent:    toCloudKey(k.Parent()),
+		Namespace: k.Namespace(),
+	}
+}
+
+func (b *Backend) fromCloudKey(k *datastore.Key) (*aeds.Key, error) {
+	if k == nil {
+		return nil, nil
+	}
+	parent, err := b.fromCloudKey(k.Parent)
+	if err != nil {
+		return nil, err
+	}
+	return appds.NewKey(b.appID, k.Namespace, k.Kind, k.Name, k.ID, parent)
+}
+
+func toCloudCursor(c aeds.Cursor) (*datastore.Cursor, error) {
+	s, err := appds.UnwrapCursor(c)
+	if err != nil || s == "" {
+		return nil, err
+	}
+	cc, err := datastore.DecodeCursor(s)
+	if err != nil {
+		return nil, err
+	}
+	return &cc, nil
+}
+
+// toCloudProperties converts App Engine properties, combining those with
+// the same name into a single array property
+func toCloudProperties(props []aeds.Property) []datastore.Property {
+	var out []datastore.Property
+	index := map[string]int{}
+	for _, p := range props {
+		v := toCloudValue(p.Value)
+		if !p.Multiple {
+			out = append(out, datastore.Property{Name: p.Name, Value: v, NoIndex: p.NoIndex})
+			continue
+		}
+		if i, ok := index[p.Name]; ok {
+			out[i].Value = append(out[i].Value.([]interface{}), v)
+			continue
+		}
+		index[p.Name] = len(out)
+		out = append(out, datastore.Property{Name: p.Name, Value: []interface{}{v}, NoIndex: p.NoIndex})
+	}
+	return out
+}
+
+func toCloudValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *aeds.Key:
+		return toCloudKey(v)
+	case aeds.ByteString:
+		return []byte(v)
+	case appengine.BlobKey:
+		return string(v)
+	case appengine.GeoPoint:
+		return datastore.GeoPoint{Lat: v.Lat, Lng: v.Lng}
+	case *aeds.Entity:
+		return &datastore.Entity{
+			Key:        toCloudKey(v.Key),
+			Properties: toCloudProperties(v.Properties),
+		}
+	}
+	return v
+}
+
+// fromCloudProperties converts cloud properties, expanding array
+// properties into multiple App Engine properties
+func (b *Backend) fromCloudProperties(props []datastore.Property) ([]aeds.Property, error) {
+	var out []aeds.Property
+	for _, p := range props {
+		values, multiple := p.Value.([]interface{})
+		if !multiple {
+			values = []interface{}{p.Value}
+		}
+		for _, v := range values {
+			v, err := b.fromCloudValue(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, aeds.Property{Name: p.Name, Value: v, NoIndex: p.NoIndex, Multiple: multiple})
+		}
+	}
+	return out, nil
+}
+
+func (b *Backend) fromCloudValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case *datastore.Key:
+		return b.fromCloudKey(v)
+	case datastore.GeoPoint:
+		return appengine.GeoPoint{Lat: v.Lat, Lng: v.Lng}, nil
+	case *datastore.Entity:
+		key, err := b.fromCloudKey(v.Key)
+		if err != nil {
+			return nil, err
+		}
+		props, err := b.fromCloudProperties(v.Properties)
+		if err != nil {
+			return nil, err
+		}
+		return &aeds.Entity{Key: key, Properties: props}, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,42 @@
+package cloudds
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine"
+	aeds "google.golang.org/appengine/datastore"
+)
+
+func TestConvertProperties(t *testing.T) {
+	b := New(nil, "app")
+	key, err := appds.NewKey("app", "ns", "Kind", "k", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	props := []aeds.Property{
+		{Name: "Name", Value: "John"},
+		{Name: "Age", Value: int64(42), NoIndex: true},
+		{Name: "Tags", Value: "a", Multiple: true},
+		{Name: "Tags", Value: "b", Multiple: true},
+		{Name: "Ref", Value: key},
+		{Name: "At", Value: now},
+		{Name: "Where", Value: appengine.GeoPoint{Lat: 1, Lng: 2}},
+		{Name: "Nested", Value: &aeds.Entity{Properties: []aeds.Property{{Name: "X", Value: 1.5}}}},
+	}
+
+	cloud := toCloudProperties(props)
+	if len(cloud) != len(props)-1 {
+		t.Fatalf("Expected [%d] properties but got [%d]", len(props)-1, len(cloud))
+	}
+	got, err := b.fromCloudProperties(cloud)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, props) {
+		t.Fatalf("Expected properties [%v] but got [%v]", props, got)
+	}
+}
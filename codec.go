@@ -0,0 +1,70 @@
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// The fields the store stores differently than entities hold them,
+// JSONProperty, Location, compressed and normalized fields along with
+// SplitBytes, are encoded and decoded by the functions below, which every
+// read and write of entities goes through
+
+// encodeEntity encodes the fields of src before it's written, returning
+// src wrapped to save the shadow properties of its normalized fields and
+// a func restoring its compressed fields, to be called once it's written
+func encodeEntity(src interface{}) (interface{}, func(), error) {
+	if err := encodeJSONFields(src); err != nil {
+		return nil, nil, err
+	}
+	encodeLocations(src)
+	restore, err := compressFields(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return withNormalized(src), restore, nil
+}
+
+// decodeEntity decodes the fields of dst once it's been read from key
+// through withNormalized(dst)
+func (s *Client) decodeEntity(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	if err := decodeJSONFields(dst); err != nil {
+		return err
+	}
+	if err := decompressFields(dst); err != nil {
+		return err
+	}
+	return s.loadChunks(ctx, key, dst)
+}
+
+// saveEntity returns the properties e is written with
+func saveEntity(e Entity) ([]datastore.Property, error) {
+	src, restore, err := encodeEntity(e)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	if pls, ok := src.(datastore.PropertyLoadSaver); ok {
+		return pls.Save()
+	}
+	return datastore.SaveStruct(src)
+}
+
+// loadEntity loads e, the entity for key, from props as read from the
+// datastore
+func (s *Client) loadEntity(ctx context.Context, key *datastore.Key, e Entity, props []datastore.Property) error {
+	var err error
+	if pls, ok := withNormalized(e).(datastore.PropertyLoadSaver); ok {
+		err = pls.Load(props)
+	} else {
+		err = datastore.LoadStruct(e, props)
+	}
+	if err != nil && !IsFieldMismatch(err) {
+		return err
+	}
+	if derr := s.decodeEntity(ctx, key, e); derr != nil {
+		return derr
+	}
+	return err
+}
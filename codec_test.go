@@ -0,0 +1,159 @@
+package gaestore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+// coded has a field of each kind the store encodes
+type coded struct {
+	ID    string `datastore:"-" json:"-"`
+	Email string `gaestore:"normalize"`
+	Body  string `datastore:",noindex" gaestore:"compress"`
+	Tags  gaestore.JSONProperty[[]string]
+	Views int
+}
+
+func (c *coded) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "coded", c.ID, 0, nil)
+}
+
+func (c *coded) AfterGet(ctx context.Context, key *datastore.Key) error {
+	c.ID = key.StringID()
+	return nil
+}
+
+func init() {
+	gaestore.Register("coded", &coded{})
+}
+
+func newCoded(id string) *coded {
+	return &coded{ID: id, Email: "Ann@Example.com", Body: "body " + id, Tags: gaestore.JSONProperty[[]string]{Value: []string{"a", "b"}}}
+}
+
+// checkDecoded fails unless c holds the fields of newCoded(c.ID)
+func checkDecoded(t *testing.T, c *coded) {
+	t.Helper()
+	want := newCoded(c.ID)
+	if c.Email != want.Email || c.Body != want.Body || !reflect.DeepEqual(c.Tags.Value, want.Tags.Value) {
+		t.Fatalf("Expected decoded fields but got [%+v]", c)
+	}
+}
+
+func putCoded(t *testing.T, ctx context.Context, s *gaestore.Client, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if _, err := s.Put(ctx, newCoded(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCodecIncrement(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+
+	// Creating the entity, then updating it
+	for i := int64(1); i <= 2; i++ {
+		c := newCoded("1")
+		if n, err := s.Increment(ctx, c, "Views", 1); err != nil || n != i {
+			t.Fatalf("Expected [%d] views but got [%d] %v", i, n, err)
+		}
+		checkDecoded(t, c)
+	}
+	for _, store := range []*gaestore.Client{s, s.WithoutCache()} {
+		got := &coded{ID: "1"}
+		if err := store.Get(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		checkDecoded(t, got)
+	}
+	var found []*coded
+	q := gaestore.FilterNormalized(datastore.NewQuery("coded"), "Email =", "ann@example.com")
+	if _, err := s.Query(ctx, q, &found); err != nil || len(found) != 1 {
+		t.Fatalf("Expected the normalized email to be written but got [%d] %v", len(found), err)
+	}
+}
+
+func TestCodecExport(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	putCoded(t, ctx, s, "1")
+
+	b := gaestoretest.NewBucket()
+	if err := s.NewExporter(b, "coded", "backup").Export(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := b.Object("backup-00000.ndjson")
+	var line struct {
+		Entity coded
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&line); err != nil {
+		t.Fatal(err)
+	}
+	line.Entity.ID = "1"
+	checkDecoded(t, &line.Entity)
+}
+
+func TestCodecMigration(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	putCoded(t, ctx, s, "1", "2")
+
+	n := 0
+	m := s.NewMigration("coded", "renamed", func(ctx context.Context, old gaestore.Entity) (gaestore.Entity, error) {
+		checkDecoded(t, old.(*coded))
+		n++
+		return nil, nil
+	})
+	if err := m.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected [2] entities to be migrated but got [%d]", n)
+	}
+}
+
+func TestCodecMap(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	tasks := queueMapShards(t)
+	putCoded(t, ctx, s, "1", "2")
+
+	n := 0
+	s.RegisterMapper("checkDecoded", func(ctx context.Context, e gaestore.Entity) (gaestore.Entity, error) {
+		checkDecoded(t, e.(*coded))
+		n++
+		return nil, nil
+	})
+	if _, err := s.Map(ctx, "coded", "checkDecoded", gaestore.MapOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	runMapShards(t, ctx, tasks)
+	if n != 2 {
+		t.Fatalf("Expected [2] entities to be mapped but got [%d]", n)
+	}
+}
+
+func TestCodecReadYourWrites(t *testing.T) {
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	ctx := gaestoretest.NewContext()
+	b.SetConsistency(0)
+
+	rctx := gaestore.WithReadYourWrites(ctx)
+	putCoded(t, rctx, s, "1")
+	var found []*coded
+	q := gaestore.FilterNormalized(datastore.NewQuery("coded"), "Email =", "ann@example.com")
+	if _, err := s.Query(rctx, q, &found); err != nil || len(found) != 1 {
+		t.Fatalf("Expected the request's write to be matched but got [%d] %v", len(found), err)
+	}
+	checkDecoded(t, found[0])
+}
@@ -0,0 +1,144 @@
+package gaestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// compressTag tags top level string and []byte fields that are gzipped
+// when written to the datastore and decompressed when read, as with
+//
+//	type Page struct {
+//		ID   string `datastore:"-"`
+//		HTML string `datastore:",noindex" gaestore:"compress"`
+//	}
+//
+// String fields should also be tagged noindex, as compressed values can't
+// be usefully queried. Values written before the field was tagged are read
+// as they are. Cached entities with compressed fields are gzipped whole.
+const compressTag = "compress"
+
+// gzipMagic starts every gzipped value
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressFieldsOf returns the indexes of the fields of t tagged to be
+// compressed
+func compressFieldsOf(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("gaestore") != compressTag {
+			continue
+		}
+		if f.Type.Kind() == reflect.String || f.Type == bytesType {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// hasCompressFields reports whether the type of e has compressed fields,
+// unless e is a PropertyLoadSaver encoding its own fields
+func hasCompressFields(e interface{}) bool {
+	if _, ok := e.(datastore.PropertyLoadSaver); ok {
+		return false
+	}
+	info := structInfo(e)
+	return info != nil && len(info.compressFields) > 0
+}
+
+// compressFields replaces the compressed fields of src with their gzipped
+// values before it's written, returning a func that restores them
+func compressFields(src interface{}) (func(), error) {
+	if !hasCompressFields(src) {
+		return func() {}, nil
+	}
+	v := reflect.ValueOf(src).Elem()
+	fields := structInfo(src).compressFields
+	saved := make([]reflect.Value, len(fields))
+	restore := func() {
+		for j, i := range fields {
+			if saved[j].IsValid() {
+				v.Field(i).Set(saved[j])
+			}
+		}
+	}
+	for j, i := range fields {
+		f := v.Field(i)
+		if f.Len() == 0 {
+			continue
+		}
+		b, err := gzipBytes(fieldBytes(f))
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		saved[j] = reflect.ValueOf(f.Interface())
+		setFieldBytes(f, b)
+	}
+	return restore, nil
+}
+
+// decompressFields decompresses the compressed fields of dst once it's
+// read, leaving values that aren't gzipped as they are
+func decompressFields(dst interface{}) error {
+	if !hasCompressFields(dst) {
+		return nil
+	}
+	v := reflect.ValueOf(dst).Elem()
+	for _, i := range structInfo(dst).compressFields {
+		f := v.Field(i)
+		b := fieldBytes(f)
+		if !bytes.HasPrefix(b, gzipMagic) {
+			continue
+		}
+		b, err := gunzipBytes(b)
+		if err != nil {
+			return err
+		}
+		setFieldBytes(f, b)
+	}
+	return nil
+}
+
+func fieldBytes(f reflect.Value) []byte {
+	if f.Kind() == reflect.String {
+		return []byte(f.String())
+	}
+	return f.Bytes()
+}
+
+func setFieldBytes(f reflect.Value, b []byte) {
+	if f.Kind() == reflect.String {
+		f.SetString(string(b))
+	} else {
+		f.SetBytes(b)
+	}
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
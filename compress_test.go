@@ -0,0 +1,79 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type post struct {
+	ID    string `datastore:"-"`
+	Body  string `datastore:",noindex" gaestore:"compress"`
+	Thumb []byte `gaestore:"compress"`
+}
+
+func (p *post) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "post", p.ID, 0, nil)
+}
+
+func TestCompress(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	cacher := mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	body := strings.Repeat("lorem ipsum ", 1000)
+	thumb := bytes.Repeat([]byte{1, 2, 3}, 1000)
+	e := &post{ID: "1", Body: body, Thumb: thumb}
+	key, err := s.Put(ctx, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Body != body || !bytes.Equal(e.Thumb, thumb) {
+		t.Fatal("Expected the entity's fields to be restored once written")
+	}
+	for _, p := range backend[key.Encode()] {
+		var n int
+		switch v := p.Value.(type) {
+		case string:
+			n = len(v)
+		case []byte:
+			n = len(v)
+		}
+		if n >= len(thumb) {
+			t.Fatalf("Expected [%s] to be compressed but got [%d] bytes", p.Name, n)
+		}
+	}
+	if v := cacher[key.Encode()]; !bytes.HasPrefix(v, gzipMagic) {
+		t.Fatalf("Expected the cached entity to be compressed")
+	}
+
+	for _, useCache := range []bool{true, false} {
+		if !useCache {
+			for k := range cacher {
+				delete(cacher, k)
+			}
+		}
+		got := &post{ID: "1"}
+		if err := s.Get(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Body != body || !bytes.Equal(got.Thumb, thumb) {
+			t.Fatalf("Expected the fields to be decompressed with cache %v", useCache)
+		}
+	}
+
+	// Values written before the field was tagged are read as they are
+	backend[key.Encode()] = []datastore.Property{{Name: "Body", Value: "plain", NoIndex: true}}
+	delete(cacher, key.Encode())
+	got := &post{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Body != "plain" {
+		t.Fatalf("Expected [plain] but got [%s]", got.Body)
+	}
+}
@@ -0,0 +1,99 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestStrongConsistency(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+
+	parent := datastore.NewKey(ctx, "parent", "p", 0, nil)
+	if _, err := s.Put(ctx, &exported{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var entities []*exported
+	for _, q := range []*datastore.Query{
+		datastore.NewQuery("exported"),
+		datastore.NewQuery("exported").Ancestor(parent).EventualConsistency(),
+	} {
+		if _, err := s.Query(ctx, q, &entities, gaestore.StrongConsistency); !errors.Is(err, gaestore.ErrInconsistentQuery) {
+			t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrInconsistentQuery, err)
+		}
+	}
+
+	// Ancestor queries are allowed, as are reads by key
+	if _, err := s.Query(ctx, datastore.NewQuery("exported").Ancestor(parent), &entities, gaestore.StrongConsistency); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &exported{ID: "1"}, gaestore.StrongConsistency); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queries without the option are unchanged
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities); err != nil || len(entities) != 1 {
+		t.Fatalf("Expected 1 entity but got [%d] %v", len(entities), err)
+	}
+}
+
+func TestTransactionCache(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	if _, err := s.Put(ctx, &exported{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads in a transaction are made from the datastore
+	if _, err := b.Put(ctx, datastore.NewKey(ctx, "exported", "1", 0, nil), &exported{Name: "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		e := &exported{ID: "1"}
+		if err := s.Get(tc, e); err != nil {
+			return err
+		}
+		if e.Name != "Jane" {
+			t.Fatalf("Expected the stored entity but got [%s]", e.Name)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes of a transaction that fails aren't cached
+	errAbort := errors.New("abort")
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := s.Put(tc, &exported{ID: "1", Name: "Joe"}); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	e := &exported{ID: "1"}
+	if err := s.Get(ctx, e); err != nil || e.Name == "Joe" {
+		t.Fatalf("Expected the aborted write not to be read but got [%s] [%v]", e.Name, err)
+	}
+
+	// Those of one that commits replace the cached copy
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		_, err := s.Put(tc, &exported{ID: "1", Name: "Joe"})
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, e); err != nil || e.Name != "Joe" {
+		t.Fatalf("Expected [Joe] but got [%s] [%v]", e.Name, err)
+	}
+}
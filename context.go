@@ -0,0 +1,20 @@
+package gaestore
+
+import "context"
+
+type storeKey struct{}
+
+// NewContext returns a copy of ctx carrying s, so that a store configured
+// once per request can be retrieved with FromContext
+func NewContext(ctx context.Context, s Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, s)
+}
+
+// FromContext returns the store installed with NewContext, or the package
+// level default store if there is none
+func FromContext(ctx context.Context) Store {
+	if s, ok := ctx.Value(storeKey{}).(Store); ok {
+		return s
+	}
+	return defaultStore
+}
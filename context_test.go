@@ -0,0 +1,37 @@
+package gaestore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	if s := FromContext(ctx); s != Store(defaultStore) {
+		t.Fatalf("Expected default store [%p] but got [%p]", defaultStore, s)
+	}
+
+	s := NewStore(WithNamespace("tenant"))
+	if got := FromContext(NewContext(ctx, s)); got != Store(s) {
+		t.Fatalf("Expected store [%p] but got [%p]", s, got)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(keyContext(t))
+	cancel()
+
+	called := false
+	s := NewStore(WithMiddleware(func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op *Operation) error {
+			called = true
+			return next(ctx, op)
+		}
+	}))
+	if err := s.Get(ctx, object{ID: "1"}); err != context.Canceled {
+		t.Fatalf("Expected error [%v] but got [%v]", context.Canceled, err)
+	}
+	if called {
+		t.Fatalf("Expected no operation to run on a canceled context")
+	}
+}
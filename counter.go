@@ -0,0 +1,174 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// CounterShardKind is the kind the shards of a ShardedCounter are stored
+// under
+const CounterShardKind = "GaestoreCounterShard"
+
+// counterTotalTTL bounds how long a cached total can be stale, as a total
+// summed while an increment is made may be cached after the increment
+// removed the previous one
+const counterTotalTTL = time.Minute
+
+// ShardedCounter is a count spread over several entities, so it can be
+// incremented faster than the one write per second an entity group allows.
+// Each increment updates a random shard and the value is the sum of the
+// shards, cached when the store caches entities.
+type ShardedCounter struct {
+	name   string
+	shards int
+	s      *Client
+}
+
+type counterShard struct {
+	Name  string
+	Count int64 `datastore:",noindex"`
+}
+
+// NewShardedCounter returns the counter called name, spread over shards
+// entities, 20 when zero. The number of shards can be raised as the write
+// rate grows but not lowered, as the counts of the removed shards would be
+// lost.
+func (s *Client) NewShardedCounter(name string, shards int) *ShardedCounter {
+	if shards <= 0 {
+		shards = 20
+	}
+	return &ShardedCounter{name: name, shards: shards, s: s}
+}
+
+// Increment adds delta to the counter. Called in a transaction, the shard
+// is updated as part of it, so the transaction must be cross group, and
+// the cached total once it commits.
+func (c *ShardedCounter) Increment(ctx context.Context, delta int64) error {
+	s := c.s
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := c.shardKey(ctx, rand.Intn(c.shards))
+	err = s.transact(ctx, func(tc context.Context) error {
+		shard := counterShard{Name: c.name}
+		if err := s.datastoreGet(tc, key, &shard); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		shard.Count += delta
+		_, err := s.datastorePut(tc, key, &shard)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if s.useCache {
+		afterCommit(ctx, func(ctx context.Context) {
+			c.updateTotal(ctx, delta)
+		})
+	}
+	return nil
+}
+
+// Decrement subtracts delta from the counter
+func (c *ShardedCounter) Decrement(ctx context.Context, delta int64) error {
+	return c.Increment(ctx, -delta)
+}
+
+// Value returns the sum of the counter's shards
+func (c *ShardedCounter) Value(ctx context.Context) (int64, error) {
+	s := c.s
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if s.useCache {
+		if n, ok := c.cachedTotal(ctx); ok {
+			return n, nil
+		}
+	}
+	var total int64
+	for i := 0; i < c.shards; i++ {
+		var shard counterShard
+		err := s.datastoreGet(ctx, c.shardKey(ctx, i), &shard)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return 0, err
+		}
+		total += shard.Count
+	}
+	if s.useCache && total >= 0 {
+		c.fillTotal(ctx, total)
+	}
+	return total, nil
+}
+
+// fillTotal caches total unless a total is already cached, which may have
+// been kept up to date by increments since the shards were summed
+func (c *ShardedCounter) fillTotal(ctx context.Context, total int64) {
+	s := c.s
+	value := []byte(strconv.FormatInt(total, 10))
+	ctx, done := s.startRPC(ctx, RPCMemcacheSet, CounterShardKind)
+	var err error
+	if adder, ok := s.mc().(Adder); ok {
+		if err = adder.Add(ctx, c.cacheKey(), value, counterTotalTTL); err == memcache.ErrNotStored {
+			err = nil
+		}
+	} else {
+		err = s.mc().Set(ctx, c.cacheKey(), value, counterTotalTTL)
+	}
+	done(err)
+	if err != nil {
+		s.log().Warningf(ctx, "gaestore: unable to cache counter [%s]: %v", c.name, err)
+	}
+}
+
+// cachedTotal returns the cached value of the counter
+func (c *ShardedCounter) cachedTotal(ctx context.Context) (int64, bool) {
+	ctx, done := c.s.startRPC(ctx, RPCMemcacheGet, CounterShardKind)
+	value, err := c.s.mc().Get(ctx, c.cacheKey())
+	done(err)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	return n, err == nil
+}
+
+// updateTotal adds delta to the cached value of the counter when it can
+// be incremented in place, and otherwise removes it to be summed again
+func (c *ShardedCounter) updateTotal(ctx context.Context, delta int64) {
+	s := c.s
+	if inc, ok := s.mc().(Incrementer); ok {
+		if n, cached := c.cachedTotal(ctx); cached && n+delta >= 0 {
+			ctx, done := s.startRPC(ctx, RPCMemcacheIncrement, CounterShardKind)
+			_, err := inc.Increment(ctx, c.cacheKey(), delta, uint64(n))
+			done(err)
+			if err == nil {
+				return
+			}
+		}
+	}
+	ctx, done := s.startRPC(ctx, RPCMemcacheDelete, CounterShardKind)
+	err := s.mc().Delete(ctx, c.cacheKey())
+	done(err)
+	if err != nil && err != memcache.ErrCacheMiss {
+		s.log().Warningf(ctx, "gaestore: unable to delete counter [%s] from cache: %v", c.name, err)
+	}
+}
+
+func (c *ShardedCounter) shardKey(ctx context.Context, i int) *datastore.Key {
+	return datastore.NewKey(ctx, CounterShardKind, fmt.Sprintf("%s-%d", c.name, i), 0, nil)
+}
+
+func (c *ShardedCounter) cacheKey() string {
+	return "gaestore:shardedcounter:" + c.name
+}
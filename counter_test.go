@@ -0,0 +1,119 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestShardedCounter(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	c := gaestoretest.NewCacher()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithCacher(c))
+	counter := s.NewShardedCounter("views", 4)
+	for i := 0; i < 10; i++ {
+		if err := counter.Increment(ctx, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := counter.Decrement(ctx, 5); err != nil {
+		t.Fatal(err)
+	}
+	n, err := counter.Value(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 15 {
+		t.Fatalf("Expected [15] but got [%d]", n)
+	}
+
+	// Increments update the cached total
+	if err := counter.Increment(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Expected the total to be cached but got [%d] items", c.Len())
+	}
+	if n, err = counter.Value(ctx); err != nil || n != 16 {
+		t.Fatalf("Expected [16] but got [%d, %v]", n, err)
+	}
+
+	// Other stores share the shards
+	other := gaestoretest.NewStore(t, gaestore.WithBackend(b)).NewShardedCounter("views", 4)
+	if n, err = other.Value(ctx); err != nil || n != 16 {
+		t.Fatalf("Expected [16] but got [%d, %v]", n, err)
+	}
+}
+
+func TestShardedCounterStaleTotal(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	c := gaestoretest.NewCacher()
+	clock := gaestoretest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.SetClock(clock)
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithCacher(c))
+	counter := s.NewShardedCounter("views", 4)
+	if err := counter.Increment(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := counter.Value(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected [1] but got [%d, %v]", n, err)
+	}
+
+	// A total cached stale, as when an increment lands while it's summed,
+	// is summed again once it expires
+	uncached := gaestoretest.NewStore(t, gaestore.WithBackend(b)).WithoutCache().NewShardedCounter("views", 4)
+	if err := uncached.Increment(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := counter.Value(ctx); n != 1 {
+		t.Fatalf("Expected the cached total [1] but got [%d]", n)
+	}
+	clock.Advance(time.Hour)
+	if n, err := counter.Value(ctx); err != nil || n != 2 {
+		t.Fatalf("Expected [2] but got [%d, %v]", n, err)
+	}
+}
+
+func TestShardedCounterInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	counter := s.NewShardedCounter("views", 4)
+	if err := counter.Increment(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := counter.Value(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected [1] but got [%d] [%v]", n, err)
+	}
+
+	// Increments of a transaction that fails leave the cached total alone
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if err := counter.Increment(tc, 5); err != nil {
+			return err
+		}
+		return errAbort
+	}, &datastore.TransactionOptions{XG: true})
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	if n, err := counter.Value(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected [1] but got [%d] [%v]", n, err)
+	}
+
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		return counter.Increment(tc, 5)
+	}, &datastore.TransactionOptions{XG: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := counter.Value(ctx); err != nil || n != 6 {
+		t.Fatalf("Expected [6] but got [%d] [%v]", n, err)
+	}
+}
@@ -0,0 +1,295 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// CRUDOptions configures a CRUDHandler
+type CRUDOptions struct {
+	// Store serves requests, by default the store returned by FromContext
+	Store Store
+
+	// Context returns the context of a request, by default
+	// appengine.NewContext(r)
+	Context func(r *http.Request) context.Context
+
+	// Authorize is called before each operation with OpQuery, OpGet, OpPut
+	// or OpDelete and the key of the entity, which is nil for lists and
+	// creates. The request fails with 403 Forbidden when it returns an
+	// error.
+	Authorize func(r *http.Request, op string, key *datastore.Key) error
+
+	// PageSize is the number of entities listed per page, and the most a
+	// request can ask for with the limit parameter. It is 20 by default.
+	PageSize int
+
+	// IntIDs parses the IDs in paths as integer IDs rather than strings
+	IntIDs bool
+
+	// Cursors signs the cursors of lists when set, so that clients can't
+	// edit them. Their scope is the kind and the request's namespace.
+	Cursors *CursorSigner
+}
+
+// ErrKeyMismatch is returned by CRUDHandler updates changing the key of the
+// entity
+var ErrKeyMismatch = errors.New("Entity key does not match the path")
+
+// CRUDHandler returns a handler serving JSON over the root entities of kind,
+// which are of e's type:
+//
+//	GET    /           lists entities, with cursor and limit parameters
+//	GET    /{id}       gets an entity
+//	POST   /           creates an entity
+//	PUT    /{id}       updates fields of an entity
+//	DELETE /{id}       deletes an entity
+//
+// Paths are relative to the handler, so mount it with http.StripPrefix:
+//
+//	http.Handle("/admin/users/", http.StripPrefix("/admin/users", gaestore.CRUDHandler("User", &User{}, gaestore.CRUDOptions{
+//		Authorize: requireAdmin,
+//	})))
+//
+// Lists return the entities and the cursor of the next page, which is
+// empty after the last. Updates decode the body over the stored entity,
+// so fields left out keep their values.
+func CRUDHandler(kind string, e Entity, opts CRUDOptions) http.Handler {
+	t := reflect.TypeOf(e)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = 20
+	}
+	if opts.Context == nil {
+		opts.Context = appengine.NewContext
+	}
+	return &crudHandler{kind: kind, t: t, opts: opts}
+}
+
+type crudHandler struct {
+	kind string
+	t    reflect.Type
+	opts CRUDOptions
+}
+
+// crudPage is the response to a list
+type crudPage struct {
+	Entities interface{} `json:"entities"`
+	Cursor   string      `json:"cursor"`
+}
+
+// httpError is an error answered with its status code
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (h *crudHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := h.opts.Context(r)
+	id := strings.Trim(r.URL.Path, "/")
+	var (
+		v    interface{}
+		code = http.StatusOK
+		err  error
+	)
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		v, err = h.list(ctx, r)
+	case id == "" && r.Method == http.MethodPost:
+		v, err = h.create(ctx, r)
+		code = http.StatusCreated
+	case id != "" && r.Method == http.MethodGet:
+		v, err = h.get(ctx, r, id)
+	case id != "" && r.Method == http.MethodPut:
+		v, err = h.update(ctx, r, id)
+	case id != "" && r.Method == http.MethodDelete:
+		err = h.delete(ctx, r, id)
+		code = http.StatusNoContent
+	default:
+		err = &httpError{http.StatusMethodNotAllowed, fmt.Errorf("Method [%s] not allowed", r.Method)}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+	if v == nil {
+		w.WriteHeader(code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func statusCode(err error) int {
+	var herr *httpError
+	switch {
+	case errors.As(err, &herr):
+		return herr.code
+	case IsNotFound(err):
+		return http.StatusNotFound
+	case errors.Is(err, ErrReadOnly):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *crudHandler) store(ctx context.Context) Store {
+	if h.opts.Store != nil {
+		return h.opts.Store
+	}
+	return FromContext(ctx)
+}
+
+func (h *crudHandler) authorize(r *http.Request, op string, key *datastore.Key) error {
+	if h.opts.Authorize == nil {
+		return nil
+	}
+	if err := h.opts.Authorize(r, op, key); err != nil {
+		return &httpError{http.StatusForbidden, err}
+	}
+	return nil
+}
+
+func (h *crudHandler) newEntity() Entity {
+	return reflect.New(h.t).Interface().(Entity)
+}
+
+func (h *crudHandler) key(ctx context.Context, id string) (*datastore.Key, error) {
+	if !h.opts.IntIDs {
+		return datastore.NewKey(ctx, h.kind, id, 0, nil), nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n == 0 {
+		return nil, &httpError{http.StatusNotFound, fmt.Errorf("Invalid ID [%s]", id)}
+	}
+	return datastore.NewKey(ctx, h.kind, "", n, nil), nil
+}
+
+// load reads the entity with id, after authorizing op on it
+func (h *crudHandler) load(ctx context.Context, r *http.Request, op, id string) (Entity, *datastore.Key, error) {
+	key, err := h.key(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := h.authorize(r, op, key); err != nil {
+		return nil, nil, err
+	}
+	e := h.newEntity()
+	if h.opts.IntIDs {
+		err = h.store(ctx).GetByIntID(ctx, h.kind, key.IntID(), e)
+	} else {
+		err = h.store(ctx).GetByStringID(ctx, h.kind, key.StringID(), e)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, key, nil
+}
+
+func (h *crudHandler) list(ctx context.Context, r *http.Request) (interface{}, error) {
+	if err := h.authorize(r, OpQuery, nil); err != nil {
+		return nil, err
+	}
+	limit := h.opts.PageSize
+	if l := r.FormValue("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, &httpError{http.StatusBadRequest, fmt.Errorf("Invalid limit [%s]", l)}
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	q := datastore.NewQuery(h.kind).Limit(limit)
+	if c := r.FormValue("cursor"); c != "" {
+		cursor, err := h.decodeCursor(ctx, c)
+		if err != nil {
+			return nil, &httpError{http.StatusBadRequest, fmt.Errorf("Invalid cursor [%s]", c)}
+		}
+		q = q.Start(cursor)
+	}
+	entities := reflect.New(reflect.SliceOf(reflect.PtrTo(h.t)))
+	entities.Elem().Set(reflect.MakeSlice(entities.Elem().Type(), 0, limit))
+	cursor, err := h.store(ctx).Query(ctx, q, entities.Interface())
+	if err != nil {
+		return nil, err
+	}
+	page := crudPage{Entities: entities.Elem().Interface()}
+	if entities.Elem().Len() == limit {
+		page.Cursor = h.encodeCursor(ctx, cursor)
+	}
+	return page, nil
+}
+
+func (h *crudHandler) encodeCursor(ctx context.Context, c datastore.Cursor) string {
+	return EncodeCursor(c, h.opts.Cursors, h.cursorScope(ctx))
+}
+
+func (h *crudHandler) decodeCursor(ctx context.Context, c string) (datastore.Cursor, error) {
+	return DecodeCursor(c, h.opts.Cursors, h.cursorScope(ctx))
+}
+
+func (h *crudHandler) cursorScope(ctx context.Context) string {
+	return h.kind + "/" + appds.Namespace(ctx)
+}
+
+func (h *crudHandler) get(ctx context.Context, r *http.Request, id string) (interface{}, error) {
+	e, _, err := h.load(ctx, r, OpGet, id)
+	return e, err
+}
+
+func (h *crudHandler) create(ctx context.Context, r *http.Request) (interface{}, error) {
+	e := h.newEntity()
+	if err := json.NewDecoder(r.Body).Decode(e); err != nil {
+		return nil, &httpError{http.StatusBadRequest, err}
+	}
+	if err := h.authorize(r, OpPut, nil); err != nil {
+		return nil, err
+	}
+	if _, err := h.store(ctx).Put(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (h *crudHandler) update(ctx context.Context, r *http.Request, id string) (interface{}, error) {
+	e, key, err := h.load(ctx, r, OpPut, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewDecoder(r.Body).Decode(e); err != nil {
+		return nil, &httpError{http.StatusBadRequest, err}
+	}
+	if !key.Equal(e.Key(ctx)) {
+		return nil, &httpError{http.StatusBadRequest, ErrKeyMismatch}
+	}
+	if _, err := h.store(ctx).Put(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (h *crudHandler) delete(ctx context.Context, r *http.Request, id string) error {
+	e, _, err := h.load(ctx, r, OpDelete, id)
+	if err != nil {
+		return err
+	}
+	return h.store(ctx).Delete(ctx, e)
+}
@@ -0,0 +1,104 @@
+package gaestore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type widget struct {
+	ID   string `datastore:"-" json:"id"`
+	Name string `json:"name"`
+}
+
+func (w *widget) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "widget", w.ID, 0, nil)
+}
+
+func (w *widget) AfterGet(ctx context.Context, key *datastore.Key) error {
+	w.ID = key.StringID()
+	return nil
+}
+
+func TestCRUDHandler(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	h := gaestore.CRUDHandler("widget", &widget{}, gaestore.CRUDOptions{
+		Store:    s,
+		Context:  func(r *http.Request) context.Context { return ctx },
+		PageSize: 2,
+		Authorize: func(r *http.Request, op string, key *datastore.Key) error {
+			if op == gaestore.OpDelete && key.StringID() == "locked" {
+				return errors.New("locked")
+			}
+			return nil
+		},
+	})
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	for _, id := range []string{"a", "b", "c", "locked"} {
+		if w := serve("POST", "/", `{"id":"`+id+`","name":"`+id+`"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Expected [201] but got [%d] %s", w.Code, w.Body)
+		}
+	}
+	b.Apply()
+
+	var page struct {
+		Entities []widget
+		Cursor   string
+	}
+	var names []string
+	for cursor := ""; ; {
+		w := serve("GET", "/?cursor="+cursor, "")
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page.Entities {
+			names = append(names, e.Name)
+		}
+		if cursor = page.Cursor; cursor == "" {
+			break
+		}
+	}
+	if got := strings.Join(names, ","); got != "a,b,c,locked" {
+		t.Fatalf("Expected [a,b,c,locked] but got [%s]", got)
+	}
+
+	w := serve("PUT", "/b", `{"name":"bee"}`)
+	var got widget
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.ID != "b" || got.Name != "bee" {
+		t.Fatalf("Expected [b bee] but got [%+v] %v", got, err)
+	}
+	if w := serve("PUT", "/b", `{"id":"x"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected [400] but got [%d]", w.Code)
+	}
+	if w := serve("GET", "/b", ""); !strings.Contains(w.Body.String(), `"bee"`) {
+		t.Fatalf("Expected [bee] but got [%s]", w.Body)
+	}
+
+	if w := serve("DELETE", "/a", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected [204] but got [%d] %s", w.Code, w.Body)
+	}
+	if w := serve("GET", "/a", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected [404] but got [%d]", w.Code)
+	}
+	if w := serve("DELETE", "/locked", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("Expected [403] but got [%d]", w.Code)
+	}
+	if w := serve("PATCH", "/b", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected [405] but got [%d]", w.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package gaestore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// EncodeCursor returns a URL-safe token holding c, for handing cursors to
+// clients such as in the next page link of a list, signed by signer for
+// scope when signer is not nil. The zero cursor is the empty token.
+func EncodeCursor(c datastore.Cursor, signer *CursorSigner, scope string) string {
+	if signer != nil {
+		return signer.Sign(c, scope)
+	}
+	return c.String()
+}
+
+// DecodeCursor returns the cursor held by a token from EncodeCursor, given
+// the same signer and scope, or ErrInvalidCursor for a malformed token.
+// See CursorSigner.Verify for the errors of signed tokens.
+func DecodeCursor(token string, signer *CursorSigner, scope string) (datastore.Cursor, error) {
+	if signer != nil {
+		return signer.Verify(token, scope)
+	}
+	if token == "" {
+		return datastore.Cursor{}, nil
+	}
+	c, err := datastore.DecodeCursor(token)
+	if err != nil {
+		return datastore.Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// CursorSigner wraps datastore cursors in tokens signed with HMAC-SHA256,
+// for handing cursors to clients. A cursor resumes any query it's used
+// with, so a client able to edit one could page through entities it
+// shouldn't see; a signed token is only accepted unmodified, before it
+// expires, and for the scope it was signed for.
+type CursorSigner struct {
+	// Key signs the tokens, and should be at least 32 random bytes kept
+	// secret by the app
+	Key []byte
+
+	// TTL is how long tokens are accepted for, forever when zero
+	TTL time.Duration
+
+	// Clock tells the time tokens expire from, the system clock when nil
+	Clock Clock
+}
+
+// Sign returns a token holding c for scope, which identifies what the
+// cursor may be used for, such as the kind and the signed in user or
+// tenant. The zero cursor is signed as an empty token.
+func (s CursorSigner) Sign(c datastore.Cursor, scope string) string {
+	cursor := c.String()
+	if cursor == "" {
+		return ""
+	}
+	var expires int64
+	if s.TTL > 0 {
+		expires = s.now().Add(s.TTL).Unix()
+	}
+	payload := strconv.FormatInt(expires, 36) + "." + cursor
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload, scope))
+}
+
+// Verify returns the cursor held by a token from Sign for the same scope,
+// or ErrInvalidCursor when it has been modified or signed for another
+// scope, and ErrCursorExpired when it's too old. An empty token is the
+// zero cursor.
+func (s CursorSigner) Verify(token, scope string) (datastore.Cursor, error) {
+	if token == "" {
+		return datastore.Cursor{}, nil
+	}
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return datastore.Cursor{}, ErrInvalidCursor
+	}
+	payload := token[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(sig, s.mac(payload, scope)) {
+		return datastore.Cursor{}, ErrInvalidCursor
+	}
+	j := strings.Index(payload, ".")
+	if j < 0 {
+		return datastore.Cursor{}, ErrInvalidCursor
+	}
+	expires, err := strconv.ParseInt(payload[:j], 36, 64)
+	if err != nil {
+		return datastore.Cursor{}, ErrInvalidCursor
+	}
+	if expires > 0 && s.now().Unix() >= expires {
+		return datastore.Cursor{}, ErrCursorExpired
+	}
+	c, err := datastore.DecodeCursor(payload[j+1:])
+	if err != nil {
+		return datastore.Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (s CursorSigner) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// mac signs payload for scope, which is kept apart from the payload so
+// that no scope can be made to collide with another
+func (s CursorSigner) mac(payload, scope string) []byte {
+	m := hmac.New(sha256.New, s.Key)
+	m.Write([]byte(strconv.Itoa(len(scope))))
+	m.Write([]byte(":" + scope))
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
@@ -0,0 +1,78 @@
+package gaestore
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestCursorSigner(t *testing.T) {
+	c, err := appds.WrapCursor("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := CursorSigner{Key: []byte("secret"), TTL: time.Hour}
+	token := s.Sign(c, "user/1")
+	got, err := s.Verify(token, "user/1")
+	if err != nil || got.String() != c.String() {
+		t.Fatalf("Expected [%v] but got [%v] %v", c, got, err)
+	}
+
+	other, _ := appds.WrapCursor("43")
+	for _, tc := range []struct {
+		token, scope string
+	}{
+		{token, "user/2"},
+		{token[:len(token)-1], "user/1"},
+		{other.String(), "user/1"},
+		{strings.Replace(token, c.String(), other.String(), 1), "user/1"},
+		{CursorSigner{Key: []byte("other")}.Sign(c, "user/1"), "user/1"},
+	} {
+		if _, err := s.Verify(tc.token, tc.scope); err != ErrInvalidCursor {
+			t.Fatalf("Expected [%v] for [%s] but got [%v]", ErrInvalidCursor, tc.token, err)
+		}
+	}
+
+	expired := CursorSigner{Key: s.Key, TTL: time.Nanosecond}
+	if _, err := expired.Verify(expired.Sign(c, "user/1"), "user/1"); err != ErrCursorExpired {
+		t.Fatalf("Expected [%v] but got [%v]", ErrCursorExpired, err)
+	}
+
+	if s.Sign(datastore.Cursor{}, "user/1") != "" {
+		t.Fatalf("Expected the zero cursor to be an empty token")
+	}
+	if got, err := s.Verify("", "user/1"); err != nil || got.String() != "" {
+		t.Fatalf("Expected the zero cursor but got [%v] %v", got, err)
+	}
+}
+
+func TestEncodeCursor(t *testing.T) {
+	c, err := appds.WrapCursor("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &CursorSigner{Key: []byte("secret")}
+	for _, s := range []*CursorSigner{nil, signer} {
+		token := EncodeCursor(c, s, "user/1")
+		if url.QueryEscape(token) != token {
+			t.Fatalf("Expected a URL-safe token but got [%s]", token)
+		}
+		got, err := DecodeCursor(token, s, "user/1")
+		if err != nil || got.String() != c.String() {
+			t.Fatalf("Expected [%v] but got [%v] %v", c, got, err)
+		}
+		if got, err := DecodeCursor("", s, "user/1"); err != nil || got.String() != "" {
+			t.Fatalf("Expected the zero cursor but got [%v] %v", got, err)
+		}
+		if _, err := DecodeCursor("not a cursor", s, "user/1"); err != ErrInvalidCursor {
+			t.Fatalf("Expected [%v] but got [%v]", ErrInvalidCursor, err)
+		}
+	}
+	if _, err := DecodeCursor(EncodeCursor(c, nil, ""), signer, "user/1"); err != ErrInvalidCursor {
+		t.Fatalf("Expected an unsigned token to be rejected but got [%v]", err)
+	}
+}
@@ -0,0 +1,96 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// DedupKind is the kind the tokens recorded by a Dedup are stored under.
+// Expired tokens can be deleted with Sweep.
+const DedupKind = "GaestoreDedup"
+
+// Dedup records tokens, such as webhook delivery IDs or task names, so
+// handlers retried with the same token can tell they've already run
+//
+//	if seen, err := s.NewDedup("stripe").Seen(ctx, event.ID, 24*time.Hour); err != nil || seen {
+//		return err
+//	}
+type Dedup struct {
+	scope string
+	s     *Client
+}
+
+type dedupToken struct {
+	Expires time.Time
+}
+
+func (t *dedupToken) ExpiresAt() time.Time {
+	return t.Expires
+}
+
+// NewDedup returns a Dedup whose tokens are kept apart from those of other
+// scopes
+func (s *Client) NewDedup(scope string) *Dedup {
+	return &Dedup{scope: scope, s: s}
+}
+
+// Seen records token for window and reports whether it was already
+// recorded within its window. Tokens are recorded in a transaction, so of
+// concurrent calls with the same token only one reports false. Called in a
+// transaction, the token is recorded as part of it, and is only recorded
+// if it commits.
+func (d *Dedup) Seen(ctx context.Context, token string, window time.Duration) (bool, error) {
+	s := d.s
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return false, err
+	}
+	name := d.scope + ":" + token
+	if s.useCache {
+		ctx, done := s.startRPC(ctx, RPCMemcacheGet, DedupKind)
+		_, err := s.mc().Get(ctx, dedupCacheKey(name))
+		done(err)
+		if err == nil {
+			return true, nil
+		}
+	}
+	key := datastore.NewKey(ctx, DedupKind, name, 0, nil)
+	seen := false
+	err = s.transact(ctx, func(tc context.Context) error {
+		var t dedupToken
+		err := s.datastoreGet(tc, key, &t)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if seen = err == nil && s.now().Before(t.Expires); seen {
+			return nil
+		}
+		_, err = s.datastorePut(tc, key, &dedupToken{Expires: s.now().Add(window)})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if s.useCache && window >= time.Second {
+		// The token isn't known to be recorded until the transaction
+		// commits
+		afterCommit(ctx, func(ctx context.Context) {
+			ctx, done := s.startRPC(ctx, RPCMemcacheSet, DedupKind)
+			err := s.mc().Set(ctx, dedupCacheKey(name), []byte{1}, window)
+			done(err)
+			if err != nil {
+				s.log().Warningf(ctx, "gaestore: unable to cache token [%s]: %v", name, err)
+			}
+		})
+	}
+	return seen, nil
+}
+
+func dedupCacheKey(name string) string {
+	return "gaestore:dedup:" + name
+}
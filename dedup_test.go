@@ -0,0 +1,70 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestDedup(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	d := gaestoretest.NewStore(t, gaestore.WithBackend(b)).NewDedup("webhooks")
+	for i, want := range []bool{false, true, true} {
+		seen, err := d.Seen(ctx, "evt_1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != want {
+			t.Fatalf("Expected call %d to report [%t] but got [%t]", i, want, seen)
+		}
+	}
+
+	// The datastore is used when the cache misses
+	cold := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithCacher(gaestoretest.NewCacher()))
+	if seen, err := cold.NewDedup("webhooks").Seen(ctx, "evt_1", time.Hour); err != nil || !seen {
+		t.Fatalf("Expected the token to be seen but got [%t, %v]", seen, err)
+	}
+	if seen, err := cold.NewDedup("tasks").Seen(ctx, "evt_1", time.Hour); err != nil || seen {
+		t.Fatalf("Expected scopes to be separate but got [%t, %v]", seen, err)
+	}
+
+	// Tokens can be reused once their window has passed
+	if seen, err := d.Seen(ctx, "evt_2", -time.Second); err != nil || seen {
+		t.Fatalf("Expected [false] but got [%t, %v]", seen, err)
+	}
+	if seen, err := d.Seen(ctx, "evt_2", time.Hour); err != nil || seen {
+		t.Fatalf("Expected an expired token to be unseen but got [%t, %v]", seen, err)
+	}
+}
+
+func TestDedupInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	d := s.NewDedup("webhooks")
+
+	// A token recorded by a transaction that fails isn't seen
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := d.Seen(tc, "evt_1", time.Hour); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	for i, want := range []bool{false, true} {
+		seen, err := d.Seen(ctx, "evt_1", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != want {
+			t.Fatalf("Expected call %d to report [%t] but got [%t]", i, want, seen)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gaestore
+
+import "time"
+
+// With returns a copy of the store with opts applied, leaving the store
+// unchanged so a shared store can be specialized per request. The copy
+// starts with the store's event subscriptions, and shares its cache
+// breaker.
+func (s *Client) With(opts ...Option) *Client {
+	c := &Client{config: s.config}
+	// Force middleware added to the copy into a new array
+	c.middleware = s.middleware[:len(s.middleware):len(s.middleware)]
+	s.mu.RLock()
+	for kind, fns := range s.subscribers {
+		if c.subscribers == nil {
+			c.subscribers = make(map[string][]func(Event))
+		}
+		c.subscribers[kind] = append(make([]func(Event), 0, len(fns)), fns...)
+	}
+	s.mu.RUnlock()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithNamespace returns a copy of the store using namespace
+func (s *Client) WithNamespace(namespace string) *Client {
+	return s.With(WithNamespace(namespace))
+}
+
+// WithoutCache returns a copy of the store that doesn't cache entities
+func (s *Client) WithoutCache() *Client {
+	return s.With(func(c *Client) {
+		c.useCache = false
+	})
+}
+
+// WithTTL returns a copy of the store caching entities for ttl
+func (s *Client) WithTTL(ttl time.Duration) *Client {
+	return s.With(WithCache(ttl))
+}
+
+// WithLogger returns a copy of the store logging to l
+func (s *Client) WithLogger(l Logger) *Client {
+	return s.With(WithLogger(l))
+}
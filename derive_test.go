@@ -0,0 +1,32 @@
+package gaestore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWith(t *testing.T) {
+	base := NewStore(WithCache(time.Minute), WithNamespace("base"))
+	base.Use(func(next OpFunc) OpFunc { return next })
+
+	derived := base.WithNamespace("tenant").WithoutCache()
+	derived.Use(func(next OpFunc) OpFunc { return next })
+	if derived.namespace != "tenant" || derived.useCache {
+		t.Fatalf("Expected namespace [tenant] without cache but got [%s] [%v]", derived.namespace, derived.useCache)
+	}
+	if base.namespace != "base" || !base.useCache || len(base.middleware) != 1 {
+		t.Fatalf("Expected the base store to be unchanged but got [%s] [%v] [%d]", base.namespace, base.useCache, len(base.middleware))
+	}
+
+	if ttl := base.WithTTL(time.Hour).cacheTTL; ttl != time.Hour {
+		t.Fatalf("Expected ttl [%v] but got [%v]", time.Hour, ttl)
+	}
+	if l := base.WithLogger(nopLogger{}).log(); l != (nopLogger{}) {
+		t.Fatalf("Expected logger [%v] but got [%v]", nopLogger{}, l)
+	}
+
+	base.Subscribe("object", func(Event) {})
+	if n := len(base.With().subscribed("object")); n != 1 {
+		t.Fatalf("Expected [1] subscriber but got [%d]", n)
+	}
+}
@@ -0,0 +1,66 @@
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// Mutation is a Put, Delete or Touch recorded instead of being made by a
+// store created with WithDryRun
+type Mutation struct {
+	Op     string
+	Key    *datastore.Key
+	Entity Entity
+}
+
+// Mutations returns the mutations recorded by a dry run store, in the
+// order they were made
+func (s *Client) Mutations() []Mutation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Mutation(nil), s.mutations...)
+}
+
+// ResetMutations discards the recorded mutations
+func (s *Client) ResetMutations() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutations = nil
+}
+
+// recordMutation records and logs op in place of executing it. Hooks are not run
+// and nothing is cached; a Put returns the entity's key, which may be
+// incomplete.
+func (s *Client) recordMutation(ctx context.Context, op *Operation) error {
+	if op.Name == OpPutMulti {
+		if op.Keys == nil {
+			op.Keys = make([]*datastore.Key, len(op.Entities))
+		}
+		for i, e := range op.Entities {
+			if op.Keys[i] == nil {
+				op.Keys[i] = e.Key(ctx)
+			}
+			s.record(ctx, OpPut, op.Keys[i], e)
+		}
+		return nil
+	}
+	if op.Name == OpPutLater {
+		// Recorded as the Put its task would make
+		s.record(ctx, OpPut, op.Key, op.Entity)
+		return nil
+	}
+	s.record(ctx, op.Name, op.Key, op.Entity)
+	return nil
+}
+
+func (s *Client) record(ctx context.Context, op string, key *datastore.Key, e Entity) {
+	if e == nil {
+		s.log().Infof(ctx, "gaestore: dry run: %s [%v]", op, key)
+	} else {
+		s.log().Infof(ctx, "gaestore: dry run: %s [%v] %s", op, key, Redact(e))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutations = append(s.mutations, Mutation{Op: op, Key: key, Entity: e})
+}
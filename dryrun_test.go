@@ -0,0 +1,35 @@
+package gaestore
+
+import (
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(backend), WithDryRun(), WithLogger(nopLogger{}))
+
+	o := &object{ID: "1", Name: "John"}
+	key, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.StringID() != "1" {
+		t.Fatalf("Expected key [1] but got [%v]", key)
+	}
+	if err := s.Delete(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend) != 0 {
+		t.Fatalf("Expected nothing to be written but got [%d] entities", len(backend))
+	}
+
+	mutations := s.Mutations()
+	if len(mutations) != 2 || mutations[0].Op != OpPut || mutations[1].Op != OpDelete || mutations[0].Entity != o {
+		t.Fatalf("Expected a Put and a Delete of [%v] but got [%+v]", o, mutations)
+	}
+	s.ResetMutations()
+	if n := len(s.Mutations()); n != 0 {
+		t.Fatalf("Expected no mutations but got [%d]", n)
+	}
+}
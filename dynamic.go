@@ -0,0 +1,206 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// DynamicEntity is an entity of any kind whose properties are held in a
+// map, for tools such as admin pages and data browsers that work with
+// kinds whose schema isn't known at compile time. Multiple valued
+// properties are held as []interface{}.
+//
+//	var all []*gaestore.DynamicEntity
+//	_, err := s.Query(ctx, datastore.NewQuery(kind), &all)
+//
+// Entities read through a store have their key set once loaded, unless
+// hooks are skipped.
+type DynamicEntity struct {
+	key *datastore.Key
+
+	Properties map[string]interface{}
+
+	// NoIndex holds the names of the properties that aren't indexed
+	NoIndex map[string]bool
+}
+
+// NewDynamicEntity returns an empty entity with key
+func NewDynamicEntity(key *datastore.Key) *DynamicEntity {
+	return &DynamicEntity{key: key, Properties: make(map[string]interface{})}
+}
+
+// Key returns the entity's key
+func (e *DynamicEntity) Key(ctx context.Context) *datastore.Key {
+	return e.key
+}
+
+// SetKey sets the entity's key
+func (e *DynamicEntity) SetKey(key *datastore.Key) {
+	e.key = key
+}
+
+// AfterGet sets the entity's key to the key it was read with
+func (e *DynamicEntity) AfterGet(ctx context.Context, key *datastore.Key) error {
+	e.key = key
+	return nil
+}
+
+// Load replaces the entity's properties with props
+func (e *DynamicEntity) Load(props []datastore.Property) error {
+	e.Properties = make(map[string]interface{}, len(props))
+	e.NoIndex = nil
+	for _, p := range props {
+		if p.Multiple {
+			values, _ := e.Properties[p.Name].([]interface{})
+			e.Properties[p.Name] = append(values, p.Value)
+		} else {
+			e.Properties[p.Name] = p.Value
+		}
+		if p.NoIndex {
+			if e.NoIndex == nil {
+				e.NoIndex = make(map[string]bool)
+			}
+			e.NoIndex[p.Name] = true
+		}
+	}
+	return nil
+}
+
+// Save returns the entity's properties, ordered by name
+func (e *DynamicEntity) Save() ([]datastore.Property, error) {
+	names := make([]string, 0, len(e.Properties))
+	for name := range e.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var props []datastore.Property
+	for _, name := range names {
+		noIndex := e.NoIndex[name]
+		values, multiple := e.Properties[name].([]interface{})
+		if !multiple {
+			props = append(props, datastore.Property{Name: name, Value: e.Properties[name], NoIndex: noIndex})
+			continue
+		}
+		for _, v := range values {
+			props = append(props, datastore.Property{Name: name, Value: v, NoIndex: noIndex, Multiple: true})
+		}
+	}
+	return props, nil
+}
+
+// dynamicProperty is a property of a DynamicEntity encoded as JSON along
+// with the type of its value, so that it decodes to the same type
+type dynamicProperty struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Value    json.RawMessage `json:"value"`
+	NoIndex  bool            `json:"noindex,omitempty"`
+	Multiple bool            `json:"multiple,omitempty"`
+}
+
+// MarshalJSON encodes the entity's properties along with the types of
+// their values, as it's cached
+func (e *DynamicEntity) MarshalJSON() ([]byte, error) {
+	props, err := e.Save()
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]dynamicProperty, len(props))
+	for i, p := range props {
+		typ, v := "", p.Value
+		switch pv := p.Value.(type) {
+		case nil:
+			typ = "null"
+		case int64:
+			typ = "int"
+		case bool:
+			typ = "bool"
+		case string:
+			typ = "string"
+		case float64:
+			typ = "float"
+		case *datastore.Key:
+			typ, v = "key", pv.Encode()
+		case time.Time:
+			typ = "time"
+		case appengine.BlobKey:
+			typ = "blobkey"
+		case appengine.GeoPoint:
+			typ = "geopoint"
+		case datastore.ByteString:
+			typ, v = "bytestring", []byte(pv)
+		case []byte:
+			typ = "bytes"
+		default:
+			return nil, fmt.Errorf("gaestore: unsupported type [%T] of property [%s]", p.Value, p.Name)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = dynamicProperty{Name: p.Name, Type: typ, Value: b, NoIndex: p.NoIndex, Multiple: p.Multiple}
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes properties encoded by MarshalJSON
+func (e *DynamicEntity) UnmarshalJSON(b []byte) error {
+	var encoded []dynamicProperty
+	if err := json.Unmarshal(b, &encoded); err != nil {
+		return err
+	}
+	props := make([]datastore.Property, len(encoded))
+	for i, p := range encoded {
+		var (
+			v   interface{}
+			err error
+		)
+		switch p.Type {
+		case "null":
+		case "int":
+			v, err = unmarshalAs[int64](p.Value)
+		case "bool":
+			v, err = unmarshalAs[bool](p.Value)
+		case "string":
+			v, err = unmarshalAs[string](p.Value)
+		case "float":
+			v, err = unmarshalAs[float64](p.Value)
+		case "key":
+			var s string
+			if s, err = unmarshalAs[string](p.Value); err == nil {
+				v, err = datastore.DecodeKey(s)
+			}
+		case "time":
+			v, err = unmarshalAs[time.Time](p.Value)
+		case "blobkey":
+			v, err = unmarshalAs[appengine.BlobKey](p.Value)
+		case "geopoint":
+			v, err = unmarshalAs[appengine.GeoPoint](p.Value)
+		case "bytestring":
+			var bs []byte
+			bs, err = unmarshalAs[[]byte](p.Value)
+			v = datastore.ByteString(bs)
+		case "bytes":
+			v, err = unmarshalAs[[]byte](p.Value)
+		default:
+			err = fmt.Errorf("gaestore: unknown type [%s] of property [%s]", p.Type, p.Name)
+		}
+		if err != nil {
+			return err
+		}
+		props[i] = datastore.Property{Name: p.Name, Value: v, NoIndex: p.NoIndex, Multiple: p.Multiple}
+	}
+	return e.Load(props)
+}
+
+func unmarshalAs[T any](b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
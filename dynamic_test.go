@@ -0,0 +1,61 @@
+package gaestore_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestDynamicEntity(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+
+	key := datastore.NewKey(ctx, "thing", "1", 0, nil)
+	e := gaestore.NewDynamicEntity(key)
+	e.Properties["Name"] = "lamp"
+	e.Properties["Count"] = int64(3)
+	e.Properties["Price"] = 9.5
+	e.Properties["Lit"] = true
+	e.Properties["Added"] = time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	e.Properties["Owner"] = datastore.NewKey(ctx, "user", "ann", 0, nil)
+	e.Properties["Where"] = appengine.GeoPoint{Lat: 1, Lng: 2}
+	e.Properties["Data"] = []byte{0, 1}
+	e.Properties["Tags"] = []interface{}{"a", "b"}
+	e.Properties["Missing"] = nil
+	e.NoIndex = map[string]bool{"Data": true}
+	if _, err := s.Put(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read from the cache, then from the datastore
+	for _, useCache := range []bool{true, false} {
+		store := s
+		if !useCache {
+			store = s.WithoutCache()
+		}
+		got := &gaestore.DynamicEntity{}
+		if err := store.GetByStringID(ctx, "thing", "1", got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Properties, e.Properties) || !reflect.DeepEqual(got.NoIndex, e.NoIndex) {
+			t.Fatalf("Expected [%v] but got [%v] with cache %v", e.Properties, got.Properties, useCache)
+		}
+		if !got.Key(ctx).Equal(key) {
+			t.Fatalf("Expected key [%v] but got [%v]", key, got.Key(ctx))
+		}
+	}
+
+	var all []*gaestore.DynamicEntity
+	if _, err := s.Query(ctx, datastore.NewQuery("thing").Filter("Tags =", "b"), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || !all[0].Key(ctx).Equal(key) || all[0].Properties["Name"] != "lamp" {
+		t.Fatalf("Expected the entity to be found but got [%v]", all)
+	}
+}
@@ -0,0 +1,185 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// Errors returned by stores. Errors from an operation are wrapped in an
+// *OpError, so compare against these with errors.Is.
+var (
+	// ErrNoSuchEntity is returned when the entity does not exist. Use
+	// IsNotFound to also match a MultiError of missing entities.
+	ErrNoSuchEntity = datastore.ErrNoSuchEntity
+
+	// ErrInvalidEntityType is returned for a destination that can't hold
+	// an entity
+	ErrInvalidEntityType = datastore.ErrInvalidEntityType
+
+	// ErrNilKey is returned when an entity's Key method returns nil
+	ErrNilKey = errors.New("gaestore: entity has a nil key")
+
+	// ErrUniqueConstraint is for BeforePut hooks to return when a write
+	// would duplicate a value that must be unique
+	ErrUniqueConstraint = errors.New("gaestore: unique constraint violated")
+
+	// ErrReadOnly is returned by Put and Delete on a store created with
+	// WithReadOnly
+	ErrReadOnly = errors.New("gaestore: store is read only")
+
+	// ErrOversizedCacheItem is returned when an encoded entity is too large
+	// to cache. The entity is still written, and any stale cached copy
+	// removed.
+	ErrOversizedCacheItem = errors.New("gaestore: entity too large to cache")
+
+	// ErrInconsistentQuery is returned for queries made with
+	// StrongConsistency that can't be strongly consistent
+	ErrInconsistentQuery = errors.New("gaestore: query is not strongly consistent")
+
+	// ErrConditionFailed is returned by PutIf when the stored entity
+	// doesn't have the expected value
+	ErrConditionFailed = errors.New("gaestore: condition failed")
+
+	// ErrUnauthorized is for Authorizers to return when an operation isn't
+	// allowed
+	ErrUnauthorized = errors.New("gaestore: operation not authorized")
+
+	// ErrInvalidCursor is returned by CursorSigner.Verify for a token that
+	// wasn't signed with its key and scope, and by DecodeCursor for a
+	// malformed token
+	ErrInvalidCursor = errors.New("gaestore: invalid cursor")
+
+	// ErrCursorExpired is returned by CursorSigner.Verify for a token older
+	// than the signer's TTL
+	ErrCursorExpired = errors.New("gaestore: cursor expired")
+
+	// ErrNotOwner is returned by writes of an entity owned by another user
+	// through a store created with WithOwner
+	ErrNotOwner = errors.New("gaestore: entity is owned by another user")
+
+	// ErrInvalidRange is returned by Between for a range that ends before
+	// it starts
+	ErrInvalidRange = errors.New("gaestore: invalid range")
+
+	// ErrInequalityFilter is returned by CheckQuery for a query with
+	// inequality filters on more than one property
+	ErrInequalityFilter = errors.New("gaestore: inequality filters on more than one property")
+
+	// ErrInequalityOrder is returned by CheckQuery for a query not first
+	// ordered by the property of its inequality filters
+	ErrInequalityOrder = errors.New("gaestore: query not first ordered by its inequality filter property")
+)
+
+// ErrResultTruncated is returned by a query reading more results than the
+// store's maximum, set with WithMaxResults or MaxResults. The entities
+// read are still appended to the destination, and Cursor resumes the
+// query after the last of them.
+type ErrResultTruncated struct {
+	Max    int
+	Cursor datastore.Cursor
+}
+
+func (e *ErrResultTruncated) Error() string {
+	return fmt.Sprintf("gaestore: query truncated at [%d] results", e.Max)
+}
+
+// OpError is returned when an operation made through a store fails. It
+// unwraps to the underlying datastore or memcache error so it can still be
+// compared against errors like datastore.ErrNoSuchEntity with errors.Is.
+type OpError struct {
+	Op   string
+	Kind string
+	Key  *datastore.Key
+	Err  error
+}
+
+func (e *OpError) Error() string {
+	if e.Key != nil {
+		return fmt.Sprintf("gaestore: %s %v: %v", e.Op, e.Key, e.Err)
+	}
+	if e.Kind != "" {
+		return fmt.Sprintf("gaestore: %s %s: %v", e.Op, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("gaestore: %s: %v", e.Op, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// newOpError wraps err with the details of op unless it has already been
+// wrapped
+func newOpError(ctx context.Context, op *Operation, err error) *OpError {
+	if oerr, ok := err.(*OpError); ok {
+		return oerr
+	}
+	oerr := &OpError{
+		Op:  op.Name,
+		Key: operationKey(ctx, op),
+		Err: err,
+	}
+	if oerr.Key != nil {
+		oerr.Kind = oerr.Key.Kind()
+	}
+	return oerr
+}
+
+// IsNotFound reports whether err means the entity does not exist. A
+// MultiError is not found only if every entity that failed does not exist,
+// and an ErrFieldMismatch is never not found as the entity was loaded.
+func IsNotFound(err error) bool {
+	var merr appengine.MultiError
+	if errors.As(err, &merr) {
+		found := false
+		for _, err := range merr {
+			if err == nil {
+				continue
+			}
+			if !IsNotFound(err) {
+				return false
+			}
+			found = true
+		}
+		return found
+	}
+	return errors.Is(err, datastore.ErrNoSuchEntity)
+}
+
+// IsFieldMismatch reports whether err is a datastore.ErrFieldMismatch,
+// which leaves the entity loaded apart from the mismatched field
+func IsFieldMismatch(err error) bool {
+	var ferr *datastore.ErrFieldMismatch
+	return errors.As(err, &ferr)
+}
+
+// ErrorHandler receives every error a store encounters, including cache
+// errors that are otherwise swallowed. op is the name of the operation
+// that failed and key may be nil when no single entity is involved.
+type ErrorHandler func(ctx context.Context, op string, key *datastore.Key, err error)
+
+// OnError sets the handler called whenever an operation made through the
+// store fails
+func (s *Client) OnError(fn ErrorHandler) {
+	s.onError = fn
+}
+
+func (s *Client) reportError(ctx context.Context, op string, key *datastore.Key, err error) {
+	if s.onError != nil {
+		s.onError(ctx, op, key, err)
+	}
+}
+
+// operationKey returns the key of the entity an operation acts on
+func operationKey(ctx context.Context, op *Operation) *datastore.Key {
+	if op.Key != nil {
+		return op.Key
+	}
+	if op.Entity != nil {
+		return op.Entity.Key(ctx)
+	}
+	return nil
+}
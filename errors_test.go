@@ -0,0 +1,93 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestOpError(t *testing.T) {
+	ctx := keyContext(t)
+	s := NewStore()
+	s.Use(func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op *Operation) error {
+			return datastore.ErrNoSuchEntity
+		}
+	})
+
+	err := s.Get(ctx, object{ID: "1"})
+	if !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("Expected error to unwrap to [%v] but got [%v]", datastore.ErrNoSuchEntity, err)
+	}
+	var oerr *OpError
+	if !errors.As(err, &oerr) {
+		t.Fatalf("Expected an *OpError but got [%T]", err)
+	}
+	if oerr.Op != OpGet || oerr.Kind != "object" || oerr.Key.StringID() != "1" {
+		t.Fatalf("Unexpected error details [%+v]", oerr)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		Err      error
+		Expected bool
+	}{
+		{nil, false},
+		{datastore.ErrNoSuchEntity, true},
+		{&OpError{Op: OpGet, Err: datastore.ErrNoSuchEntity}, true},
+		{&datastore.ErrFieldMismatch{FieldName: "Name"}, false},
+		{appengine.MultiError{nil, datastore.ErrNoSuchEntity}, true},
+		{appengine.MultiError{datastore.ErrNoSuchEntity, errors.New("failed")}, false},
+		{appengine.MultiError{nil, nil}, false},
+	}
+	for _, test := range tests {
+		if got := IsNotFound(test.Err); got != test.Expected {
+			t.Fatalf("Expected IsNotFound(%v) to be [%v] but got [%v]", test.Err, test.Expected, got)
+		}
+	}
+}
+
+type nilKeyObject struct{}
+
+func (nilKeyObject) Key(ctx context.Context) *datastore.Key {
+	return nil
+}
+
+func TestSentinelErrors(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	if _, err := s.Put(ctx, nilKeyObject{}); !errors.Is(err, ErrNilKey) {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrNilKey, err)
+	}
+	if err := s.Get(ctx, &object{ID: "missing"}); !errors.Is(err, ErrNoSuchEntity) {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrNoSuchEntity, err)
+	}
+
+	o := &object{ID: "1", Name: "John"}
+	key, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Name = strings.Repeat("x", maxCacheItemSize)
+	if _, err := s.Put(ctx, o); !errors.Is(err, ErrOversizedCacheItem) {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrOversizedCacheItem, err)
+	}
+	if _, ok := cacher[key.Encode()]; ok {
+		t.Fatalf("Expected the stale cached copy to be removed")
+	}
+
+	q := datastore.NewQuery("object")
+	var notEntities []struct{ Name string }
+	for _, dst := range []interface{}{[]object{}, &[]int{}, &notEntities} {
+		if _, err := s.Query(ctx, q, dst); !errors.Is(err, ErrInvalidEntityType) {
+			t.Fatalf("Expected error [%v] for [%T] but got [%v]", ErrInvalidEntityType, dst, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// EventType identifies the kind of change an Event describes
+type EventType int
+
+const (
+	EventCreate EventType = iota + 1
+	EventUpdate
+	EventDelete
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCreate:
+		return "Create"
+	case EventUpdate:
+		return "Update"
+	case EventDelete:
+		return "Delete"
+	}
+	return "Unknown"
+}
+
+// Event describes a change made to an entity through a store
+type Event struct {
+	Type   EventType
+	Kind   string
+	Key    *datastore.Key
+	Entity Entity
+
+	// Context is the context of the operation that made the change
+	Context context.Context
+}
+
+// Subscribe registers fn to be called after every create, update and
+// delete of the given kind made through the store. An empty kind
+// subscribes to changes of every kind. Subscribers are called
+// synchronously, in the order they subscribed.
+func (s *Client) Subscribe(kind string, fn func(Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[string][]func(Event))
+	}
+	s.subscribers[kind] = append(s.subscribers[kind], fn)
+}
+
+func (s *Client) subscribed(kind string) []func(Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.subscribers) == 0 {
+		return nil
+	}
+	var fns []func(Event)
+	fns = append(fns, s.subscribers[kind]...)
+	return append(fns, s.subscribers[""]...)
+}
+
+func (s *Client) publish(ctx context.Context, t EventType, key *datastore.Key, e Entity) {
+	ev := Event{
+		Type:    t,
+		Kind:    key.Kind(),
+		Key:     key,
+		Entity:  e,
+		Context: ctx,
+	}
+	for _, fn := range s.subscribed(ev.Kind) {
+		fn(ev)
+	}
+	s.syncSearch(ctx, t, key, e)
+	s.syncTokens(ctx, t, key, e)
+	s.logChange(ctx, t, key)
+	s.notifyWebhooks(ctx, t, key, e)
+}
+
+// putEventType determines whether writing key creates or updates an
+// entity. It costs a datastore read so is only used when there are
+// subscribers for the kind.
+func (s *Client) putEventType(ctx context.Context, key *datastore.Key) (EventType, error) {
+	if key.Incomplete() {
+		return EventCreate, nil
+	}
+	var props datastore.PropertyList
+	err := s.datastoreGet(ctx, key, &props)
+	switch err {
+	case nil:
+		return EventUpdate, nil
+	case datastore.ErrNoSuchEntity:
+		return EventCreate, nil
+	}
+	if _, ok := err.(*datastore.ErrFieldMismatch); ok {
+		return EventUpdate, nil
+	}
+	return 0, err
+}
@@ -0,0 +1,49 @@
+package gaestore
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+)
+
+func TestSubscribe(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	var kindEvents, allEvents []string
+	s := NewStore()
+	s.Subscribe("object", func(ev Event) {
+		kindEvents = append(kindEvents, ev.Type.String()+":"+ev.Key.StringID())
+	})
+	s.Subscribe("", func(ev Event) {
+		allEvents = append(allEvents, ev.Kind+":"+ev.Type.String())
+	})
+
+	o := &object{ID: "1", Name: "John"}
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	o.Name = "Winston"
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, &hookObject{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Create:1", "Update:1", "Delete:1"}
+	if !reflect.DeepEqual(kindEvents, expected) {
+		t.Fatalf("Expected events [%v] but got [%v]", expected, kindEvents)
+	}
+	expected = []string{"object:Create", "object:Update", "hookObject:Create", "object:Delete"}
+	if !reflect.DeepEqual(allEvents, expected) {
+		t.Fatalf("Expected events [%v] but got [%v]", expected, allEvents)
+	}
+}
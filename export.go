@@ -0,0 +1,215 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// ExportKind is the kind export checkpoints are stored under
+const ExportKind = "GaestoreExport"
+
+// Bucket stores the objects written by an Exporter and read by an
+// Importer. The gcs package provides one for a Cloud Storage bucket.
+type Bucket interface {
+	// NewWriter returns a writer that creates or replaces the object name
+	// when closed
+	NewWriter(ctx context.Context, name string) io.WriteCloser
+
+	// NewReader returns a reader of the object name
+	NewReader(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// Exporter writes every entity of a kind to a bucket as JSON lines, one
+// object per chunk of entities. Each line holds the encoded key and the
+// entity, in the JSON of its registered type or, for kinds that aren't
+// registered, as an object of its properties:
+//
+//	{"key":"ahB0ZXN0YXBw...","entity":{"Name":"John"}}
+//
+// Entities are scrubbed of personal data before they're written, as set
+// by their pii tags and the kind's scrubbers; see RegisterScrubber.
+//
+// Progress is checkpointed after each object, so an export interrupted by
+// a deadline resumes from the last object when run again, for example by
+// a retried task.
+type Exporter struct {
+	// ChunkSize is the number of entities in each object, 1000 unless set
+	ChunkSize int
+
+	// HashKey keys the hashes of fields tagged `pii:"hash"`, so they can't
+	// be reversed by hashing guesses. Without it hashes are plain SHA-256.
+	HashKey []byte
+
+	s      *Client
+	bucket Bucket
+	kind   string
+	prefix string
+}
+
+// ExportProgress is the checkpoint of an export
+type ExportProgress struct {
+	Cursor   string `datastore:",noindex"`
+	Objects  int
+	Entities int
+	Done     bool
+}
+
+// NewExporter returns an exporter of kind writing the objects
+// prefix-00000.ndjson, prefix-00001.ndjson and so on to b. The prefix also
+// identifies the export's checkpoint, so use a new prefix for each export.
+func (s *Client) NewExporter(b Bucket, kind, prefix string) *Exporter {
+	return &Exporter{s: s, bucket: b, kind: kind, prefix: prefix}
+}
+
+// Export writes the objects not yet written, returning ctx's error if it
+// ends first. Calling Export once it's done does nothing.
+func (x *Exporter) Export(ctx context.Context) error {
+	ctx, err := x.s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := x.checkpointKey(ctx)
+	var p ExportProgress
+	if err := x.s.datastoreGet(ctx, key, &p); err != nil && err != datastore.ErrNoSuchEntity {
+		return err
+	}
+	for !p.Done {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := x.exportChunk(ctx, &p); err != nil {
+			return err
+		}
+		if _, err := x.s.datastorePut(ctx, key, &p); err != nil {
+			return err
+		}
+		x.s.log().Debugf(ctx, "gaestore: exported %d entities of [%s] to %d objects", p.Entities, x.kind, p.Objects)
+	}
+	return nil
+}
+
+// Progress returns the export's last checkpoint
+func (x *Exporter) Progress(ctx context.Context) (ExportProgress, error) {
+	var p ExportProgress
+	ctx, err := x.s.withNamespace(ctx)
+	if err != nil {
+		return p, err
+	}
+	err = x.s.datastoreGet(ctx, x.checkpointKey(ctx), &p)
+	if err == datastore.ErrNoSuchEntity {
+		err = nil
+	}
+	return p, err
+}
+
+func (x *Exporter) checkpointKey(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, ExportKind, x.prefix, 0, nil)
+}
+
+func (x *Exporter) chunkSize() int {
+	if x.ChunkSize <= 0 {
+		return 1000
+	}
+	return x.ChunkSize
+}
+
+// exportChunk writes the next object and advances p past it
+func (x *Exporter) exportChunk(ctx context.Context, p *ExportProgress) error {
+	q := datastore.NewQuery(x.kind).Limit(x.chunkSize())
+	if p.Cursor != "" {
+		c, err := datastore.DecodeCursor(p.Cursor)
+		if err != nil {
+			return err
+		}
+		q = q.Start(c)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	it := x.s.ds().RunQuery(ctx, q)
+	n := 0
+	for {
+		dst, err := exportValue(x.kind)
+		if err != nil {
+			return err
+		}
+		key, err := it.Next(withNormalized(dst))
+		if err == datastore.Done {
+			break
+		}
+		if err != nil && !IsFieldMismatch(err) {
+			return err
+		}
+		if err := x.s.decodeEntity(ctx, key, dst); err != nil {
+			return fmt.Errorf("Unable to decode [%v]: %v", key, err)
+		}
+		if err := scrub(ctx, key, dst, x.HashKey); err != nil {
+			return fmt.Errorf("Unable to scrub [%v]: %v", key, err)
+		}
+		if err := enc.Encode(exportLine{Key: key, Entity: dst}); err != nil {
+			return fmt.Errorf("Unable to encode [%v]: %v", key, err)
+		}
+		n++
+	}
+	if n > 0 {
+		w := x.bucket.NewWriter(ctx, fmt.Sprintf("%s-%05d.ndjson", x.prefix, p.Objects))
+		if _, err := buf.WriteTo(w); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		p.Objects++
+		p.Entities += n
+	}
+	c, err := it.Cursor()
+	if err != nil {
+		return err
+	}
+	p.Cursor = c.String()
+	p.Done = n < x.chunkSize()
+	return nil
+}
+
+// exportLine is a line of an exported object
+type exportLine struct {
+	Key    *datastore.Key `json:"key"`
+	Entity interface{}    `json:"entity"`
+}
+
+// exportValue returns the value to load an exported entity of kind into
+func exportValue(kind string) (interface{}, error) {
+	registry.RLock()
+	_, ok := registry.kinds[kind]
+	registry.RUnlock()
+	if !ok {
+		return propertyMap{}, nil
+	}
+	return newEntity(kind)
+}
+
+// propertyMap holds the properties of an entity of an unregistered kind,
+// with the values of multiple properties in a slice
+type propertyMap map[string]interface{}
+
+func (m propertyMap) Load(props []datastore.Property) error {
+	for _, p := range props {
+		if !p.Multiple {
+			m[p.Name] = p.Value
+			continue
+		}
+		values, _ := m[p.Name].([]interface{})
+		m[p.Name] = append(values, p.Value)
+	}
+	return nil
+}
+
+func (m propertyMap) Save() ([]datastore.Property, error) {
+	return nil, errors.New("gaestore: property maps are read only")
+}
@@ -0,0 +1,104 @@
+package gaestore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type exported struct {
+	ID   string `datastore:"-" json:"-"`
+	Name string
+}
+
+func (e *exported) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "exported", e.ID, 0, nil)
+}
+
+func (e *exported) AfterGet(ctx context.Context, key *datastore.Key) error {
+	e.ID = key.StringID()
+	return nil
+}
+
+func init() {
+	gaestore.Register("exported", &exported{})
+}
+
+var errUpload = errors.New("upload failed")
+
+// failingBucket fails to write the object numbered fail
+type failingBucket struct {
+	*gaestoretest.Bucket
+	fail   int
+	writes int
+}
+
+func (b *failingBucket) NewWriter(ctx context.Context, name string) io.WriteCloser {
+	b.writes++
+	if b.writes-1 == b.fail {
+		return failingWriter{}
+	}
+	return b.Bucket.NewWriter(ctx, name)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (failingWriter) Close() error                { return errUpload }
+
+func TestExporter(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	for i := 0; i < 5; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i), Name: fmt.Sprint("name", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An export failing on its second object resumes from there
+	b := gaestoretest.NewBucket()
+	fb := &failingBucket{Bucket: b, fail: 1}
+	x := s.NewExporter(fb, "exported", "backup")
+	x.ChunkSize = 2
+	if err := x.Export(ctx); err != errUpload {
+		t.Fatalf("Expected error [%v] but got [%v]", errUpload, err)
+	}
+	if err := x.Export(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fb.writes != 4 {
+		t.Fatalf("Expected [4] objects to be written but got [%d]", fb.writes)
+	}
+	p, err := x.Progress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done || p.Entities != 5 || p.Objects != 3 {
+		t.Fatalf("Expected 5 entities in 3 objects but got [%+v]", p)
+	}
+	expected := []string{"backup-00000.ndjson", "backup-00001.ndjson", "backup-00002.ndjson"}
+	if names := b.Names(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected [%v] but got [%v]", expected, names)
+	}
+
+	data, _ := b.Object("backup-00002.ndjson")
+	var line struct {
+		Key    *datastore.Key
+		Entity exported
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Key.StringID() != "4" || line.Entity.Name != "name4" {
+		t.Fatalf("Expected entity [4] but got [%v] [%+v]", line.Key, line.Entity)
+	}
+}
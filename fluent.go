@@ -0,0 +1,136 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// Ref refers to an entity by kind and ID, for reading and writing values
+// that don't implement Entity. Refs are immutable; each method returns a
+// new Ref.
+//
+//	var u User
+//	err := gaestore.Kind("User").StringID("42").Get(ctx, &u)
+type Ref struct {
+	s        *Client
+	kind     string
+	stringID string
+	intID    int64
+	parent   *datastore.Key
+}
+
+// Kind returns a Ref to an entity of kind in the default store
+func Kind(kind string) *Ref {
+	return defaultStore.Kind(kind)
+}
+
+// Kind returns a Ref to an entity of kind in the store
+func (s *Client) Kind(kind string) *Ref {
+	return &Ref{s: s, kind: kind}
+}
+
+// StringID returns a Ref to the entity with the string id
+func (r *Ref) StringID(id string) *Ref {
+	c := *r
+	c.stringID, c.intID = id, 0
+	return &c
+}
+
+// IntID returns a Ref to the entity with the integer id
+func (r *Ref) IntID(id int64) *Ref {
+	c := *r
+	c.stringID, c.intID = "", id
+	return &c
+}
+
+// Parent returns a Ref to the entity with the parent key
+func (r *Ref) Parent(parent *datastore.Key) *Ref {
+	c := *r
+	c.parent = parent
+	return &c
+}
+
+// Key returns the key referred to, which is incomplete when no ID is set
+func (r *Ref) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, r.kind, r.stringID, r.intID, r.parent)
+}
+
+// Get loads the entity into dst, a struct pointer or PropertyLoadSaver
+func (r *Ref) Get(ctx context.Context, dst interface{}, opts ...CallOption) error {
+	return r.s.Get(ctx, &refEntity{r, dst}, opts...)
+}
+
+// Put saves src, a struct pointer or PropertyLoadSaver, allocating an ID
+// when none is set
+func (r *Ref) Put(ctx context.Context, src interface{}, opts ...CallOption) (*datastore.Key, error) {
+	return r.s.Put(ctx, &refEntity{r, src}, opts...)
+}
+
+// Delete deletes the entity
+func (r *Ref) Delete(ctx context.Context, opts ...CallOption) error {
+	return r.s.Delete(ctx, &refEntity{ref: r}, opts...)
+}
+
+// refEntity is the Entity for a value read or written through a Ref. It
+// saves, loads, caches and runs the hooks of the value.
+type refEntity struct {
+	ref *Ref
+	v   interface{}
+}
+
+func (e *refEntity) Key(ctx context.Context) *datastore.Key {
+	return e.ref.Key(ctx)
+}
+
+func (e *refEntity) Load(props []datastore.Property) error {
+	if pls, ok := e.v.(datastore.PropertyLoadSaver); ok {
+		return pls.Load(props)
+	}
+	return datastore.LoadStruct(e.v, props)
+}
+
+func (e *refEntity) Save() ([]datastore.Property, error) {
+	if pls, ok := e.v.(datastore.PropertyLoadSaver); ok {
+		return pls.Save()
+	}
+	return datastore.SaveStruct(e.v)
+}
+
+func (e *refEntity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.v)
+}
+
+func (e *refEntity) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, e.v)
+}
+
+func (e *refEntity) BeforePut(ctx context.Context) error {
+	if putter, ok := e.v.(BeforePutter); ok {
+		return putter.BeforePut(ctx)
+	}
+	return nil
+}
+
+func (e *refEntity) AfterPut(ctx context.Context, key *datastore.Key) error {
+	if putter, ok := e.v.(AfterPutter); ok {
+		return putter.AfterPut(ctx, key)
+	}
+	return nil
+}
+
+func (e *refEntity) AfterGet(ctx context.Context, key *datastore.Key) error {
+	if getter, ok := e.v.(AfterGetter); ok {
+		return getter.AfterGet(ctx, key)
+	}
+	return nil
+}
+
+// cacheValue returns the value cached for e
+func cacheValue(e interface{}) interface{} {
+	if re, ok := e.(*refEntity); ok {
+		return re.v
+	}
+	return e
+}
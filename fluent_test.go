@@ -0,0 +1,45 @@
+package gaestore
+
+import (
+	"testing"
+)
+
+type plain struct {
+	Name string
+}
+
+func TestRef(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	ref := s.Kind("plain").StringID("42")
+	if key := ref.Key(ctx); key.Kind() != "plain" || key.StringID() != "42" {
+		t.Fatalf("Expected key [plain 42] but got [%v]", key)
+	}
+	if key := ref.IntID(7).Key(ctx); key.StringID() != "" || key.IntID() != 7 {
+		t.Fatalf("Expected key [plain 7] but got [%v]", key)
+	}
+
+	if _, err := ref.Put(ctx, &plain{Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	if string(cacher[ref.Key(ctx).Encode()]) != `{"Name":"John"}` {
+		t.Fatalf("Expected the value to be cached but got [%s]", cacher[ref.Key(ctx).Encode()])
+	}
+	delete(cacher, ref.Key(ctx).Encode())
+
+	var got plain
+	if err := ref.Get(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "John" {
+		t.Fatalf("Expected name [John] but got [%s]", got.Name)
+	}
+	if err := ref.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ref.Get(ctx, &got); !IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+}
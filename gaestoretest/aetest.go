@@ -0,0 +1,110 @@
+package gaestoretest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// deleteBatch is the most keys deleted by one datastore call
+const deleteBatch = 500
+
+// shared is the dev server started by SharedAEContext, which is started
+// once per test binary as starting it takes seconds
+var shared struct {
+	sync.Mutex
+	inst aetest.Instance
+	err  error
+}
+
+// Main runs the tests of a package and stops the dev server started by
+// SharedAEContext before exiting. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		gaestoretest.Main(m)
+//	}
+func Main(m *testing.M) {
+	code := m.Run()
+	shared.Lock()
+	if shared.inst != nil {
+		shared.inst.Close()
+		shared.inst = nil
+	}
+	shared.Unlock()
+	os.Exit(code)
+}
+
+// SharedAEContext is like NewAEContext, but the dev server is started on
+// first use and then shared by every test of the binary, which must stop
+// it with Main. When the test ends the entities it wrote, in every kind
+// and namespace, are deleted and memcache is flushed, so tests using the
+// server can't see each other's data. They must not run in parallel.
+func SharedAEContext(t testing.TB) context.Context {
+	shared.Lock()
+	if shared.inst == nil && shared.err == nil {
+		shared.inst, shared.err = aetest.NewInstance(&aetest.Options{StronglyConsistentDatastore: true})
+	}
+	inst, err := shared.inst, shared.err
+	shared.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := inst.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := appengine.NewContext(r)
+	t.Cleanup(func() {
+		if err := clearAEContext(ctx); err != nil {
+			t.Errorf("Unable to clear the dev server: %v", err)
+		}
+	})
+	return ctx
+}
+
+// clearAEContext deletes every entity stored in the dev server of ctx and
+// flushes memcache
+func clearAEContext(ctx context.Context) error {
+	namespaces, err := datastore.Namespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		nctx, err := appengine.Namespace(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		kinds, err := datastore.Kinds(nctx)
+		if err != nil {
+			return err
+		}
+		for _, kind := range kinds {
+			if strings.HasPrefix(kind, "__") {
+				continue
+			}
+			keys, err := datastore.NewQuery(kind).KeysOnly().GetAll(nctx, nil)
+			if err != nil {
+				return err
+			}
+			for len(keys) > 0 {
+				n := len(keys)
+				if n > deleteBatch {
+					n = deleteBatch
+				}
+				if err := datastore.DeleteMulti(nctx, keys[:n]); err != nil {
+					return err
+				}
+				keys = keys[n:]
+			}
+		}
+	}
+	return memcache.Flush(ctx)
+}
@@ -0,0 +1,30 @@
+package gaestoretest
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+func TestMain(m *testing.M) {
+	Main(m)
+}
+
+func TestSharedAEContext(t *testing.T) {
+	t.Run("write", func(t *testing.T) {
+		ctx := SharedAEContext(t)
+		if _, err := datastore.Put(ctx, datastore.NewKey(ctx, "person", "1", 0, nil), &person{Name: "John"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("read", func(t *testing.T) {
+		ctx := SharedAEContext(t)
+		n, err := datastore.NewQuery("person").Count(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Fatalf("Expected the previous test's entities to be deleted but got [%d]", n)
+		}
+	})
+}
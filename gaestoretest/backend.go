@@ -0,0 +1,292 @@
+package gaestoretest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// Backend is an in-memory gaestore.Backend. Keyed reads and ancestor
+// queries are strongly consistent. Other queries read an index which, as
+// on the datastore, may lag behind writes; see SetConsistency and
+// SetIndexDelay.
+type Backend struct {
+	mu       sync.Mutex
+	entities map[string]*record
+	index    map[string]*record
+	nextID   int64
+
+	// pending holds the time of each write not yet visible to queries
+	pending map[string]time.Time
+
+	// applied is the probability a write is visible to non ancestor
+	// queries as soon as it's made
+	applied float64
+
+	// delay is how long writes take to become visible when they aren't
+	// at once, or until they're applied when zero
+	delay time.Duration
+	clock gaestore.Clock
+}
+
+var (
+	_ gaestore.Backend     = (*Backend)(nil)
+	_ gaestore.MultiGetter = (*Backend)(nil)
+)
+
+// record is a stored entity
+type record struct {
+	key   *datastore.Key
+	props []datastore.Property
+}
+
+// NewBackend returns an empty backend whose writes are immediately visible
+// to every query
+func NewBackend() *Backend {
+	return &Backend{
+		entities: map[string]*record{},
+		index:    map[string]*record{},
+		pending:  map[string]time.Time{},
+		applied:  1,
+	}
+}
+
+// SetConsistency sets the probability, between 0 and 1, that a write is
+// visible to non ancestor queries as soon as it's made, like the dev
+// server's datastore consistency policy. Writes that are not yet visible
+// are applied when their key is read.
+func (b *Backend) SetConsistency(applied float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.applied = applied
+}
+
+// SetIndexDelay makes writes that aren't visible to non ancestor queries
+// when they're made, as set by SetConsistency, become visible once d has
+// passed, like the datastore applying writes in the background. Without a
+// delay they're only visible once their key is read or they're applied.
+//
+//	b.SetConsistency(0)
+//	b.SetIndexDelay(time.Second)
+func (b *Backend) SetIndexDelay(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.delay = d
+}
+
+// SetClock sets the clock index delays are measured with, the system
+// clock by default
+func (b *Backend) SetClock(clock gaestore.Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+}
+
+// now returns the time of the clock. The caller must hold b.mu.
+func (b *Backend) now() time.Time {
+	if b.clock == nil {
+		return time.Now()
+	}
+	return b.clock.Now()
+}
+
+// applyDelayed makes the writes older than the index delay visible to
+// queries. The caller must hold b.mu.
+func (b *Backend) applyDelayed() {
+	if b.delay <= 0 {
+		return
+	}
+	now := b.now()
+	for k, written := range b.pending {
+		if !now.Before(written.Add(b.delay)) {
+			b.apply(k)
+		}
+	}
+}
+
+// Apply makes the writes to keys, or every write when none are given,
+// visible to queries
+func (b *Backend) Apply(keys ...*datastore.Key) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(keys) == 0 {
+		for k := range b.pending {
+			b.apply(k)
+		}
+		return
+	}
+	for _, key := range keys {
+		b.apply(key.Encode())
+	}
+}
+
+// Len returns the number of stored entities
+func (b *Backend) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entities)
+}
+
+func (b *Backend) Get(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	if key == nil || key.Incomplete() {
+		return datastore.ErrInvalidKey
+	}
+	b.mu.Lock()
+	k := key.Encode()
+	b.apply(k)
+	r, ok := b.entities[k]
+	b.mu.Unlock()
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	return load(dst, r.props)
+}
+
+// GetMulti gets each of keys into dst, a slice of pointers, failing with
+// an appengine.MultiError as the datastore does
+func (b *Backend) GetMulti(ctx context.Context, keys []*datastore.Key, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Slice || v.Len() != len(keys) {
+		return errors.New("gaestoretest: keys and dst have different lengths")
+	}
+	var merr appengine.MultiError
+	for i, key := range keys {
+		if err := b.Get(ctx, key, v.Index(i).Interface()); err != nil {
+			if merr == nil {
+				merr = make(appengine.MultiError, len(keys))
+			}
+			merr[i] = err
+		}
+	}
+	if merr != nil {
+		return merr
+	}
+	return nil
+}
+
+func (b *Backend) Put(ctx context.Context, key *datastore.Key, src interface{}) (*datastore.Key, error) {
+	if key == nil {
+		return nil, datastore.ErrInvalidKey
+	}
+	props, err := save(src)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if key.Incomplete() {
+		if key, err = b.allocate(key); err != nil {
+			return nil, err
+		}
+	}
+	r := &record{key: key, props: props}
+	if tx := transaction(ctx); tx != nil {
+		tx.writes[key.Encode()] = r
+		return key, nil
+	}
+	b.write(key.Encode(), r)
+	return key, nil
+}
+
+func (b *Backend) Delete(ctx context.Context, key *datastore.Key) error {
+	if key == nil || key.Incomplete() {
+		return datastore.ErrInvalidKey
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if tx := transaction(ctx); tx != nil {
+		tx.writes[key.Encode()] = nil
+		return nil
+	}
+	b.write(key.Encode(), nil)
+	return nil
+}
+
+// RunInTransaction runs fn, applying its writes when it returns without
+// an error. Reads made by fn don't see its own writes, as on the
+// datastore. Concurrent transactions are not detected.
+func (b *Backend) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	if transaction(ctx) != nil {
+		return datastore.ErrConcurrentTransaction
+	}
+	tx := &fakeTransaction{writes: map[string]*record{}}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for k, r := range tx.writes {
+		b.write(k, r)
+	}
+	return nil
+}
+
+// write stores r, or deletes the entity when r is nil. The caller must
+// hold b.mu.
+func (b *Backend) write(k string, r *record) {
+	if r == nil {
+		delete(b.entities, k)
+	} else {
+		b.entities[k] = r
+	}
+	b.pending[k] = b.now()
+	if b.applied >= 1 || rand.Float64() < b.applied {
+		b.apply(k)
+	}
+}
+
+// apply makes the latest write to k visible to queries. The caller must
+// hold b.mu.
+func (b *Backend) apply(k string) {
+	if _, ok := b.pending[k]; !ok {
+		return
+	}
+	delete(b.pending, k)
+	if r, ok := b.entities[k]; ok {
+		b.index[k] = r
+	} else {
+		delete(b.index, k)
+	}
+}
+
+// allocate completes an incomplete key. The caller must hold b.mu.
+func (b *Backend) allocate(key *datastore.Key) (*datastore.Key, error) {
+	b.nextID++
+	return appds.NewKey(key.AppID(), key.Namespace(), key.Kind(), "", b.nextID, key.Parent())
+}
+
+type txKey struct{}
+
+type fakeTransaction struct {
+	// writes holds the entities written, nil for deletes
+	writes map[string]*record
+}
+
+func transaction(ctx context.Context) *fakeTransaction {
+	tx, _ := ctx.Value(txKey{}).(*fakeTransaction)
+	return tx
+}
+
+func save(src interface{}) ([]datastore.Property, error) {
+	if pls, ok := src.(datastore.PropertyLoadSaver); ok {
+		return pls.Save()
+	}
+	return datastore.SaveStruct(src)
+}
+
+func load(dst interface{}, props []datastore.Property) error {
+	// Copy so a loader can't modify the stored properties
+	props = append([]datastore.Property(nil), props...)
+	if pls, ok := dst.(datastore.PropertyLoadSaver); ok {
+		return pls.Load(props)
+	}
+	return datastore.LoadStruct(dst, props)
+}
@@ -0,0 +1,250 @@
+package gaestoretest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+type person struct {
+	Parent *datastore.Key `datastore:"-"`
+	ID     string         `datastore:"-"`
+	Name   string
+	Age    int
+	Tags   []string
+}
+
+func (p *person) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "person", p.ID, 0, p.Parent)
+}
+
+func names(people []*person) []string {
+	var n []string
+	for _, p := range people {
+		n = append(n, p.Name)
+	}
+	return n
+}
+
+func TestStore(t *testing.T) {
+	ctx := NewContext()
+	s := NewStore(t)
+
+	p := &person{ID: "1", Name: "John", Age: 30}
+	if _, err := s.Put(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	got := &person{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("Expected [%+v] but got [%+v]", p, got)
+	}
+	if err := s.Delete(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &person{ID: "1"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx := NewContext()
+	s := NewStore(t)
+	for _, p := range []*person{
+		{ID: "1", Name: "Ann", Age: 30, Tags: []string{"a", "b"}},
+		{ID: "2", Name: "Bob", Age: 20, Tags: []string{"b"}},
+		{ID: "3", Name: "Cat", Age: 40},
+		{ID: "4", Name: "Dan", Age: 25},
+	} {
+		if _, err := s.Put(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		q        *datastore.Query
+		expected []string
+	}{
+		{datastore.NewQuery("person"), []string{"Ann", "Bob", "Cat", "Dan"}},
+		{datastore.NewQuery("person").Filter("Age >=", 25).Order("-Age"), []string{"Cat", "Ann", "Dan"}},
+		{datastore.NewQuery("person").Filter("Tags =", "b").Order("Name"), []string{"Ann", "Bob"}},
+		{datastore.NewQuery("person").Order("Age").Offset(1).Limit(2), []string{"Dan", "Ann"}},
+		{datastore.NewQuery("other"), nil},
+	}
+	for _, test := range tests {
+		var people []*person
+		if _, err := s.Query(ctx, test.q, &people); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(people); !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("Expected [%v] but got [%v]", test.expected, got)
+		}
+	}
+
+	// Continue from a cursor
+	q := datastore.NewQuery("person").Order("Name").Limit(3)
+	var page []*person
+	c, err := s.Query(ctx, q, &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page = nil
+	if _, err := s.Query(ctx, q.Start(c), &page); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(page); !reflect.DeepEqual(got, []string{"Dan"}) {
+		t.Fatalf("Expected [[Dan]] but got [%v]", got)
+	}
+}
+
+func TestEventualConsistency(t *testing.T) {
+	ctx := NewContext()
+	b := NewBackend()
+	b.SetConsistency(0)
+	s := NewStore(t, gaestore.WithBackend(b))
+
+	parent := datastore.NewKey(ctx, "group", "g", 0, nil)
+	p := &person{Parent: parent, ID: "1", Name: "Ann"}
+	if _, err := s.Put(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(q *datastore.Query) int {
+		var people []*person
+		if _, err := s.Query(ctx, q, &people); err != nil {
+			t.Fatal(err)
+		}
+		return len(people)
+	}
+	if n := count(datastore.NewQuery("person")); n != 0 {
+		t.Fatalf("Expected the write not to be applied but got [%d] results", n)
+	}
+	if n := count(datastore.NewQuery("person").Ancestor(parent)); n != 1 {
+		t.Fatalf("Expected ancestor queries to be consistent but got [%d] results", n)
+	}
+
+	// Reading the key applies the write
+	if err := b.Get(ctx, p.Key(ctx), &person{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(datastore.NewQuery("person")); n != 1 {
+		t.Fatalf("Expected the write to be applied but got [%d] results", n)
+	}
+}
+
+func TestIndexDelay(t *testing.T) {
+	ctx := NewContext()
+	clock := NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewBackend()
+	b.SetConsistency(0)
+	b.SetIndexDelay(time.Second)
+	b.SetClock(clock)
+	s := NewStore(t, gaestore.WithBackend(b))
+
+	if _, err := s.Put(ctx, &person{ID: "1", Name: "Ann"}); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		var people []*person
+		if _, err := s.Query(ctx, datastore.NewQuery("person"), &people); err != nil {
+			t.Fatal(err)
+		}
+		return len(people)
+	}
+	clock.Advance(999 * time.Millisecond)
+	if n := count(); n != 0 {
+		t.Fatalf("Expected the write not to be applied but got [%d] results", n)
+	}
+	clock.Advance(time.Millisecond)
+	if n := count(); n != 1 {
+		t.Fatalf("Expected the write to be applied after the delay but got [%d] results", n)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	ctx := NewContext()
+	b := NewBackend()
+	s := NewStore(t, gaestore.WithBackend(b))
+
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := s.Put(tc, &person{ID: "1"}); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected error [%v] but got [%v]", errAbort, err)
+	}
+	if b.Len() != 0 {
+		t.Fatalf("Expected the transaction's writes to be discarded but got [%d] entities", b.Len())
+	}
+
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		_, err := s.Put(tc, &person{ID: "1"})
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 1 {
+		t.Fatalf("Expected the transaction's writes to be applied but got [%d] entities", b.Len())
+	}
+}
+
+type page struct {
+	ID    string `datastore:"-"`
+	Views int
+}
+
+func (p *page) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "page", p.ID, 0, nil)
+}
+
+func TestCachedIncrement(t *testing.T) {
+	ctx := NewContext()
+	b := NewBackend()
+	s := NewStore(t, gaestore.WithBackend(b))
+	if _, err := s.Put(ctx, &page{ID: "home", Views: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The counter is seeded from the entity and doesn't write it
+	for i := 0; i < 3; i++ {
+		if _, err := s.Increment(ctx, &page{ID: "home"}, "Views", 1, gaestore.CachedIncrement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := s.Increment(ctx, &page{ID: "home"}, "Views", 1, gaestore.CachedIncrement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 14 {
+		t.Fatalf("Expected [14] views but got [%d]", n)
+	}
+	stored := &page{}
+	if err := b.Get(ctx, (&page{ID: "home"}).Key(ctx), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Views != 10 {
+		t.Fatalf("Expected [10] stored views but got [%d]", stored.Views)
+	}
+
+	got := &page{ID: "home"}
+	if err := s.FlushIncrement(ctx, got, "Views"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Get(ctx, got.Key(ctx), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Views != 14 || got.Views != 14 {
+		t.Fatalf("Expected [14] views to be flushed but got [%d] and [%d]", stored.Views, got.Views)
+	}
+}
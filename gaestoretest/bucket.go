@@ -0,0 +1,96 @@
+package gaestoretest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/floresj/gaestore"
+)
+
+// Bucket is an in-memory gaestore.Bucket
+type Bucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+var (
+	_ gaestore.BlobBucket = (*Bucket)(nil)
+	_ gaestore.URLSigner  = (*Bucket)(nil)
+)
+
+// NewBucket returns an empty bucket
+func NewBucket() *Bucket {
+	return &Bucket{objects: map[string][]byte{}}
+}
+
+// Object returns the contents of the object name
+func (b *Bucket) Object(name string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.objects[name]
+	return data, ok
+}
+
+// SetObject creates or replaces the object name
+func (b *Bucket) SetObject(name string, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[name] = append([]byte(nil), data...)
+}
+
+// Names returns the names of the objects in the bucket, sorted
+func (b *Bucket) Names() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for name := range b.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewWriter returns a writer storing the object once closed
+func (b *Bucket) NewWriter(ctx context.Context, name string) io.WriteCloser {
+	return &objectWriter{b: b, name: name}
+}
+
+type objectWriter struct {
+	bytes.Buffer
+	b    *Bucket
+	name string
+}
+
+func (w *objectWriter) Close() error {
+	w.b.mu.Lock()
+	defer w.b.mu.Unlock()
+	w.b.objects[w.name] = w.Bytes()
+	return nil
+}
+
+// NewReader returns a reader of the object name
+func (b *Bucket) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	data, ok := b.Object(name)
+	if !ok {
+		return nil, fmt.Errorf("gaestoretest: no object [%s]", name)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete deletes the object name, if it exists
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.objects, name)
+	return nil
+}
+
+// SignedURL returns a URL naming the object and ttl, which isn't signed
+func (b *Bucket) SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	return fmt.Sprintf("https://storage.test/%s?ttl=%v", name, ttl), nil
+}
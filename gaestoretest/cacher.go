@@ -0,0 +1,201 @@
+package gaestoretest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/memcache"
+)
+
+// Cacher is an in-memory gaestore.Cacher
+type Cacher struct {
+	mu    sync.Mutex
+	items map[string]cacheItem
+	cas   uint64
+	clock gaestore.Clock
+}
+
+var (
+	_ gaestore.Cacher      = (*Cacher)(nil)
+	_ gaestore.Incrementer = (*Cacher)(nil)
+	_ gaestore.ValueCopier = (*Cacher)(nil)
+	_ gaestore.Adder       = (*Cacher)(nil)
+	_ gaestore.Swapper     = (*Cacher)(nil)
+)
+
+type cacheItem struct {
+	value   []byte
+	expires time.Time
+
+	// cas changes with every write of the item
+	cas uint64
+}
+
+// store writes item, which must be called with c.mu held
+func (c *Cacher) store(key string, item cacheItem) {
+	c.cas++
+	item.cas = c.cas
+	c.items[key] = item
+}
+
+// live returns the item for key unless it has expired, which must be
+// called with c.mu held
+func (c *Cacher) live(key string) (cacheItem, bool) {
+	item, ok := c.items[key]
+	if ok && !item.expires.IsZero() && !c.now().Before(item.expires) {
+		delete(c.items, key)
+		return cacheItem{}, false
+	}
+	return item, ok
+}
+
+// SetClock sets the clock items expire by, the system clock by default
+func (c *Cacher) SetClock(clock gaestore.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clock
+}
+
+// now returns the time of the clock, which must be called with c.mu held
+func (c *Cacher) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// NewCacher returns an empty cacher
+func NewCacher() *Cacher {
+	return &Cacher{items: map[string]cacheItem{}}
+}
+
+// Len returns the number of cached items, including expired items not yet
+// read
+func (c *Cacher) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Flush removes every cached item
+func (c *Cacher) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = map[string]cacheItem{}
+}
+
+func (c *Cacher) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.live(key)
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return append([]byte(nil), item.value...), nil
+}
+
+func (c *Cacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	item := cacheItem{value: append([]byte(nil), value...)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ttl > 0 {
+		item.expires = c.now().Add(ttl)
+	}
+	c.store(key, item)
+	return nil
+}
+
+// Add stores value unless key is cached, returning memcache.ErrNotStored
+// when it is
+func (c *Cacher) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	item := cacheItem{value: append([]byte(nil), value...)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ttl > 0 {
+		item.expires = c.now().Add(ttl)
+	}
+	if _, ok := c.live(key); ok {
+		return memcache.ErrNotStored
+	}
+	c.store(key, item)
+	return nil
+}
+
+// GetForSwap returns the value of key with its current revision as the
+// token for CompareAndSwap
+func (c *Cacher) GetForSwap(ctx context.Context, key string) ([]byte, interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.live(key)
+	if !ok {
+		return nil, nil, memcache.ErrCacheMiss
+	}
+	return append([]byte(nil), item.value...), item.cas, nil
+}
+
+// CompareAndSwap stores value if key hasn't been written since token was
+// returned by GetForSwap
+func (c *Cacher) CompareAndSwap(ctx context.Context, key string, value []byte, ttl time.Duration, token interface{}) error {
+	item := cacheItem{value: append([]byte(nil), value...)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ttl > 0 {
+		item.expires = c.now().Add(ttl)
+	}
+	old, ok := c.live(key)
+	if !ok {
+		return memcache.ErrNotStored
+	}
+	if old.cas != token {
+		return memcache.ErrCASConflict
+	}
+	c.store(key, item)
+	return nil
+}
+
+// CopiesValues is true as Set stores a copy of the value
+func (c *Cacher) CopiesValues() bool {
+	return true
+}
+
+func (c *Cacher) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(c.items, key)
+	return nil
+}
+
+// Increment stores counters as decimal strings, floored at zero like
+// memcache
+func (c *Cacher) Increment(ctx context.Context, key string, delta int64, initial uint64) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := initial
+	item, ok := c.items[key]
+	if ok && (item.expires.IsZero() || c.now().Before(item.expires)) {
+		var err error
+		if n, err = strconv.ParseUint(string(item.value), 10, 64); err != nil {
+			return 0, fmt.Errorf("gaestoretest: cannot increment non-numeric value [%s]", item.value)
+		}
+	} else {
+		item = cacheItem{}
+	}
+	switch {
+	case delta >= 0:
+		n += uint64(delta)
+	case uint64(-delta) > n:
+		n = 0
+	default:
+		n -= uint64(-delta)
+	}
+	item.value = []byte(strconv.FormatUint(n, 10))
+	c.store(key, item)
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package gaestoretest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+func TestCacherCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	c := NewCacher()
+
+	if _, _, err := c.GetForSwap(ctx, "key"); err != memcache.ErrCacheMiss {
+		t.Fatalf("Expected [%v] but got [%v]", memcache.ErrCacheMiss, err)
+	}
+	c.Set(ctx, "key", []byte("a"), 0)
+	_, token, err := c.GetForSwap(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set(ctx, "key", []byte("b"), 0)
+	if err := c.CompareAndSwap(ctx, "key", []byte("c"), 0, token); err != memcache.ErrCASConflict {
+		t.Fatalf("Expected [%v] but got [%v]", memcache.ErrCASConflict, err)
+	}
+
+	value, token, _ := c.GetForSwap(ctx, "key")
+	if err := c.CompareAndSwap(ctx, "key", []byte("c"), 0, token); err != nil || string(value) != "b" {
+		t.Fatalf("Expected to swap [b] but got [%s] %v", value, err)
+	}
+	c.Delete(ctx, "key")
+	if err := c.CompareAndSwap(ctx, "key", []byte("d"), 0, token); err != memcache.ErrNotStored {
+		t.Fatalf("Expected [%v] but got [%v]", memcache.ErrNotStored, err)
+	}
+}
+
+func TestCacherClock(t *testing.T) {
+	ctx := context.Background()
+	clock := NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCacher()
+	c.SetClock(clock)
+
+	c.Set(ctx, "key", []byte("a"), time.Minute)
+	clock.Advance(59 * time.Second)
+	if _, err := c.Get(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if _, err := c.Get(ctx, "key"); err != memcache.ErrCacheMiss {
+		t.Fatalf("Expected [%v] but got [%v]", memcache.ErrCacheMiss, err)
+	}
+}
@@ -0,0 +1,42 @@
+package gaestoretest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/floresj/gaestore"
+)
+
+// Clock is a gaestore.Clock whose time only changes when it's set or
+// advanced
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ gaestore.Clock = (*Clock)(nil)
+
+// NewClock returns a clock stopped at now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set stops the clock at now
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
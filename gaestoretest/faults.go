@@ -0,0 +1,164 @@
+package gaestoretest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/floresj/gaestore"
+)
+
+// corruptValue is returned by reads programmed to be corrupt, and can't
+// be decoded as an entity
+var corruptValue = []byte("\xffcorrupt")
+
+// Fault is a failure a FaultyCacher is programmed to make
+type Fault struct {
+	// Method is the Cacher method failing, such as "Get" or "Set", or
+	// every method when empty
+	Method string
+
+	// Key is the key failing, or every key when empty
+	Key string
+
+	// Err is returned in place of making the call, such as
+	// memcache.ErrCacheMiss to simulate an eviction
+	Err error
+
+	// Corrupt makes reads return a value that can't be decoded
+	Corrupt bool
+
+	// Latency delays the call, which fails with the context's error if it
+	// ends first
+	Latency time.Duration
+
+	// Times is the number of calls failing, after which the fault is
+	// removed, or every call when zero
+	Times int
+}
+
+// FaultyCacher is a Cacher that can be programmed to fail, for exercising
+// how a store handles misses, errors, corrupt values and slow calls.
+// Calls without a fault are made on the embedded Cacher.
+type FaultyCacher struct {
+	*Cacher
+
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+var (
+	_ gaestore.Cacher      = (*FaultyCacher)(nil)
+	_ gaestore.Incrementer = (*FaultyCacher)(nil)
+	_ gaestore.Adder       = (*FaultyCacher)(nil)
+	_ gaestore.Swapper     = (*FaultyCacher)(nil)
+)
+
+// NewFaultyCacher returns an empty cacher without faults
+func NewFaultyCacher() *FaultyCacher {
+	return &FaultyCacher{Cacher: NewCacher()}
+}
+
+// Inject adds f to the faults, which are matched against calls in the
+// order they were added
+func (c *FaultyCacher) Inject(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &f)
+}
+
+// Reset removes every fault
+func (c *FaultyCacher) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// fault applies the first fault matching a call of method with key,
+// returning whether its value is corrupt and the error to fail with
+func (c *FaultyCacher) fault(ctx context.Context, method, key string) (bool, error) {
+	c.mu.Lock()
+	var f Fault
+	for i, p := range c.faults {
+		if p.Method != "" && p.Method != method || p.Key != "" && p.Key != key {
+			continue
+		}
+		f = *p
+		if p.Times > 0 {
+			if p.Times--; p.Times == 0 {
+				c.faults = append(c.faults[:i], c.faults[i+1:]...)
+			}
+		}
+		break
+	}
+	c.mu.Unlock()
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	return f.Corrupt, f.Err
+}
+
+func (c *FaultyCacher) Get(ctx context.Context, key string) ([]byte, error) {
+	corrupt, err := c.fault(ctx, "Get", key)
+	if err != nil {
+		return nil, err
+	}
+	if corrupt {
+		return append([]byte(nil), corruptValue...), nil
+	}
+	return c.Cacher.Get(ctx, key)
+}
+
+func (c *FaultyCacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if _, err := c.fault(ctx, "Set", key); err != nil {
+		return err
+	}
+	return c.Cacher.Set(ctx, key, value, ttl)
+}
+
+func (c *FaultyCacher) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if _, err := c.fault(ctx, "Add", key); err != nil {
+		return err
+	}
+	return c.Cacher.Add(ctx, key, value, ttl)
+}
+
+func (c *FaultyCacher) Delete(ctx context.Context, key string) error {
+	if _, err := c.fault(ctx, "Delete", key); err != nil {
+		return err
+	}
+	return c.Cacher.Delete(ctx, key)
+}
+
+func (c *FaultyCacher) Increment(ctx context.Context, key string, delta int64, initial uint64) (uint64, error) {
+	if _, err := c.fault(ctx, "Increment", key); err != nil {
+		return 0, err
+	}
+	return c.Cacher.Increment(ctx, key, delta, initial)
+}
+
+// GetForSwap returns a corrupt value with a token that never swaps when
+// it's programmed to be corrupt
+func (c *FaultyCacher) GetForSwap(ctx context.Context, key string) ([]byte, interface{}, error) {
+	corrupt, err := c.fault(ctx, "GetForSwap", key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if corrupt {
+		return append([]byte(nil), corruptValue...), nil, nil
+	}
+	return c.Cacher.GetForSwap(ctx, key)
+}
+
+func (c *FaultyCacher) CompareAndSwap(ctx context.Context, key string, value []byte, ttl time.Duration, token interface{}) error {
+	if _, err := c.fault(ctx, "CompareAndSwap", key); err != nil {
+		return err
+	}
+	return c.Cacher.CompareAndSwap(ctx, key, value, ttl, token)
+}
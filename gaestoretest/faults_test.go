@@ -0,0 +1,48 @@
+package gaestoretest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/memcache"
+)
+
+func TestFaultyCacher(t *testing.T) {
+	ctx := NewContext()
+	c := NewFaultyCacher()
+	s := NewStore(t, gaestore.WithCacher(c))
+
+	if _, err := s.Put(ctx, &person{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Expected the entity to be cached but got [%d] items", c.Len())
+	}
+
+	// The store falls back to the datastore for failing and corrupt reads
+	c.Inject(Fault{Method: "Get", Err: errors.New("unavailable"), Times: 1})
+	c.Inject(Fault{Method: "Get", Corrupt: true, Times: 1})
+	for i := 0; i < 3; i++ {
+		p := &person{ID: "1"}
+		if err := s.Get(ctx, p); err != nil || p.Name != "John" {
+			t.Fatalf("Expected [John] but got [%+v] %v", p, err)
+		}
+	}
+
+	c.Inject(Fault{Key: "other", Err: memcache.ErrCacheMiss})
+	c.Set(ctx, "other", []byte("a"), 0)
+	if _, err := c.Get(ctx, "other"); err != memcache.ErrCacheMiss {
+		t.Fatalf("Expected [%v] but got [%v]", memcache.ErrCacheMiss, err)
+	}
+	c.Reset()
+
+	c.Inject(Fault{Latency: time.Hour})
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(tctx, "other"); err != context.DeadlineExceeded {
+		t.Fatalf("Expected [%v] but got [%v]", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,154 @@
+package gaestoretest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+	"gopkg.in/yaml.v3"
+)
+
+// FixtureOption changes how LoadFixtures loads fixtures
+type FixtureOption func(*fixtureOptions)
+
+type fixtureOptions struct {
+	backend *Backend
+}
+
+// ApplyIndex makes the fixtures visible to b's queries as soon as they're
+// loaded, whatever its consistency
+func ApplyIndex(b *Backend) FixtureOption {
+	return func(o *fixtureOptions) {
+		o.backend = b
+	}
+}
+
+// LoadFixtures reads fixtures from r and puts them with s, returning their
+// keys. Fixtures are YAML or JSON, listing entities by kind with their
+// fields set by Go field name. Kinds must be registered with
+// gaestore.Register, and are loaded in order of name.
+//
+//	User:
+//	  - ID: john
+//	    Name: John
+//	    Joined: 2020-01-02T15:04:05Z
+//	    Tags: [admin, staff]
+func LoadFixtures(ctx context.Context, s gaestore.Store, r io.Reader, opts ...FixtureOption) ([]*datastore.Key, error) {
+	var o fixtureOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var fixtures map[string][]map[string]interface{}
+	if err := yaml.NewDecoder(r).Decode(&fixtures); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("gaestoretest: invalid fixtures: %v", err)
+	}
+	kinds := make([]string, 0, len(fixtures))
+	for kind := range fixtures {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var keys []*datastore.Key
+	for _, kind := range kinds {
+		for i, fields := range fixtures[kind] {
+			e, err := gaestore.NewEntity(kind)
+			if err != nil {
+				return keys, err
+			}
+			if err := setFields(reflect.ValueOf(e).Elem(), fields); err != nil {
+				return keys, fmt.Errorf("gaestoretest: fixture %d of [%s]: %v", i, kind, err)
+			}
+			key, err := s.Put(ctx, e)
+			if err != nil {
+				return keys, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	if o.backend != nil && len(keys) > 0 {
+		o.backend.Apply(keys...)
+	}
+	return keys, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setFields sets the fields of the struct v by name
+func setFields(v reflect.Value, fields map[string]interface{}) error {
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot set fields of [%v]", v.Type())
+	}
+	for name, value := range fields {
+		f := v.FieldByName(name)
+		if !f.IsValid() || !f.CanSet() {
+			return fmt.Errorf("no field [%s] in [%v]", name, v.Type())
+		}
+		if err := setValue(f, value); err != nil {
+			return fmt.Errorf("field [%s]: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setValue sets f to a value decoded from a fixture
+func setValue(f reflect.Value, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch value := value.(type) {
+	case string:
+		if f.Type() == timeType {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return err
+			}
+			f.Set(reflect.ValueOf(t))
+			return nil
+		}
+	case []interface{}:
+		if f.Kind() != reflect.Slice {
+			break
+		}
+		s := reflect.MakeSlice(f.Type(), len(value), len(value))
+		for i, elem := range value {
+			if err := setValue(s.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		f.Set(s)
+		return nil
+	case map[string]interface{}:
+		if f.Kind() == reflect.Ptr {
+			f.Set(reflect.New(f.Type().Elem()))
+			f = f.Elem()
+		}
+		return setFields(f, value)
+	}
+	v := reflect.ValueOf(value)
+	if !compatible(v.Kind(), f.Kind()) || !v.Type().ConvertibleTo(f.Type()) {
+		return fmt.Errorf("cannot set [%v] from [%v]", f.Type(), v)
+	}
+	f.Set(v.Convert(f.Type()))
+	return nil
+}
+
+// compatible reports whether values of kind a may be converted to kind b,
+// which unlike reflect doesn't allow numbers to become strings
+func compatible(a, b reflect.Kind) bool {
+	return category(a) == category(b)
+}
+
+func category(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return k
+}
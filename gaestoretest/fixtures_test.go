@@ -0,0 +1,59 @@
+package gaestoretest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+func init() {
+	gaestore.Register("person", &person{})
+}
+
+const fixtures = `
+person:
+  - ID: john
+    Name: John
+    Age: 30
+    Tags: [admin, staff]
+  - ID: jane
+    Name: Jane
+`
+
+func TestLoadFixtures(t *testing.T) {
+	ctx := NewContext()
+	b := NewBackend()
+	b.SetConsistency(0)
+	s := NewStore(t, gaestore.WithBackend(b))
+
+	keys, err := LoadFixtures(ctx, s, strings.NewReader(fixtures), ApplyIndex(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Expected [2] keys but got [%v]", keys)
+	}
+	p := &person{ID: "john"}
+	if err := s.Get(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Age != 30 || !reflect.DeepEqual(p.Tags, []string{"admin", "staff"}) {
+		t.Fatalf("Expected the fixture to be loaded but got [%+v]", p)
+	}
+	var people []*person
+	if _, err := s.Query(ctx, datastore.NewQuery("person").Order("Name"), &people); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(people); !reflect.DeepEqual(got, []string{"Jane", "John"}) {
+		t.Fatalf("Expected the fixtures to be indexed but got [%v]", got)
+	}
+
+	// JSON is read as well
+	_, err = LoadFixtures(ctx, s, strings.NewReader(`{"person": [{"ID": "x", "Age": "old"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "Age") {
+		t.Fatalf("Expected an error setting Age but got [%v]", err)
+	}
+}
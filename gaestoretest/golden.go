@@ -0,0 +1,56 @@
+package gaestoretest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/floresj/gaestore"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set, makes
+// CheckGolden write golden files rather than compare against them:
+//
+//	GAESTORETEST_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "GAESTORETEST_UPDATE_GOLDEN"
+
+// CheckGolden encodes e, a pointer to an entity, with the codec s caches
+// entities with, and fails t unless it matches the golden file at path,
+// typically under testdata. The golden file must also decode into e's
+// type. Checking a fully populated entity of each cached type catches
+// field changes that would stop values cached by the previous release
+// decoding, or decoding the same, before they're deployed; changes that
+// are compatible are accepted by updating the golden files.
+func CheckGolden(t testing.TB, s *gaestore.Client, path string, e interface{}) {
+	t.Helper()
+	codec := s.Codec()
+	got, err := codec.Marshal(e)
+	if err != nil {
+		t.Fatalf("Unable to encode [%T]: %v", e, err)
+	}
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("Golden file [%s] doesn't exist, set %s to write it", path, UpdateGoldenEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := reflect.New(reflect.TypeOf(e).Elem()).Interface()
+	if err := codec.Unmarshal(want, dst); err != nil {
+		t.Fatalf("Golden file [%s] no longer decodes into [%T]: %v", path, e, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("[%T] encodes differently from golden file [%s], set %s to update it if cached values are compatible\ngot:  %s\nwant: %s", e, path, UpdateGoldenEnv, got, want)
+	}
+}
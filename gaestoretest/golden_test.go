@@ -0,0 +1,8 @@
+package gaestoretest
+
+import "testing"
+
+func TestCheckGolden(t *testing.T) {
+	s := NewStore(t)
+	CheckGolden(t, s, "testdata/person.golden", &person{ID: "1", Name: "John", Age: 30, Tags: []string{"a", "b"}})
+}
@@ -0,0 +1,322 @@
+package gaestoretest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+// MockStore is a gaestore.Store that records calls and answers them from
+// expectations set by a test. A call without a matching expectation fails
+// with an error.
+//
+//	m := gaestoretest.NewMockStore()
+//	m.ExpectGet(key).WillReturn(&User{Name: "John"})
+//	m.ExpectPut(nil).WillReturnError(errFull)
+//	handler(ctx, m)
+//	if err := m.ExpectationsWereMet(); err != nil {
+//		t.Fatal(err)
+//	}
+type MockStore struct {
+	mu           sync.Mutex
+	calls        []Call
+	expectations []*Expectation
+}
+
+var _ gaestore.Store = (*MockStore)(nil)
+
+// Call is a call made to a MockStore
+type Call struct {
+	// Op is the name of the operation, such as gaestore.OpPut
+	Op     string
+	Key    *datastore.Key
+	Entity gaestore.Entity
+	Query  *datastore.Query
+}
+
+// Expectation is an expected call, answered with the values it's
+// programmed with
+type Expectation struct {
+	op     string
+	key    *datastore.Key
+	met    bool
+	err    error
+	value  interface{}
+	rkey   *datastore.Key
+	cursor datastore.Cursor
+	n      int64
+}
+
+// NewMockStore returns a store without expectations
+func NewMockStore() *MockStore {
+	return &MockStore{}
+}
+
+// ExpectPut expects a Put of the entity with key, or any entity when key
+// is nil
+func (m *MockStore) ExpectPut(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpPut, key)
+}
+
+// ExpectGet expects a Get of key, or any key when nil
+func (m *MockStore) ExpectGet(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpGet, key)
+}
+
+// ExpectReload expects a Reload of key, or any key when nil
+func (m *MockStore) ExpectReload(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpReload, key)
+}
+
+// ExpectDelete expects a Delete of key, or any key when nil
+func (m *MockStore) ExpectDelete(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpDelete, key)
+}
+
+// ExpectTouch expects a Touch of key, or any key when nil
+func (m *MockStore) ExpectTouch(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpTouch, key)
+}
+
+// ExpectIncrement expects an Increment of key, or any key when nil
+func (m *MockStore) ExpectIncrement(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpIncrement, key)
+}
+
+// ExpectPutIf expects a PutIf of the entity with key, or any entity when
+// key is nil
+func (m *MockStore) ExpectPutIf(key *datastore.Key) *Expectation {
+	return m.expect(gaestore.OpPutIf, key)
+}
+
+// ExpectQuery expects a query
+func (m *MockStore) ExpectQuery() *Expectation {
+	return m.expect(gaestore.OpQuery, nil)
+}
+
+func (m *MockStore) expect(op string, key *datastore.Key) *Expectation {
+	e := &Expectation{op: op, key: key}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// WillReturnError makes the call fail with err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// WillReturn sets the entity loaded by a Get, Reload or Touch, which must
+// have the same type as the destination, or the slice of entities loaded
+// by a query
+func (e *Expectation) WillReturn(value interface{}) *Expectation {
+	e.value = value
+	return e
+}
+
+// WillReturnKey sets the key returned by a Put or PutIf, by default the key of the
+// entity put
+func (e *Expectation) WillReturnKey(key *datastore.Key) *Expectation {
+	e.rkey = key
+	return e
+}
+
+// WillReturnValue sets the value returned by an Increment
+func (e *Expectation) WillReturnValue(n int64) *Expectation {
+	e.n = n
+	return e
+}
+
+// WillReturnCursor sets the cursor returned by a query
+func (e *Expectation) WillReturnCursor(c datastore.Cursor) *Expectation {
+	e.cursor = c
+	return e
+}
+
+// Calls returns the calls made so far, in order
+func (m *MockStore) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// ExpectationsWereMet returns an error listing the expected calls that
+// were not made
+func (m *MockStore) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var missing []string
+	for _, e := range m.expectations {
+		if !e.met {
+			missing = append(missing, describe(e.op, e.key))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("gaestoretest: expected calls were not made: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// call records c and returns the first unmet expectation it matches
+func (m *MockStore) call(c Call) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, c)
+	for _, e := range m.expectations {
+		if e.met || e.op != c.Op {
+			continue
+		}
+		if e.key != nil && !e.key.Equal(c.Key) {
+			continue
+		}
+		e.met = true
+		return e, nil
+	}
+	return nil, fmt.Errorf("gaestoretest: unexpected call %s", describe(c.Op, c.Key))
+}
+
+func describe(op string, key *datastore.Key) string {
+	if key == nil {
+		return op
+	}
+	return fmt.Sprintf("%s [%v]", op, key)
+}
+
+func (m *MockStore) Put(ctx context.Context, e gaestore.Entity, opts ...gaestore.CallOption) (*datastore.Key, error) {
+	key := e.Key(ctx)
+	x, err := m.call(Call{Op: gaestore.OpPut, Key: key, Entity: e})
+	if err != nil {
+		return nil, err
+	}
+	if x.err != nil {
+		return nil, x.err
+	}
+	if x.rkey != nil {
+		return x.rkey, nil
+	}
+	return key, nil
+}
+
+// PutMulti expects a Put of each entity, failing on the first that fails
+func (m *MockStore) PutMulti(ctx context.Context, entities []gaestore.Entity, opts ...gaestore.CallOption) ([]*datastore.Key, error) {
+	keys := make([]*datastore.Key, len(entities))
+	for i, e := range entities {
+		k, err := m.Put(ctx, e, opts...)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+func (m *MockStore) Get(ctx context.Context, e gaestore.Entity, opts ...gaestore.CallOption) error {
+	return m.load(gaestore.OpGet, e.Key(ctx), e)
+}
+
+func (m *MockStore) Reload(ctx context.Context, e gaestore.Entity, opts ...gaestore.CallOption) error {
+	return m.load(gaestore.OpReload, e.Key(ctx), e)
+}
+
+func (m *MockStore) GetByStringID(ctx context.Context, kind, id string, dst gaestore.Entity, opts ...gaestore.CallOption) error {
+	return m.load(gaestore.OpGet, datastore.NewKey(ctx, kind, id, 0, nil), dst)
+}
+
+func (m *MockStore) GetByIntID(ctx context.Context, kind string, id int64, dst gaestore.Entity, opts ...gaestore.CallOption) error {
+	return m.load(gaestore.OpGet, datastore.NewKey(ctx, kind, "", id, nil), dst)
+}
+
+func (m *MockStore) load(op string, key *datastore.Key, dst gaestore.Entity) error {
+	x, err := m.call(Call{Op: op, Key: key, Entity: dst})
+	if err != nil {
+		return err
+	}
+	if x.err != nil || x.value == nil {
+		return x.err
+	}
+	return assign(dst, x.value)
+}
+
+func (m *MockStore) Query(ctx context.Context, q *datastore.Query, entities interface{}, opts ...gaestore.CallOption) (datastore.Cursor, error) {
+	x, err := m.call(Call{Op: gaestore.OpQuery, Query: q})
+	if err != nil {
+		return datastore.Cursor{}, err
+	}
+	if x.err != nil {
+		return datastore.Cursor{}, x.err
+	}
+	if x.value != nil {
+		if err := assign(entities, x.value); err != nil {
+			return datastore.Cursor{}, err
+		}
+	}
+	return x.cursor, nil
+}
+
+func (m *MockStore) Delete(ctx context.Context, e gaestore.Entity, opts ...gaestore.CallOption) error {
+	x, err := m.call(Call{Op: gaestore.OpDelete, Key: e.Key(ctx), Entity: e})
+	if err != nil {
+		return err
+	}
+	return x.err
+}
+
+func (m *MockStore) Touch(ctx context.Context, e gaestore.Entity, opts ...gaestore.CallOption) error {
+	return m.load(gaestore.OpTouch, e.Key(ctx), e)
+}
+
+// Increment returns the value set by WillReturnValue
+func (m *MockStore) Increment(ctx context.Context, e gaestore.Entity, field string, delta int64, opts ...gaestore.CallOption) (int64, error) {
+	x, err := m.call(Call{Op: gaestore.OpIncrement, Key: e.Key(ctx), Entity: e})
+	if err != nil {
+		return 0, err
+	}
+	return x.n, x.err
+}
+
+// PutIf returns the key set by WillReturnKey, or the key of the entity
+func (m *MockStore) PutIf(ctx context.Context, e gaestore.Entity, field string, expected interface{}, opts ...gaestore.CallOption) (*datastore.Key, error) {
+	key := e.Key(ctx)
+	x, err := m.call(Call{Op: gaestore.OpPutIf, Key: key, Entity: e})
+	if err != nil {
+		return nil, err
+	}
+	if x.err != nil {
+		return nil, x.err
+	}
+	if x.rkey != nil {
+		return x.rkey, nil
+	}
+	return key, nil
+}
+
+// RunInTransaction runs fn without a transaction
+func (m *MockStore) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	return fn(ctx)
+}
+
+// HealthCheck always reports a healthy store
+func (m *MockStore) HealthCheck(ctx context.Context) gaestore.HealthStatus {
+	return gaestore.HealthStatus{}
+}
+
+// assign copies what value points to into what dst points to
+func assign(dst, value interface{}) error {
+	dv := reflect.ValueOf(dst)
+	vv := reflect.ValueOf(value)
+	if vv.Kind() == reflect.Ptr && vv.Type() == dv.Type() {
+		vv = vv.Elem()
+	}
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || vv.Type() != dv.Elem().Type() {
+		return fmt.Errorf("gaestoretest: cannot return [%T] into [%T]", value, dst)
+	}
+	dv.Elem().Set(vv)
+	return nil
+}
@@ -0,0 +1,53 @@
+package gaestoretest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestMockStore(t *testing.T) {
+	ctx := NewContext()
+	m := NewMockStore()
+	john := &person{ID: "1", Name: "John"}
+	errFull := errors.New("full")
+
+	m.ExpectGet(john.Key(ctx)).WillReturn(john)
+	m.ExpectPut(nil).WillReturnError(errFull)
+	m.ExpectQuery().WillReturn([]*person{john})
+
+	got := &person{ID: "1"}
+	if err := m.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, john) {
+		t.Fatalf("Expected [%+v] but got [%+v]", john, got)
+	}
+	if _, err := m.Put(ctx, &person{ID: "2"}); err != errFull {
+		t.Fatalf("Expected error [%v] but got [%v]", errFull, err)
+	}
+	if err := m.ExpectationsWereMet(); err == nil {
+		t.Fatalf("Expected the query to be unmet")
+	}
+	var people []*person
+	if _, err := m.Query(ctx, datastore.NewQuery("person"), &people); err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 1 || people[0] != john {
+		t.Fatalf("Expected [[%v]] but got [%v]", john, people)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Delete(ctx, john); err == nil {
+		t.Fatalf("Expected an error for an unexpected call")
+	}
+	calls := m.Calls()
+	if len(calls) != 4 || calls[1].Op != gaestore.OpPut || calls[1].Key.StringID() != "2" {
+		t.Fatalf("Expected the Put to be recorded but got [%+v]", calls)
+	}
+}
@@ -0,0 +1,165 @@
+package gaestoretest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// RunQuery runs q against the stored entities. Ancestor queries see every
+// write, other queries only those applied to the index.
+func (b *Backend) RunQuery(ctx context.Context, q *datastore.Query) gaestore.Iterator {
+	pq, err := appds.ParseQuery(q)
+	if err != nil {
+		return &iterator{err: err}
+	}
+	namespace := appds.Namespace(ctx)
+	if pq.Ancestor != nil {
+		namespace = pq.Ancestor.Namespace()
+	}
+	b.mu.Lock()
+	b.applyDelayed()
+	source := b.index
+	if pq.Ancestor != nil {
+		source = b.entities
+	}
+	var results []*record
+	for _, r := range source {
+		if r.key.Namespace() == namespace && appds.Matches(pq, r.key, r.props) {
+			results = append(results, r)
+		}
+	}
+	b.mu.Unlock()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return appds.Less(pq.Orders, results[i].key, results[i].props, results[j].key, results[j].props)
+	})
+	if len(pq.Projection) > 0 {
+		results = project(results, pq.Projection, pq.Distinct)
+	}
+
+	start, err := cursorOffset(pq.Start, pq.Orders, results)
+	if err != nil {
+		return &iterator{err: err}
+	}
+	end, err := cursorOffset(pq.End, pq.Orders, results)
+	if err != nil {
+		return &iterator{err: err}
+	}
+	if end < 0 || end > len(results) {
+		end = len(results)
+	}
+	if start < 0 {
+		start = 0
+	}
+	pos := start + int(pq.Offset)
+	if pq.Limit >= 0 && pos+int(pq.Limit) < end {
+		end = pos + int(pq.Limit)
+	}
+	return &iterator{results: results, pos: pos, end: end, keysOnly: pq.KeysOnly}
+}
+
+type iterator struct {
+	results  []*record
+	pos, end int
+	keysOnly bool
+	err      error
+}
+
+func (it *iterator) Next(dst interface{}) (*datastore.Key, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	if it.pos >= it.end {
+		return nil, datastore.Done
+	}
+	r := it.results[it.pos]
+	it.pos++
+	if dst == nil || it.keysOnly {
+		return r.key, nil
+	}
+	return r.key, load(dst, r.props)
+}
+
+// Cursor returns a cursor holding the position of the iterator and the
+// key of the last result, so that a query continued after the results
+// before it changed resumes after that entity as on the datastore
+func (it *iterator) Cursor() (datastore.Cursor, error) {
+	if it.err != nil {
+		return datastore.Cursor{}, it.err
+	}
+	c := strconv.Itoa(it.pos)
+	if it.pos > 0 && it.pos <= len(it.results) {
+		c += ":" + it.results[it.pos-1].key.Encode()
+	}
+	return appds.WrapCursor(c)
+}
+
+// cursorOffset returns the position in results a cursor resumes from, or
+// -1 for the zero cursor. Positions are only used when the entity before
+// the cursor can't be placed in the results.
+func cursorOffset(c datastore.Cursor, orders []appds.Order, results []*record) (int, error) {
+	s, err := appds.UnwrapCursor(c)
+	if err != nil || s == "" {
+		return -1, err
+	}
+	pos, encoded := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		pos, encoded = s[:i], s[i+1:]
+	}
+	n, err := strconv.Atoi(pos)
+	if err != nil || encoded == "" {
+		return n, err
+	}
+	last, err := datastore.DecodeKey(encoded)
+	if err != nil {
+		return 0, err
+	}
+	for i, r := range results {
+		if r.key.Equal(last) {
+			return i + 1, nil
+		}
+	}
+	if len(orders) == 0 {
+		// Results are in key order, so resume from the next key
+		return sort.Search(len(results), func(i int) bool {
+			return appds.CompareKeys(results[i].key, last) > 0
+		}), nil
+	}
+	return n, nil
+}
+
+// project returns records holding only the projected properties, one per
+// combination of values as on the datastore
+func project(results []*record, fields []string, distinct bool) []*record {
+	var out []*record
+	seen := map[string]bool{}
+	for _, r := range results {
+		props := make([]datastore.Property, 0, len(fields))
+		for _, f := range fields {
+			vs := appds.Values(r.key, r.props, f)
+			if len(vs) == 0 {
+				break
+			}
+			props = append(props, datastore.Property{Name: f, Value: vs[0]})
+		}
+		if len(props) < len(fields) {
+			continue
+		}
+		if distinct {
+			id := fmt.Sprint(props)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		out = append(out, &record{key: r.key, props: props})
+	}
+	return out
+}
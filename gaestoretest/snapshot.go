@@ -0,0 +1,89 @@
+package gaestoretest
+
+import (
+	"context"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+// Snapshot holds the entities of some kinds, for restoring them between
+// tests that share a store, such as one backed by an expensive aetest
+// context
+//
+//	snap, err := gaestoretest.TakeSnapshot(ctx, s, "User", "Order")
+//	...
+//	t.Cleanup(func() { snap.Restore(ctx) })
+//
+// Kinds are read with queries, so on the dev server the context should be
+// made with aetest.Options{StronglyConsistentDatastore: true}.
+type Snapshot struct {
+	s        *gaestore.Client
+	kinds    []string
+	keys     []*datastore.Key
+	entities []datastore.PropertyList
+}
+
+// TakeSnapshot reads every entity of kinds through the store's backend,
+// in the namespace of ctx
+func TakeSnapshot(ctx context.Context, s *gaestore.Client, kinds ...string) (*Snapshot, error) {
+	snap := &Snapshot{s: s, kinds: kinds}
+	for _, kind := range kinds {
+		it := s.Backend().RunQuery(ctx, datastore.NewQuery(kind))
+		for {
+			var props datastore.PropertyList
+			key, err := it.Next(&props)
+			if err == datastore.Done {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			snap.keys = append(snap.keys, key)
+			snap.entities = append(snap.entities, props)
+		}
+	}
+	return snap, nil
+}
+
+// Len returns the number of entities in the snapshot
+func (snap *Snapshot) Len() int {
+	return len(snap.keys)
+}
+
+// Restore deletes the entities of the snapshot's kinds written since it
+// was taken, writes back those it holds and evicts them all from the cache
+func (snap *Snapshot) Restore(ctx context.Context) error {
+	b := snap.s.Backend()
+	kept := make(map[string]bool, len(snap.keys))
+	for _, key := range snap.keys {
+		kept[key.Encode()] = true
+	}
+	var evict []*datastore.Key
+	for _, kind := range snap.kinds {
+		it := b.RunQuery(ctx, datastore.NewQuery(kind).KeysOnly())
+		for {
+			key, err := it.Next(nil)
+			if err == datastore.Done {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if kept[key.Encode()] {
+				continue
+			}
+			if err := b.Delete(ctx, key); err != nil && err != datastore.ErrNoSuchEntity {
+				return err
+			}
+			evict = append(evict, key)
+		}
+	}
+	for i, key := range snap.keys {
+		props := append(datastore.PropertyList(nil), snap.entities[i]...)
+		if _, err := b.Put(ctx, key, &props); err != nil {
+			return err
+		}
+	}
+	return snap.s.Evict(ctx, append(evict, snap.keys...)...)
+}
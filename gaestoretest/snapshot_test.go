@@ -0,0 +1,46 @@
+package gaestoretest
+
+import (
+	"testing"
+
+	"github.com/floresj/gaestore"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := NewContext()
+	s := NewStore(t)
+	if _, err := s.Put(ctx, &person{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := TakeSnapshot(ctx, s, "person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Len() != 1 {
+		t.Fatalf("Expected [1] entity but got [%d]", snap.Len())
+	}
+
+	if _, err := s.Put(ctx, &person{ID: "1", Name: "Changed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, &person{ID: "2", Name: "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &person{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := snap.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &person{ID: "1"}
+	if err := s.Get(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "John" {
+		t.Fatalf("Expected [John] but got [%s]", p.Name)
+	}
+	if err := s.Get(ctx, &person{ID: "2"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected the new entity to be removed but got [%v]", err)
+	}
+}
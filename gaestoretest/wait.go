@@ -0,0 +1,75 @@
+package gaestoretest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+// pollInterval is how often WaitForQuery runs its query
+const pollInterval = 50 * time.Millisecond
+
+// NewAEContext starts a dev server whose datastore is strongly consistent,
+// so that queries see writes as soon as they're made, and returns a
+// context for a request to it. The server is stopped when the test ends.
+func NewAEContext(t testing.TB) context.Context {
+	inst, err := aetest.NewInstance(&aetest.Options{StronglyConsistentDatastore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { inst.Close() })
+	r, err := inst.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return appengine.NewContext(r)
+}
+
+// WaitForQuery runs q until it has count results, returning an error once
+// timeout has passed. It's for tests on an eventually consistent datastore
+// that can't use NewAEContext, in place of sleeping for a while and hoping
+// the writes are applied. q runs against the backend of the store returned
+// by gaestore.FromContext.
+func WaitForQuery(ctx context.Context, q *datastore.Query, count int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		n, err := countQuery(ctx, q)
+		if err != nil {
+			return err
+		}
+		if n == count {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Query has [%d] results rather than [%d] after [%v]", n, count, timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// countQuery returns the number of results of q
+func countQuery(ctx context.Context, q *datastore.Query) (int, error) {
+	c, ok := gaestore.FromContext(ctx).(*gaestore.Client)
+	if !ok {
+		return q.Count(ctx)
+	}
+	it := c.Backend().RunQuery(ctx, q.KeysOnly())
+	n := 0
+	for {
+		_, err := it.Next(nil)
+		if err == datastore.Done {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,32 @@
+package gaestoretest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestWaitForQuery(t *testing.T) {
+	b := NewBackend()
+	b.SetConsistency(0)
+	s := NewStore(t, gaestore.WithBackend(b))
+	ctx := gaestore.NewContext(NewContext(), s)
+
+	if _, err := s.Put(ctx, &person{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+	q := datastore.NewQuery("person")
+	if err := WaitForQuery(ctx, q, 1, 10*time.Millisecond); err == nil {
+		t.Fatalf("Expected the write not to be seen by the query")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		b.Apply()
+	}()
+	if err := WaitForQuery(ctx, q, 1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+// Package gcs provides a gaestore.Bucket for a Cloud Storage bucket, for
+// exports made with gaestore.Exporter and imports with gaestore.Importer,
+// and the objects of gaestore.BlobRef fields.
+//
+//	client, err := storage.NewClient(ctx)
+//	x := store.NewExporter(gcs.New(client.Bucket("backups")), "User", "users/2016-05-01")
+//	err = x.Export(ctx)
+package gcs
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/floresj/gaestore"
+)
+
+// Bucket is a gaestore.Bucket for a Cloud Storage bucket
+type Bucket struct {
+	h *storage.BucketHandle
+}
+
+var (
+	_ gaestore.BlobBucket = (*Bucket)(nil)
+	_ gaestore.URLSigner  = (*Bucket)(nil)
+)
+
+// New returns a bucket reading and writing objects with h
+func New(h *storage.BucketHandle) *Bucket {
+	return &Bucket{h: h}
+}
+
+// NewWriter returns a writer uploading the object name. The object is
+// created when the writer is closed, and isn't if ctx is canceled first.
+func (b *Bucket) NewWriter(ctx context.Context, name string) io.WriteCloser {
+	return b.h.Object(name).NewWriter(ctx)
+}
+
+func (b *Bucket) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.h.Object(name).NewReader(ctx)
+}
+
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	err := b.h.Object(name).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// SignedURL returns a URL reading the object name for ttl, signed with the
+// credentials of the client
+func (b *Bucket) SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	return b.h.SignedURL(name, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+	})
+}
@@ -0,0 +1,220 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// Location is a field holding a point, stored along with the point's
+// geohash so that entities can be found near another point with
+// QueryNear. Geohash is set from Point when the entity is written.
+//
+//	type Shop struct {
+//		ID       string `datastore:"-"`
+//		Location gaestore.Location
+//	}
+type Location struct {
+	Point appengine.GeoPoint
+
+	// Geohash is Point's geohash as last written
+	Geohash string
+}
+
+const (
+	// geohashPrecision is the length of the stored geohashes, locating
+	// points to within a few centimeters
+	geohashPrecision = 12
+
+	geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	// earthRadius is the mean radius of the earth in meters
+	earthRadius = 6371000.0
+)
+
+var locationType = reflect.TypeOf(Location{})
+
+type locationField struct {
+	index int
+
+	// property is the name of the Geohash property
+	property string
+}
+
+func locationFieldOf(f reflect.StructField, i int) (locationField, bool) {
+	if f.Type != locationType {
+		return locationField{}, false
+	}
+	name := strings.Split(f.Tag.Get("datastore"), ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return locationField{index: i, property: name + ".Geohash"}, true
+}
+
+// encodeLocations sets the geohashes of the Location fields of src before
+// it's written
+func encodeLocations(src interface{}) {
+	if _, ok := src.(datastore.PropertyLoadSaver); ok {
+		return
+	}
+	info := structInfo(src)
+	if info == nil || len(info.locations) == 0 {
+		return
+	}
+	v := reflect.ValueOf(src).Elem()
+	for _, lf := range info.locations {
+		l := v.Field(lf.index).Addr().Interface().(*Location)
+		l.Geohash = geohash(l.Point, geohashPrecision)
+	}
+}
+
+// QueryNear loads the entities matching q within radius meters of center
+// into dst, a pointer to a slice of entities with a Location field,
+// ordered by their distance from center. The first Location field is
+// matched with range queries on the prefixes of the geohashes around
+// center, whose results are filtered by their true distance, so q can't
+// have inequality filters or orders of its own. Limits apply to each of
+// the range queries.
+func (s *Client) QueryNear(ctx context.Context, q *datastore.Query, center appengine.GeoPoint, radius float64, dst interface{}, opts ...CallOption) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return ErrInvalidEntityType
+	}
+	dv = dv.Elem()
+	mat, elemType := checkMultiArg(dv)
+	if mat != multiArgTypeStruct && mat != multiArgTypeStructPtr {
+		return ErrInvalidEntityType
+	}
+	locations := typeInfoOf(elemType).locations
+	if len(locations) == 0 {
+		return fmt.Errorf("gaestore: [%v] has no Location field", elemType)
+	}
+	lf := locations[0]
+
+	type near struct {
+		e        reflect.Value
+		distance float64
+	}
+	// The prefixes have the same length, so each entity matches only one
+	var found []near
+	for _, prefix := range nearPrefixes(center, radius) {
+		pq := q
+		if prefix != "" {
+			// '~' sorts after every geohash character
+			pq = q.Filter(lf.property+" >=", prefix).Filter(lf.property+" <", prefix+"~")
+		}
+		results := reflect.New(dv.Type())
+		if _, err := s.Query(ctx, pq, results.Interface(), opts...); err != nil {
+			return err
+		}
+		for i := 0; i < results.Elem().Len(); i++ {
+			e := results.Elem().Index(i)
+			ev := e
+			if mat == multiArgTypeStructPtr {
+				ev = e.Elem()
+			}
+			l := ev.Field(lf.index).Interface().(Location)
+			if d := distance(center, l.Point); d <= radius {
+				found = append(found, near{e, d})
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	for _, n := range found {
+		dv.Set(reflect.Append(dv, n.e))
+	}
+	return nil
+}
+
+// nearPrefixes returns the geohash prefixes of the cells that may hold
+// points within radius meters of center. The cells are at least radius
+// across, so each cell overlapping the circle's bounding box holds one of
+// the box's corners, the midpoints of its sides or center. An empty prefix
+// is returned when the radius is too large for any cell.
+func nearPrefixes(center appengine.GeoPoint, radius float64) []string {
+	dLat := radius / earthRadius * 180 / math.Pi
+	dLng := dLat / math.Max(math.Cos(center.Lat*math.Pi/180), 1e-9)
+	precision := 0
+	for p := 1; p <= geohashPrecision; p++ {
+		lat, lng := geohashCell(p)
+		if lat < dLat || lng < dLng {
+			break
+		}
+		precision = p
+	}
+	if precision == 0 {
+		return []string{""}
+	}
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, lat := range []float64{center.Lat - dLat, center.Lat, center.Lat + dLat} {
+		for _, lng := range []float64{center.Lng - dLng, center.Lng, center.Lng + dLng} {
+			p := geohash(appengine.GeoPoint{Lat: clampLat(lat), Lng: wrapLng(lng)}, precision)
+			if !seen[p] {
+				seen[p] = true
+				prefixes = append(prefixes, p)
+			}
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// geohashCell returns the height and width in degrees of the cells of
+// geohashes with precision characters
+func geohashCell(precision int) (lat, lng float64) {
+	bits := 5 * precision
+	return 180 / math.Exp2(float64(bits/2)), 360 / math.Exp2(float64(bits-bits/2))
+}
+
+// geohash returns the geohash of p with precision characters
+func geohash(p appengine.GeoPoint, precision int) string {
+	lat, lng := [2]float64{-90, 90}, [2]float64{-180, 180}
+	b := make([]byte, 0, precision)
+	var ch, bit int
+	for even := true; len(b) < precision; even = !even {
+		r, v := &lat, p.Lat
+		if even {
+			r, v = &lng, p.Lng
+		}
+		ch <<= 1
+		if mid := (r[0] + r[1]) / 2; v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		if bit++; bit == 5 {
+			b = append(b, geohashAlphabet[ch])
+			ch, bit = 0, 0
+		}
+	}
+	return string(b)
+}
+
+// distance returns the great circle distance in meters between a and b
+func distance(a, b appengine.GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLng := lat2-lat1, (b.Lng-a.Lng)*math.Pi/180
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func clampLat(lat float64) float64 {
+	return math.Max(-90, math.Min(90, lat))
+}
+
+func wrapLng(lng float64) float64 {
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+	return lng - 180
+}
@@ -0,0 +1,70 @@
+package gaestore_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+type shop struct {
+	ID       string `datastore:"-"`
+	Location gaestore.Location
+}
+
+func (s *shop) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "shop", s.ID, 0, nil)
+}
+
+func (s *shop) AfterGet(ctx context.Context, key *datastore.Key) error {
+	s.ID = key.StringID()
+	return nil
+}
+
+func TestQueryNear(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+
+	london := appengine.GeoPoint{Lat: 51.5074, Lng: -0.1278}
+	for _, sh := range []*shop{
+		{ID: "paris", Location: gaestore.Location{Point: appengine.GeoPoint{Lat: 48.8566, Lng: 2.3522}}},
+		{ID: "camden", Location: gaestore.Location{Point: appengine.GeoPoint{Lat: 51.5390, Lng: -0.1426}}},
+		{ID: "soho", Location: gaestore.Location{Point: appengine.GeoPoint{Lat: 51.5136, Lng: -0.1365}}},
+		{ID: "sydney", Location: gaestore.Location{Point: appengine.GeoPoint{Lat: -33.8688, Lng: 151.2093}}},
+	} {
+		if _, err := s.Put(ctx, sh); err != nil {
+			t.Fatal(err)
+		}
+		if len(sh.Location.Geohash) != 12 {
+			t.Fatalf("Expected the geohash to be set but got [%s]", sh.Location.Geohash)
+		}
+	}
+
+	tests := []struct {
+		radius   float64
+		expected []string
+	}{
+		{100, nil},
+		{1000, []string{"soho"}},
+		{5000, []string{"soho", "camden"}},
+		{500000, []string{"soho", "camden", "paris"}},
+		{20000000, []string{"soho", "camden", "paris", "sydney"}},
+	}
+	for _, test := range tests {
+		var shops []*shop
+		if err := s.QueryNear(ctx, datastore.NewQuery("shop"), london, test.radius, &shops); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, sh := range shops {
+			got = append(got, sh.ID)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("Expected [%v] within [%v]m but got [%v]", test.expected, test.radius, got)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package goon provides the Goon type of github.com/mjibson/goon backed by
+// gaestore, so code using goon can move to gaestore by changing its import
+// path. Keys are taken from struct fields tagged as in goon:
+//
+//	type User struct {
+//		ID     int64          `datastore:"-" goon:"id"`
+//		Parent *datastore.Key `datastore:"-" goon:"parent"`
+//		Kind   string         `datastore:"-" goon:"kind,User"`
+//		Name   string
+//	}
+//
+// The id field is an int64 or string, left zero for Put to allocate an ID.
+// The kind is the value of the kind field, the name in its tag, or else
+// the name of the struct type.
+//
+// Values need not be gaestore.Entities, and their hooks are not run.
+package goon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/internal/compat"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// Goon reads and writes entities through a store
+type Goon struct {
+	ctx   context.Context
+	store gaestore.Store
+}
+
+// NewGoon returns a Goon for the request, using the store returned by
+// gaestore.FromContext
+func NewGoon(r *http.Request) *Goon {
+	return FromContext(appengine.NewContext(r))
+}
+
+// FromContext returns a Goon for ctx, using the store returned by
+// gaestore.FromContext
+func FromContext(ctx context.Context) *Goon {
+	return New(ctx, gaestore.FromContext(ctx))
+}
+
+// New returns a Goon for ctx using s
+func New(ctx context.Context, s gaestore.Store) *Goon {
+	return &Goon{ctx: ctx, store: s}
+}
+
+// Context returns the context the Goon was created with
+func (g *Goon) Context() context.Context {
+	return g.ctx
+}
+
+// Key returns the key of src, or nil if it has no valid key
+func (g *Goon) Key(src interface{}) *datastore.Key {
+	key, err := g.KeyError(src)
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// KeyError returns the key of src, a struct pointer, from its tagged fields
+func (g *Goon) KeyError(src interface{}) (*datastore.Key, error) {
+	v := reflect.ValueOf(src)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("goon: [%T] is not a struct pointer", src)
+	}
+	v = v.Elem()
+	t := v.Type()
+	kind := t.Name()
+	var (
+		id     interface{}
+		parent *datastore.Key
+	)
+	for i := 0; i < t.NumField(); i++ {
+		name, def := tagParts(t.Field(i).Tag.Get("goon"))
+		f := v.Field(i)
+		switch name {
+		case "id":
+			switch f.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				id = f.Int()
+			case reflect.String:
+				id = f.String()
+			default:
+				return nil, fmt.Errorf("goon: id field of [%T] is not an int64 or string", src)
+			}
+		case "parent":
+			k, ok := f.Interface().(*datastore.Key)
+			if !ok {
+				return nil, fmt.Errorf("goon: parent field of [%T] is not a *datastore.Key", src)
+			}
+			parent = k
+		case "kind":
+			if f.Kind() != reflect.String {
+				return nil, fmt.Errorf("goon: kind field of [%T] is not a string", src)
+			}
+			switch {
+			case f.String() != "":
+				kind = f.String()
+			case def != "":
+				kind = def
+			}
+		}
+	}
+	switch id := id.(type) {
+	case int64:
+		return datastore.NewKey(g.ctx, kind, "", id, parent), nil
+	case string:
+		return datastore.NewKey(g.ctx, kind, id, 0, parent), nil
+	}
+	return nil, fmt.Errorf("goon: [%T] has no id field", src)
+}
+
+// tagParts splits a goon tag into its name and default value
+func tagParts(tag string) (name, def string) {
+	parts := strings.SplitN(tag, ",", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// setKey sets the id and parent fields of dst from key
+func setKey(dst interface{}, key *datastore.Key) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _ := tagParts(t.Field(i).Tag.Get("goon"))
+		f := v.Field(i)
+		switch name {
+		case "id":
+			if f.Kind() == reflect.String {
+				f.SetString(key.StringID())
+			} else {
+				f.SetInt(key.IntID())
+			}
+		case "parent":
+			f.Set(reflect.ValueOf(key.Parent()))
+		}
+	}
+}
+
+// Put saves src, setting its id field when the key was incomplete
+func (g *Goon) Put(src interface{}) (*datastore.Key, error) {
+	key, err := g.KeyError(src)
+	if err != nil {
+		return nil, err
+	}
+	key, err = g.store.Put(g.ctx, &compat.Entity{K: key, Src: src})
+	if err != nil {
+		return nil, err
+	}
+	setKey(src, key)
+	return key, nil
+}
+
+// PutMulti is a batch version of Put for a slice of struct pointers
+func (g *Goon) PutMulti(src interface{}) ([]*datastore.Key, error) {
+	vals, err := g.values(src)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*datastore.Key, len(vals))
+	for i, val := range vals {
+		if keys[i], err = g.KeyError(val); err != nil {
+			return nil, err
+		}
+	}
+	keys, err = g.store.PutMulti(g.ctx, compat.Entities(keys, vals))
+	for i, key := range keys {
+		if key != nil {
+			setKey(vals[i], key)
+		}
+	}
+	return keys, err
+}
+
+// Get loads the entity for the key of dst into it
+func (g *Goon) Get(dst interface{}) error {
+	key, err := g.KeyError(dst)
+	if err != nil {
+		return err
+	}
+	if err := g.store.Get(g.ctx, &compat.Entity{K: key, Src: dst}); err != nil {
+		return compat.Err(err)
+	}
+	setKey(dst, key)
+	return nil
+}
+
+// GetMulti is a batch version of Get for a slice of struct pointers,
+// returning an appengine.MultiError when only some entities are loaded
+func (g *Goon) GetMulti(dst interface{}) error {
+	vals, err := g.values(dst)
+	if err != nil {
+		return err
+	}
+	return compat.Each(len(vals), func(i int) error {
+		return g.Get(vals[i])
+	})
+}
+
+// Delete deletes the entity for key
+func (g *Goon) Delete(key *datastore.Key) error {
+	return g.store.Delete(g.ctx, &compat.Entity{K: key})
+}
+
+// DeleteMulti is a batch version of Delete
+func (g *Goon) DeleteMulti(keys []*datastore.Key) error {
+	return compat.Each(len(keys), func(i int) error {
+		return g.Delete(keys[i])
+	})
+}
+
+// GetAll runs q, appending the results to dst, a pointer to a slice of
+// structs or struct pointers, and returns their keys. Pass a nil dst for
+// only the keys.
+func (g *Goon) GetAll(q *datastore.Query, dst interface{}) ([]*datastore.Key, error) {
+	var results []*compat.Result
+	if _, err := g.store.Query(g.ctx, q, &results); err != nil {
+		return nil, err
+	}
+	keys := make([]*datastore.Key, len(results))
+	for i, r := range results {
+		keys[i] = r.K
+	}
+	if dst == nil {
+		return keys, nil
+	}
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("goon: [%T] is not a pointer to a slice", dst)
+	}
+	sv := dv.Elem()
+	elemType := sv.Type().Elem()
+	for _, r := range results {
+		ptr := elemType.Kind() == reflect.Ptr
+		t := elemType
+		if ptr {
+			t = t.Elem()
+		}
+		ev := reflect.New(t)
+		if err := (&compat.Entity{K: r.K, Src: ev.Interface()}).Load(r.Props); err != nil && !gaestore.IsFieldMismatch(err) {
+			return nil, err
+		}
+		setKey(ev.Interface(), r.K)
+		if !ptr {
+			ev = ev.Elem()
+		}
+		sv.Set(reflect.Append(sv, ev))
+	}
+	return keys, nil
+}
+
+// RunInTransaction runs f in a transaction with a Goon for the transaction
+func (g *Goon) RunInTransaction(f func(tg *Goon) error, opts *datastore.TransactionOptions) error {
+	return g.store.RunInTransaction(g.ctx, func(tc context.Context) error {
+		return f(New(tc, g.store))
+	}, opts)
+}
+
+// values returns the struct pointers in the slice v
+func (g *Goon) values(v interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("goon: [%T] is not a slice", v)
+	}
+	vals := make([]interface{}, rv.Len())
+	for i := range vals {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Struct {
+			elem = elem.Addr()
+		}
+		vals[i] = elem.Interface()
+	}
+	return vals, nil
+}
@@ -0,0 +1,68 @@
+package goon_test
+
+import (
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"github.com/floresj/gaestore/goon"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+type User struct {
+	ID     int64          `datastore:"-" goon:"id"`
+	Parent *datastore.Key `datastore:"-" goon:"parent"`
+	Name   string
+}
+
+type Tag struct {
+	ID   string `datastore:"-" goon:"id"`
+	Kind string `datastore:"-" goon:"kind,Label"`
+}
+
+func TestGoon(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	g := goon.New(ctx, gaestoretest.NewStore(t, gaestore.WithBackend(b)))
+
+	parent := datastore.NewKey(ctx, "Org", "acme", 0, nil)
+	u := &User{Parent: parent, Name: "John"}
+	key, err := g.Put(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID == 0 || key.IntID() != u.ID || key.Kind() != "User" || !key.Parent().Equal(parent) {
+		t.Fatalf("Expected an allocated key but got [%v] with ID [%d]", key, u.ID)
+	}
+
+	got := &User{ID: u.ID, Parent: parent}
+	if err := g.Get(got); err != nil || got.Name != "John" {
+		t.Fatalf("Expected [John] but got [%s] %v", got.Name, err)
+	}
+	if k := g.Key(&Tag{ID: "go"}); k.Kind() != "Label" || k.StringID() != "go" {
+		t.Fatalf("Expected [Label go] but got [%v]", k)
+	}
+
+	if _, err := g.PutMulti([]*User{{Name: "Jane"}, {Name: "Joe"}}); err != nil {
+		t.Fatal(err)
+	}
+	b.Apply()
+	var users []User
+	keys, err := g.GetAll(datastore.NewQuery("User").Filter("Name >", "Ja"), &users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].ID != keys[0].IntID() {
+		t.Fatalf("Expected 3 users but got [%+v]", users)
+	}
+
+	if err := g.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	err = g.GetMulti([]*User{{ID: u.ID, Parent: parent}, {ID: keys[1].IntID()}})
+	merr, ok := err.(appengine.MultiError)
+	if !ok || merr[0] != datastore.ErrNoSuchEntity || merr[1] != nil {
+		t.Fatalf("Expected [ErrNoSuchEntity] for the deleted user but got [%v]", err)
+	}
+}
@@ -0,0 +1,67 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+const healthKind = "GaestoreHealth"
+
+// HealthStatus reports the result of a HealthCheck for each backend. A nil
+// error means the backend is healthy.
+type HealthStatus struct {
+	Datastore error
+	Memcache  error
+}
+
+// OK reports whether every backend is healthy
+func (h HealthStatus) OK() bool {
+	return h.Datastore == nil && h.Memcache == nil
+}
+
+// HealthCheck performs a cheap datastore read and a memcache round trip
+// using the store's timeouts, for use in health and readiness handlers
+func (s *Client) HealthCheck(ctx context.Context) HealthStatus {
+	return HealthStatus{
+		Datastore: s.checkDatastore(ctx),
+		Memcache:  s.checkMemcache(ctx),
+	}
+}
+
+func (s *Client) checkDatastore(ctx context.Context) error {
+	var props datastore.PropertyList
+	key := datastore.NewKey(ctx, healthKind, "probe", 0, nil)
+	err := s.datastoreGet(ctx, key, &props)
+	if err == datastore.ErrNoSuchEntity {
+		return nil
+	}
+	return err
+}
+
+func (s *Client) checkMemcache(ctx context.Context) error {
+	// Each check uses its own key so concurrent checks can't interfere
+	value := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	cacheKey := "gaestore:health:" + string(value)
+	setCtx, done := s.startRPC(ctx, RPCMemcacheSet, healthKind)
+	err := s.mc().Set(setCtx, cacheKey, value, time.Minute)
+	done(err)
+	if err != nil {
+		return err
+	}
+
+	getCtx, done := s.startRPC(ctx, RPCMemcacheGet, healthKind)
+	got, err := s.mc().Get(getCtx, cacheKey)
+	done(err)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, value) {
+		return errors.New("gaestore: memcache returned a different value than was set")
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package gaestore
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+)
+
+func TestHealthCheck(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	status := NewStore().HealthCheck(ctx)
+	if !status.OK() {
+		t.Fatalf("Expected healthy backends but got [%+v]", status)
+	}
+}
@@ -0,0 +1,53 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// HookPanicError is returned in place of a panic raised by an entity's
+// hook
+type HookPanicError struct {
+	Hook  string
+	Kind  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *HookPanicError) Error() string {
+	return fmt.Sprintf("gaestore: %s hook for kind [%s] panicked: %v", e.Hook, e.Kind, e.Value)
+}
+
+// recoverHook converts a panic in the named hook into a HookPanicError
+// stored in err. It must be deferred directly by the function calling the
+// hook.
+func recoverHook(ctx context.Context, hook string, key *datastore.Key, e Entity, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	*err = &HookPanicError{
+		Hook:  hook,
+		Kind:  entityKind(ctx, key, e),
+		Value: r,
+		Stack: debug.Stack(),
+	}
+}
+
+// entityKind returns the kind of key, or of e's key when key is nil. The
+// entity may be left inconsistent by a panicking hook so falls back to its
+// Go type if computing its key panics too.
+func entityKind(ctx context.Context, key *datastore.Key, e Entity) (kind string) {
+	if key != nil {
+		return key.Kind()
+	}
+	defer func() {
+		if recover() != nil {
+			kind = fmt.Sprintf("%T", e)
+		}
+	}()
+	return e.Key(ctx).Kind()
+}
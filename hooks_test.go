@@ -0,0 +1,27 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type panicObject struct {
+	object
+}
+
+func (o *panicObject) BeforePut(ctx context.Context) error {
+	panic("boom")
+}
+
+func TestHookPanic(t *testing.T) {
+	ctx := keyContext(t)
+	err := beforePut(ctx, &panicObject{object{ID: "1"}})
+	var perr *HookPanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected a *HookPanicError but got [%v]", err)
+	}
+	if perr.Hook != "BeforePut" || perr.Kind != "object" || perr.Value != "boom" {
+		t.Fatalf("Unexpected error details [%+v]", perr)
+	}
+}
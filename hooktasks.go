@@ -0,0 +1,81 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+)
+
+// WithAfterPutFunc enqueues a task calling f after each Put of an entity
+// of kind, or of any kind when kind is empty, so the side effect is
+// retried by the task queue until it succeeds. f must have been created
+// with delay.Func from a function taking the key and a JSON snapshot of
+// the entity:
+//
+//	var sendWelcome = delay.Func("sendWelcome", func(ctx context.Context, key *datastore.Key, snapshot []byte) error {
+//		var u User
+//		if err := json.Unmarshal(snapshot, &u); err != nil {
+//			return err
+//		}
+//		return mail.Send(ctx, welcome(&u))
+//	})
+//
+//	s := gaestore.NewStore(gaestore.WithAfterPutFunc("User", sendWelcome))
+//
+// Tasks are enqueued unless hooks are skipped, and within transactions are
+// added only if the transaction commits.
+func WithAfterPutFunc(kind string, f *delay.Function) Option {
+	return func(c *Client) {
+		c.afterPutFuncs = appendHookTask(c.afterPutFuncs, kind, f)
+	}
+}
+
+// WithAfterDeleteFunc enqueues a task calling f after each Delete of an
+// entity of kind, or of any kind when kind is empty, as WithAfterPutFunc.
+// The snapshot is of the entity passed to Delete.
+func WithAfterDeleteFunc(kind string, f *delay.Function) Option {
+	return func(c *Client) {
+		c.afterDeleteFuncs = appendHookTask(c.afterDeleteFuncs, kind, f)
+	}
+}
+
+// appendHookTask returns a copy of funcs with f added for kind, leaving
+// the funcs of stores derived from the same client unchanged
+func appendHookTask(funcs map[string][]*delay.Function, kind string, f *delay.Function) map[string][]*delay.Function {
+	m := make(map[string][]*delay.Function, len(funcs)+1)
+	for k, fs := range funcs {
+		m[k] = fs
+	}
+	m[kind] = append(append([]*delay.Function(nil), m[kind]...), f)
+	return m
+}
+
+// callHookTask adds a task calling f, and is replaced in tests
+var callHookTask = func(ctx context.Context, f *delay.Function, key *datastore.Key, snapshot []byte) error {
+	return f.Call(ctx, key, snapshot)
+}
+
+// enqueueHookTasks adds a task for each of the funcs registered for the
+// kind of key, with a snapshot of e
+func enqueueHookTasks(ctx context.Context, funcs map[string][]*delay.Function, key *datastore.Key, e Entity) error {
+	// A fresh slice, as appending to the shared funcs[""] could write
+	// into spare capacity other calls read concurrently
+	fs := make([]*delay.Function, 0, len(funcs[""])+len(funcs[key.Kind()]))
+	fs = append(fs, funcs[""]...)
+	fs = append(fs, funcs[key.Kind()]...)
+	if len(fs) == 0 {
+		return nil
+	}
+	snapshot, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if err := callHookTask(ctx, f, key, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
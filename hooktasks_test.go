@@ -0,0 +1,90 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+)
+
+var (
+	putTask    = delay.Func("gaestore.test.putTask", func(ctx context.Context, key *datastore.Key, snapshot []byte) error { return nil })
+	deleteTask = delay.Func("gaestore.test.deleteTask", func(ctx context.Context, key *datastore.Key, snapshot []byte) error { return nil })
+)
+
+func TestHookTasks(t *testing.T) {
+	type call struct {
+		f    *delay.Function
+		key  *datastore.Key
+		name string
+	}
+	var calls []call
+	defer func(orig func(context.Context, *delay.Function, *datastore.Key, []byte) error) {
+		callHookTask = orig
+	}(callHookTask)
+	callHookTask = func(ctx context.Context, f *delay.Function, key *datastore.Key, snapshot []byte) error {
+		var o object
+		if err := json.Unmarshal(snapshot, &o); err != nil {
+			t.Fatal(err)
+		}
+		calls = append(calls, call{f, key, o.Name})
+		return nil
+	}
+
+	ctx := keyContext(t)
+	s := NewStore(WithBackend(mapBackend{}), WithLogger(nopLogger{}),
+		WithAfterPutFunc("object", putTask),
+		WithAfterPutFunc("other", deleteTask),
+		WithAfterDeleteFunc("", deleteTask))
+
+	o := object{ID: "1", Name: "John"}
+	if _, err := s.Put(ctx, &o); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, &object{ID: "2"}, SkipHooks); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, &o); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 tasks but got [%d]", len(calls))
+	}
+	if calls[0].f != putTask || !calls[0].key.Equal(o.Key(ctx)) || calls[0].name != "John" {
+		t.Fatalf("Expected the put task for [%v] but got [%+v]", o.Key(ctx), calls[0])
+	}
+	if calls[1].f != deleteTask || !calls[1].key.Equal(o.Key(ctx)) {
+		t.Fatalf("Expected the delete task for [%v] but got [%+v]", o.Key(ctx), calls[1])
+	}
+}
+
+func TestHookTasksShareNoSlices(t *testing.T) {
+	defer func(orig func(context.Context, *delay.Function, *datastore.Key, []byte) error) {
+		callHookTask = orig
+	}(callHookTask)
+	callHookTask = func(ctx context.Context, f *delay.Function, key *datastore.Key, snapshot []byte) error {
+		return nil
+	}
+
+	// Three funcs for every kind leave spare capacity in their slice
+	var funcs map[string][]*delay.Function
+	for i := 0; i < 3; i++ {
+		funcs = appendHookTask(funcs, "", deleteTask)
+	}
+	funcs = appendHookTask(funcs, "object", putTask)
+	all := funcs[""]
+	if cap(all) == len(all) {
+		t.Fatal("Expected spare capacity")
+	}
+
+	ctx := keyContext(t)
+	o := &object{ID: "1"}
+	if err := enqueueHookTasks(ctx, funcs, o.Key(ctx), o); err != nil {
+		t.Fatal(err)
+	}
+	if spare := all[:cap(all)][len(all)]; spare != nil {
+		t.Fatalf("Expected the shared funcs to be left unchanged but got [%v]", spare)
+	}
+}
@@ -0,0 +1,346 @@
+package gaestore
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// ImportKind is the kind import checkpoints are stored under
+const ImportKind = "GaestoreImport"
+
+// Importer puts the entities read from objects in a bucket, in chunks
+// made with PutMulti. Entities are decoded into the type registered for
+// the importer's kind. Objects named *.csv hold a header row of field
+// names followed by a row for each entity; others hold JSON lines as
+// written by an Exporter, and are put with the exported keys rather than
+// the keys of the decoded entities.
+//
+// Progress is checkpointed after each chunk, so an import interrupted by
+// a deadline or failing entity resumes from the last chunk when run again.
+type Importer struct {
+	// ChunkSize is the number of entities put at a time, 500 unless set
+	ChunkSize int
+
+	// Validate, when set, is called with each decoded entity. An error
+	// stops the import before the entity's chunk is put.
+	Validate func(ctx context.Context, e Entity) error
+
+	s       *Client
+	bucket  Bucket
+	kind    string
+	objects []string
+}
+
+// ImportProgress is the checkpoint of an import. Object is the index of
+// the object being read and Line the number of its lines already put,
+// not counting a CSV header.
+type ImportProgress struct {
+	Object   int
+	Line     int
+	Entities int
+	Done     bool
+}
+
+// NewImporter returns an importer of entities of kind from objects. The
+// import's checkpoint is identified by the kind and objects.
+func (s *Client) NewImporter(b Bucket, kind string, objects ...string) *Importer {
+	return &Importer{s: s, bucket: b, kind: kind, objects: objects}
+}
+
+// Import puts the entities not yet imported, returning ctx's error if it
+// ends first. Calling Import once it's done does nothing.
+func (x *Importer) Import(ctx context.Context) error {
+	ctx, err := x.s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := x.checkpointKey(ctx)
+	var p ImportProgress
+	if err := x.s.datastoreGet(ctx, key, &p); err != nil && err != datastore.ErrNoSuchEntity {
+		return err
+	}
+	for ; p.Object < len(x.objects); p.Object, p.Line = p.Object+1, 0 {
+		if err := x.importObject(ctx, key, &p); err != nil {
+			return err
+		}
+	}
+	p.Done = true
+	_, err = x.s.datastorePut(ctx, key, &p)
+	return err
+}
+
+// Progress returns the import's last checkpoint
+func (x *Importer) Progress(ctx context.Context) (ImportProgress, error) {
+	var p ImportProgress
+	ctx, err := x.s.withNamespace(ctx)
+	if err != nil {
+		return p, err
+	}
+	err = x.s.datastoreGet(ctx, x.checkpointKey(ctx), &p)
+	if err == datastore.ErrNoSuchEntity {
+		err = nil
+	}
+	return p, err
+}
+
+func (x *Importer) checkpointKey(ctx context.Context) *datastore.Key {
+	h := sha1.New()
+	io.WriteString(h, x.kind)
+	for _, name := range x.objects {
+		io.WriteString(h, "\x00"+name)
+	}
+	return datastore.NewKey(ctx, ImportKind, hex.EncodeToString(h.Sum(nil)), 0, nil)
+}
+
+func (x *Importer) chunkSize() int {
+	if x.ChunkSize <= 0 {
+		return 500
+	}
+	return x.ChunkSize
+}
+
+// importObject puts the remaining entities of the object p is at,
+// checkpointing after each chunk
+func (x *Importer) importObject(ctx context.Context, key *datastore.Key, p *ImportProgress) error {
+	name := x.objects[p.Object]
+	r, err := x.bucket.NewReader(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var dec decoder
+	if strings.HasSuffix(name, ".csv") {
+		dec, err = newCSVDecoder(ctx, r, x.kind)
+	} else {
+		dec = newJSONDecoder(ctx, r, x.kind)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read [%s]: %v", name, err)
+	}
+	for line := 0; line < p.Line; line++ {
+		if err := dec.skip(); err != nil {
+			return fmt.Errorf("Unable to resume [%s] at line %d: %v", name, p.Line, err)
+		}
+	}
+
+	var keys []*datastore.Key
+	var entities []Entity
+	flush := func() error {
+		if len(entities) == 0 {
+			return nil
+		}
+		op := &Operation{Name: OpPutMulti, Entities: entities, Keys: keys}
+		if err := x.s.run(ctx, op); err != nil {
+			return err
+		}
+		p.Line += len(entities)
+		p.Entities += len(entities)
+		_, err := x.s.datastorePut(ctx, key, p)
+		keys, entities = nil, nil
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		k, e, err := dec.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to decode [%s] line %d: %v", name, p.Line+len(entities)+1, err)
+		}
+		if x.Validate != nil {
+			if err := x.Validate(ctx, e); err != nil {
+				return fmt.Errorf("Invalid entity in [%s] line %d: %v", name, p.Line+len(entities)+1, err)
+			}
+		}
+		keys = append(keys, k)
+		entities = append(entities, e)
+		if len(entities) == x.chunkSize() {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// decoder reads the entities of an imported object
+type decoder interface {
+	// next returns the next entity and its key, if the object has one
+	next() (*datastore.Key, Entity, error)
+	skip() error
+}
+
+type jsonDecoder struct {
+	ctx  context.Context
+	kind string
+	r    *bufio.Reader
+}
+
+func newJSONDecoder(ctx context.Context, r io.Reader, kind string) *jsonDecoder {
+	return &jsonDecoder{ctx: ctx, kind: kind, r: bufio.NewReader(r)}
+}
+
+func (d *jsonDecoder) readLine() ([]byte, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *jsonDecoder) skip() error {
+	_, err := d.readLine()
+	return err
+}
+
+func (d *jsonDecoder) next() (*datastore.Key, Entity, error) {
+	line, err := d.readLine()
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := newEntity(d.kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	var l struct {
+		Key    *datastore.Key  `json:"key"`
+		Entity json.RawMessage `json:"entity"`
+	}
+	if err := json.Unmarshal(line, &l); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(l.Entity, e); err != nil {
+		return nil, nil, err
+	}
+	if l.Key == nil {
+		return nil, e, nil
+	}
+	if l.Key.Kind() != d.kind {
+		return nil, nil, fmt.Errorf("Key [%v] is not of kind [%s]", l.Key, d.kind)
+	}
+	return rekey(d.ctx, l.Key), e, nil
+}
+
+// rekey rebuilds key for the app and namespace of ctx, which may differ
+// from those it was exported from
+func rekey(ctx context.Context, key *datastore.Key) *datastore.Key {
+	if key == nil {
+		return nil
+	}
+	return datastore.NewKey(ctx, key.Kind(), key.StringID(), key.IntID(), rekey(ctx, key.Parent()))
+}
+
+// csvDecoder sets the fields named by the header row of a CSV object
+type csvDecoder struct {
+	kind   string
+	r      *csv.Reader
+	fields []string
+}
+
+func newCSVDecoder(ctx context.Context, r io.Reader, kind string) (*csvDecoder, error) {
+	d := &csvDecoder{kind: kind, r: csv.NewReader(r)}
+	header, err := d.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	d.fields = header
+	return d, nil
+}
+
+func (d *csvDecoder) skip() error {
+	_, err := d.r.Read()
+	return err
+}
+
+func (d *csvDecoder) next() (*datastore.Key, Entity, error) {
+	row, err := d.r.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := newEntity(d.kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := reflect.ValueOf(e).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("Registered type [%v] is not a struct", v.Type())
+	}
+	fields := typeInfoOf(v.Type()).fields
+	for i, name := range d.fields {
+		var f reflect.Value
+		if index, ok := fields[name]; ok {
+			f = v.FieldByIndex(index)
+		} else {
+			// Fields promoted from embedded structs
+			f = v.FieldByName(name)
+		}
+		if !f.IsValid() || !f.CanSet() {
+			return nil, nil, fmt.Errorf("No field [%s] in [%v]", name, v.Type())
+		}
+		if err := setField(f, row[i]); err != nil {
+			return nil, nil, fmt.Errorf("Invalid value for field [%s]: %v", name, err)
+		}
+	}
+	return nil, e, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setField parses s into f, an empty string leaving f unset
+func setField(f reflect.Value, s string) error {
+	if s == "" {
+		return nil
+	}
+	if f.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("Unsupported type [%v]", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+// labelled trims its label before it's put, and doesn't keep its ID
+type labelled struct {
+	ID    string `datastore:"-"`
+	Label string
+}
+
+func (l *labelled) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "labelled", l.ID, 0, nil)
+}
+
+func (l *labelled) BeforePut(ctx context.Context) error {
+	l.Label = strings.TrimSpace(l.Label)
+	return nil
+}
+
+func init() {
+	gaestore.Register("labelled", &labelled{})
+}
+
+func TestImporter(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	src := gaestoretest.NewStore(t)
+	for i := 0; i < 5; i++ {
+		if _, err := src.Put(ctx, &exported{ID: fmt.Sprint(i), Name: fmt.Sprint("name", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := gaestoretest.NewBucket()
+	if err := src.NewExporter(b, "exported", "backup").Export(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b.SetObject("extra.csv", []byte("ID,Name\n5,name5\n6,\n"))
+
+	// An import stopped by an invalid entity resumes from its last chunk
+	dst := gaestoretest.NewStore(t)
+	x := dst.NewImporter(b, "exported", "backup-00000.ndjson", "extra.csv")
+	x.ChunkSize = 2
+	errInvalid := errors.New("no name")
+	x.Validate = func(ctx context.Context, e gaestore.Entity) error {
+		if e.(*exported).Name == "" {
+			return errInvalid
+		}
+		return nil
+	}
+	if err := x.Import(ctx); err == nil {
+		t.Fatal("Expected the entity without a name to fail the import")
+	}
+	p, err := x.Progress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Entities != 5 || p.Done {
+		t.Fatalf("Expected to be stopped after 5 entities but got [%+v]", p)
+	}
+
+	x.Validate = nil
+	if err := x.Import(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ = x.Progress(ctx); !p.Done || p.Entities != 7 {
+		t.Fatalf("Expected 7 entities to be imported but got [%+v]", p)
+	}
+	for i := 0; i < 7; i++ {
+		got := &exported{ID: fmt.Sprint(i)}
+		if err := dst.Get(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprint("name", i); i != 6 && got.Name != expected {
+			t.Fatalf("Expected [%s] but got [%s]", expected, got.Name)
+		}
+	}
+	if err := dst.Get(ctx, &exported{ID: "7"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+}
+
+func TestImporterKeepsKeys(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	src := gaestoretest.NewStore(t)
+	if _, err := src.Put(ctx, &labelled{ID: "1", Label: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	b := gaestoretest.NewBucket()
+	if err := src.NewExporter(b, "labelled", "backup").Export(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The exported keys are kept though the hook runs on entities whose
+	// own keys have no ID
+	dst := gaestoretest.NewStore(t)
+	if err := dst.NewImporter(b, "labelled", "backup-00000.ndjson").Import(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := &labelled{ID: "1"}
+	if err := dst.Get(ctx, got); err != nil || got.Label != "one" {
+		t.Fatalf("Expected the entity under its exported key but got [%+v] %v", got, err)
+	}
+}
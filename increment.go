@@ -0,0 +1,191 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// Incrementer is implemented by Cachers that can atomically increment a
+// counter, for Increment's CachedIncrement fast path. Counters must be
+// readable with Get as decimal strings, as they are in memcache.
+type Incrementer interface {
+	// Increment adds delta to the counter at key, first setting it to
+	// initial when it doesn't exist, and returns the new value
+	Increment(ctx context.Context, key string, delta int64, initial uint64) (uint64, error)
+}
+
+// CachedIncrement makes Increment add to a counter in the cache, seeded
+// from the entity, rather than writing the entity in a transaction. It
+// suits high rate approximate counts like page views: increments are lost
+// if the counter is evicted before FlushIncrement writes it to the
+// datastore, and counters can't go below zero.
+var CachedIncrement CallOption = func(o *callOptions) {
+	o.cachedIncrement = true
+}
+
+// Increment atomically adds delta to the integer property field of e and
+// returns the new value. The entity is read and written in a transaction,
+// joining the one ctx is in if any, created from e when it doesn't exist,
+// and e is loaded with the result. Put hooks are not run.
+func (s *Client) Increment(ctx context.Context, e Entity, field string, delta int64, opts ...CallOption) (int64, error) {
+	incr := &increment{field: field, delta: delta}
+	err := s.run(ctx, &Operation{Name: OpIncrement, Entity: e, incr: incr, opts: newCallOptions(opts)})
+	return incr.value, err
+}
+
+// increment is the change made by an OpIncrement, which either adds delta
+// to the field or when set sets it to delta
+type increment struct {
+	field string
+	delta int64
+	set   bool
+	value int64
+}
+
+func (i *increment) apply(n int64) int64 {
+	if i.set {
+		return i.delta
+	}
+	return n + i.delta
+}
+
+func (s *Client) increment(ctx context.Context, key *datastore.Key, e Entity, incr *increment, opts callOptions) (err error) {
+	if opts.cachedIncrement && !incr.set {
+		incr.value, err = s.cachedIncrement(ctx, key, incr.field, incr.delta)
+		return err
+	}
+	var props datastore.PropertyList
+	err = s.transact(ctx, func(tc context.Context) error {
+		props = nil
+		err := s.datastoreGet(tc, key, &props)
+		if err == datastore.ErrNoSuchEntity {
+			props, err = saveEntity(e)
+		}
+		if err != nil {
+			return err
+		}
+		if incr.value, err = updateProperty(&props, incr.field, incr.apply); err != nil {
+			return err
+		}
+		if _, err := s.datastorePut(tc, key, &props); err != nil {
+			return err
+		}
+		if s.auditUser != nil {
+			return s.putAuditEntry(tc, OpIncrement, key, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.loadEntity(ctx, key, e, props); err != nil {
+		return err
+	}
+	if s.useCache {
+		s.refreshCache(ctx, key, e)
+	}
+	if opts.skipHooks {
+		return nil
+	}
+	return afterGet(ctx, key, e)
+}
+
+// cachedIncrement adds delta to the cached counter for the field, seeding
+// it from the datastore when missing
+func (s *Client) cachedIncrement(ctx context.Context, key *datastore.Key, field string, delta int64) (int64, error) {
+	inc, ok := s.mc().(Incrementer)
+	if !ok {
+		return 0, fmt.Errorf("Cacher [%T] does not support increments", s.mc())
+	}
+	var initial uint64
+	if _, err := s.counterGet(ctx, key, field); err == memcache.ErrCacheMiss {
+		n, err := s.storedCount(ctx, key, field)
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			initial = uint64(n)
+		}
+	}
+	ctx, done := s.startRPC(ctx, RPCMemcacheIncrement, key.Kind())
+	n, err := inc.Increment(ctx, counterKey(key, field), delta, initial)
+	done(err)
+	return int64(n), err
+}
+
+func (s *Client) counterGet(ctx context.Context, key *datastore.Key, field string) ([]byte, error) {
+	ctx, done := s.startRPC(ctx, RPCMemcacheGet, key.Kind())
+	value, err := s.mc().Get(ctx, counterKey(key, field))
+	done(err)
+	return value, err
+}
+
+// FlushIncrement writes the cached counter of e's field, kept by
+// increments made with CachedIncrement, to the datastore
+func (s *Client) FlushIncrement(ctx context.Context, e Entity, field string) error {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := e.Key(ctx)
+	value, err := s.counterGet(ctx, key, field)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid counter [%s] for [%v]: %v", value, key, err)
+	}
+	incr := &increment{field: field, delta: n, set: true}
+	return s.run(ctx, &Operation{Name: OpIncrement, Entity: e, Key: key, incr: incr})
+}
+
+// storedCount returns the value of the property field of the entity for
+// key, or zero when either doesn't exist
+func (s *Client) storedCount(ctx context.Context, key *datastore.Key, field string) (int64, error) {
+	var props datastore.PropertyList
+	err := s.datastoreGet(ctx, key, &props)
+	if err == datastore.ErrNoSuchEntity {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range props {
+		if p.Name == field {
+			n, _ := p.Value.(int64)
+			return n, nil
+		}
+	}
+	return 0, nil
+}
+
+func counterKey(key *datastore.Key, field string) string {
+	return "gaestore:counter:" + key.Encode() + ":" + field
+}
+
+// updateProperty replaces the integer property field with the result of
+// fn, adding the property when it's missing, and returns its new value
+func updateProperty(props *datastore.PropertyList, field string, fn func(int64) int64) (int64, error) {
+	for i, p := range *props {
+		if p.Name != field {
+			continue
+		}
+		n, ok := p.Value.(int64)
+		if !ok || p.Multiple {
+			return 0, fmt.Errorf("Property [%s] is not an integer", field)
+		}
+		(*props)[i].Value = fn(n)
+		return fn(n), nil
+	}
+	n := fn(0)
+	*props = append(*props, datastore.Property{Name: field, Value: n})
+	return n, nil
+}
@@ -0,0 +1,82 @@
+package gaestore
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type page struct {
+	ID    string `datastore:"-"`
+	Title string
+	Views int
+}
+
+func (p *page) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "page", p.ID, 0, nil)
+}
+
+func TestIncrement(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	// A missing entity is created from e
+	p := &page{ID: "home", Title: "Home"}
+	n, err := s.Increment(ctx, p, "Views", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || p.Views != 1 {
+		t.Fatalf("Expected [1] view but got [%d] and [%d]", n, p.Views)
+	}
+
+	got := &page{ID: "home"}
+	if n, err = s.Increment(ctx, got, "Views", 5); err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 || got.Views != 6 || got.Title != "Home" {
+		t.Fatalf("Expected [6] views of the stored page but got [%d] and [%+v]", n, got)
+	}
+	cached := &page{ID: "home"}
+	if err := s.Get(ctx, cached); err != nil {
+		t.Fatal(err)
+	}
+	if cached.Views != 6 {
+		t.Fatalf("Expected the cache to be refreshed with [6] views but got [%d]", cached.Views)
+	}
+
+	if _, err := s.Increment(ctx, got, "Title", 1); err == nil {
+		t.Fatal("Expected incrementing a string property to fail")
+	}
+}
+
+func TestIncrementInTransaction(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(flatBackend{backend}), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+	p := &page{ID: "home"}
+	if _, err := s.Increment(ctx, p, "Views", 1); err != nil {
+		t.Fatal(err)
+	}
+	key := p.Key(ctx).Encode()
+	cached := string(cacher[key])
+
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := s.Increment(tc, p, "Views", 1); err != nil {
+			return err
+		}
+		if string(cacher[key]) != cached {
+			t.Fatal("Expected the cache not to change before the transaction commits")
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &page{ID: "home"}
+	if err := s.Get(ctx, got); err != nil || got.Views != 2 {
+		t.Fatalf("Expected [2] views but got [%d] %v", got.Views, err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package appds converts App Engine datastore keys, queries and cursors to
+// and from their parts, for backends that don't use the App Engine
+// datastore API.
+//
+// Queries and cursors keep their state in unexported fields, which are
+// read with reflection.
+package appds
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrForeignCursor is returned by UnwrapCursor for cursors that were not
+// created by WrapCursor
+var ErrForeignCursor = errors.New("appds: cursor was not created by this backend")
+
+// Namespace returns the namespace set on ctx with appengine.Namespace
+func Namespace(ctx context.Context) string {
+	// The namespace isn't exposed, but is applied to every new key
+	return datastore.NewKey(ctx, "Namespace", "", 1, nil).Namespace()
+}
+
+// NewKey returns a key in the given application and namespace. Unlike
+// datastore.NewKey it doesn't need an App Engine context.
+func NewKey(appID, namespace, kind, name string, id int64, parent *datastore.Key) (*datastore.Key, error) {
+	if appID == "" || kind == "" {
+		return nil, datastore.ErrInvalidKey
+	}
+	var path []byte
+	for k := parent; k != nil; k = k.Parent() {
+		path = append(appendElement(nil, k.Kind(), k.StringID(), k.IntID()), path...)
+	}
+	path = appendElement(path, kind, name, id)
+
+	var b []byte
+	b = protowire.AppendTag(b, 13, protowire.BytesType)
+	b = protowire.AppendString(b, appID)
+	b = protowire.AppendTag(b, 14, protowire.BytesType)
+	b = protowire.AppendBytes(b, path)
+	if namespace != "" {
+		b = protowire.AppendTag(b, 20, protowire.BytesType)
+		b = protowire.AppendString(b, namespace)
+	}
+	return datastore.DecodeKey(base64.URLEncoding.EncodeToString(b))
+}
+
+// appendElement appends a Path.Element group
+func appendElement(b []byte, kind, name string, id int64) []byte {
+	b = protowire.AppendTag(b, 1, protowire.StartGroupType)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, kind)
+	if name != "" {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+	} else if id != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(id))
+	}
+	return protowire.AppendTag(b, 1, protowire.EndGroupType)
+}
+
+// WrapCursor returns a datastore cursor holding a backend specific cursor
+func WrapCursor(s string) (datastore.Cursor, error) {
+	if s == "" {
+		return datastore.Cursor{}, nil
+	}
+	// A CompiledCursor with s as the start key of its position
+	var b []byte
+	b = protowire.AppendTag(b, 2, protowire.StartGroupType)
+	b = protowire.AppendTag(b, 27, protowire.BytesType)
+	b = protowire.AppendString(b, s)
+	b = protowire.AppendTag(b, 2, protowire.EndGroupType)
+	return datastore.DecodeCursor(base64.URLEncoding.EncodeToString(b))
+}
+
+// UnwrapCursor returns the backend specific cursor held by c. The zero
+// cursor unwraps to the empty string.
+func UnwrapCursor(c datastore.Cursor) (string, error) {
+	s := c.String()
+	if s == "" {
+		return "", nil
+	}
+	if n := len(s) % 4; n != 0 {
+		s += strings.Repeat("=", 4-n)
+	}
+	b, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	num, typ, n := protowire.ConsumeTag(b)
+	if n < 0 || num != 2 || typ != protowire.StartGroupType {
+		return "", ErrForeignCursor
+	}
+	b = b[n:]
+	num, typ, n = protowire.ConsumeTag(b)
+	if n < 0 || num != 27 || typ != protowire.BytesType {
+		return "", ErrForeignCursor
+	}
+	b = b[n:]
+	v, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return "", ErrForeignCursor
+	}
+	return v, nil
+}
+
+// Filter is a property filter, with Op one of "<", "<=", "=", ">=" or ">"
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// Order sorts query results by Field
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Query holds the settings of a *datastore.Query
+type Query struct {
+	Kind       string
+	Ancestor   *datastore.Key
+	Filters    []Filter
+	Orders     []Order
+	Projection []string
+	Distinct   bool
+	DistinctOn []string
+	KeysOnly   bool
+	Eventual   bool
+
+	// Limit is negative when the query is unlimited
+	Limit  int32
+	Offset int32
+	Start  datastore.Cursor
+	End    datastore.Cursor
+}
+
+var operators = []string{"<", "<=", "=", ">=", ">"}
+
+// ParseQuery returns the settings of q, or the error recorded when q was
+// built
+func ParseQuery(q *datastore.Query) (*Query, error) {
+	v := reflect.ValueOf(q).Elem()
+	if err, _ := field(v, "err").Interface().(error); err != nil {
+		return nil, err
+	}
+	pq := &Query{
+		Kind:       v.FieldByName("kind").String(),
+		Ancestor:   field(v, "ancestor").Interface().(*datastore.Key),
+		Projection: field(v, "projection").Interface().([]string),
+		Distinct:   v.FieldByName("distinct").Bool(),
+		KeysOnly:   v.FieldByName("keysOnly").Bool(),
+		Eventual:   v.FieldByName("eventual").Bool(),
+		Limit:      int32(v.FieldByName("limit").Int()),
+		Offset:     int32(v.FieldByName("offset").Int()),
+		Start:      cursor(v, "start"),
+		End:        cursor(v, "end"),
+	}
+	if f := v.FieldByName("distinctOn"); f.IsValid() {
+		pq.DistinctOn = field(v, "distinctOn").Interface().([]string)
+	}
+	filters := v.FieldByName("filter")
+	for i := 0; i < filters.Len(); i++ {
+		f := filters.Index(i)
+		op := f.FieldByName("Op").Int()
+		if op < 0 || int(op) >= len(operators) {
+			return nil, fmt.Errorf("Unknown operator [%d]", op)
+		}
+		pq.Filters = append(pq.Filters, Filter{
+			Field: f.FieldByName("FieldName").String(),
+			Op:    operators[op],
+			Value: exported(f.FieldByName("Value")).Interface(),
+		})
+	}
+	orders := v.FieldByName("order")
+	for i := 0; i < orders.Len(); i++ {
+		o := orders.Index(i)
+		pq.Orders = append(pq.Orders, Order{
+			Field:      o.FieldByName("FieldName").String(),
+			Descending: o.FieldByName("Direction").Int() != 0,
+		})
+	}
+	return pq, nil
+}
+
+// QueryLimit returns the limit of q, which is negative when q is unlimited.
+// It is cheaper than ParseQuery when only the limit is needed.
+func QueryLimit(q *datastore.Query) int32 {
+	return int32(reflect.ValueOf(q).Elem().FieldByName("limit").Int())
+}
+
+// field returns the named unexported field of v, which must be addressable
+func field(v reflect.Value, name string) reflect.Value {
+	return exported(v.FieldByName(name))
+}
+
+func exported(f reflect.Value) reflect.Value {
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// cursor returns the compiled cursor in the named field as a Cursor
+func cursor(v reflect.Value, name string) datastore.Cursor {
+	var c datastore.Cursor
+	cc := field(v, name)
+	if cc.IsNil() {
+		return c
+	}
+	field(reflect.ValueOf(&c).Elem(), "cc").Set(cc)
+	return c
+}
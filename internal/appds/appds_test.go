@@ -0,0 +1,117 @@
+package appds
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestNamespace(t *testing.T) {
+	t.Setenv("GAE_APPLICATION", "testapp")
+	ctx, err := appengine.Namespace(context.Background(), "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns := Namespace(ctx); ns != "tenant" {
+		t.Fatalf("Expected namespace [tenant] but got [%s]", ns)
+	}
+	if ns := Namespace(context.Background()); ns != "" {
+		t.Fatalf("Expected no namespace but got [%s]", ns)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	parent, err := NewKey("app", "ns", "Parent", "", 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewKey("app", "ns", "Child", "c", 0, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.AppID() != "app" || key.Namespace() != "ns" || key.Kind() != "Child" || key.StringID() != "c" {
+		t.Fatalf("Expected key [app ns Child c] but got [%v %v %v %v]", key.AppID(), key.Namespace(), key.Kind(), key.StringID())
+	}
+	if !key.Parent().Equal(parent) || key.Parent().IntID() != 7 {
+		t.Fatalf("Expected parent [%v] but got [%v]", parent, key.Parent())
+	}
+
+	incomplete, err := NewKey("app", "", "Child", "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !incomplete.Incomplete() {
+		t.Fatalf("Expected key [%v] to be incomplete", incomplete)
+	}
+	if _, err := NewKey("", "", "Child", "c", 0, nil); err != datastore.ErrInvalidKey {
+		t.Fatalf("Expected error [%v] but got [%v]", datastore.ErrInvalidKey, err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	for _, s := range []string{"", "CgwSBm9iamVjdCIBMQ", "42"} {
+		c, err := WrapCursor(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := UnwrapCursor(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Fatalf("Expected cursor [%s] but got [%s]", s, got)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	ancestor, _ := NewKey("app", "", "Parent", "p", 0, nil)
+	start, _ := WrapCursor("start")
+	q := datastore.NewQuery("Kind").
+		Ancestor(ancestor).
+		Filter("Age >=", 18).
+		Filter("Name =", "John").
+		Order("-Age").
+		Order("Name").
+		Project("Age", "Name").
+		Distinct().
+		KeysOnly().
+		EventualConsistency().
+		Limit(10).
+		Offset(5).
+		Start(start)
+	got, err := ParseQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Query{
+		Kind:       "Kind",
+		Ancestor:   ancestor,
+		Filters:    []Filter{{"Age", ">=", 18}, {"Name", "=", "John"}},
+		Orders:     []Order{{"Age", true}, {"Name", false}},
+		Projection: []string{"Age", "Name"},
+		Distinct:   true,
+		KeysOnly:   true,
+		Eventual:   true,
+		Limit:      10,
+		Offset:     5,
+	}
+	startCursor, err := UnwrapCursor(got.Start)
+	if err != nil || startCursor != "start" {
+		t.Fatalf("Expected start cursor [start] but got [%s] [%v]", startCursor, err)
+	}
+	got.Start = datastore.Cursor{}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected query [%+v] but got [%+v]", expected, got)
+	}
+
+	if _, err := ParseQuery(datastore.NewQuery("Kind").Filter("Age", 1)); err == nil {
+		t.Fatalf("Expected an error for an invalid filter")
+	}
+	if got, _ := ParseQuery(datastore.NewQuery("Kind")); got.Limit >= 0 {
+		t.Fatalf("Expected an unlimited query but got limit [%d]", got.Limit)
+	}
+}
@@ -0,0 +1,260 @@
+package appds
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+const keyField = "__key__"
+
+// Matches reports whether the entity with key and props is a result of q,
+// leaving aside the namespace
+func Matches(q *Query, key *datastore.Key, props []datastore.Property) bool {
+	if q.Kind != "" && key.Kind() != q.Kind {
+		return false
+	}
+	if q.Ancestor != nil && !hasAncestor(key, q.Ancestor) {
+		return false
+	}
+	for _, f := range q.Filters {
+		if !matchesFilter(f, key, props) {
+			return false
+		}
+	}
+	// Entities without an ordered property are not in the index
+	for _, o := range q.Orders {
+		if len(Values(key, props, o.Field)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAncestor(key, ancestor *datastore.Key) bool {
+	for k := key; k != nil; k = k.Parent() {
+		if k.Equal(ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter reports whether any of the property's indexed values
+// satisfies f
+func matchesFilter(f Filter, key *datastore.Key, props []datastore.Property) bool {
+	for _, v := range Values(key, props, f.Field) {
+		c := Compare(v, Normalize(f.Value))
+		switch f.Op {
+		case "<":
+			if c < 0 {
+				return true
+			}
+		case "<=":
+			if c <= 0 {
+				return true
+			}
+		case "=":
+			if c == 0 {
+				return true
+			}
+		case ">=":
+			if c >= 0 {
+				return true
+			}
+		case ">":
+			if c > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Values returns the indexed values of the named property of the entity
+// with key and props, normalized to the types properties are stored as
+func Values(key *datastore.Key, props []datastore.Property, name string) []interface{} {
+	if name == keyField {
+		return []interface{}{key}
+	}
+	var vs []interface{}
+	for _, p := range props {
+		if p.Name == name && !p.NoIndex {
+			vs = append(vs, Normalize(p.Value))
+		}
+	}
+	return vs
+}
+
+// Less reports whether the entity with key a and props pa is before the
+// one with key b and props pb in the results of a query with orders
+func Less(orders []Order, a *datastore.Key, pa []datastore.Property, b *datastore.Key, pb []datastore.Property) bool {
+	for _, o := range orders {
+		va, vb := sortValue(a, pa, o), sortValue(b, pb, o)
+		if c := Compare(va, vb); c != 0 {
+			return (c < 0) != o.Descending
+		}
+	}
+	return CompareKeys(a, b) < 0
+}
+
+// sortValue returns the value an entity is sorted by, the smallest of a
+// multiple valued property when ascending and the largest when descending
+func sortValue(key *datastore.Key, props []datastore.Property, o Order) interface{} {
+	vs := Values(key, props, o.Field)
+	if len(vs) == 0 {
+		return nil
+	}
+	best := vs[0]
+	for _, v := range vs[1:] {
+		if c := Compare(v, best); c < 0 && !o.Descending || c > 0 && o.Descending {
+			best = v
+		}
+	}
+	return best
+}
+
+// Normalize converts the value types accepted in filters to the types
+// properties are stored as
+func Normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case datastore.ByteString:
+		return []byte(v)
+	case appengine.BlobKey:
+		return string(v)
+	}
+	return v
+}
+
+// rank orders values of different types as the datastore does
+func rank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, time.Time:
+		return 1
+	case bool:
+		return 2
+	case []byte:
+		return 3
+	case string:
+		return 4
+	case float64:
+		return 5
+	case appengine.GeoPoint:
+		return 6
+	case *datastore.Key:
+		return 7
+	}
+	return 8
+}
+
+// Compare orders values of the types properties are stored as like the
+// datastore
+func Compare(a, b interface{}) int {
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case int64, time.Time:
+		return compareInt(micros(a), micros(b))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return compareFloat(a, b.(float64))
+	case appengine.GeoPoint:
+		g := b.(appengine.GeoPoint)
+		if c := compareFloat(a.Lat, g.Lat); c != 0 {
+			return c
+		}
+		return compareFloat(a.Lng, g.Lng)
+	case *datastore.Key:
+		return CompareKeys(a, b.(*datastore.Key))
+	}
+	return 0
+}
+
+// micros returns times as microseconds, which they're stored as
+func micros(v interface{}) int64 {
+	if t, ok := v.(time.Time); ok {
+		return t.UnixNano() / 1e3
+	}
+	return v.(int64)
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// CompareKeys orders keys by their paths from the root, with IDs before
+// names
+func CompareKeys(a, b *datastore.Key) int {
+	pa, pb := path(a), path(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, y := pa[i], pb[i]
+		if c := strings.Compare(x.Kind(), y.Kind()); c != 0 {
+			return c
+		}
+		switch {
+		case x.StringID() == "" && y.StringID() != "":
+			return -1
+		case x.StringID() != "" && y.StringID() == "":
+			return 1
+		}
+		if c := compareInt(x.IntID(), y.IntID()); c != 0 {
+			return c
+		}
+		if c := strings.Compare(x.StringID(), y.StringID()); c != 0 {
+			return c
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func path(k *datastore.Key) []*datastore.Key {
+	var p []*datastore.Key
+	for ; k != nil; k = k.Parent() {
+		p = append([]*datastore.Key{k}, p...)
+	}
+	return p
+}
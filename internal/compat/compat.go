@@ -0,0 +1,121 @@
+// Package compat adapts values that aren't gaestore.Entities, for the goon
+// and nds packages.
+package compat
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/floresj/gaestore"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// ErrLength is returned for a slice of values that isn't as long as the
+// keys
+var ErrLength = errors.New("gaestore: keys and values have different lengths")
+
+// Entity is a gaestore.Entity storing Src under K
+type Entity struct {
+	K   *datastore.Key
+	Src interface{}
+}
+
+func (e *Entity) Key(ctx context.Context) *datastore.Key {
+	return e.K
+}
+
+func (e *Entity) Load(props []datastore.Property) error {
+	if pls, ok := e.Src.(datastore.PropertyLoadSaver); ok {
+		return pls.Load(props)
+	}
+	return datastore.LoadStruct(e.Src, props)
+}
+
+func (e *Entity) Save() ([]datastore.Property, error) {
+	if pls, ok := e.Src.(datastore.PropertyLoadSaver); ok {
+		return pls.Save()
+	}
+	return datastore.SaveStruct(e.Src)
+}
+
+// Result is a query result, holding its key and properties to be loaded
+// into the caller's type
+type Result struct {
+	K     *datastore.Key
+	Props datastore.PropertyList
+}
+
+func (r *Result) Key(ctx context.Context) *datastore.Key {
+	return r.K
+}
+
+func (r *Result) Load(props []datastore.Property) error {
+	r.Props = props
+	return nil
+}
+
+func (r *Result) Save() ([]datastore.Property, error) {
+	return r.Props, nil
+}
+
+// AfterGet records the key of the result
+func (r *Result) AfterGet(ctx context.Context, key *datastore.Key) error {
+	r.K = key
+	return nil
+}
+
+// Err returns datastore.ErrNoSuchEntity for missing entities, which goon
+// and nds return unwrapped
+func Err(err error) error {
+	if err != nil && gaestore.IsNotFound(err) {
+		return datastore.ErrNoSuchEntity
+	}
+	return err
+}
+
+// Each calls fn with each index up to n, returning an appengine.MultiError
+// holding the errors when any fail
+func Each(n int, fn func(i int) error) error {
+	var merr appengine.MultiError
+	for i := 0; i < n; i++ {
+		if err := fn(i); err != nil {
+			if merr == nil {
+				merr = make(appengine.MultiError, n)
+			}
+			merr[i] = err
+		}
+	}
+	if merr != nil {
+		return merr
+	}
+	return nil
+}
+
+// Values returns pointers to the values of the slice v, which must be as
+// long as the keys
+func Values(keys []*datastore.Key, v interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Len() != len(keys) {
+		return nil, ErrLength
+	}
+	vals := make([]interface{}, len(keys))
+	for i := range vals {
+		elem := rv.Index(i)
+		if elem.Kind() != reflect.Ptr && elem.Kind() != reflect.Interface {
+			elem = elem.Addr()
+		}
+		vals[i] = elem.Interface()
+	}
+	return vals, nil
+}
+
+// Entities wraps vals stored under keys
+func Entities(keys []*datastore.Key, vals []interface{}) []gaestore.Entity {
+	entities := make([]gaestore.Entity, len(keys))
+	for i, key := range keys {
+		entities[i] = &Entity{K: key, Src: vals[i]}
+	}
+	return entities
+}
@@ -0,0 +1,12 @@
+package gaestore
+
+// Hooks for the external tests
+
+// EnqueueMapShard replaces the task queue used by map jobs
+var EnqueueMapShard = &enqueueMapShard
+
+// RunMapShard runs a map shard task
+var RunMapShard = runMapShard
+
+// CallPutLaterTask replaces the task queue used by PutLater
+var CallPutLaterTask = &callPutLaterTask
@@ -0,0 +1,84 @@
+package gaestore
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// JSONProperty is a field holding a value of any type, such as a nested
+// struct the datastore can't flatten or a map, stored as JSON in a single
+// unindexed property named after the field with a ".JSON" suffix. Value is
+// encoded when the entity is written and decoded when it's read, and
+// cached entities hold Value as is.
+//
+//	type User struct {
+//		ID    string `datastore:"-"`
+//		Prefs gaestore.JSONProperty[map[string][]string]
+//	}
+//
+// Values are limited to the 1MB of a datastore property, and as the
+// property isn't indexed they can't be queried.
+type JSONProperty[T any] struct {
+	Value T `datastore:"-"`
+
+	// JSON is Value as last written or read
+	JSON string `datastore:",noindex" json:"-"`
+}
+
+// jsonValue is implemented by JSONProperty fields
+type jsonValue interface {
+	encodeJSON() error
+	decodeJSON() error
+}
+
+var jsonValueType = reflect.TypeOf((*jsonValue)(nil)).Elem()
+
+func (p *JSONProperty[T]) encodeJSON() error {
+	b, err := json.Marshal(p.Value)
+	if err != nil {
+		return err
+	}
+	p.JSON = string(b)
+	return nil
+}
+
+func (p *JSONProperty[T]) decodeJSON() error {
+	var zero T
+	p.Value = zero
+	if p.JSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(p.JSON), &p.Value)
+}
+
+// encodeJSONFields encodes the JSONProperty fields of src before it's
+// written
+func encodeJSONFields(src interface{}) error {
+	return eachJSONField(src, jsonValue.encodeJSON)
+}
+
+// decodeJSONFields decodes the JSONProperty fields of dst once it's read
+func decodeJSONFields(dst interface{}) error {
+	return eachJSONField(dst, jsonValue.decodeJSON)
+}
+
+// eachJSONField calls fn with the JSONProperty fields of e, unless e is a
+// PropertyLoadSaver encoding its own fields
+func eachJSONField(e interface{}, fn func(jsonValue) error) error {
+	if _, ok := e.(datastore.PropertyLoadSaver); ok {
+		return nil
+	}
+	info := structInfo(e)
+	if info == nil || len(info.jsonFields) == 0 {
+		return nil
+	}
+	v := reflect.ValueOf(e).Elem()
+	for _, i := range info.jsonFields {
+		if err := fn(v.Field(i).Addr().Interface().(jsonValue)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package gaestore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type prefs struct {
+	Theme  string
+	Alerts map[string][]string
+}
+
+type profile struct {
+	ID    string `datastore:"-"`
+	Prefs JSONProperty[prefs]
+}
+
+func (p *profile) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "profile", p.ID, 0, nil)
+}
+
+func TestJSONProperty(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	cacher := mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	want := prefs{Theme: "dark", Alerts: map[string][]string{"email": {"weekly"}}}
+	p := &profile{ID: "1", Prefs: JSONProperty[prefs]{Value: want}}
+	key, err := s.Put(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := backend[key.Encode()]
+	if len(props) != 1 || props[0].Name != "Prefs.JSON" || !props[0].NoIndex {
+		t.Fatalf("Expected a single unindexed property but got [%+v]", props)
+	}
+
+	for _, useCache := range []bool{true, false} {
+		if !useCache {
+			for k := range cacher {
+				delete(cacher, k)
+			}
+		}
+		got := &profile{ID: "1"}
+		if err := s.Get(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Prefs.Value, want) {
+			t.Fatalf("Expected [%+v] but got [%+v] with cache %v", want, got.Prefs.Value, useCache)
+		}
+	}
+}
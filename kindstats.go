@@ -0,0 +1,123 @@
+package gaestore
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+// OpStats aggregates the calls of a single RPC
+type OpStats struct {
+	Calls   int
+	Errors  int
+	Latency time.Duration
+}
+
+// MeanLatency returns the average latency of the calls
+func (s OpStats) MeanLatency() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Calls)
+}
+
+// ErrorRate returns the fraction of calls that failed
+func (s OpStats) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// KindSnapshot is the breakdown of the calls made for a single kind
+type KindSnapshot struct {
+	// Ops is keyed by RPC name, for example datastore.Get
+	Ops         map[string]OpStats
+	CacheHits   int
+	CacheMisses int
+}
+
+// HitRate returns the fraction of cache reads that were hits
+func (s KindSnapshot) HitRate() float64 {
+	total := s.CacheHits + s.CacheMisses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(total)
+}
+
+// KindStats is a Metrics that keeps call counts, latencies, error rates and
+// cache hit rates for each entity kind, so a single misbehaving kind stands
+// out from the aggregate numbers.
+type KindStats struct {
+	mu    sync.Mutex
+	kinds map[string]*KindSnapshot
+}
+
+func NewKindStats() *KindStats {
+	return &KindStats{kinds: make(map[string]*KindSnapshot)}
+}
+
+func (k *KindStats) ObserveOp(op, kind string, d time.Duration, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	snap, ok := k.kinds[kind]
+	if !ok {
+		snap = &KindSnapshot{Ops: make(map[string]OpStats)}
+		k.kinds[kind] = snap
+	}
+	stats := snap.Ops[op]
+	stats.Calls++
+	stats.Latency += d
+	if op == RPCMemcacheGet {
+		switch err {
+		case nil:
+			snap.CacheHits++
+		case memcache.ErrCacheMiss:
+			snap.CacheMisses++
+			err = nil
+		}
+	}
+	if err != nil {
+		stats.Errors++
+	}
+	snap.Ops[op] = stats
+}
+
+// Snapshot returns a copy of the stats collected so far, keyed by kind
+func (k *KindStats) Snapshot() map[string]KindSnapshot {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	snaps := make(map[string]KindSnapshot, len(k.kinds))
+	for kind, snap := range k.kinds {
+		cp := *snap
+		cp.Ops = make(map[string]OpStats, len(snap.Ops))
+		for op, stats := range snap.Ops {
+			cp.Ops[op] = stats
+		}
+		snaps[kind] = cp
+	}
+	return snaps
+}
+
+// Reset discards the stats collected so far
+func (k *KindStats) Reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.kinds = make(map[string]*KindSnapshot)
+}
+
+type multiMetrics []Metrics
+
+// MultiMetrics returns a Metrics that reports to each of metrics, so
+// KindStats can be used alongside an exporter
+func MultiMetrics(metrics ...Metrics) Metrics {
+	return multiMetrics(metrics)
+}
+
+func (m multiMetrics) ObserveOp(op, kind string, d time.Duration, err error) {
+	for _, metrics := range m {
+		metrics.ObserveOp(op, kind, d, err)
+	}
+}
@@ -0,0 +1,35 @@
+package gaestore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+func TestKindStats(t *testing.T) {
+	stats := NewKindStats()
+	m := MultiMetrics(stats)
+	m.ObserveOp(RPCMemcacheGet, "object", time.Millisecond, nil)
+	m.ObserveOp(RPCMemcacheGet, "object", time.Millisecond, memcache.ErrCacheMiss)
+	m.ObserveOp(RPCDatastoreGet, "object", 10*time.Millisecond, nil)
+	m.ObserveOp(RPCDatastoreGet, "object", 20*time.Millisecond, errors.New("failed"))
+	m.ObserveOp(RPCDatastoreGet, "other", time.Millisecond, nil)
+
+	snaps := stats.Snapshot()
+	if len(snaps) != 2 {
+		t.Fatalf("Expected stats for [2] kinds but got [%v]", len(snaps))
+	}
+	object := snaps["object"]
+	if rate := object.HitRate(); rate != 0.5 {
+		t.Fatalf("Expected hit rate [0.5] but got [%v]", rate)
+	}
+	if errs := object.Ops[RPCMemcacheGet].Errors; errs != 0 {
+		t.Fatalf("Expected misses not to count as errors but got [%v]", errs)
+	}
+	get := object.Ops[RPCDatastoreGet]
+	if get.Calls != 2 || get.ErrorRate() != 0.5 || get.MeanLatency() != 15*time.Millisecond {
+		t.Fatalf("Unexpected datastore.Get stats [%+v]", get)
+	}
+}
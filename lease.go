@@ -0,0 +1,217 @@
+package gaestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// LeaseKind is the kind leases are stored under. Expired leases can be
+// deleted with Sweep.
+const LeaseKind = "GaestoreLease"
+
+var (
+	// ErrLeaseHeld is returned by AcquireLease while another owner holds
+	// the lease
+	ErrLeaseHeld = errors.New("gaestore: lease is held")
+
+	// ErrLeaseLost is returned by Renew once the lease has expired and
+	// been acquired by another owner, or released
+	ErrLeaseLost = errors.New("gaestore: lease was lost")
+)
+
+// Lease is the exclusive right to a name until it expires, for singleton
+// cron jobs and leader elected workers. The holder must renew it before it
+// expires to keep it, and should stop its work once Renew fails.
+type Lease struct {
+	Name    string
+	Owner   string
+	Expires time.Time
+
+	s *Client
+}
+
+type leaseEntity struct {
+	Owner   string
+	Expires time.Time
+}
+
+func (e *leaseEntity) ExpiresAt() time.Time {
+	return e.Expires
+}
+
+// AcquireLease acquires the lease called name for ttl, failing with
+// ErrLeaseHeld while another owner holds it. The lease is taken in a
+// transaction, with the cache only used to fail fast while it's held.
+// Called in a transaction, the lease is taken as part of it, so the
+// transaction must be cross group and the lease is only held once it
+// commits.
+func (s *Client) AcquireLease(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.useCache {
+		if expires, ok := s.cachedLease(ctx, name); ok && s.now().Before(expires) {
+			return nil, ErrLeaseHeld
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	l := &Lease{Name: name, Owner: hex.EncodeToString(b), s: s}
+	key := leaseKey(ctx, name)
+	err = s.transact(ctx, func(tc context.Context) error {
+		var e leaseEntity
+		err := s.datastoreGet(tc, key, &e)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if err == nil && s.now().Before(e.Expires) {
+			return ErrLeaseHeld
+		}
+		l.Expires = s.now().Add(ttl)
+		_, err = s.datastorePut(tc, key, &leaseEntity{Owner: l.Owner, Expires: l.Expires})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	afterCommit(ctx, l.cache)
+	return l, nil
+}
+
+// Renew extends the lease to ttl from now, failing with ErrLeaseLost when
+// it's no longer held. Called in a transaction, the lease is only extended
+// once it commits.
+func (l *Lease) Renew(ctx context.Context, ttl time.Duration) error {
+	s := l.s
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := leaseKey(ctx, l.Name)
+	var expires time.Time
+	err = s.transact(ctx, func(tc context.Context) error {
+		var e leaseEntity
+		err := s.datastoreGet(tc, key, &e)
+		if IsNotFound(err) || err == nil && e.Owner != l.Owner {
+			return ErrLeaseLost
+		}
+		if err != nil {
+			return err
+		}
+		expires = s.now().Add(ttl)
+		_, err = s.datastorePut(tc, key, &leaseEntity{Owner: l.Owner, Expires: expires})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	afterCommit(ctx, func(ctx context.Context) {
+		l.Expires = expires
+		l.cache(ctx)
+	})
+	return nil
+}
+
+// Release gives up the lease so it can be acquired straight away. Releasing
+// a lease that was lost does nothing. Called in a transaction, the lease is
+// only released once it commits.
+func (l *Lease) Release(ctx context.Context) error {
+	s := l.s
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := leaseKey(ctx, l.Name)
+	err = s.transact(ctx, func(tc context.Context) error {
+		var e leaseEntity
+		err := s.datastoreGet(tc, key, &e)
+		if IsNotFound(err) || err == nil && e.Owner != l.Owner {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.datastoreDelete(tc, key)
+	})
+	if err != nil {
+		return err
+	}
+	if s.useCache {
+		afterCommit(ctx, func(ctx context.Context) {
+			ctx, done := s.startRPC(ctx, RPCMemcacheDelete, LeaseKind)
+			err := s.mc().Delete(ctx, leaseCacheKey(l.Name))
+			done(err)
+			if err != nil && err != memcache.ErrCacheMiss {
+				// The lease will look held until the cached copy expires
+				s.log().Warningf(ctx, "gaestore: unable to delete lease [%s] from cache: %v", l.Name, err)
+			}
+		})
+	}
+	return nil
+}
+
+// cache caches the lease's owner and expiry until it expires
+func (l *Lease) cache(ctx context.Context) {
+	s := l.s
+	if !s.useCache {
+		return
+	}
+	value := l.Owner + " " + strconv.FormatInt(l.Expires.UnixNano(), 10)
+	ttl := l.Expires.Sub(s.now())
+	if ttl < time.Second {
+		return
+	}
+	ctx, done := s.startRPC(ctx, RPCMemcacheSet, LeaseKind)
+	err := s.mc().Set(ctx, leaseCacheKey(l.Name), []byte(value), ttl)
+	done(err)
+	if err != nil {
+		s.log().Warningf(ctx, "gaestore: unable to cache lease [%s]: %v", l.Name, err)
+	}
+}
+
+// cachedLease returns when the cached copy of the lease expires
+func (s *Client) cachedLease(ctx context.Context, name string) (time.Time, bool) {
+	ctx, done := s.startRPC(ctx, RPCMemcacheGet, LeaseKind)
+	value, err := s.mc().Get(ctx, leaseCacheKey(name))
+	done(err)
+	if err != nil {
+		return time.Time{}, false
+	}
+	parts := strings.SplitN(string(value), " ", 2)
+	if len(parts) != 2 {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, n), true
+}
+
+func leaseKey(ctx context.Context, name string) *datastore.Key {
+	return datastore.NewKey(ctx, LeaseKind, name, 0, nil)
+}
+
+func leaseCacheKey(name string) string {
+	return "gaestore:lease:" + name
+}
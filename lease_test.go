@@ -0,0 +1,75 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestLease(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	l, err := s.AcquireLease(ctx, "cron", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AcquireLease(ctx, "cron", time.Minute); err != gaestore.ErrLeaseHeld {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrLeaseHeld, err)
+	}
+	// With a cold cache the transaction finds the lease held
+	uncached := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithCacher(gaestoretest.NewCacher()))
+	if _, err := uncached.AcquireLease(ctx, "cron", time.Minute); err != gaestore.ErrLeaseHeld {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrLeaseHeld, err)
+	}
+	if err := l.Renew(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Renew(ctx, time.Hour); err != gaestore.ErrLeaseLost {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrLeaseLost, err)
+	}
+
+	// Expired leases can be taken by another owner
+	l, err = s.AcquireLease(ctx, "cron", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.AcquireLease(ctx, "cron", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Owner == l.Owner {
+		t.Fatal("Expected a new owner")
+	}
+	if err := l.Renew(ctx, time.Minute); err != gaestore.ErrLeaseLost {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrLeaseLost, err)
+	}
+}
+
+func TestLeaseInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+
+	// A lease taken by a transaction that fails isn't held
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := s.AcquireLease(tc, "cron", time.Minute); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	if _, err := s.AcquireLease(ctx, "cron", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
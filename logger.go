@@ -0,0 +1,46 @@
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine/log"
+)
+
+// Logger receives the messages a store logs. The default logger writes to
+// the App Engine request log.
+type Logger interface {
+	Debugf(ctx context.Context, format string, args ...interface{})
+	Infof(ctx context.Context, format string, args ...interface{})
+	Warningf(ctx context.Context, format string, args ...interface{})
+	Errorf(ctx context.Context, format string, args ...interface{})
+}
+
+type appengineLogger struct{}
+
+func (appengineLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	log.Debugf(ctx, format, args...)
+}
+
+func (appengineLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	log.Infof(ctx, format, args...)
+}
+
+func (appengineLogger) Warningf(ctx context.Context, format string, args ...interface{}) {
+	log.Warningf(ctx, format, args...)
+}
+
+func (appengineLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	log.Errorf(ctx, format, args...)
+}
+
+// SetLogger replaces the store's logger
+func (s *Client) SetLogger(l Logger) {
+	s.logger = l
+}
+
+func (s *Client) log() Logger {
+	if s.logger == nil {
+		return appengineLogger{}
+	}
+	return s.logger
+}
@@ -0,0 +1,365 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/taskqueue"
+)
+
+// Kinds the progress of map jobs is stored under. Shards are children of
+// their job.
+const (
+	MapJobKind   = "GaestoreMapJob"
+	MapShardKind = "GaestoreMapShard"
+)
+
+// MapFunc is applied to each entity by a map job. A non-nil entity it
+// returns is written, in batches with the rest of the shard's writes. An
+// entity may be mapped more than once if a task is retried.
+type MapFunc func(ctx context.Context, e Entity) (Entity, error)
+
+var mappers = struct {
+	sync.RWMutex
+	m map[string]mapper
+}{m: make(map[string]mapper)}
+
+type mapper struct {
+	s  *Client
+	fn MapFunc
+}
+
+// RegisterMapper registers fn under name so map jobs started with Map can
+// find it from their tasks, which run fn with the store's settings. It is
+// meant to be called from init.
+func (s *Client) RegisterMapper(name string, fn MapFunc) {
+	mappers.Lock()
+	defer mappers.Unlock()
+	mappers.m[name] = mapper{s: s, fn: fn}
+}
+
+// MapOptions configures a map job
+type MapOptions struct {
+	// Shards is the number of ranges of keys mapped in parallel, 8 unless
+	// set. Fewer are used for kinds too small to split.
+	Shards int
+
+	// BatchSize is the number of entities mapped by each task, 100
+	// unless set
+	BatchSize int
+
+	// Queue is the task queue to run the job on, the default queue unless
+	// set
+	Queue string
+}
+
+// MapJob is the progress of a map job
+type MapJob struct {
+	Kind      string
+	Mapper    string
+	BatchSize int
+	Queue     string
+	Shards    []MapShard `datastore:"-"`
+}
+
+// Done reports whether every shard of the job has finished
+func (j *MapJob) Done() bool {
+	for _, sh := range j.Shards {
+		if !sh.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// Entities returns the number of entities mapped so far
+func (j *MapJob) Entities() int {
+	n := 0
+	for _, sh := range j.Shards {
+		n += sh.Entities
+	}
+	return n
+}
+
+// MapShard is the progress of a range of keys of a map job. A nil Start
+// or End leaves the range open.
+type MapShard struct {
+	Start, End *datastore.Key
+	Cursor     string `datastore:",noindex"`
+	Entities   int
+	Done       bool
+	Error      string `datastore:",noindex"`
+}
+
+var mapShardFunc = delay.Func("gaestore.MapShard", runMapShard)
+
+// enqueueMapShard adds the task mapping the next batch of a shard. It's
+// set in init as the task function refers to it.
+var enqueueMapShard func(ctx context.Context, queue, name string, job *datastore.Key, shard int) error
+
+func init() {
+	enqueueMapShard = addMapShardTask
+}
+
+func addMapShardTask(ctx context.Context, queue, name string, job *datastore.Key, shard int) error {
+	t, err := mapShardFunc.Task(name, job.Encode(), shard)
+	if err != nil {
+		return err
+	}
+	_, err = taskqueue.Add(ctx, t, queue)
+	return err
+}
+
+// Map starts a job applying the mapper registered as name to every entity
+// of kind, which must be registered with Register. The keyspace is split
+// into shards that are mapped in parallel by chains of tasks, each task
+// checkpointing its shard before adding the next. It returns the key of
+// the job, for following its progress with MapProgress.
+func (s *Client) Map(ctx context.Context, kind, name string, opts MapOptions) (*datastore.Key, error) {
+	if _, ok := lookupMapper(name); !ok {
+		return nil, fmt.Errorf("No mapper registered as [%s]", name)
+	}
+	if _, err := newEntity(kind); err != nil {
+		return nil, err
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Shards <= 0 {
+		opts.Shards = 8
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	splits, err := s.splitPoints(ctx, kind, opts.Shards)
+	if err != nil {
+		return nil, err
+	}
+	job := &MapJob{Kind: kind, Mapper: name, BatchSize: opts.BatchSize, Queue: opts.Queue}
+	key, err := s.datastorePut(ctx, datastore.NewIncompleteKey(ctx, MapJobKind, nil), job)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i <= len(splits); i++ {
+		sh := &MapShard{}
+		if i > 0 {
+			sh.Start = splits[i-1]
+		}
+		if i < len(splits) {
+			sh.End = splits[i]
+		}
+		if _, err := s.datastorePut(ctx, shardKey(ctx, key, i), sh); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i <= len(splits); i++ {
+		if err := enqueueMapShard(ctx, opts.Queue, name, key, i); err != nil {
+			return nil, err
+		}
+	}
+	s.log().Infof(ctx, "gaestore: started map job [%v] over [%s] with %d shards", key, kind, len(splits)+1)
+	return key, nil
+}
+
+// MapProgress returns the progress of the job started by Map
+func (s *Client) MapProgress(ctx context.Context, key *datastore.Key) (*MapJob, error) {
+	job := &MapJob{}
+	if err := s.datastoreGet(ctx, key, job); err != nil {
+		return nil, err
+	}
+	for i := 0; ; i++ {
+		var sh MapShard
+		err := s.datastoreGet(ctx, shardKey(ctx, key, i), &sh)
+		if err == datastore.ErrNoSuchEntity {
+			return job, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		job.Shards = append(job.Shards, sh)
+	}
+}
+
+// splitPoints returns up to shards-1 keys splitting kind into ranges of
+// about the same size, sampled using the __scatter__ property the
+// datastore sets on a fraction of entities
+func (s *Client) splitPoints(ctx context.Context, kind string, shards int) ([]*datastore.Key, error) {
+	if shards < 2 {
+		return nil, nil
+	}
+	const oversample = 32
+	q := datastore.NewQuery(kind).Order("__scatter__").KeysOnly().Limit(shards * oversample)
+	it := s.ds().RunQuery(ctx, q)
+	var keys []*datastore.Key
+	for {
+		key, err := it.Next(nil)
+		if err == datastore.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) < shards {
+		return nil, nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	splits := make([]*datastore.Key, 0, shards-1)
+	for i := 1; i < shards; i++ {
+		k := keys[i*len(keys)/shards]
+		if len(splits) == 0 || !splits[len(splits)-1].Equal(k) {
+			splits = append(splits, k)
+		}
+	}
+	return splits, nil
+}
+
+// keyLess orders keys as the datastore does, by their paths from the root
+// with IDs before names
+func keyLess(a, b *datastore.Key) bool {
+	pa, pb := keyPath(a), keyPath(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, y := pa[i], pb[i]
+		switch {
+		case x.Kind() != y.Kind():
+			return x.Kind() < y.Kind()
+		case (x.StringID() == "") != (y.StringID() == ""):
+			return x.StringID() == ""
+		case x.IntID() != y.IntID():
+			return x.IntID() < y.IntID()
+		case x.StringID() != y.StringID():
+			return x.StringID() < y.StringID()
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+func keyPath(k *datastore.Key) []*datastore.Key {
+	var p []*datastore.Key
+	for ; k != nil; k = k.Parent() {
+		p = append([]*datastore.Key{k}, p...)
+	}
+	return p
+}
+
+func shardKey(ctx context.Context, job *datastore.Key, shard int) *datastore.Key {
+	return datastore.NewKey(ctx, MapShardKind, "", int64(shard)+1, job)
+}
+
+func lookupMapper(name string) (mapper, bool) {
+	mappers.RLock()
+	defer mappers.RUnlock()
+	m, ok := mappers.m[name]
+	return m, ok
+}
+
+func runMapShard(ctx context.Context, name, encodedJob string, shard int) error {
+	job, err := datastore.DecodeKey(encodedJob)
+	if err != nil {
+		// Retrying will never decode the key so drop the task
+		log.Errorf(ctx, "gaestore: dropping map shard, invalid key [%v]", err)
+		return nil
+	}
+	m, ok := lookupMapper(name)
+	if !ok {
+		log.Errorf(ctx, "gaestore: dropping map shard of [%v], no mapper registered as [%s]", job, name)
+		return nil
+	}
+	if ctx, err = appengine.Namespace(ctx, job.Namespace()); err != nil {
+		return err
+	}
+	var j MapJob
+	if err := m.s.datastoreGet(ctx, job, &j); err != nil {
+		return err
+	}
+	done, err := m.s.mapShard(ctx, job, &j, shard, m.fn)
+	if err != nil || done {
+		return err
+	}
+	return enqueueMapShard(ctx, j.Queue, name, job, shard)
+}
+
+// mapShard maps the next batch of a shard and checkpoints it, reporting
+// whether the shard is done. A failing MapFunc fails the shard, recording
+// the error, rather than being retried forever.
+func (s *Client) mapShard(ctx context.Context, job *datastore.Key, j *MapJob, shard int, fn MapFunc) (bool, error) {
+	key := shardKey(ctx, job, shard)
+	var sh MapShard
+	if err := s.datastoreGet(ctx, key, &sh); err != nil {
+		return false, err
+	}
+	if sh.Done {
+		return true, nil
+	}
+	q := datastore.NewQuery(j.Kind).Limit(j.BatchSize)
+	if sh.Start != nil {
+		q = q.Filter("__key__ >=", sh.Start)
+	}
+	if sh.End != nil {
+		q = q.Filter("__key__ <", sh.End)
+	}
+	if sh.Cursor != "" {
+		c, err := datastore.DecodeCursor(sh.Cursor)
+		if err != nil {
+			return false, err
+		}
+		q = q.Start(c)
+	}
+	it := s.ds().RunQuery(ctx, q)
+	var writes []Entity
+	n := 0
+	for {
+		e, err := newEntity(j.Kind)
+		if err != nil {
+			return false, err
+		}
+		k, err := it.Next(withNormalized(e))
+		if err == datastore.Done {
+			break
+		}
+		if err != nil && !IsFieldMismatch(err) {
+			return false, err
+		}
+		if err := s.decodeEntity(ctx, k, e); err != nil {
+			return false, err
+		}
+		n++
+		if err := afterGet(ctx, k, e); err != nil {
+			return false, err
+		}
+		w, err := fn(ctx, e)
+		if err != nil {
+			sh.Done, sh.Error = true, fmt.Sprintf("mapping [%v]: %v", k, err)
+			s.log().Errorf(ctx, "gaestore: map shard %d of [%v] failed %s", shard, job, sh.Error)
+			_, err := s.datastorePut(ctx, key, &sh)
+			return true, err
+		}
+		if w != nil {
+			writes = append(writes, w)
+		}
+	}
+	if len(writes) > 0 {
+		if _, err := s.PutMulti(ctx, writes); err != nil {
+			return false, err
+		}
+	}
+	c, err := it.Cursor()
+	if err != nil {
+		return false, err
+	}
+	sh.Cursor = c.String()
+	sh.Entities += n
+	sh.Done = n < j.BatchSize
+	_, err = s.datastorePut(ctx, key, &sh)
+	return sh.Done, err
+}
@@ -0,0 +1,122 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type mapTask struct {
+	name, job string
+	shard     int
+}
+
+// queueMapShards queues the tasks of map jobs until they're run by
+// runMapShards
+func queueMapShards(t *testing.T) *[]mapTask {
+	var tasks []mapTask
+	enqueue := *gaestore.EnqueueMapShard
+	t.Cleanup(func() { *gaestore.EnqueueMapShard = enqueue })
+	*gaestore.EnqueueMapShard = func(ctx context.Context, queue, name string, job *datastore.Key, shard int) error {
+		tasks = append(tasks, mapTask{name, job.Encode(), shard})
+		return nil
+	}
+	return &tasks
+}
+
+func runMapShards(t *testing.T, ctx context.Context, tasks *[]mapTask) int {
+	n := 0
+	for len(*tasks) > 0 {
+		task := (*tasks)[0]
+		*tasks = (*tasks)[1:]
+		if err := gaestore.RunMapShard(ctx, task.name, task.job, task.shard); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	return n
+}
+
+func TestMap(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	tasks := queueMapShards(t)
+	for i := 0; i < 5; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i), Name: fmt.Sprint("name", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.RegisterMapper("upper", func(ctx context.Context, e gaestore.Entity) (gaestore.Entity, error) {
+		x := e.(*exported)
+		if x.ID == "0" {
+			return nil, nil
+		}
+		x.Name = strings.ToUpper(x.Name)
+		return x, nil
+	})
+	if _, err := s.Map(ctx, "exported", "missing", gaestore.MapOptions{}); err == nil {
+		t.Fatal("Expected an error for an unregistered mapper")
+	}
+	job, err := s.Map(ctx, "exported", "upper", gaestore.MapOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each batch chains the next, the last finding fewer than a batch
+	if n := runMapShards(t, ctx, tasks); n != 3 {
+		t.Fatalf("Expected [3] tasks but got [%d]", n)
+	}
+	p, err := s.MapProgress(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done() || p.Entities() != 5 || len(p.Shards) != 1 {
+		t.Fatalf("Expected 5 entities mapped by 1 shard but got [%+v]", p)
+	}
+
+	got := &exported{ID: "3"}
+	if err := s.Reload(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "NAME3" {
+		t.Fatalf("Expected [NAME3] but got [%s]", got.Name)
+	}
+	got = &exported{ID: "0"}
+	if err := s.Reload(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "name0" {
+		t.Fatalf("Expected the skipped entity to be unchanged but got [%s]", got.Name)
+	}
+}
+
+func TestMapError(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	tasks := queueMapShards(t)
+	if _, err := s.Put(ctx, &exported{ID: "1", Name: "name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RegisterMapper("failing", func(ctx context.Context, e gaestore.Entity) (gaestore.Entity, error) {
+		return nil, errors.New("failed")
+	})
+	job, err := s.Map(ctx, "exported", "failing", gaestore.MapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runMapShards(t, ctx, tasks)
+	p, err := s.MapProgress(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done() || !strings.Contains(p.Shards[0].Error, "failed") {
+		t.Fatalf("Expected the shard to fail but got [%+v]", p.Shards)
+	}
+}
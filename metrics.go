@@ -0,0 +1,42 @@
+package gaestore
+
+import (
+	"strings"
+	"time"
+)
+
+// Names of the datastore and memcache calls reported to Metrics
+const (
+	RPCDatastoreGet    = "datastore.Get"
+	RPCDatastorePut    = "datastore.Put"
+	RPCDatastoreDelete = "datastore.Delete"
+	RPCDatastoreQuery  = "datastore.Query"
+	RPCMemcacheGet     = "memcache.Get"
+	RPCMemcacheSet     = "memcache.Set"
+	RPCMemcacheDelete  = "memcache.Delete"
+
+	RPCMemcacheIncrement = "memcache.Increment"
+)
+
+func isMemcacheRPC(rpc string) bool {
+	return strings.HasPrefix(rpc, "memcache.")
+}
+
+// Metrics observes every datastore and memcache call a store makes. op is
+// one of the RPC names above, kind is the entity kind the call was made
+// for and err is the result of the call, which for memcache.Get includes
+// memcache.ErrCacheMiss.
+type Metrics interface {
+	ObserveOp(op, kind string, d time.Duration, err error)
+}
+
+// SetMetrics sets the Metrics the store reports its calls to
+func (s *Client) SetMetrics(m Metrics) {
+	s.metrics = m
+}
+
+func (s *Client) observe(op, kind string, start time.Time, err error) {
+	if s.metrics != nil {
+		s.metrics.ObserveOp(op, kind, time.Since(start), err)
+	}
+}
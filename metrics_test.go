@@ -0,0 +1,49 @@
+package gaestore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/aetest"
+)
+
+type recordedOps []string
+
+func (r *recordedOps) ObserveOp(op, kind string, d time.Duration, err error) {
+	*r = append(*r, op+":"+kind)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	var ops recordedOps
+	s := NewStoreWithCache()
+	s.SetMetrics(&ops)
+
+	o := &object{ID: "1", Name: "John"}
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &object{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := recordedOps{
+		"datastore.Put:object",
+		"memcache.Set:object",
+		"memcache.Get:object",
+		"datastore.Delete:object",
+		"memcache.Delete:object",
+	}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Fatalf("Expected ops [%v] but got [%v]", expected, ops)
+	}
+}
@@ -0,0 +1,241 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// Names of the operations passed through a store's middleware chain
+const (
+	OpPut    = "Put"
+	OpGet    = "Get"
+	OpDelete = "Delete"
+	OpQuery  = "Query"
+	OpReload = "Reload"
+	OpTouch  = "Touch"
+
+	OpIncrement = "Increment"
+	OpPutMulti  = "PutMulti"
+	OpPutIf     = "PutIf"
+	OpPutLater  = "PutLater"
+)
+
+// Operation describes a single operation made through a store. Middleware
+// may inspect or modify it before calling the next OpFunc in the chain.
+type Operation struct {
+	Name   string
+	Entity Entity
+
+	// Key is the key of the entity. It is set by a Put once the entity has
+	// been written, and when set before a Get or Delete is used in place
+	// of the entity's own key.
+	Key *datastore.Key
+
+	// Query and Dst are only set for queries, Cursor is set once the query
+	// has run
+	Query  *datastore.Query
+	Dst    interface{}
+	Cursor datastore.Cursor
+
+	// Entities and Keys are set in place of Entity and Key for a PutMulti.
+	// Keys set before the put replace the entities' own keys.
+	Entities []Entity
+	Keys     []*datastore.Key
+
+	incr *increment
+	cond *condition
+	opts callOptions
+}
+
+// OpFunc executes an operation
+type OpFunc func(ctx context.Context, op *Operation) error
+
+// Middleware wraps an OpFunc, typically calling next to continue the chain
+type Middleware func(next OpFunc) OpFunc
+
+// Use appends middleware to the store. Middleware runs in the order it was
+// added, with the first added being the outermost.
+func (s *Client) Use(mw ...Middleware) {
+	s.middleware = append(s.middleware, mw...)
+}
+
+func (s *Client) run(ctx context.Context, op *Operation) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fn := s.exec
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		fn = s.middleware[i](fn)
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = s.withClock(ctx)
+	if acct := accountingFromContext(ctx); acct != nil {
+		acct.recordOperation()
+	}
+	start := time.Now()
+	ctx, st := withOpStats(ctx)
+	ctx, span := s.startSpan(ctx, "gaestore."+op.Name)
+	err = fn(ctx, op)
+	span.End(err)
+	elapsed := time.Since(start)
+	s.logSlow(ctx, op, elapsed, st)
+	s.logDebug(ctx, op, elapsed, err, st)
+	if err == nil {
+		return nil
+	}
+	oerr := newOpError(ctx, op, err)
+	s.reportError(ctx, oerr.Op, oerr.Key, oerr.Err)
+	return oerr
+}
+
+// withNamespace applies the store's namespace to ctx
+func (s *Client) withNamespace(ctx context.Context) (context.Context, error) {
+	if s.namespace == "" {
+		return ctx, nil
+	}
+	return appengine.Namespace(ctx, s.namespace)
+}
+
+func (s *Client) exec(ctx context.Context, op *Operation) (err error) {
+	if s.readOnly && op.writes() {
+		return ErrReadOnly
+	}
+	// Key is computed once per operation and passed down, as it may be
+	// costly to build
+	if op.Key == nil && op.Entity != nil {
+		if op.Key = op.Entity.Key(ctx); op.Key == nil {
+			return ErrNilKey
+		}
+	}
+	if err := s.authorize(ctx, op); err != nil {
+		return err
+	}
+	if err := s.checkOwner(ctx, op); err != nil {
+		return err
+	}
+	if s.dryRun && op.writes() {
+		return s.recordMutation(ctx, op)
+	}
+	switch op.Name {
+	case OpPut:
+		return s.execPut(ctx, op)
+	case OpPutMulti:
+		return s.execPutMulti(ctx, op)
+	case OpPutIf:
+		return s.execPutIf(ctx, op)
+	case OpGet:
+		if err = s.getByKey(ctx, op.Key, op.Entity, s.useCache, op.opts); err == nil {
+			err = s.checkLoaded(ctx, op)
+		}
+	case OpReload:
+		if err = s.reload(ctx, op.Key, op.Entity, op.opts); err == nil {
+			err = s.checkLoaded(ctx, op)
+		}
+	case OpTouch:
+		if err = s.touch(ctx, op.Key, op.Entity, op.opts); err != nil {
+			return err
+		}
+		if err = s.checkLoaded(ctx, op); err == nil && op.writes() {
+			recordWrites(ctx, op.Key)
+			s.publish(ctx, EventUpdate, op.Key, op.Entity)
+		}
+	case OpPutLater:
+		err = s.putLater(ctx, op.Key, op.Entity, op.opts)
+	case OpIncrement:
+		if err = s.increment(ctx, op.Key, op.Entity, op.incr, op.opts); err == nil && !op.opts.cachedIncrement {
+			recordWrites(ctx, op.Key)
+			s.publish(ctx, EventUpdate, op.Key, op.Entity)
+		}
+	case OpDelete:
+		var before map[string]interface{}
+		if before, err = s.before(ctx, op.Key); err != nil {
+			return err
+		}
+		var (
+			blobs  []string
+			chunks []*datastore.Key
+		)
+		if blobs, err = s.storedBlobs(ctx, op.Key, op.Entity); err != nil {
+			return err
+		}
+		if chunks, err = s.storedChunks(ctx, op.Key, op.Entity); err != nil {
+			return err
+		}
+		if err = s.delete(ctx, op.Key); err == nil {
+			recordDelete(ctx, op.Key)
+			s.deleteBlobs(ctx, op.Key, blobs)
+			s.deleteChunks(ctx, op.Key, chunks)
+			s.publish(ctx, EventDelete, op.Key, op.Entity)
+			s.publishChange(ctx, OpDelete, op.Key, before, nil)
+			if !op.opts.skipHooks {
+				err = enqueueHookTasks(ctx, s.afterDeleteFuncs, op.Key, op.Entity)
+			}
+		}
+	case OpQuery:
+		start := resultCount(op.Dst)
+		op.Cursor, err = s.query(ctx, s.ownerQuery(ctx, op.Query, op.Dst), s.useCache, op.Dst, op.opts)
+		err = s.authorizeResults(ctx, op, start, err)
+	default:
+		err = fmt.Errorf("Unknown operation [%s]", op.Name)
+	}
+	return err
+}
+
+// checkLoaded checks the entity just loaded by op may be read
+func (s *Client) checkLoaded(ctx context.Context, op *Operation) error {
+	if err := s.checkRead(ctx, op.Entity); err != nil {
+		return err
+	}
+	return s.authorizeRead(ctx, op, op.Entity)
+}
+
+// resultCount returns the length of the slice dst points to, or 0 when
+// it doesn't point to a slice
+func resultCount(dst interface{}) int {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Slice {
+		return 0
+	}
+	return dv.Elem().Len()
+}
+
+// writes reports whether op writes to the datastore
+func (op *Operation) writes() bool {
+	switch op.Name {
+	case OpPut, OpPutMulti, OpPutIf, OpPutLater, OpDelete, OpIncrement:
+		return true
+	case OpTouch:
+		_, ok := op.Entity.(Toucher)
+		return ok
+	}
+	return false
+}
+
+func (s *Client) execPut(ctx context.Context, op *Operation) (err error) {
+	t := EventUpdate
+	if len(s.subscribed(op.Key.Kind())) > 0 {
+		if t, err = s.putEventType(ctx, op.Key); err != nil {
+			return err
+		}
+	}
+	before, err := s.before(ctx, op.Key)
+	if err != nil {
+		return err
+	}
+	op.Key, err = s.put(ctx, op.Key, op.Entity, s.useCache, op.opts)
+	// A failing hook or cache write still leaves the entity written
+	if op.Key != nil {
+		recordWrites(ctx, op.Key)
+		s.publish(ctx, t, op.Key, op.Entity)
+		s.publishChange(ctx, OpPut, op.Key, before, op.Entity)
+	}
+	return err
+}
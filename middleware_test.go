@@ -0,0 +1,102 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	errStop := errors.New("stop")
+
+	s := NewStore()
+	trace := func(name string) Middleware {
+		return func(next OpFunc) OpFunc {
+			return func(ctx context.Context, op *Operation) error {
+				calls = append(calls, name+":"+op.Name)
+				return next(ctx, op)
+			}
+		}
+	}
+	s.Use(trace("first"), trace("second"))
+	s.Use(func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op *Operation) error {
+			return errStop
+		}
+	})
+
+	err := s.Get(keyContext(t), object{ID: "1"})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Expected error [%v] but got [%v]", errStop, err)
+	}
+	expected := []string{"first:Get", "second:Get"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("Expected calls [%v] but got [%v]", expected, calls)
+	}
+}
+
+func TestOnError(t *testing.T) {
+	ctx := keyContext(t)
+	errStop := errors.New("stop")
+
+	s := NewStore()
+	s.Use(func(next OpFunc) OpFunc {
+		return func(ctx context.Context, op *Operation) error {
+			return errStop
+		}
+	})
+	var reported []string
+	s.OnError(func(ctx context.Context, op string, key *datastore.Key, err error) {
+		if err != errStop {
+			t.Errorf("Expected error [%v] but got [%v]", errStop, err)
+		}
+		reported = append(reported, op+":"+key.StringID())
+	})
+
+	s.Delete(ctx, object{ID: "1"})
+	s.Get(ctx, object{ID: "2"})
+	expected := []string{"Delete:1", "Get:2"}
+	if !reflect.DeepEqual(reported, expected) {
+		t.Fatalf("Expected reported errors [%v] but got [%v]", expected, reported)
+	}
+}
+
+// countedObject counts the calls to its Key method
+type countedObject struct {
+	object
+	calls *int
+}
+
+func (o *countedObject) Key(ctx context.Context) *datastore.Key {
+	*o.calls++
+	return o.object.Key(ctx)
+}
+
+func TestKeyOncePerOperation(t *testing.T) {
+	ctx := keyContext(t)
+	s := NewStore(WithBackend(mapBackend{}), WithCacher(mapCacher{}), WithCache(0), WithLogger(nopLogger{}))
+	var calls int
+	o := &countedObject{object: object{ID: "1", Name: "John"}, calls: &calls}
+
+	ops := []struct {
+		name string
+		fn   func() error
+	}{
+		{OpPut, func() error { _, err := s.Put(ctx, o); return err }},
+		{OpGet, func() error { return s.Get(ctx, o) }},
+		{OpDelete, func() error { return s.Delete(ctx, o) }},
+	}
+	for _, op := range ops {
+		calls = 0
+		if err := op.fn(); err != nil {
+			t.Fatal(err)
+		}
+		if calls != 1 {
+			t.Fatalf("Expected [%s] to build the key once but got [%d]", op.name, calls)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// MigrationKind is the kind migration checkpoints are stored under
+const MigrationKind = "GaestoreMigration"
+
+// MigrateFunc returns the entity to write in place of old, or nil to skip
+// it. The entities returned should have complete keys, so that a chunk
+// migrated again after an interruption overwrites its earlier copies.
+type MigrateFunc func(ctx context.Context, old Entity) (Entity, error)
+
+// Migration walks the entities of a kind in chunks, writing what transform
+// returns for each to another kind and optionally deleting the originals.
+// Entities are read into the type registered for their kind. Progress is
+// checkpointed after each chunk, so a migration interrupted by a deadline
+// resumes from the last chunk when run again.
+type Migration struct {
+	// ChunkSize is the number of entities migrated at a time, 500 unless
+	// set
+	ChunkSize int
+
+	// DeleteOriginals deletes each migrated entity once its replacement
+	// is written. Skipped entities are kept.
+	DeleteOriginals bool
+
+	s         *Client
+	from, to  string
+	transform MigrateFunc
+}
+
+// MigrationProgress is the checkpoint of a migration
+type MigrationProgress struct {
+	Cursor   string `datastore:",noindex"`
+	Entities int
+	Skipped  int
+	Done     bool
+}
+
+// NewMigration returns a migration of the entities of kind from to kind to
+func (s *Client) NewMigration(from, to string, transform MigrateFunc) *Migration {
+	return &Migration{s: s, from: from, to: to, transform: transform}
+}
+
+// Migrate runs a migration from kind from to kind to with the default
+// settings
+func (s *Client) Migrate(ctx context.Context, from, to string, transform MigrateFunc) error {
+	return s.NewMigration(from, to, transform).Run(ctx)
+}
+
+// Run migrates the entities not yet migrated, returning ctx's error if it
+// ends first. Calling Run once it's done does nothing.
+func (m *Migration) Run(ctx context.Context) error {
+	ctx, err := m.s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	key := m.checkpointKey(ctx)
+	var p MigrationProgress
+	if err := m.s.datastoreGet(ctx, key, &p); err != nil && err != datastore.ErrNoSuchEntity {
+		return err
+	}
+	for !p.Done {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.migrateChunk(ctx, &p); err != nil {
+			return err
+		}
+		if _, err := m.s.datastorePut(ctx, key, &p); err != nil {
+			return err
+		}
+		m.s.log().Debugf(ctx, "gaestore: migrated %d entities of [%s] to [%s]", p.Entities, m.from, m.to)
+	}
+	return nil
+}
+
+// Progress returns the migration's last checkpoint
+func (m *Migration) Progress(ctx context.Context) (MigrationProgress, error) {
+	var p MigrationProgress
+	ctx, err := m.s.withNamespace(ctx)
+	if err != nil {
+		return p, err
+	}
+	err = m.s.datastoreGet(ctx, m.checkpointKey(ctx), &p)
+	if err == datastore.ErrNoSuchEntity {
+		err = nil
+	}
+	return p, err
+}
+
+func (m *Migration) checkpointKey(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, MigrationKind, m.from+"\x00"+m.to, 0, nil)
+}
+
+func (m *Migration) chunkSize() int {
+	if m.ChunkSize <= 0 {
+		return 500
+	}
+	return m.ChunkSize
+}
+
+// migrateChunk migrates the next chunk and advances p past it
+func (m *Migration) migrateChunk(ctx context.Context, p *MigrationProgress) error {
+	q := datastore.NewQuery(m.from).Limit(m.chunkSize())
+	if p.Cursor != "" {
+		c, err := datastore.DecodeCursor(p.Cursor)
+		if err != nil {
+			return err
+		}
+		q = q.Start(c)
+	}
+	it := m.s.ds().RunQuery(ctx, q)
+	var olds []*Operation
+	var news []Entity
+	n := 0
+	for {
+		old, err := newEntity(m.from)
+		if err != nil {
+			return err
+		}
+		key, err := it.Next(withNormalized(old))
+		if err == datastore.Done {
+			break
+		}
+		if err != nil && !IsFieldMismatch(err) {
+			return err
+		}
+		if err := m.s.decodeEntity(ctx, key, old); err != nil {
+			return err
+		}
+		n++
+		if err := afterGet(ctx, key, old); err != nil {
+			return err
+		}
+		e, err := m.transform(ctx, old)
+		if err != nil {
+			return fmt.Errorf("Unable to migrate [%v]: %v", key, err)
+		}
+		if e == nil {
+			p.Skipped++
+			continue
+		}
+		if k := e.Key(ctx); k == nil || k.Kind() != m.to {
+			return fmt.Errorf("Migrating [%v] returned an entity with key [%v], not of kind [%s]", key, k, m.to)
+		}
+		olds = append(olds, &Operation{Name: OpDelete, Entity: old, Key: key})
+		news = append(news, e)
+	}
+	if len(news) > 0 {
+		if _, err := m.s.PutMulti(ctx, news); err != nil {
+			return err
+		}
+	}
+	if m.DeleteOriginals {
+		for _, op := range olds {
+			if err := m.s.run(ctx, op); err != nil {
+				return err
+			}
+		}
+	}
+	c, err := it.Cursor()
+	if err != nil {
+		return err
+	}
+	p.Cursor = c.String()
+	p.Entities += len(news)
+	p.Done = n < m.chunkSize()
+	return nil
+}
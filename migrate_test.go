@@ -0,0 +1,66 @@
+package gaestore_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type renamed struct {
+	ID       string `datastore:"-"`
+	FullName string
+}
+
+func (e *renamed) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "renamed", e.ID, 0, nil)
+}
+
+func TestMigration(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	for i := 0; i < 5; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i), Name: fmt.Sprint("name", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := s.NewMigration("exported", "renamed", func(ctx context.Context, old gaestore.Entity) (gaestore.Entity, error) {
+		e := old.(*exported)
+		if e.ID == "0" {
+			return nil, nil
+		}
+		return &renamed{ID: e.ID, FullName: strings.ToUpper(e.Name)}, nil
+	})
+	m.ChunkSize = 2
+	m.DeleteOriginals = true
+	if err := m.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p, err := m.Progress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done || p.Entities != 4 || p.Skipped != 1 {
+		t.Fatalf("Expected 4 entities to be migrated and 1 skipped but got [%+v]", p)
+	}
+
+	got := &renamed{ID: "3"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.FullName != "NAME3" {
+		t.Fatalf("Expected [NAME3] but got [%s]", got.FullName)
+	}
+	if err := s.Get(ctx, &exported{ID: "3"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected the original to be deleted but got [%v]", err)
+	}
+	if err := s.Get(ctx, &exported{ID: "0"}); err != nil {
+		t.Fatalf("Expected the skipped original to be kept but got [%v]", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package nds provides the functions of github.com/qedus/nds backed by
+// gaestore, so code using nds can move to gaestore by changing its import
+// path. Entities are read and written through the store returned by
+// gaestore.FromContext, which caches them when configured to.
+//
+//	ctx = gaestore.NewContext(ctx, store)
+//	key, err := nds.Put(ctx, datastore.NewIncompleteKey(ctx, "User", nil), &u)
+//
+// Values need not be gaestore.Entities, and their hooks are not run.
+package nds
+
+import (
+	"context"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/internal/compat"
+	"google.golang.org/appengine/datastore"
+)
+
+// Get loads the entity stored for key into val, a struct pointer or
+// datastore.PropertyLoadSaver
+func Get(ctx context.Context, key *datastore.Key, val interface{}) error {
+	return compat.Err(gaestore.FromContext(ctx).Get(ctx, &compat.Entity{K: key, Src: val}))
+}
+
+// GetMulti is a batch version of Get, returning an appengine.MultiError
+// when only some entities are loaded
+func GetMulti(ctx context.Context, keys []*datastore.Key, vals interface{}) error {
+	vs, err := compat.Values(keys, vals)
+	if err != nil {
+		return err
+	}
+	return compat.Each(len(keys), func(i int) error {
+		return Get(ctx, keys[i], vs[i])
+	})
+}
+
+// Put saves val for key, returning the key it was stored under
+func Put(ctx context.Context, key *datastore.Key, val interface{}) (*datastore.Key, error) {
+	return gaestore.FromContext(ctx).Put(ctx, &compat.Entity{K: key, Src: val})
+}
+
+// PutMulti is a batch version of Put
+func PutMulti(ctx context.Context, keys []*datastore.Key, vals interface{}) ([]*datastore.Key, error) {
+	vs, err := compat.Values(keys, vals)
+	if err != nil {
+		return nil, err
+	}
+	return gaestore.FromContext(ctx).PutMulti(ctx, compat.Entities(keys, vs))
+}
+
+// Delete deletes the entity for key
+func Delete(ctx context.Context, key *datastore.Key) error {
+	return gaestore.FromContext(ctx).Delete(ctx, &compat.Entity{K: key})
+}
+
+// DeleteMulti is a batch version of Delete
+func DeleteMulti(ctx context.Context, keys []*datastore.Key) error {
+	return compat.Each(len(keys), func(i int) error {
+		return Delete(ctx, keys[i])
+	})
+}
+
+// RunInTransaction runs f in a transaction
+func RunInTransaction(ctx context.Context, f func(tc context.Context) error, opts *datastore.TransactionOptions) error {
+	return gaestore.FromContext(ctx).RunInTransaction(ctx, f, opts)
+}
@@ -0,0 +1,41 @@
+package nds_test
+
+import (
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"github.com/floresj/gaestore/nds"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+type user struct {
+	Name string
+}
+
+func TestNDS(t *testing.T) {
+	ctx := gaestore.NewContext(gaestoretest.NewContext(), gaestoretest.NewStore(t))
+
+	keys := []*datastore.Key{
+		datastore.NewKey(ctx, "user", "a", 0, nil),
+		datastore.NewKey(ctx, "user", "b", 0, nil),
+	}
+	if _, err := nds.PutMulti(ctx, keys, []user{{"Ann"}, {"Bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	var u user
+	if err := nds.Get(ctx, keys[1], &u); err != nil || u.Name != "Bob" {
+		t.Fatalf("Expected [Bob] but got [%s] %v", u.Name, err)
+	}
+
+	if err := nds.Delete(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	users := make([]user, 2)
+	err := nds.GetMulti(ctx, keys, users)
+	merr, ok := err.(appengine.MultiError)
+	if !ok || merr[0] != datastore.ErrNoSuchEntity || merr[1] != nil || users[1].Name != "Bob" {
+		t.Fatalf("Expected [ErrNoSuchEntity] for [a] but got [%v]", err)
+	}
+}
@@ -0,0 +1,123 @@
+package gaestore
+
+import (
+	"reflect"
+	"strings"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// NormSuffix ends the names of the properties holding the normalized
+// values of fields tagged `gaestore:"normalize"`
+const NormSuffix = "_norm"
+
+// normalizeTag tags top level string and []string fields stored along
+// with an indexed shadow property of their normalized values, so they can
+// be matched regardless of case with FilterNormalized. The shadow property
+// is named after the field's with NormSuffix, as with Email_norm for
+//
+//	type User struct {
+//		ID    string `datastore:"-"`
+//		Email string `gaestore:"normalize"`
+//	}
+//
+// Shadow properties are written with the entity and dropped when it's
+// read, and entities written before the field was tagged need to be put
+// again to be matched.
+const normalizeTag = "normalize"
+
+// Normalize returns s as stored in the shadow properties of normalized
+// fields, trimmed and lower cased
+func Normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// FilterNormalized adds a filter on the shadow property of a normalized
+// field to q, comparing it with the normalized value. filterStr is as for
+// datastore.Query.Filter, naming the field's property.
+//
+//	q = gaestore.FilterNormalized(q, "Email =", "Ann@Example.com")
+func FilterNormalized(q *datastore.Query, filterStr, value string) *datastore.Query {
+	filterStr = strings.TrimSpace(filterStr)
+	if i := strings.IndexAny(filterStr, " =<>"); i >= 0 {
+		filterStr = filterStr[:i] + NormSuffix + filterStr[i:]
+	} else {
+		filterStr += NormSuffix
+	}
+	return q.Filter(filterStr, Normalize(value))
+}
+
+// normalizedFieldsOf returns the property names of the fields of t tagged
+// to be normalized
+func normalizedFieldsOf(t reflect.Type) map[string]bool {
+	var props map[string]bool
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("gaestore") != normalizeTag {
+			continue
+		}
+		if f.Type.Kind() != reflect.String && f.Type != reflect.TypeOf([]string(nil)) {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("datastore"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		if props == nil {
+			props = make(map[string]bool)
+		}
+		props[name] = true
+	}
+	return props
+}
+
+// normalized saves and loads an entity with normalized fields, adding and
+// removing their shadow properties
+type normalized struct {
+	Entity
+	props map[string]bool
+}
+
+// withNormalized returns e wrapped to save and load the shadow properties
+// of its normalized fields, or e as is when it has none
+func withNormalized(e interface{}) interface{} {
+	if _, ok := e.(datastore.PropertyLoadSaver); ok {
+		return e
+	}
+	ent, ok := e.(Entity)
+	if !ok {
+		return e
+	}
+	info := structInfo(e)
+	if info == nil || len(info.normalized) == 0 {
+		return e
+	}
+	return &normalized{Entity: ent, props: info.normalized}
+}
+
+func (n *normalized) Save() ([]datastore.Property, error) {
+	props, err := datastore.SaveStruct(n.Entity)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range props {
+		if v, ok := p.Value.(string); ok && n.props[p.Name] {
+			props = append(props, datastore.Property{
+				Name:     p.Name + NormSuffix,
+				Value:    Normalize(v),
+				Multiple: p.Multiple,
+			})
+		}
+	}
+	return props, nil
+}
+
+func (n *normalized) Load(props []datastore.Property) error {
+	kept := props[:0:0]
+	for _, p := range props {
+		if name := strings.TrimSuffix(p.Name, NormSuffix); name == p.Name || !n.props[name] {
+			kept = append(kept, p)
+		}
+	}
+	return datastore.LoadStruct(n.Entity, kept)
+}
@@ -0,0 +1,65 @@
+package gaestore_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type member struct {
+	ID      string   `datastore:"-"`
+	Email   string   `gaestore:"normalize"`
+	Aliases []string `datastore:"alias" gaestore:"normalize"`
+}
+
+func (m *member) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "member", m.ID, 0, nil)
+}
+
+func TestNormalize(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t).WithoutCache()
+
+	ann := &member{ID: "1", Email: "Ann@Example.com", Aliases: []string{"ANNIE"}}
+	for _, m := range []*member{ann, {ID: "2", Email: "bob@example.com"}, {ID: "3", Email: " ANN@example.COM "}} {
+		if _, err := s.Put(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shadow properties aren't loaded back into the entity
+	got := &member{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ann) {
+		t.Fatalf("Expected [%+v] but got [%+v]", ann, got)
+	}
+
+	tests := []struct {
+		q        *datastore.Query
+		expected []string
+	}{
+		{gaestore.FilterNormalized(datastore.NewQuery("member"), "Email =", "ann@EXAMPLE.com"), []string{"Ann@Example.com", " ANN@example.COM "}},
+		{gaestore.FilterNormalized(datastore.NewQuery("member"), "Email=", "BOB@example.com"), []string{"bob@example.com"}},
+		{gaestore.FilterNormalized(datastore.NewQuery("member"), "alias =", "Annie"), []string{"Ann@Example.com"}},
+		{datastore.NewQuery("member").Filter("Email =", "ann@example.com"), nil},
+	}
+	for _, test := range tests {
+		var members []*member
+		if _, err := s.Query(ctx, test.q, &members); err != nil {
+			t.Fatal(err)
+		}
+		var emails []string
+		for _, m := range members {
+			emails = append(emails, m.Email)
+		}
+		if !reflect.DeepEqual(emails, test.expected) {
+			t.Fatalf("Expected [%v] but got [%v]", test.expected, emails)
+		}
+	}
+}
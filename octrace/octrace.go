@@ -0,0 +1,37 @@
+// Package octrace traces gaestore operations with OpenCensus.
+//
+//	s := gaestore.NewStoreWithCache()
+//	s.SetTracer(octrace.Tracer{})
+package octrace
+
+import (
+	"context"
+
+	"github.com/floresj/gaestore"
+	"go.opencensus.io/trace"
+)
+
+// Tracer is a gaestore.Tracer that starts OpenCensus spans
+type Tracer struct {
+	// Options are passed to every span started
+	Options []trace.StartOption
+}
+
+func (t Tracer) StartSpan(ctx context.Context, name string) (context.Context, gaestore.Span) {
+	ctx, span := trace.StartSpan(ctx, name, t.Options...)
+	return ctx, ocSpan{span}
+}
+
+type ocSpan struct {
+	span *trace.Span
+}
+
+func (s ocSpan) End(err error) {
+	if err != nil {
+		s.span.SetStatus(trace.Status{
+			Code:    trace.StatusCodeUnknown,
+			Message: err.Error(),
+		})
+	}
+	s.span.End()
+}
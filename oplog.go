@@ -0,0 +1,41 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+)
+
+// SetSlowThreshold makes the store log a warning for every operation that
+// takes longer than d, with the time spent in memcache and the datastore.
+// A zero threshold disables the warnings.
+func (s *Client) SetSlowThreshold(d time.Duration) {
+	s.slowThreshold = d
+}
+
+func (s *Client) logSlow(ctx context.Context, op *Operation, elapsed time.Duration, st *opStats) {
+	if s.slowThreshold <= 0 || elapsed < s.slowThreshold {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s.log().Warningf(ctx, "gaestore: slow %s kind=%s key=%v took %v (memcache %v in %d calls, datastore %v in %d calls)",
+		op.Name, st.kind, operationKey(ctx, op), elapsed, st.memcache, st.memcacheCalls, st.datastore, st.datastoreCalls)
+}
+
+// SetDebug makes the store log every operation at debug level with its
+// cache decisions, RPC counts and cache payload sizes. It is intended for
+// debugging on the dev server.
+func (s *Client) SetDebug(debug bool) {
+	s.debug = debug
+}
+
+func (s *Client) logDebug(ctx context.Context, op *Operation, elapsed time.Duration, err error, st *opStats) {
+	if !s.debug {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s.log().Debugf(ctx, "gaestore: %s kind=%s key=%v took %v err=%v cache=[hit:%d miss:%d bypass:%d] memcache=[%d calls, %d bytes, %v] datastore=[%d calls, %v]",
+		op.Name, st.kind, operationKey(ctx, op), elapsed, err, st.hits, st.misses, st.bypasses,
+		st.memcacheCalls, st.cacheBytes, st.memcache, st.datastoreCalls, st.datastore)
+}
@@ -0,0 +1,84 @@
+package gaestore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// opStats accumulates the datastore and memcache calls made while running
+// a single operation
+type opStats struct {
+	mu             sync.Mutex
+	kind           string
+	datastore      time.Duration
+	memcache       time.Duration
+	datastoreCalls int
+	memcacheCalls  int
+
+	// Cache decisions made by reads and the size of the cache payloads
+	// read and written
+	hits       int
+	misses     int
+	bypasses   int
+	cacheBytes int
+}
+
+type opStatsKey struct{}
+
+func withOpStats(ctx context.Context) (context.Context, *opStats) {
+	st := &opStats{}
+	return context.WithValue(ctx, opStatsKey{}, st), st
+}
+
+func opStatsFromContext(ctx context.Context) *opStats {
+	st, _ := ctx.Value(opStatsKey{}).(*opStats)
+	return st
+}
+
+func (st *opStats) record(rpc, kind string, d time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if kind != "" {
+		st.kind = kind
+	}
+	if isMemcacheRPC(rpc) {
+		st.memcache += d
+		st.memcacheCalls++
+		return
+	}
+	st.datastore += d
+	st.datastoreCalls++
+}
+
+func (st *opStats) recordBytes(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.cacheBytes += n
+}
+
+// cacheDecision values passed to recordCache
+const (
+	cacheHit = iota
+	cacheMiss
+	cacheBypass
+)
+
+func (st *opStats) recordCache(decision int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	switch decision {
+	case cacheHit:
+		st.hits++
+	case cacheMiss:
+		st.misses++
+	case cacheBypass:
+		st.bypasses++
+	}
+}
+
+func recordCache(ctx context.Context, decision int) {
+	if st := opStatsFromContext(ctx); st != nil {
+		st.recordCache(decision)
+	}
+}
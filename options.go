@@ -0,0 +1,239 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+// Option configures a Client created by NewStore
+type Option func(*Client)
+
+// WithCache enables caching entities in memcache. A zero ttl caches
+// entities until memcache evicts them.
+func WithCache(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.useCache = true
+		c.cacheTTL = ttl
+	}
+}
+
+// WithCodec sets the codec used to encode cached entities, memcache.JSON
+// by default. Only the default codec encodes into reused buffers for
+// Cachers implementing ValueCopier.
+func WithCodec(codec memcache.Codec) Option {
+	return func(c *Client) {
+		c.codec = codec
+	}
+}
+
+// WithBackend sets the backend entities are read from and written to, the
+// App Engine datastore by default
+func WithBackend(b Backend) Option {
+	return func(c *Client) {
+		c.SetBackend(b)
+	}
+}
+
+// WithCacher sets where entities are cached when caching is enabled, App
+// Engine memcache by default. Cachers should implement Adder, without
+// which a read racing a write or delete can cache a stale entity.
+func WithCacher(cacher Cacher) Option {
+	return func(c *Client) {
+		c.SetCacher(cacher)
+	}
+}
+
+// WithNamespace runs every operation in the given datastore and memcache
+// namespace
+func WithNamespace(namespace string) Option {
+	return func(c *Client) {
+		c.namespace = namespace
+	}
+}
+
+func WithLogger(l Logger) Option {
+	return func(c *Client) {
+		c.SetLogger(l)
+	}
+}
+
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.SetRetryPolicy(p)
+	}
+}
+
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		c.Use(mw...)
+	}
+}
+
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(c *Client) {
+		c.OnError(fn)
+	}
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(c *Client) {
+		c.SetMetrics(m)
+	}
+}
+
+func WithTracer(t Tracer) Option {
+	return func(c *Client) {
+		c.SetTracer(t)
+	}
+}
+
+func WithSlowThreshold(d time.Duration) Option {
+	return func(c *Client) {
+		c.SetSlowThreshold(d)
+	}
+}
+
+// WithReadOnly makes Put and Delete fail with ErrReadOnly, for handing a
+// store to code that must not modify data. There is no way to make the
+// store writable again.
+func WithReadOnly() Option {
+	return func(c *Client) {
+		c.readOnly = true
+	}
+}
+
+// WithDryRun makes Put and Delete log and record the mutations they would
+// make, available from Mutations, without making them. Reads are made as
+// normal, for rehearsing backfills and migrations against real data.
+func WithDryRun() Option {
+	return func(c *Client) {
+		c.dryRun = true
+	}
+}
+
+func WithDebug() Option {
+	return func(c *Client) {
+		c.SetDebug(true)
+	}
+}
+
+// WithConcurrentCacheSet writes entities to the cache on another
+// goroutine while the AfterPut hooks run, rather than after them. When
+// wait is true Put waits for the cache write and returns its error as
+// before. Otherwise Put returns without waiting, and failures are logged
+// and reported to the error handler; as the write is made with the
+// request's context it must still complete before the request ends, and
+// a Get racing it may be served the previously cached copy.
+func WithConcurrentCacheSet(wait bool) Option {
+	return func(c *Client) {
+		c.concurrentCacheSet = true
+		c.waitCacheSet = wait
+	}
+}
+
+// WithBatchSize reads query results n at a time, looking up the batch in
+// the cache and reading those not cached with a single datastore call when
+// the backend is a MultiGetter. Larger batches make fewer RPCs but hold
+// more entities in memory. By default each result is read on its own.
+func WithBatchSize(n int) Option {
+	return func(c *Client) {
+		c.batchSize = n
+	}
+}
+
+// DefaultMaxResults is the most results a query reads unless changed
+// with WithMaxResults or MaxResults
+const DefaultMaxResults = 10000
+
+// WithMaxResults caps the results read by a query at n rather than
+// DefaultMaxResults, or lifts the cap when n is 0 or less. A query with more
+// results, and no limit of its own within the cap, reads the first n and
+// fails with an *ErrResultTruncated holding the cursor to read the rest
+// from, guarding against a miswritten query reading a whole kind.
+func WithMaxResults(n int) Option {
+	return func(c *Client) {
+		c.maxResults = unboundedIfZero(n)
+	}
+}
+
+// unboundedIfZero maps a cap of n, unbounded when 0 or less, to its
+// setting, where 0 is the default and negative is unbounded
+func unboundedIfZero(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// WithCacheChecksums stores a checksum with each cached entity, and
+// treats a cached value that doesn't match its checksum, such as one
+// truncated by the cache, as undecodable: it's replaced by the entity
+// read from the datastore. Values cached without checksums are still
+// read.
+func WithCacheChecksums() Option {
+	return func(c *Client) {
+		c.cacheChecksums = true
+	}
+}
+
+func WithCacheBreaker(failures int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.SetCacheBreaker(failures, cooldown)
+	}
+}
+
+func WithTimeouts(datastore, memcache time.Duration) Option {
+	return func(c *Client) {
+		c.SetTimeouts(datastore, memcache)
+	}
+}
+
+func WithAudit(who func(ctx context.Context) string) Option {
+	return func(c *Client) {
+		c.EnableAudit(who)
+	}
+}
+
+// CallOption changes the behaviour of a single call made through a store
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	skipHooks       bool
+	cachedIncrement bool
+	strong          bool
+	maxResults      int
+}
+
+// SkipHooks stops the entity's BeforePut, AfterPut, AsyncAfterPut and
+// AfterGet hooks, and the tasks of WithAfterPutFunc and
+// WithAfterDeleteFunc, from running for the call. It is intended for
+// administrative backfills that need raw reads and writes.
+var SkipHooks CallOption = func(o *callOptions) {
+	o.skipHooks = true
+}
+
+// StrongConsistency declares that the call needs strongly consistent
+// results. Queries without an ancestor, or made eventually consistent,
+// fail with ErrInconsistentQuery rather than returning results that may
+// be stale. Reads by key are always strongly consistent.
+var StrongConsistency CallOption = func(o *callOptions) {
+	o.strong = true
+}
+
+// MaxResults caps the results read by a query made with the call at n
+// instead of the store's maximum, with no cap when n is 0 or less
+func MaxResults(n int) CallOption {
+	return func(o *callOptions) {
+		o.maxResults = unboundedIfZero(n)
+	}
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,73 @@
+package gaestore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+func TestNewStoreOptions(t *testing.T) {
+	s := NewStore()
+	if s.useCache {
+		t.Fatal("Expected caching to be disabled by default")
+	}
+
+	s = NewStore(WithCache(time.Minute), WithNamespace("tenant"), WithCodec(memcache.Gob), WithDebug())
+	if !s.useCache || s.cacheTTL != time.Minute {
+		t.Fatalf("Expected caching with a ttl of [%v] but got [%v, %v]", time.Minute, s.useCache, s.cacheTTL)
+	}
+	if s.namespace != "tenant" {
+		t.Fatalf("Expected namespace [tenant] but got [%v]", s.namespace)
+	}
+	if !s.debug {
+		t.Fatal("Expected debug to be enabled")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(backend), WithReadOnly(), WithLogger(nopLogger{}))
+
+	if _, err := s.Put(ctx, &object{ID: "1"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrReadOnly, err)
+	}
+	if err := s.Delete(ctx, &object{ID: "1"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrReadOnly, err)
+	}
+	l := &Lease{Name: "cron", s: s}
+	if err := l.Renew(ctx, time.Minute); err != ErrReadOnly {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrReadOnly, err)
+	}
+	if err := l.Release(ctx); err != ErrReadOnly {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrReadOnly, err)
+	}
+	if len(backend) != 0 {
+		t.Fatalf("Expected nothing to be written but got [%d] entities", len(backend))
+	}
+	if err := s.Get(ctx, &object{ID: "1"}); !IsNotFound(err) {
+		t.Fatalf("Expected reads to be allowed but got [%v]", err)
+	}
+}
+
+func TestQueryMax(t *testing.T) {
+	tests := []struct {
+		store    []Option
+		call     []CallOption
+		expected int
+	}{
+		{nil, nil, DefaultMaxResults},
+		{[]Option{WithMaxResults(5)}, nil, 5},
+		{[]Option{WithMaxResults(0)}, nil, 0},
+		{nil, []CallOption{MaxResults(0)}, 0},
+		{[]Option{WithMaxResults(0)}, []CallOption{MaxResults(2)}, 2},
+	}
+	for i, test := range tests {
+		s := NewStore(test.store...)
+		if got := s.queryMax(newCallOptions(test.call)); got != test.expected {
+			t.Fatalf("Expected test %d to cap queries at [%d] but got [%d]", i, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package gaestore
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// ownerTag marks the string field holding the user owning an entity
+const ownerTag = "owner"
+
+// ownerField is the field of a struct type tagged `gaestore:"owner"`
+type ownerField struct {
+	index    []int
+	property string
+}
+
+// WithOwner restricts the entities of types with a string field tagged
+// `gaestore:"owner"` to the user returned by user, such as the signed in
+// user's ID:
+//
+//	type Note struct {
+//		Owner string `gaestore:"owner"`
+//		Text  string
+//	}
+//
+// Queries for these types are filtered to the user's entities, and Get
+// and Reload fail with ErrNoSuchEntity for an entity owned by another
+// user, leaving it zeroed. Put fills in an empty owner with the user, and
+// writes fail with ErrNotOwner when the entity written or stored belongs
+// to another user, so an entity's owner can't be changed. Filtered
+// queries with other filters or sort orders need a composite index
+// including the owner property.
+//
+// Ownership applies to every call, including those made with SkipHooks;
+// use a store without the option for administrative work. The stored
+// owner is read before a write outside of its transaction.
+func WithOwner(user func(ctx context.Context) string) Option {
+	return func(c *Client) {
+		c.ownerUser = user
+	}
+}
+
+// ownerFieldOf returns the owner field of t, or nil when it has none
+func ownerFieldOf(t reflect.Type) *ownerField {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("gaestore") != ownerTag || f.Type.Kind() != reflect.String {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("datastore"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		return &ownerField{index: f.Index, property: name}
+	}
+	return nil
+}
+
+// owned returns the owner field of e's type when the store enforces
+// ownership, along with the struct e points to
+func (s *Client) owned(e interface{}) (*ownerField, reflect.Value) {
+	if s.ownerUser == nil {
+		return nil, reflect.Value{}
+	}
+	info := structInfo(e)
+	if info == nil || info.owner == nil {
+		return nil, reflect.Value{}
+	}
+	return info.owner, reflect.ValueOf(e).Elem()
+}
+
+// checkOwner fails op when it writes an entity owned by another user,
+// filling in the owner of entities put without one
+func (s *Client) checkOwner(ctx context.Context, op *Operation) error {
+	if s.ownerUser == nil || !op.writes() {
+		return nil
+	}
+	if op.Name != OpPutMulti {
+		return s.checkWrite(ctx, op.Name, op.Key, op.Entity)
+	}
+	for i, e := range op.Entities {
+		key := e.Key(ctx)
+		if op.Keys != nil && op.Keys[i] != nil {
+			key = op.Keys[i]
+		}
+		if err := s.checkWrite(ctx, op.Name, key, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkWrite fails a write of e with key, as op, when e or the stored
+// entity belongs to another user
+func (s *Client) checkWrite(ctx context.Context, op string, key *datastore.Key, e Entity) error {
+	f, v := s.owned(e)
+	if f == nil {
+		return nil
+	}
+	user := s.ownerUser(ctx)
+	switch op {
+	case OpPut, OpPutMulti, OpPutIf, OpPutLater:
+		owner := v.FieldByIndex(f.index)
+		if owner.String() == "" {
+			owner.SetString(user)
+		}
+		if owner.String() != user {
+			return ErrNotOwner
+		}
+	}
+	if key == nil || key.Incomplete() {
+		return nil
+	}
+	var props datastore.PropertyList
+	switch err := s.datastoreGet(ctx, key, &props); err {
+	case nil:
+	case datastore.ErrNoSuchEntity:
+		return nil
+	default:
+		return err
+	}
+	for _, p := range props {
+		if p.Name == f.property {
+			if owner, _ := p.Value.(string); owner != user {
+				return ErrNotOwner
+			}
+			return nil
+		}
+	}
+	return ErrNotOwner
+}
+
+// checkRead fails with ErrNoSuchEntity when e, just read, is owned by
+// another user, zeroing it so the entity can't be used by mistake
+func (s *Client) checkRead(ctx context.Context, e Entity) error {
+	f, v := s.owned(e)
+	if f == nil || v.FieldByIndex(f.index).String() == s.ownerUser(ctx) {
+		return nil
+	}
+	v.Set(reflect.Zero(v.Type()))
+	return ErrNoSuchEntity
+}
+
+// ownerQuery returns q filtered to the user's entities when dst is a slice
+// of owned entities
+func (s *Client) ownerQuery(ctx context.Context, q *datastore.Query, dst interface{}) *datastore.Query {
+	if s.ownerUser == nil {
+		return q
+	}
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return q
+	}
+	mat, elemType := checkMultiArg(dv.Elem())
+	if mat != multiArgTypeStruct && mat != multiArgTypeStructPtr {
+		return q
+	}
+	f := typeInfoOf(elemType).owner
+	if f == nil {
+		return q
+	}
+	return q.Filter(f.property+" =", s.ownerUser(ctx))
+}
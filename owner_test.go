@@ -0,0 +1,91 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type note struct {
+	ID    string `datastore:"-"`
+	Owner string `gaestore:"owner" datastore:"owner"`
+	Text  string
+}
+
+func (n *note) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "note", n.ID, 0, nil)
+}
+
+type userKey struct{}
+
+func TestOwner(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	alice := context.WithValue(ctx, userKey{}, "alice")
+	bob := context.WithValue(ctx, userKey{}, "bob")
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithOwner(func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}))
+
+	n := &note{ID: "1", Text: "hello"}
+	if _, err := s.Put(alice, n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Owner != "alice" {
+		t.Fatalf("Expected the owner to be filled in but got [%s]", n.Owner)
+	}
+	if _, err := s.Put(bob, &note{ID: "2", Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	b.Apply()
+
+	// Other users' entities can't be read or overwritten
+	got := &note{ID: "1"}
+	if err := s.Get(bob, got); !gaestore.IsNotFound(err) || got.Text != "" {
+		t.Fatalf("Expected [%v] but got [%+v] %v", gaestore.ErrNoSuchEntity, got, err)
+	}
+	for _, e := range []*note{{ID: "1", Text: "stolen"}, {ID: "1", Owner: "bob"}} {
+		if _, err := s.Put(bob, e); !errors.Is(err, gaestore.ErrNotOwner) {
+			t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrNotOwner, err)
+		}
+	}
+	if err := s.Delete(bob, &note{ID: "1"}); !errors.Is(err, gaestore.ErrNotOwner) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrNotOwner, err)
+	}
+	got = &note{ID: "1"}
+	if err := s.Touch(bob, got); !gaestore.IsNotFound(err) || got.Text != "" {
+		t.Fatalf("Expected [%v] but got [%+v] %v", gaestore.ErrNoSuchEntity, got, err)
+	}
+	queued := 0
+	putLater := *gaestore.CallPutLaterTask
+	defer func() { *gaestore.CallPutLaterTask = putLater }()
+	*gaestore.CallPutLaterTask = func(ctx context.Context, key *datastore.Key, props datastore.PropertyList) error {
+		queued++
+		return nil
+	}
+	if err := s.PutLater(bob, &note{ID: "1", Text: "stolen"}); !errors.Is(err, gaestore.ErrNotOwner) || queued != 0 {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrNotOwner, err)
+	}
+
+	// The owner can't be changed by its owner either
+	if _, err := s.Put(alice, &note{ID: "1", Owner: "bob"}); !errors.Is(err, gaestore.ErrNotOwner) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrNotOwner, err)
+	}
+	got = &note{ID: "1"}
+	if err := s.Get(alice, got); err != nil || got.Text != "hello" {
+		t.Fatalf("Expected [hello] but got [%+v] %v", got, err)
+	}
+
+	var notes []*note
+	if _, err := s.Query(alice, datastore.NewQuery("note"), &notes); err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Owner != "alice" {
+		t.Fatalf("Expected only alice's note but got [%+v]", notes)
+	}
+}
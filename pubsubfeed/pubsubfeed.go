@@ -0,0 +1,44 @@
+// Package pubsubfeed provides a gaestore.ChangeFeed publishing the changes
+// made through a store to a Pub/Sub topic, as JSON messages with the kind
+// and operation as attributes.
+//
+//	client, err := pubsub.NewClient(ctx, projectID)
+//	s := gaestore.NewStore(gaestore.WithChangeFeed(pubsubfeed.New(client.Topic("changes"))))
+package pubsubfeed
+
+import (
+	"context"
+	"encoding/json"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/floresj/gaestore"
+)
+
+// Feed is a gaestore.ChangeFeed for a Pub/Sub topic
+type Feed struct {
+	t *pubsub.Topic
+}
+
+var _ gaestore.ChangeFeed = (*Feed)(nil)
+
+// New returns a feed publishing to t
+func New(t *pubsub.Topic) *Feed {
+	return &Feed{t: t}
+}
+
+// PublishChange publishes c and waits for the topic to accept it
+func (f *Feed) PublishChange(ctx context.Context, c *gaestore.Change) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	msg := &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			"kind": c.Kind,
+			"op":   c.Op,
+		},
+	}
+	_, err = f.t.Publish(ctx, msg).Get(ctx)
+	return err
+}
@@ -0,0 +1,229 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// ErrPurgeDisabled is returned by PurgeKind on a store created without
+// WithPurge
+var ErrPurgeDisabled = errors.New("gaestore: purging is disabled")
+
+// purgeBatchSize is the number of keys deleted by each pass of PurgeKind,
+// the most a datastore batch delete accepts
+const purgeBatchSize = 500
+
+// MultiDeleter is implemented by Backends that can delete several entities
+// in a single call. PurgeKind deletes one entity at a time with other
+// backends.
+type MultiDeleter interface {
+	// DeleteMulti deletes the entities for keys, returning an
+	// appengine.MultiError when only some of them fail
+	DeleteMulti(ctx context.Context, keys []*datastore.Key) error
+}
+
+// WithPurge allows PurgeKind, which refuses to run otherwise so that a
+// store used in production can't delete a kind by accident. Only enable it
+// for development, test and staging data.
+func WithPurge() Option {
+	return func(c *Client) {
+		c.allowPurge = true
+	}
+}
+
+// PurgeKind deletes every entity of kind in batched keys only passes and
+// removes them from the cache, returning the number deleted. Hooks aren't
+// run, no events are published and deletes aren't audited, but the token
+// indexes kept with WithTokenIndex are deleted along with the entities,
+// and so are the chunks of SplitBytes fields and the objects of BlobRef
+// fields when kind is registered with Register, which costs a read of
+// each entity. It fails with ErrPurgeDisabled unless the store was
+// created with WithPurge, and on a dry run store only records the
+// deletes.
+func (s *Client) PurgeKind(ctx context.Context, kind string) (int, error) {
+	if !s.allowPurge {
+		return 0, ErrPurgeDisabled
+	}
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	q := datastore.NewQuery(kind).KeysOnly()
+	n, err := s.deleteMatching(ctx, q, purgeBatchSize)
+	if err != nil {
+		return n, err
+	}
+	s.log().Infof(ctx, "gaestore: purged %d entities of [%s]", n, kind)
+	return n, nil
+}
+
+// deleteMatching deletes the entities matched by the keys only query q in
+// batches, returning the number deleted
+func (s *Client) deleteMatching(ctx context.Context, q *datastore.Query, batchSize int) (int, error) {
+	n := 0
+	var cursor *datastore.Cursor
+	for {
+		q := q.Limit(batchSize)
+		if cursor != nil {
+			q = q.Start(*cursor)
+		}
+		it := s.ds().RunQuery(ctx, q)
+		var keys []*datastore.Key
+		for {
+			key, err := it.Next(nil)
+			if err == datastore.Done {
+				break
+			}
+			if err != nil {
+				return n, err
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			return n, nil
+		}
+		if err := s.purge(ctx, keys); err != nil {
+			return n, err
+		}
+		n += len(keys)
+		if len(keys) < batchSize {
+			return n, nil
+		}
+		c, err := it.Cursor()
+		if err != nil {
+			return n, err
+		}
+		cursor = &c
+	}
+}
+
+// purge deletes the entities for keys and their cached copies
+func (s *Client) purge(ctx context.Context, keys []*datastore.Key) error {
+	if s.dryRun {
+		for _, key := range keys {
+			s.record(ctx, OpDelete, key, nil)
+		}
+		return nil
+	}
+	children, err := s.storedChildren(ctx, keys)
+	if err != nil {
+		return err
+	}
+	// Tombstones are written first as for Delete
+	tombstoned := make([]bool, len(keys))
+	for i, key := range keys {
+		tombstoned[i] = s.useCache && s.cacheTombstone(ctx, key) == nil
+	}
+	if err := s.datastoreDeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+	s.deleteTokenIndexes(ctx, keys)
+	for i, c := range children {
+		s.deleteChunks(ctx, keys[i], c.chunks)
+		s.deleteBlobs(ctx, keys[i], c.blobs)
+	}
+	if !s.useCache {
+		return nil
+	}
+	for i, key := range keys {
+		if tombstoned[i] {
+			continue
+		}
+		err := s.cacheDelete(ctx, key)
+		if err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+			s.log().Warningf(ctx, "gaestore: unable to delete [%v] from cache: %v", key, err)
+			s.reportError(ctx, "DeleteCache", key, err)
+		}
+	}
+	return nil
+}
+
+// children are the chunks and blob objects stored for an entity
+type children struct {
+	chunks []*datastore.Key
+	blobs  []string
+}
+
+// storedChildren returns the children of the entities for keys, all of
+// the same kind, or nil when the kind isn't registered or has none
+func (s *Client) storedChildren(ctx context.Context, keys []*datastore.Key) ([]children, error) {
+	e, err := newEntity(keys[0].Kind())
+	if err != nil || !hasSplitFields(e) && (s.blobBucket == nil || !hasBlobRefs(e)) {
+		return nil, nil
+	}
+	stored := make([]children, len(keys))
+	for i, key := range keys {
+		if stored[i].chunks, err = s.storedChunks(ctx, key, e); err != nil {
+			return nil, err
+		}
+		if stored[i].blobs, err = s.storedBlobs(ctx, key, e); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+// deleteTokenIndexes deletes the token indexes of the deleted entities for
+// keys, unless their kind is registered without search fields. Failures
+// are logged and reported rather than returned, as the entities have been
+// deleted.
+func (s *Client) deleteTokenIndexes(ctx context.Context, keys []*datastore.Key) {
+	if !s.tokenIndex {
+		return
+	}
+	if e, err := newEntity(keys[0].Kind()); err == nil {
+		if info := structInfo(e); info == nil || len(info.tokenFields) == 0 {
+			return
+		}
+	}
+	tks := make([]*datastore.Key, 0, len(keys))
+	for _, key := range keys {
+		if tk, err := tokenKey(key); err == nil {
+			tks = append(tks, tk)
+		}
+	}
+	if len(tks) == 0 {
+		return
+	}
+	if err := s.datastoreDeleteMulti(ctx, tks); err != nil {
+		s.log().Errorf(ctx, "gaestore: unable to delete token indexes of [%s]: %v", keys[0].Kind(), err)
+		s.reportError(ctx, "Tokens", nil, err)
+	}
+}
+
+func (s *Client) datastoreDeleteMulti(ctx context.Context, keys []*datastore.Key) error {
+	md, ok := s.ds().(MultiDeleter)
+	if !ok {
+		return s.deleteEach(ctx, keys)
+	}
+	return s.retry(ctx, func() error {
+		ctx, done := s.startBatchRPC(ctx, RPCDatastoreDelete, keys[0].Kind(), len(keys))
+		err := md.DeleteMulti(ctx, keys)
+		done(err)
+		return err
+	})
+}
+
+// deleteEach deletes keys one at a time, failing like a single DeleteMulti
+func (s *Client) deleteEach(ctx context.Context, keys []*datastore.Key) error {
+	var merr appengine.MultiError
+	for i, key := range keys {
+		if err := s.datastoreDelete(ctx, key); err != nil {
+			if merr == nil {
+				merr = make(appengine.MultiError, len(keys))
+			}
+			merr[i] = err
+		}
+	}
+	if merr != nil {
+		return merr
+	}
+	return nil
+}
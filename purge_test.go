@@ -0,0 +1,66 @@
+package gaestore_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestPurgeKind(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	c := gaestoretest.NewCacher()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithCacher(c))
+	for i := 0; i < 3; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i), Name: "name"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Get(ctx, &exported{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Put(ctx, &renamed{ID: "1", FullName: "name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.PurgeKind(ctx, "exported"); err != gaestore.ErrPurgeDisabled {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrPurgeDisabled, err)
+	}
+
+	s = gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithCacher(c), gaestore.WithPurge())
+	n, err := s.PurgeKind(ctx, "exported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("Expected [3] entities purged but got [%d]", n)
+	}
+	if err := s.Get(ctx, &exported{ID: "1"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected the entity to be purged but got [%v]", err)
+	}
+	if err := s.Get(ctx, &renamed{ID: "1"}); err != nil {
+		t.Fatalf("Expected other kinds to be kept but got [%v]", err)
+	}
+}
+
+func TestPurgeKindTokens(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithTokenIndex(), gaestore.WithPurge())
+	for _, r := range []*recipe{{ID: "1", Title: "Tomato Soup"}, {ID: "2", Title: "Pasta"}} {
+		if _, err := s.Put(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := b.Len(); n != 4 {
+		t.Fatalf("Expected [4] entities with their token indexes but got [%d]", n)
+	}
+	if _, err := s.PurgeKind(ctx, "recipe"); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.Len(); n != 0 {
+		t.Fatalf("Expected the token indexes to be purged but got [%d] entities", n)
+	}
+}
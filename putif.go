@@ -0,0 +1,125 @@
+package gaestore
+
+import (
+	"context"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// PutIf saves e like Put, but only when the property field of the stored
+// entity equals expected, failing with ErrConditionFailed otherwise. The
+// stored entity is checked and e written in a transaction, joining the
+// one ctx is in if any, as a lighter alternative to optimistic locking for
+// a single field such as a status or revision. A nil expected matches an
+// entity that doesn't exist or lacks the property.
+func (s *Client) PutIf(ctx context.Context, e Entity, field string, expected interface{}, opts ...CallOption) (*datastore.Key, error) {
+	op := &Operation{Name: OpPutIf, Entity: e, cond: &condition{field: field, expected: expected}, opts: newCallOptions(opts)}
+	err := s.run(ctx, op)
+	return op.Key, err
+}
+
+// condition is the check made by an OpPutIf
+type condition struct {
+	field    string
+	expected interface{}
+}
+
+// matches reports whether the property of props, the stored entity or nil
+// when there is none, equals the expected value
+func (c *condition) matches(props []datastore.Property) bool {
+	var values []interface{}
+	for _, p := range props {
+		if p.Name == c.field && p.Value != nil {
+			values = append(values, appds.Normalize(p.Value))
+		}
+	}
+	if c.expected == nil {
+		return len(values) == 0
+	}
+	return len(values) == 1 && appds.Compare(values[0], appds.Normalize(c.expected)) == 0
+}
+
+func (s *Client) execPutIf(ctx context.Context, op *Operation) (err error) {
+	before, err := s.before(ctx, op.Key)
+	if err != nil {
+		return err
+	}
+	var created bool
+	if op.Key, created, err = s.putIf(ctx, op.Key, op.Entity, op.cond, op.opts); op.Key == nil {
+		return err
+	}
+	// A failing hook or cache write still leaves the entity written
+	recordWrites(ctx, op.Key)
+	t := EventUpdate
+	if created {
+		t = EventCreate
+	}
+	s.publish(ctx, t, op.Key, op.Entity)
+	s.publishChange(ctx, OpPut, op.Key, before, op.Entity)
+	return err
+}
+
+// putIf writes e with key in a transaction, which is the one ctx is in
+// when it is, when cond matches the stored entity, returning whether it
+// was created
+func (s *Client) putIf(ctx context.Context, key *datastore.Key, e Entity, cond *condition, opts callOptions) (*datastore.Key, bool, error) {
+	if _, ok := e.(BeforePutter); ok && !opts.skipHooks {
+		if err := beforePut(ctx, e); err != nil {
+			return nil, false, err
+		}
+		if key = e.Key(ctx); key == nil {
+			return nil, false, ErrNilKey
+		}
+	}
+	if err := s.uploadBlobs(ctx, key, e); err != nil {
+		return nil, false, err
+	}
+	var (
+		written *datastore.Key
+		created bool
+	)
+	err := s.transact(ctx, func(tc context.Context) error {
+		var props datastore.PropertyList
+		created = true
+		if !key.Incomplete() {
+			switch err := s.datastoreGet(tc, key, &props); err {
+			case nil:
+				created = false
+			case datastore.ErrNoSuchEntity:
+			default:
+				return err
+			}
+		}
+		if !cond.matches(props) {
+			return ErrConditionFailed
+		}
+		var (
+			k   *datastore.Key
+			err error
+		)
+		if hasSplitFields(e) {
+			k, err = s.putSplit(tc, key, e)
+		} else {
+			k, err = s.datastorePut(tc, key, e)
+		}
+		if err != nil {
+			return err
+		}
+		written = k
+		if s.auditUser != nil {
+			return s.putAuditEntry(tc, OpPutIf, k, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if s.useCache {
+		s.refreshCache(ctx, written, e)
+	}
+	if opts.skipHooks {
+		return written, created, nil
+	}
+	return written, created, s.afterPutHooks(ctx, written, e)
+}
@@ -0,0 +1,74 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestPutIf(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+
+	// A nil expected value matches a missing entity
+	if _, err := s.PutIf(ctx, &exported{ID: "1", Name: "John"}, "Name", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PutIf(ctx, &exported{ID: "1", Name: "Jane"}, "Name", nil); !errors.Is(err, gaestore.ErrConditionFailed) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrConditionFailed, err)
+	}
+
+	if _, err := s.PutIf(ctx, &exported{ID: "1", Name: "Jane"}, "Name", "Bob"); !errors.Is(err, gaestore.ErrConditionFailed) {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrConditionFailed, err)
+	}
+	e := &exported{ID: "1"}
+	if err := s.Get(ctx, e); err != nil || e.Name != "John" {
+		t.Fatalf("Expected the entity to be unchanged but got [%s] %v", e.Name, err)
+	}
+
+	if _, err := s.PutIf(ctx, &exported{ID: "1", Name: "Jane"}, "Name", "John"); err != nil {
+		t.Fatal(err)
+	}
+	e = &exported{ID: "1"}
+	if err := s.Get(ctx, e); err != nil || e.Name != "Jane" {
+		t.Fatalf("Expected [Jane] but got [%s] %v", e.Name, err)
+	}
+}
+
+func TestPutIfInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	if _, err := s.Put(ctx, &exported{ID: "1", Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cached copy is left alone by a transaction that fails
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := s.PutIf(tc, &exported{ID: "1", Name: "Jane"}, "Name", "John"); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	e := &exported{ID: "1"}
+	if err := s.Get(ctx, e); err != nil || e.Name != "John" {
+		t.Fatalf("Expected [John] but got [%s] %v", e.Name, err)
+	}
+
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		_, err := s.PutIf(tc, &exported{ID: "1", Name: "Jane"}, "Name", "John")
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, e); err != nil || e.Name != "Jane" {
+		t.Fatalf("Expected [Jane] but got [%s] %v", e.Name, err)
+	}
+}
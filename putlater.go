@@ -0,0 +1,78 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/memcache"
+)
+
+func init() {
+	// The property values held in interfaces by a PutLater task
+	gob.Register(time.Time{})
+	gob.Register(&datastore.Key{})
+	gob.Register(appengine.GeoPoint{})
+	gob.Register(appengine.BlobKey(""))
+	gob.Register(datastore.ByteString(nil))
+}
+
+var putLaterFunc = delay.Func("gaestore.PutLater", runPutLater)
+
+// PutLater saves e from a task instead of during the request, retrying
+// until the write succeeds, for writes where latency matters more than
+// immediacy. The entity is snapshotted when PutLater is called, which
+// runs its BeforePut hook and checks it may be written like a Put; the
+// task writes it to the App Engine datastore and removes any copy from
+// memcache, but doesn't run AfterPut hooks or publish events. The
+// entity's key must be complete, so that retried tasks write the same
+// entity.
+func (s *Client) PutLater(ctx context.Context, e Entity, opts ...CallOption) error {
+	return s.run(ctx, &Operation{Name: OpPutLater, Entity: e, opts: newCallOptions(opts)})
+}
+
+// callPutLaterTask adds a task writing props to key, and is replaced in
+// tests
+var callPutLaterTask = func(ctx context.Context, key *datastore.Key, props datastore.PropertyList) error {
+	return putLaterFunc.Call(ctx, key.Encode(), props)
+}
+
+func (s *Client) putLater(ctx context.Context, key *datastore.Key, e Entity, opts callOptions) error {
+	if key.Incomplete() {
+		return fmt.Errorf("PutLater needs a complete key, got [%v]", key)
+	}
+	if !opts.skipHooks {
+		if err := beforePut(ctx, e); err != nil {
+			return err
+		}
+	}
+	props, err := saveEntity(e)
+	if err != nil {
+		return err
+	}
+	return callPutLaterTask(ctx, key, props)
+}
+
+func runPutLater(ctx context.Context, encodedKey string, props datastore.PropertyList) error {
+	key, err := datastore.DecodeKey(encodedKey)
+	if err != nil {
+		// Retrying will never decode the key so drop the task
+		log.Errorf(ctx, "gaestore: dropping PutLater, invalid key [%v]", err)
+		return nil
+	}
+	if ctx, err = appengine.Namespace(ctx, key.Namespace()); err != nil {
+		return err
+	}
+	if _, err := datastore.Put(ctx, key, &props); err != nil {
+		return err
+	}
+	if err := memcache.Delete(ctx, key.Encode()); err != nil && err != memcache.ErrCacheMiss {
+		log.Warningf(ctx, "gaestore: unable to delete [%v] from cache: %v", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package gaestore
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestPutLater(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	s := NewStoreWithCache()
+	o := &object{ID: "1", Name: "John"}
+	if err := s.PutLater(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &object{ID: "1"}); !IsNotFound(err) {
+		t.Fatalf("Expected the write to be deferred but got [%v]", err)
+	}
+
+	// Run the task the call added
+	props, err := datastore.SaveStruct(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runPutLater(ctx, o.Key(ctx).Encode(), props); err != nil {
+		t.Fatal(err)
+	}
+	got := &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "John" {
+		t.Fatalf("Expected [John] but got [%s]", got.Name)
+	}
+
+	if err := s.PutLater(ctx, &object{}); err == nil {
+		t.Fatal("Expected an error for an incomplete key")
+	}
+}
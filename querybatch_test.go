@@ -0,0 +1,134 @@
+package gaestore_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+// countingBackend counts the batched reads made through it
+type countingBackend struct {
+	*gaestoretest.Backend
+	gets [][]*datastore.Key
+}
+
+func (b *countingBackend) GetMulti(ctx context.Context, keys []*datastore.Key, dst interface{}) error {
+	b.gets = append(b.gets, keys)
+	return b.Backend.GetMulti(ctx, keys, dst)
+}
+
+func TestQueryBatchSize(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := &countingBackend{Backend: gaestoretest.NewBackend()}
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithBatchSize(2))
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i), Name: fmt.Sprint("name", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Read the second batch from the datastore rather than the cache
+	s.Evict(ctx, datastore.NewKey(ctx, "exported", "2", 0, nil), datastore.NewKey(ctx, "exported", "3", 0, nil))
+
+	var entities []*exported
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 5 {
+		t.Fatalf("Expected 5 entities but got [%d]", len(entities))
+	}
+	for i, e := range entities {
+		if e.ID != fmt.Sprint(i) || e.Name != fmt.Sprint("name", i) {
+			t.Fatalf("Expected entity [%d] in order but got [%+v]", i, e)
+		}
+	}
+	if len(b.gets) != 1 || len(b.gets[0]) != 2 {
+		t.Fatalf("Expected a batched read of 2 keys but got [%v]", b.gets)
+	}
+
+	entities = nil
+	s = s.WithoutCache()
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities); err != nil || len(entities) != 5 {
+		t.Fatalf("Expected 5 entities but got [%d] %v", len(entities), err)
+	}
+	// The last result is read on its own
+	if len(b.gets) != 3 || len(b.gets[1]) != 2 || len(b.gets[2]) != 2 {
+		t.Fatalf("Expected 2 more batched reads but got [%v]", b.gets[1:])
+	}
+}
+
+type tagged struct {
+	ID   string `datastore:"-" json:"-"`
+	Tags []string
+}
+
+func (e *tagged) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "tagged", e.ID, 0, nil)
+}
+
+func TestQueryValuesReuse(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithBatchSize(1)).WithoutCache()
+
+	for _, e := range []*tagged{{ID: "a", Tags: []string{"x", "y"}}, {ID: "b", Tags: []string{"z"}}} {
+		if _, err := s.Put(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Values are read into the same block for every batch, which must not
+	// carry fields over
+	var entities []tagged
+	if _, err := s.Query(ctx, datastore.NewQuery("tagged"), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 || len(entities[0].Tags) != 2 || len(entities[1].Tags) != 1 || entities[1].Tags[0] != "z" {
+		t.Fatalf("Expected the tags of each entity but got [%+v]", entities)
+	}
+}
+
+func TestQueryPresize(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var entities []*exported
+	if _, err := s.Query(ctx, datastore.NewQuery("exported").Limit(10), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 3 || cap(entities) != 10 {
+		t.Fatalf("Expected 3 entities with room for 10 but got [%d] with room for [%d]", len(entities), cap(entities))
+	}
+}
+
+func TestQueryBatchAccounting(t *testing.T) {
+	ctx := gaestore.WithAccounting(gaestoretest.NewContext())
+	s := gaestoretest.NewStore(t, gaestore.WithBatchSize(5)).WithoutCache()
+	for i := 0; i < 3; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var entities []*exported
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch is a single RPC reading every result
+	sum := gaestore.Summary(ctx)
+	if sum.DatastoreQueries != 1 || sum.QueryResults != 3 || sum.DatastoreReads != 1 || sum.EntityReads != 3 {
+		t.Fatalf("Expected 1 query of 3 results read by 1 RPC but got [%+v]", sum)
+	}
+	if sum.DatastoreWrites != 3 || sum.EntityWrites != 3 {
+		t.Fatalf("Expected 3 writes but got [%+v]", sum)
+	}
+}
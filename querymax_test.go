@@ -0,0 +1,58 @@
+package gaestore_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestQueryMaxResults(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithMaxResults(3))
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.Put(ctx, &exported{ID: fmt.Sprint(i), Name: fmt.Sprint("name", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var entities []*exported
+	_, err := s.Query(ctx, datastore.NewQuery("exported"), &entities)
+	var terr *gaestore.ErrResultTruncated
+	if !errors.As(err, &terr) || terr.Max != 3 {
+		t.Fatalf("Expected the query to be truncated but got [%v]", err)
+	}
+	if len(entities) != 3 {
+		t.Fatalf("Expected 3 entities but got [%d]", len(entities))
+	}
+	// The cursor resumes after the truncated results
+	if _, err := s.Query(ctx, datastore.NewQuery("exported").Start(terr.Cursor), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 5 || entities[3].ID != "3" {
+		t.Fatalf("Expected the remaining entities but got [%d]", len(entities))
+	}
+
+	// Queries limited within the cap, or with the cap lifted, read every
+	// result
+	entities = nil
+	if _, err := s.Query(ctx, datastore.NewQuery("exported").Limit(3), &entities); err != nil || len(entities) != 3 {
+		t.Fatalf("Expected 3 entities but got [%d] [%v]", len(entities), err)
+	}
+	entities = nil
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities, gaestore.MaxResults(0)); err != nil || len(entities) != 5 {
+		t.Fatalf("Expected 5 entities but got [%d] [%v]", len(entities), err)
+	}
+
+	// Stores can opt out of the cap, and calls opt back in
+	s = gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithMaxResults(0))
+	entities = nil
+	if _, err := s.Query(ctx, datastore.NewQuery("exported"), &entities, gaestore.MaxResults(2)); !errors.As(err, &terr) || len(entities) != 2 {
+		t.Fatalf("Expected 2 entities to be read but got [%d] [%v]", len(entities), err)
+	}
+}
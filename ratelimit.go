@@ -0,0 +1,82 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// RateLimitKind is the kind the windows of a RateLimiter are stored under
+// when the cache can't count them. Expired windows can be deleted with
+// Sweep.
+const RateLimitKind = "GaestoreRateLimit"
+
+// RateLimiter allows up to a limit of calls per fixed window of time for
+// each key, such as a user ID or IP address. Calls are counted with cache
+// increments, falling back to a transactional datastore counter when the
+// cache can't increment or fails.
+type RateLimiter struct {
+	name   string
+	limit  int64
+	window time.Duration
+	s      *Client
+}
+
+type rateWindow struct {
+	Count   int64 `datastore:",noindex"`
+	Expires time.Time
+}
+
+func (w *rateWindow) ExpiresAt() time.Time {
+	return w.Expires
+}
+
+// NewRateLimiter returns a limiter called name allowing limit calls per
+// window for each key
+func (s *Client) NewRateLimiter(name string, limit int64, window time.Duration) *RateLimiter {
+	return &RateLimiter{name: name, limit: limit, window: window, s: s}
+}
+
+// Allow counts a call for key and reports whether it's within the limit of
+// the current window. Called in a transaction, a call counted in the
+// datastore is counted as part of it, so isn't counted if it fails.
+func (r *RateLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	s := r.s
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return false, err
+	}
+	start := s.now().Truncate(r.window)
+	id := fmt.Sprintf("%s:%s:%d", r.name, key, start.Unix())
+	if inc, ok := s.mc().(Incrementer); ok && s.useCache && s.breaker.allow() {
+		ctx, done := s.startRPC(ctx, RPCMemcacheIncrement, RateLimitKind)
+		n, err := inc.Increment(ctx, "gaestore:ratelimit:"+id, 1, 0)
+		done(err)
+		s.breaker.done(err)
+		if err == nil {
+			return int64(n) <= r.limit, nil
+		}
+		s.log().Warningf(ctx, "gaestore: unable to count [%s] in cache, using the datastore: %v", id, err)
+	}
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	k := datastore.NewKey(ctx, RateLimitKind, id, 0, nil)
+	var n int64
+	err = s.transact(ctx, func(tc context.Context) error {
+		w := rateWindow{Expires: start.Add(r.window)}
+		if err := s.datastoreGet(tc, k, &w); err != nil && !IsNotFound(err) {
+			return err
+		}
+		w.Count++
+		n = w.Count
+		_, err := s.datastorePut(tc, k, &w)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return n <= r.limit, nil
+}
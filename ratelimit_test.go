@@ -0,0 +1,60 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+// plainCacher hides the increments of the cacher it wraps
+type plainCacher struct {
+	gaestore.Cacher
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	for name, c := range map[string]gaestore.Cacher{
+		"cache":     gaestoretest.NewCacher(),
+		"datastore": plainCacher{gaestoretest.NewCacher()},
+	} {
+		s := gaestoretest.NewStore(t, gaestore.WithCacher(c))
+		r := s.NewRateLimiter("api", 3, time.Hour)
+		for i := 0; i < 4; i++ {
+			ok, err := r.Allow(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != (i < 3) {
+				t.Fatalf("Expected call %d counted by the %s to be allowed [%t] but got [%t]", i, name, i < 3, ok)
+			}
+		}
+		if ok, err := r.Allow(ctx, "bob"); err != nil || !ok {
+			t.Fatalf("Expected keys to be limited separately by the %s but got [%t, %v]", name, ok, err)
+		}
+	}
+}
+
+func TestRateLimiterInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithCacher(plainCacher{gaestoretest.NewCacher()}))
+	r := s.NewRateLimiter("api", 1, time.Hour)
+
+	// Calls of a transaction that fails aren't counted
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := r.Allow(tc, "alice"); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	if ok, err := r.Allow(ctx, "alice"); err != nil || !ok {
+		t.Fatalf("Expected the call to be allowed but got [%t, %v]", ok, err)
+	}
+}
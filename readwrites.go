@@ -0,0 +1,206 @@
+package gaestore
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// writeSet holds the keys of the entities written and deleted in a request
+type writeSet struct {
+	mu      sync.Mutex
+	written []*datastore.Key
+	deleted map[string]*datastore.Key
+}
+
+type writeSetKey struct{}
+
+// WithReadYourWrites returns a context that remembers the entities written
+// and deleted through stores using it, or a context derived from it. Later
+// queries with the context, which may not see those writes yet as they
+// are eventually consistent, have the written entities that match merged
+// into their results in order, and the deleted ones removed. Call it once
+// at the start of a request.
+//
+// Ancestor queries are strongly consistent so are left alone, as are
+// queries continued from a cursor or with an offset, so entities aren't
+// repeated across pages.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeSetKey{}, &writeSet{deleted: make(map[string]*datastore.Key)})
+}
+
+func writeSetFromContext(ctx context.Context) *writeSet {
+	w, _ := ctx.Value(writeSetKey{}).(*writeSet)
+	return w
+}
+
+// recordWrites remembers keys as written when ctx is from
+// WithReadYourWrites
+func recordWrites(ctx context.Context, keys ...*datastore.Key) {
+	w := writeSetFromContext(ctx)
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, key := range keys {
+		if key == nil {
+			continue
+		}
+		delete(w.deleted, key.Encode())
+		if w.index(key) < 0 {
+			w.written = append(w.written, key)
+		}
+	}
+}
+
+// recordDelete remembers key as deleted when ctx is from
+// WithReadYourWrites
+func recordDelete(ctx context.Context, key *datastore.Key) {
+	w := writeSetFromContext(ctx)
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if i := w.index(key); i >= 0 {
+		w.written = append(w.written[:i], w.written[i+1:]...)
+	}
+	w.deleted[key.Encode()] = key
+}
+
+func (w *writeSet) index(key *datastore.Key) int {
+	for i, k := range w.written {
+		if k.Equal(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// forKind returns the keys of kind written and deleted
+func (w *writeSet) forKind(kind string) (written []*datastore.Key, deleted map[string]bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	deleted = make(map[string]bool)
+	for _, k := range w.written {
+		if k.Kind() == kind {
+			written = append(written, k)
+		}
+	}
+	for id, k := range w.deleted {
+		if k.Kind() == kind {
+			deleted[id] = true
+		}
+	}
+	return written, deleted
+}
+
+// mergeWrites merges the writes remembered for ctx into the results of q
+// from index start of dv, whose keys are keys
+func (s *Client) mergeWrites(ctx context.Context, q *datastore.Query, dv reflect.Value, start int, keys []*datastore.Key, mat multiArgType, elemType reflect.Type, useCache bool, opts callOptions) error {
+	w := writeSetFromContext(ctx)
+	if w == nil {
+		return nil
+	}
+	pq, err := appds.ParseQuery(q)
+	if err != nil {
+		return err
+	}
+	if pq.Ancestor != nil && !pq.Eventual || pq.Start.String() != "" || pq.Offset > 0 || pq.Kind == "" {
+		return nil
+	}
+	written, deleted := w.forKind(pq.Kind)
+	if len(written) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	type result struct {
+		ev    reflect.Value
+		key   *datastore.Key
+		props []datastore.Property
+	}
+	var (
+		results        []result
+		added, removed bool
+	)
+	// add appends the entity ev points to, if it was written only when it
+	// still matches the query
+	add := func(ev reflect.Value, key *datastore.Key, check bool) error {
+		props, err := saveEntity(ev.Interface().(Entity))
+		if err != nil {
+			return err
+		}
+		if check && !appds.Matches(pq, key, props) {
+			removed = true
+			return nil
+		}
+		results = append(results, result{ev, key, props})
+		return nil
+	}
+	pending := make(map[string]bool, len(written))
+	for _, key := range written {
+		pending[key.Encode()] = true
+	}
+	for i, key := range keys {
+		id := key.Encode()
+		if deleted[id] {
+			removed = true
+			continue
+		}
+		ev := dv.Index(start + i)
+		if mat == multiArgTypeStructPtr {
+			ev = ev.Elem()
+		}
+		if err := add(ev.Addr(), key, pending[id]); err != nil {
+			return err
+		}
+		delete(pending, id)
+	}
+	namespace := appds.Namespace(ctx)
+	for _, key := range written {
+		if !pending[key.Encode()] || key.Namespace() != namespace {
+			continue
+		}
+		ev := reflect.New(elemType)
+		if err := s.getByKey(ctx, key, ev.Interface().(Entity), useCache, opts); err != nil {
+			if !IsNotFound(err) {
+				s.log().Errorf(ctx, "gaestore: unable to get [%v]: %v", key, err)
+				s.reportError(ctx, OpGet, key, err)
+			}
+			continue
+		}
+		n := len(results)
+		if err := add(ev, key, true); err != nil {
+			return err
+		}
+		added = added || len(results) > n
+	}
+	if !added && !removed {
+		return nil
+	}
+
+	if added {
+		sort.SliceStable(results, func(i, j int) bool {
+			return appds.Less(pq.Orders, results[i].key, results[i].props, results[j].key, results[j].props)
+		})
+	}
+	if pq.Limit >= 0 && len(results) > int(pq.Limit) {
+		results = results[:pq.Limit]
+	}
+	merged := reflect.MakeSlice(dv.Type(), start, start+len(results))
+	reflect.Copy(merged, dv.Slice(0, start))
+	for _, r := range results {
+		ev := r.ev
+		if mat != multiArgTypeStructPtr {
+			ev = ev.Elem()
+		}
+		merged = reflect.Append(merged, ev)
+	}
+	dv.Set(merged)
+	return nil
+}
@@ -0,0 +1,68 @@
+package gaestore_test
+
+import (
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	ctx := gaestoretest.NewContext()
+
+	for _, e := range []*exported{{ID: "1", Name: "b"}, {ID: "2", Name: "c"}, {ID: "3", Name: "d"}} {
+		if _, err := s.Put(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Later writes aren't seen by queries until applied
+	b.SetConsistency(0)
+
+	rctx := gaestore.WithReadYourWrites(ctx)
+	if _, err := s.Put(rctx, &exported{ID: "4", Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(rctx, &exported{ID: "3", Name: "z"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(rctx, &exported{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(entities []*exported) []string {
+		var names []string
+		for _, e := range entities {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+	var entities []*exported
+	q := datastore.NewQuery("exported").Filter("Name <", "e").Order("Name")
+	if _, err := s.Query(rctx, q, &entities); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(entities); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("Expected [a b] but got %v", got)
+	}
+
+	// Limits apply to the merged results
+	entities = nil
+	if _, err := s.Query(rctx, q.Limit(1), &entities); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(entities); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("Expected [a] but got %v", got)
+	}
+
+	// Other requests see the new entity once it's applied
+	entities = nil
+	if _, err := s.Query(ctx, q, &entities); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(entities); len(got) == 0 || got[0] == "a" {
+		t.Fatalf("Expected the new entity not to be applied but got %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package gaestore
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// redactedString replaces the string fields printed by Redact
+const redactedString = "[redacted]"
+
+// Redact returns e formatted for logs with its sensitive fields, those with
+// a pii tag such as `pii:"sensitive"`, redacted. It's used wherever the
+// store logs or records an entity, and is meant for Loggers and middleware
+// printing entities themselves. e isn't modified.
+func Redact(e interface{}) string {
+	v := reflect.ValueOf(e)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Sprintf("%+v", e)
+	}
+	return fmt.Sprintf("%+v", redactedCopy(v.Elem(), true).Elem().Interface())
+}
+
+// redactedCopy returns a pointer to a copy of the struct v with its pii
+// tagged fields zeroed, or with string fields marked as redacted when
+// mark is true
+func redactedCopy(v reflect.Value, mark bool) reflect.Value {
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	for _, pf := range typeInfoOf(v.Type()).pii {
+		f := c.Elem().FieldByIndex(pf.index)
+		if mark && f.Kind() == reflect.String && f.Len() > 0 {
+			f.SetString(redactedString)
+			continue
+		}
+		f.Set(reflect.Zero(f.Type()))
+	}
+	return c
+}
+
+// redacted returns a copy of e without its sensitive fields, or e itself
+// when it isn't a pointer to a struct
+func redacted(e interface{}) interface{} {
+	v := reflect.ValueOf(e)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct || len(typeInfoOf(v.Elem().Type()).pii) == 0 {
+		return e
+	}
+	return redactedCopy(v.Elem(), false).Interface()
+}
@@ -0,0 +1,62 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type patient struct {
+	object
+	SSN   string `pii:"sensitive"`
+	Email string `pii:"hash"`
+	Age   int    `pii:"redact"`
+}
+
+func TestRedact(t *testing.T) {
+	p := &patient{object: object{ID: "1", Name: "John"}, SSN: "123-45-6789", Email: "john@example.com", Age: 42}
+	s := Redact(p)
+	for _, v := range []string{"123-45-6789", "john@example.com", "42"} {
+		if strings.Contains(s, v) {
+			t.Fatalf("Expected [%s] to be redacted from [%s]", v, s)
+		}
+	}
+	if !strings.Contains(s, "Name:John") || !strings.Contains(s, "SSN:"+redactedString) {
+		t.Fatalf("Expected only sensitive fields to be redacted but got [%s]", s)
+	}
+	if p.SSN != "123-45-6789" {
+		t.Fatalf("Expected the entity to be unchanged but got [%+v]", p)
+	}
+	if s := Redact(object{ID: "1"}); s != "{ID:1 Name:}" {
+		t.Fatalf("Expected values to be formatted as is but got [%s]", s)
+	}
+}
+
+func TestRedactAuditSnapshot(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(backend), WithLogger(nopLogger{}), WithAudit(func(context.Context) string {
+		return "tester"
+	}))
+	p := &patient{object: object{ID: "1", Name: "John"}, SSN: "123-45-6789", Email: "john@example.com"}
+	if _, err := s.Put(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshot []byte
+	for _, props := range backend {
+		for _, prop := range props {
+			if prop.Name == "Snapshot" {
+				snapshot = prop.Value.([]byte)
+			}
+		}
+	}
+	var audited patient
+	if err := json.Unmarshal(snapshot, &audited); err != nil {
+		t.Fatal(err)
+	}
+	if audited.Name != "John" || audited.SSN != "" || audited.Email != "" {
+		t.Fatalf("Expected a snapshot without sensitive fields but got [%+v]", audited)
+	}
+}
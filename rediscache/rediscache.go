@@ -0,0 +1,107 @@
+// Package rediscache caches gaestore entities in Redis, for example Cloud
+// Memorystore, in place of App Engine memcache.
+//
+//	s := gaestore.NewStore(
+//		gaestore.WithCache(time.Hour),
+//		gaestore.WithCacher(&rediscache.Cacher{Pool: pool}),
+//	)
+package rediscache
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"google.golang.org/appengine/memcache"
+)
+
+// Cacher is a gaestore.Cacher backed by a Redis connection pool
+type Cacher struct {
+	Pool *redis.Pool
+
+	// Prefix is prepended to every key, allowing several stores to share
+	// a Redis database
+	Prefix string
+}
+
+// Get returns memcache.ErrCacheMiss when key is not cached, as the store
+// expects from every Cacher
+func (c *Cacher) Get(ctx context.Context, key string) ([]byte, error) {
+	conn, err := c.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	value, err := redis.Bytes(redis.DoContext(conn, ctx, "GET", c.Prefix+key))
+	if err == redis.ErrNil {
+		return nil, memcache.ErrCacheMiss
+	}
+	return value, err
+}
+
+// Set stores value under key. A zero ttl keeps the value until Redis
+// evicts it.
+func (c *Cacher) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	conn, err := c.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	args := []interface{}{c.Prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttlMillis(ttl))
+	}
+	_, err = redis.DoContext(conn, ctx, "SET", args...)
+	return err
+}
+
+// Add stores value unless key is cached, returning memcache.ErrNotStored
+// when it is
+func (c *Cacher) Add(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	conn, err := c.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	args := []interface{}{c.Prefix + key, value, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", ttlMillis(ttl))
+	}
+	_, err = redis.String(redis.DoContext(conn, ctx, "SET", args...))
+	if err == redis.ErrNil {
+		return memcache.ErrNotStored
+	}
+	return err
+}
+
+// CopiesValues is true as Set has written the value to the connection
+// once it returns
+func (c *Cacher) CopiesValues() bool {
+	return true
+}
+
+// Delete returns memcache.ErrCacheMiss when key was not cached
+func (c *Cacher) Delete(ctx context.Context, key string) error {
+	conn, err := c.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	n, err := redis.Int(redis.DoContext(conn, ctx, "DEL", c.Prefix+key))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return memcache.ErrCacheMiss
+	}
+	return nil
+}
+
+// ttlMillis returns ttl in milliseconds, at least 1
+func ttlMillis(ttl time.Duration) int64 {
+	ms := int64(ttl / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
@@ -0,0 +1,110 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"google.golang.org/appengine/memcache"
+)
+
+// fakeConn implements the GET, SET, SET NX and DEL commands on a map
+type fakeConn struct {
+	data map[string][]byte
+	args [][]interface{}
+}
+
+func (c *fakeConn) Close() error                      { return nil }
+func (c *fakeConn) Err() error                        { return nil }
+func (c *fakeConn) Send(string, ...interface{}) error { return errors.New("not supported") }
+func (c *fakeConn) Flush() error                      { return nil }
+func (c *fakeConn) Receive() (interface{}, error)     { return nil, errors.New("not supported") }
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.DoContext(context.Background(), cmd, args...)
+}
+
+func (c *fakeConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		// Sent by the pool when a connection is returned
+		return nil, nil
+	}
+	c.args = append(c.args, append([]interface{}{cmd}, args...))
+	key := args[0].(string)
+	switch cmd {
+	case "GET":
+		if v, ok := c.data[key]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "SET":
+		if _, ok := c.data[key]; ok && len(args) > 2 && args[2] == "NX" {
+			return nil, nil
+		}
+		c.data[key] = args[1].([]byte)
+		return "OK", nil
+	case "DEL":
+		if _, ok := c.data[key]; !ok {
+			return int64(0), nil
+		}
+		delete(c.data, key)
+		return int64(1), nil
+	}
+	return nil, errors.New("unknown command " + cmd)
+}
+
+func (c *fakeConn) ReceiveContext(context.Context) (interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestCacher(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{data: map[string][]byte{}}
+	c := &Cacher{
+		Pool:   &redis.Pool{Dial: func() (redis.Conn, error) { return conn, nil }},
+		Prefix: "app:",
+	}
+
+	if _, err := c.Get(ctx, "key"); err != memcache.ErrCacheMiss {
+		t.Fatalf("Expected error [%v] but got [%v]", memcache.ErrCacheMiss, err)
+	}
+	if err := c.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, err := c.Get(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "value" {
+		t.Fatalf("Expected value [value] but got [%s]", value)
+	}
+	if err := c.Delete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "key"); err != memcache.ErrCacheMiss {
+		t.Fatalf("Expected error [%v] but got [%v]", memcache.ErrCacheMiss, err)
+	}
+
+	expected := []interface{}{"SET", "app:key", []byte("value"), "PX", int64(60000)}
+	if !reflect.DeepEqual(conn.args[1], expected) {
+		t.Fatalf("Expected command [%v] but got [%v]", expected, conn.args[1])
+	}
+}
+
+func TestCacherAdd(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{data: map[string][]byte{}}
+	c := &Cacher{Pool: &redis.Pool{Dial: func() (redis.Conn, error) { return conn, nil }}}
+
+	if err := c.Add(ctx, "key", []byte("first"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(ctx, "key", []byte("second"), 0); err != memcache.ErrNotStored {
+		t.Fatalf("Expected error [%v] but got [%v]", memcache.ErrNotStored, err)
+	}
+	if value, err := c.Get(ctx, "key"); err != nil || string(value) != "first" {
+		t.Fatalf("Expected value [first] but got [%s] %v", value, err)
+	}
+}
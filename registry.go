@@ -0,0 +1,48 @@
+package gaestore
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var registry = struct {
+	sync.RWMutex
+	kinds map[string]reflect.Type
+}{kinds: make(map[string]reflect.Type)}
+
+// Register associates a datastore kind with the Go type of e so entities
+// of that kind can be reconstructed outside of the request that wrote
+// them, for example when running asynchronous hooks. It is meant to be
+// called from init.
+func Register(kind string, e Entity) {
+	t := reflect.TypeOf(e)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.kinds[kind] = t
+}
+
+// NewEntity returns a pointer to a new zero value of the type registered
+// for kind
+func NewEntity(kind string) (Entity, error) {
+	return newEntity(kind)
+}
+
+// newEntity returns a pointer to a new zero value of the type registered
+// for kind
+func newEntity(kind string) (Entity, error) {
+	registry.RLock()
+	t, ok := registry.kinds[kind]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No entity type registered for kind [%s]", kind)
+	}
+	e, ok := reflect.New(t).Interface().(Entity)
+	if !ok {
+		return nil, fmt.Errorf("Registered type [%v] is not an Entity", t)
+	}
+	return e, nil
+}
@@ -0,0 +1,109 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine"
+)
+
+// RetryPolicy controls how datastore calls that fail with a transient
+// error are retried
+type RetryPolicy struct {
+	// Attempts is the maximum number of times a call is made, including
+	// the first. Values below two disable retries.
+	Attempts int
+
+	// Backoff is the delay before the first retry. It doubles with each
+	// retry up to MaxBackoff, when MaxBackoff is set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a failed call should be retried. When nil
+	// timeouts and datastore internal errors are retried.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for requests with the default
+// 60 second deadline
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    50 * time.Millisecond,
+	MaxBackoff: time.Second,
+}
+
+// SetRetryPolicy sets the policy used to retry the store's datastore calls
+func (s *Client) SetRetryPolicy(p RetryPolicy) {
+	s.retryPolicy = p
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// transientErrors are the datastore error codes that are worth retrying
+var transientErrors = []string{
+	"datastore_v3: INTERNAL_ERROR",
+	"datastore_v3: TIMEOUT",
+	"datastore_v3: BIGTABLE_ERROR",
+	"datastore_v3: TRY_ALTERNATE_BACKEND",
+}
+
+// IsTransient reports whether err is a timeout or a datastore error that
+// is likely to succeed if retried. A MultiError is transient if any of its
+// errors are.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var merr appengine.MultiError
+	if errors.As(err, &merr) {
+		for _, err := range merr {
+			if IsTransient(err) {
+				return true
+			}
+		}
+		return false
+	}
+	var timeout interface {
+		IsTimeout() bool
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &timeout) && timeout.IsTimeout() {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range transientErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry calls fn until it succeeds, fails with an error the policy does
+// not retry, runs out of attempts or ctx is done
+func (s *Client) retry(ctx context.Context, fn func() error) error {
+	p := s.retryPolicy
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || ctx.Err() != nil || !p.retryable(err) {
+			return err
+		}
+		s.log().Debugf(ctx, "gaestore: retrying after attempt %d failed: %v", attempt, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	tests := []struct {
+		Errors   []error
+		Expected error
+		Calls    int
+	}{
+		{[]error{nil}, nil, 1},
+		{[]error{errTransient, nil}, nil, 2},
+		{[]error{errTransient, errTransient, errTransient, nil}, errTransient, 3},
+		{[]error{errPermanent, nil}, errPermanent, 1},
+	}
+
+	s := NewStore()
+	s.SetLogger(nopLogger{})
+	s.SetRetryPolicy(RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Millisecond,
+		Retryable: func(err error) bool {
+			return err == errTransient
+		},
+	})
+	for _, test := range tests {
+		calls := 0
+		err := s.retry(context.Background(), func() error {
+			err := test.Errors[calls]
+			calls++
+			return err
+		})
+		if err != test.Expected {
+			t.Fatalf("Expected error [%v] but got [%v]", test.Expected, err)
+		}
+		if calls != test.Calls {
+			t.Fatalf("Expected [%v] calls but got [%v]", test.Calls, calls)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		Err      error
+		Expected bool
+	}{
+		{nil, false},
+		{context.DeadlineExceeded, true},
+		{errors.New("API error 3 (datastore_v3: INTERNAL_ERROR): internal error"), true},
+		{errors.New("API error 1 (datastore_v3: BAD_REQUEST): bad request"), false},
+		{&OpError{Op: OpGet, Err: context.DeadlineExceeded}, true},
+		{appengine.MultiError{nil, datastore.ErrNoSuchEntity, context.DeadlineExceeded}, true},
+	}
+	for _, test := range tests {
+		if got := IsTransient(test.Err); got != test.Expected {
+			t.Fatalf("Expected IsTransient(%v) to be [%v] but got [%v]", test.Err, test.Expected, got)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// The datastore and memcache calls made by a store all go through the
+// functions below so they can be traced and observed
+
+// startRPC applies the store's timeouts to a single datastore or memcache
+// call, and traces and times it. The returned function must be called
+// with the result of the call.
+func (s *Client) startRPC(ctx context.Context, op, kind string) (context.Context, func(error)) {
+	return s.startBatchRPC(ctx, op, kind, 1)
+}
+
+// startBatchRPC is startRPC for a call made for n entities
+func (s *Client) startBatchRPC(ctx context.Context, op, kind string, n int) (context.Context, func(error)) {
+	start := time.Now()
+	st := opStatsFromContext(ctx)
+	acct := accountingFromContext(ctx)
+	ctx, cancel := s.withTimeout(ctx, op)
+	ctx, span := s.startSpan(ctx, op)
+	return ctx, func(err error) {
+		cancel()
+		span.End(err)
+		if st != nil {
+			st.record(op, kind, time.Since(start))
+		}
+		if acct != nil {
+			acct.recordRPC(op, n, err)
+		}
+		s.observe(op, kind, start, err)
+	}
+}
+
+func (s *Client) datastoreGet(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	err := s.retry(ctx, func() error {
+		ctx, done := s.startRPC(ctx, RPCDatastoreGet, key.Kind())
+		err := s.ds().Get(ctx, key, withNormalized(dst))
+		done(err)
+		return err
+	})
+	if err != nil && !IsFieldMismatch(err) {
+		return err
+	}
+	if derr := s.decodeEntity(ctx, key, dst); derr != nil {
+		return derr
+	}
+	return err
+}
+
+func (s *Client) datastorePut(ctx context.Context, key *datastore.Key, src interface{}) (k *datastore.Key, err error) {
+	wrapped, restore, err := encodeEntity(src)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	err = s.retry(ctx, func() error {
+		ctx, done := s.startRPC(ctx, RPCDatastorePut, key.Kind())
+		k, err = s.ds().Put(ctx, key, wrapped)
+		done(err)
+		return err
+	})
+	return k, err
+}
+
+func (s *Client) datastoreDelete(ctx context.Context, key *datastore.Key) error {
+	return s.retry(ctx, func() error {
+		ctx, done := s.startRPC(ctx, RPCDatastoreDelete, key.Kind())
+		err := s.ds().Delete(ctx, key)
+		done(err)
+		return err
+	})
+}
+
+// keyIterator runs a keys only query, timing the calls to Next so the
+// query can be observed once iteration has finished. The query's span
+// covers the whole iteration.
+type keyIterator struct {
+	s       *Client
+	ctx     context.Context
+	q       *datastore.Query
+	t       Iterator
+	fetched bool
+	span    Span
+	stats   *opStats
+	kind    string
+	results int
+	elapsed time.Duration
+	err     error
+}
+
+func (s *Client) runKeys(ctx context.Context, q *datastore.Query) *keyIterator {
+	ctx, span := s.startSpan(ctx, RPCDatastoreQuery)
+	q = q.KeysOnly()
+	return &keyIterator{
+		s:     s,
+		ctx:   ctx,
+		q:     q,
+		t:     s.ds().RunQuery(ctx, q),
+		span:  span,
+		stats: opStatsFromContext(ctx),
+	}
+}
+
+// Next returns the next key. Until the first key has been returned the
+// query can be safely restarted, so failures are retried by the store's
+// retry policy.
+func (it *keyIterator) Next() (key *datastore.Key, err error) {
+	start := time.Now()
+	next := func() error {
+		key, err = it.t.Next(nil)
+		if err == datastore.Done {
+			return nil
+		}
+		return err
+	}
+	if it.fetched {
+		next()
+	} else {
+		it.s.retry(it.ctx, func() error {
+			if err := next(); err != nil {
+				it.t = it.s.ds().RunQuery(it.ctx, it.q)
+				return err
+			}
+			return nil
+		})
+	}
+	it.elapsed += time.Since(start)
+	switch {
+	case err == nil:
+		it.fetched = true
+		it.kind = key.Kind()
+		it.results++
+	case err != datastore.Done:
+		it.err = err
+	}
+	return key, err
+}
+
+func (it *keyIterator) Cursor() (datastore.Cursor, error) {
+	return it.t.Cursor()
+}
+
+// close ends the query's span and reports it to the store's metrics
+func (it *keyIterator) close() {
+	it.span.End(it.err)
+	if it.stats != nil {
+		it.stats.record(RPCDatastoreQuery, it.kind, it.elapsed)
+	}
+	if acct := accountingFromContext(it.ctx); acct != nil {
+		acct.recordRPC(RPCDatastoreQuery, it.results, it.err)
+	}
+	if it.s.metrics != nil {
+		it.s.metrics.ObserveOp(RPCDatastoreQuery, it.kind, it.elapsed, it.err)
+	}
+}
+
+// maxCacheItemSize is the largest value memcache accepts, less room for
+// the key and item overhead
+const maxCacheItemSize = 1<<20 - 1024
+
+// Codec returns the codec entities are cached with
+func (s *Client) Codec() memcache.Codec {
+	if s.codec.Marshal == nil {
+		return memcache.JSON
+	}
+	return s.codec
+}
+
+func (s *Client) cacheGet(ctx context.Context, key *datastore.Key, dst interface{}) (*memcache.Item, error) {
+	if !s.breaker.allow() {
+		return nil, errCacheOpen
+	}
+	ctx, done := s.startRPC(ctx, RPCMemcacheGet, key.Kind())
+	value, err := s.mc().Get(ctx, key.Encode())
+	done(err)
+	s.breaker.done(err)
+	if err != nil {
+		return nil, err
+	}
+	if st := opStatsFromContext(ctx); st != nil {
+		st.recordBytes(len(value))
+	}
+	item := &memcache.Item{Key: key.Encode(), Value: value}
+	if bytes.Equal(value, tombstone) {
+		return item, errTombstone
+	}
+	_, value, _ = splitVersion(value)
+	if value, err = verifyChecksum(value); err != nil {
+		return item, &cacheDecodeError{err}
+	}
+	if hasCompressFields(cacheValue(dst)) && bytes.HasPrefix(value, gzipMagic) {
+		if value, err = gunzipBytes(value); err != nil {
+			return item, &cacheDecodeError{err}
+		}
+	}
+	if err := s.Codec().Unmarshal(value, cacheValue(dst)); err != nil {
+		return item, &cacheDecodeError{err}
+	}
+	return item, nil
+}
+
+// cacheDecodeError is returned by cacheGet for a cached value that can't
+// be decoded
+type cacheDecodeError struct {
+	err error
+}
+
+func (e *cacheDecodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cacheDecodeError) Unwrap() error {
+	return e.err
+}
+
+func (s *Client) cacheSet(ctx context.Context, key *datastore.Key, src interface{}) error {
+	set, err := s.prepareCacheSet(ctx, key, src, false)
+	if err != nil {
+		return err
+	}
+	return set()
+}
+
+// cacheFill caches src after it was read from the datastore. With an
+// Adder it's only cached when the key isn't, so a read made before a
+// concurrent write or delete can't replace the value or tombstone that
+// write leaves.
+func (s *Client) cacheFill(ctx context.Context, key *datastore.Key, src interface{}) error {
+	set, err := s.prepareCacheSet(ctx, key, src, true)
+	if err != nil {
+		return err
+	}
+	return set()
+}
+
+// prepareCacheSet encodes src and returns a function writing it to the
+// cache, so that src can change once the function is returned. A fill
+// adds the value rather than setting it.
+func (s *Client) prepareCacheSet(ctx context.Context, key *datastore.Key, src interface{}, fill bool) (func() error, error) {
+	if transactional(ctx) {
+		// Nothing is cached before the transaction commits, when the
+		// copy of an entity it wrote is evicted instead
+		if !fill {
+			s.evictAfterCommit(ctx, key)
+		}
+		return func() error { return nil }, nil
+	}
+	if !s.breaker.allow() {
+		return nil, errCacheOpen
+	}
+	value, buf, err := s.encodeCacheValue(cacheValue(src))
+	if err == nil && hasCompressFields(cacheValue(src)) {
+		value, err = gzipBytes(value)
+		buf.release()
+		buf = nil
+	}
+	if err != nil {
+		s.breaker.cancel()
+		return nil, err
+	}
+	if s.cacheChecksums {
+		value = withChecksum(value)
+		buf.release()
+		buf = nil
+	}
+	v, versioned := cacheValue(src).(Versioned)
+	var version int64
+	if versioned {
+		version = v.Version()
+		value = withVersion(value, version)
+		buf.release()
+		buf = nil
+	}
+	if len(value) > maxCacheItemSize {
+		buf.release()
+		s.breaker.cancel()
+		// Don't leave an older copy to be served in its place
+		if err := s.cacheDelete(ctx, key); err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+			return nil, err
+		}
+		if hasSplitFields(src) {
+			// Expected of large payloads, which are read from the datastore
+			return func() error { return nil }, nil
+		}
+		return nil, ErrOversizedCacheItem
+	}
+	if st := opStatsFromContext(ctx); st != nil {
+		st.recordBytes(len(value))
+	}
+	ttl := s.itemTTL(src)
+	return func() error {
+		ctx, done := s.startRPC(ctx, RPCMemcacheSet, key.Kind())
+		var err error
+		switch adder, ok := s.mc().(Adder); {
+		case versioned:
+			err = s.setVersioned(ctx, key.Encode(), value, ttl, version, fill)
+		case fill && ok:
+			if err = adder.Add(ctx, key.Encode(), value, ttl); err == memcache.ErrNotStored {
+				err = nil
+			}
+		default:
+			err = s.mc().Set(ctx, key.Encode(), value, ttl)
+		}
+		buf.release()
+		done(err)
+		s.breaker.done(err)
+		return err
+	}, nil
+}
+
+// encodeBuffer is a buffer with a JSON encoder writing to it, reused
+// between cache writes
+type encodeBuffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		b := new(encodeBuffer)
+		b.enc = json.NewEncoder(&b.Buffer)
+		return b
+	},
+}
+
+// release returns b to the pool, doing nothing for a nil b
+func (b *encodeBuffer) release() {
+	// Don't keep buffers grown for unusually large entities
+	if b != nil && b.Cap() <= maxCacheItemSize {
+		encodeBuffers.Put(b)
+	}
+}
+
+// encodeCacheValue encodes v for the cache. When the default JSON codec is
+// used with a ValueCopier the value is encoded into a pooled buffer, which
+// must be released once the value has been written.
+func (s *Client) encodeCacheValue(v interface{}) ([]byte, *encodeBuffer, error) {
+	copier, ok := s.mc().(ValueCopier)
+	if s.codec.Marshal != nil || !ok || !copier.CopiesValues() {
+		value, err := s.Codec().Marshal(v)
+		return value, nil, err
+	}
+	buf := encodeBuffers.Get().(*encodeBuffer)
+	buf.Reset()
+	if err := buf.enc.Encode(v); err != nil {
+		buf.release()
+		return nil, nil, err
+	}
+	// Encode ends the value with a newline, which Marshal doesn't
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), buf, nil
+}
+
+// startCacheSet writes src to the cache concurrently with the caller,
+// sending the result on the returned channel. Without waitCacheSet the
+// caller won't receive it, so failures are logged and reported instead.
+func (s *Client) startCacheSet(ctx context.Context, key *datastore.Key, src interface{}) <-chan error {
+	result := make(chan error, 1)
+	set, err := s.prepareCacheSet(ctx, key, src, false)
+	if err != nil {
+		s.cacheSetDone(ctx, key, err)
+		result <- err
+		return result
+	}
+	go func() {
+		err := set()
+		s.cacheSetDone(ctx, key, err)
+		result <- err
+	}()
+	return result
+}
+
+func (s *Client) cacheSetDone(ctx context.Context, key *datastore.Key, err error) {
+	if err != nil && err != errCacheOpen && !s.waitCacheSet {
+		s.log().Warningf(ctx, "gaestore: unable to put [%v] into cache: %v", key, err)
+		s.reportError(ctx, "PutCache", key, err)
+	}
+}
+
+// tombstone is cached in place of an entity being deleted, so that reads
+// don't cache it again from the datastore before the delete is made
+var tombstone = []byte("\x00gaestore:tombstone")
+
+// tombstoneTTL is how long a tombstone is kept, longer than a datastore
+// read and cache fill should take
+const tombstoneTTL = 30 * time.Second
+
+// errTombstone is returned by cacheGet for an entity being deleted
+var errTombstone = errors.New("gaestore: entity is being deleted")
+
+// cacheTombstone replaces the cached copy of key with a tombstone
+func (s *Client) cacheTombstone(ctx context.Context, key *datastore.Key) error {
+	if !s.breaker.allow() {
+		return errCacheOpen
+	}
+	ctx, done := s.startRPC(ctx, RPCMemcacheSet, key.Kind())
+	err := s.mc().Set(ctx, key.Encode(), tombstone, tombstoneTTL)
+	done(err)
+	s.breaker.done(err)
+	return err
+}
+
+// evictAfterCommit replaces the cached copy of key with a tombstone once
+// the transaction ctx is in commits, so that reads made while it commits
+// don't cache the entity as it was. When the tombstone can't be written
+// the cached copy is deleted instead.
+func (s *Client) evictAfterCommit(ctx context.Context, key *datastore.Key) {
+	afterCommit(ctx, func(ctx context.Context) {
+		if s.cacheTombstone(ctx, key) == nil {
+			return
+		}
+		if err := s.cacheDelete(ctx, key); err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+			s.log().Warningf(ctx, "gaestore: unable to delete [%v] from cache: %v", key, err)
+			s.reportError(ctx, "DeleteCache", key, err)
+		}
+	})
+}
+
+func (s *Client) cacheDelete(ctx context.Context, key *datastore.Key) error {
+	if !s.breaker.allow() {
+		return errCacheOpen
+	}
+	ctx, done := s.startRPC(ctx, RPCMemcacheDelete, key.Kind())
+	err := s.mc().Delete(ctx, key.Encode())
+	done(err)
+	s.breaker.done(err)
+	return err
+}
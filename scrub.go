@@ -0,0 +1,119 @@
+package gaestore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sync"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// Scrubber rewrites an entity before it leaves the datastore in an
+// export, to remove personal data. e is a pointer to the registered type
+// of the kind, or a map[string]interface{} of the properties of a kind
+// that isn't registered.
+type Scrubber func(ctx context.Context, key *datastore.Key, e interface{}) error
+
+var scrubbers = struct {
+	sync.RWMutex
+	kinds map[string][]Scrubber
+}{kinds: make(map[string][]Scrubber)}
+
+// RegisterScrubber adds fn to the scrubbers run on exported entities of
+// kind, in order of registration. It is meant to be called from init.
+//
+// Fields of registered types can also be scrubbed by tagging them, without
+// a Scrubber: `pii:"redact"` or `pii:"sensitive"` exports the field's zero
+// value and `pii:"hash"` exports a string field, or each string of a
+// slice, as its hex encoded SHA-256, keyed by the exporter's HashKey when
+// set. Fields with any pii tag are also redacted in logs and audit
+// snapshots; see Redact.
+func RegisterScrubber(kind string, fn Scrubber) {
+	scrubbers.Lock()
+	defer scrubbers.Unlock()
+	scrubbers.kinds[kind] = append(scrubbers.kinds[kind], fn)
+}
+
+// Scrub returns a copy of e scrubbed as in an export, for code sending
+// entities elsewhere. e is a pointer to a struct or a
+// map[string]interface{} of properties, and isn't modified.
+func Scrub(ctx context.Context, key *datastore.Key, e interface{}, hashKey []byte) (interface{}, error) {
+	switch v := reflect.ValueOf(e); {
+	case v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct:
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(v.Elem())
+		e = c.Interface()
+	case v.Kind() == reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = v.MapIndex(k).Interface()
+		}
+		e = m
+	}
+	return e, scrub(ctx, key, e, hashKey)
+}
+
+// scrub applies the tags and scrubbers of kind to e
+func scrub(ctx context.Context, key *datastore.Key, e interface{}, hashKey []byte) error {
+	if m, ok := e.(propertyMap); ok {
+		e = map[string]interface{}(m)
+	} else if v := reflect.ValueOf(e); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		scrubFields(v.Elem(), hashKey)
+	}
+	scrubbers.RLock()
+	fns := scrubbers.kinds[key.Kind()]
+	scrubbers.RUnlock()
+	for _, fn := range fns {
+		if err := fn(ctx, key, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrubFields redacts and hashes the tagged fields of the struct v,
+// including those of embedded and nested structs
+func scrubFields(v reflect.Value, hashKey []byte) {
+	for _, pf := range typeInfoOf(v.Type()).pii {
+		f := v.FieldByIndex(pf.index)
+		switch pf.tag {
+		case "redact", "sensitive":
+			f.Set(reflect.Zero(f.Type()))
+		case "hash":
+			hashField(f, hashKey)
+		}
+	}
+}
+
+func hashField(f reflect.Value, hashKey []byte) {
+	switch {
+	case f.Kind() == reflect.String:
+		if f.Len() > 0 {
+			f.SetString(hashString(f.String(), hashKey))
+		}
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		// Hash into a new slice, which may be shared with a copied entity
+		s := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+		reflect.Copy(s, f)
+		for i := 0; i < s.Len(); i++ {
+			hashField(s.Index(i), hashKey)
+		}
+		f.Set(s)
+	default:
+		// Only strings can hold a hash
+		f.Set(reflect.Zero(f.Type()))
+	}
+}
+
+func hashString(s string, key []byte) string {
+	if key == nil {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
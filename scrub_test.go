@@ -0,0 +1,79 @@
+package gaestore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type customer struct {
+	Name    string
+	Email   string   `pii:"hash"`
+	Phones  []string `pii:"hash"`
+	Address string   `pii:"redact"`
+	Age     int      `pii:"redact"`
+	Notes   string
+}
+
+func init() {
+	RegisterScrubber("customer", func(ctx context.Context, key *datastore.Key, e interface{}) error {
+		switch e := e.(type) {
+		case *customer:
+			e.Notes = ""
+		case map[string]interface{}:
+			delete(e, "Notes")
+		}
+		return nil
+	})
+}
+
+func TestScrub(t *testing.T) {
+	ctx := keyContext(t)
+	key := datastore.NewKey(ctx, "customer", "1", 0, nil)
+	c := &customer{Name: "John", Email: "john@example.com", Phones: []string{"555"}, Address: "1 Main St", Age: 40, Notes: "VIP"}
+	if err := scrub(ctx, key, c, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := &customer{
+		Name:   "John",
+		Email:  "855f96e983f1f8e8be944692b6f719fd54329826cb62e98015efee8e2e071dd4",
+		Phones: []string{hashString("555", nil)},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("Expected [%+v] but got [%+v]", want, c)
+	}
+
+	c = &customer{Email: "john@example.com"}
+	if err := scrub(ctx, key, c, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if c.Email == want.Email {
+		t.Fatal("Expected a keyed hash")
+	}
+
+	m := propertyMap{"Name": "John", "Notes": "VIP"}
+	if err := scrub(ctx, key, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["Notes"]; ok || m["Name"] != "John" {
+		t.Fatalf("Expected the scrubber to remove Notes but got [%v]", m)
+	}
+}
+
+func TestScrubCopy(t *testing.T) {
+	ctx := keyContext(t)
+	key := datastore.NewKey(ctx, "customer", "1", 0, nil)
+	c := &customer{Email: "john@example.com", Phones: []string{"555"}}
+	got, err := Scrub(ctx, key, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Email != "john@example.com" || c.Phones[0] != "555" {
+		t.Fatalf("Expected the entity to be unchanged but got [%+v]", c)
+	}
+	if got.(*customer).Phones[0] != hashString("555", nil) {
+		t.Fatalf("Expected a scrubbed copy but got [%+v]", got)
+	}
+}
@@ -0,0 +1,140 @@
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/search"
+)
+
+// Searchable is implemented by entities kept in sync with a Search API
+// index. Documents are written after each Put of the entity and removed
+// after its Delete, with the entity's encoded key as their ID.
+type Searchable interface {
+	// SearchIndex names the index of the entity's documents. It must not
+	// depend on the entity's fields, as deletes may be made without them.
+	SearchIndex() string
+
+	// SearchDocument returns the entity's document, a struct pointer or
+	// search.FieldLoadSaver
+	SearchDocument() (interface{}, error)
+}
+
+// SearchIndexer writes documents to full text search indexes, by default
+// the App Engine Search API
+type SearchIndexer interface {
+	Put(ctx context.Context, index, id string, doc interface{}) error
+	Delete(ctx context.Context, index, id string) error
+}
+
+// WithSearchIndexer sets where the documents of Searchable entities are
+// indexed
+func WithSearchIndexer(ix SearchIndexer) Option {
+	return func(c *Client) {
+		c.searchIndexer = ix
+	}
+}
+
+// WithAsyncSearch indexes Searchable entities from a task instead of
+// during the write, so a slow or failing index doesn't hold up requests.
+// The task reads the entity from the App Engine datastore and indexes it
+// in the Search API, or removes its document when it's gone, so the kind
+// must be registered with Register.
+func WithAsyncSearch() Option {
+	return func(c *Client) {
+		c.asyncSearch = true
+	}
+}
+
+var searchSyncFunc = delay.Func("gaestore.SearchSync", runSearchSync)
+
+// syncSearch updates the document of a written entity. Failures are
+// logged and reported rather than returned, as the write has been made.
+func (s *Client) syncSearch(ctx context.Context, t EventType, key *datastore.Key, e Entity) {
+	se, ok := e.(Searchable)
+	if !ok {
+		return
+	}
+	var err error
+	switch {
+	case s.asyncSearch:
+		err = searchSyncFunc.Call(ctx, key.Kind(), key.Encode())
+	case t == EventDelete:
+		err = s.searcher().Delete(ctx, se.SearchIndex(), key.Encode())
+	default:
+		var doc interface{}
+		if doc, err = se.SearchDocument(); err == nil {
+			err = s.searcher().Put(ctx, se.SearchIndex(), key.Encode(), doc)
+		}
+	}
+	if err != nil {
+		s.log().Errorf(ctx, "gaestore: unable to index [%v]: %v", key, err)
+		s.reportError(ctx, "Search", key, err)
+	}
+}
+
+func (s *Client) searcher() SearchIndexer {
+	if s.searchIndexer == nil {
+		return appengineSearch{}
+	}
+	return s.searchIndexer
+}
+
+// runSearchSync indexes the current state of the entity for key
+func runSearchSync(ctx context.Context, kind, encodedKey string) error {
+	key, err := datastore.DecodeKey(encodedKey)
+	if err != nil {
+		// Retrying will never decode the key so drop the task
+		log.Errorf(ctx, "gaestore: dropping SearchSync, invalid key [%v]", err)
+		return nil
+	}
+	e, err := newEntity(kind)
+	if err != nil {
+		log.Errorf(ctx, "gaestore: dropping SearchSync for [%v]: %v", key, err)
+		return nil
+	}
+	se, ok := e.(Searchable)
+	if !ok {
+		log.Errorf(ctx, "gaestore: dropping SearchSync for [%v], [%T] is not Searchable", key, e)
+		return nil
+	}
+	return new(Client).indexStored(ctx, appengineSearch{}, key, se)
+}
+
+// indexStored indexes the entity for key as stored, loading it into se,
+// or removes its document when it doesn't exist
+func (s *Client) indexStored(ctx context.Context, ix SearchIndexer, key *datastore.Key, se Searchable) error {
+	err := s.datastoreGet(ctx, key, se)
+	if err == datastore.ErrNoSuchEntity {
+		return ix.Delete(ctx, se.SearchIndex(), key.Encode())
+	}
+	if err != nil && !IsFieldMismatch(err) {
+		return err
+	}
+	doc, err := se.SearchDocument()
+	if err != nil {
+		return err
+	}
+	return ix.Put(ctx, se.SearchIndex(), key.Encode(), doc)
+}
+
+type appengineSearch struct{}
+
+func (appengineSearch) Put(ctx context.Context, index, id string, doc interface{}) error {
+	x, err := search.Open(index)
+	if err != nil {
+		return err
+	}
+	_, err = x.Put(ctx, id, doc)
+	return err
+}
+
+func (appengineSearch) Delete(ctx context.Context, index, id string) error {
+	x, err := search.Open(index)
+	if err != nil {
+		return err
+	}
+	return x.Delete(ctx, id)
+}
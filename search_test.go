@@ -0,0 +1,106 @@
+package gaestore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type article struct {
+	ID    string `datastore:"-"`
+	Title string
+}
+
+func (a *article) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "article", a.ID, 0, nil)
+}
+
+func (a *article) SearchIndex() string {
+	return "articles"
+}
+
+func (a *article) SearchDocument() (interface{}, error) {
+	return &struct{ Title string }{a.Title}, nil
+}
+
+// mapIndexer holds documents by index and ID
+type mapIndexer map[string]interface{}
+
+func (m mapIndexer) Put(ctx context.Context, index, id string, doc interface{}) error {
+	m[index+"/"+id] = doc
+	return nil
+}
+
+func (m mapIndexer) Delete(ctx context.Context, index, id string) error {
+	delete(m, index+"/"+id)
+	return nil
+}
+
+func TestSearchIndexing(t *testing.T) {
+	ctx := keyContext(t)
+	ix := mapIndexer{}
+	s := NewStore(WithBackend(mapBackend{}), WithSearchIndexer(ix), WithLogger(nopLogger{}))
+
+	a := &article{ID: "1", Title: "Hello"}
+	key, err := s.Put(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, ok := ix["articles/"+key.Encode()].(*struct{ Title string })
+	if !ok || doc.Title != "Hello" {
+		t.Fatalf("Expected the article to be indexed but got [%v]", ix)
+	}
+
+	if err := s.Delete(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if len(ix) != 0 {
+		t.Fatalf("Expected the document to be removed but got [%v]", ix)
+	}
+
+	// Other entities aren't indexed
+	if _, err := s.Put(ctx, &object{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ix) != 0 {
+		t.Fatalf("Expected no documents but got [%v]", ix)
+	}
+}
+
+type digest struct {
+	ID   string `datastore:"-"`
+	Body string `datastore:",noindex" gaestore:"compress"`
+	Tags JSONProperty[[]string]
+}
+
+func (d *digest) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "digest", d.ID, 0, nil)
+}
+
+func (d *digest) SearchIndex() string {
+	return "digests"
+}
+
+func (d *digest) SearchDocument() (interface{}, error) {
+	return &struct{ Body, Tags string }{d.Body, strings.Join(d.Tags.Value, " ")}, nil
+}
+
+func TestSearchSyncDecodes(t *testing.T) {
+	ctx := keyContext(t)
+	ix := mapIndexer{}
+	s := NewStore(WithBackend(mapBackend{}), WithLogger(nopLogger{}))
+
+	key, err := s.Put(ctx, &digest{ID: "1", Body: "hello", Tags: JSONProperty[[]string]{Value: []string{"a", "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.indexStored(ctx, ix, key, &digest{}); err != nil {
+		t.Fatal(err)
+	}
+	doc, ok := ix["digests/"+key.Encode()].(*struct{ Body, Tags string })
+	if !ok || doc.Body != "hello" || doc.Tags != "a b" {
+		t.Fatalf("Expected the stored digest to be indexed decoded but got [%+v]", ix)
+	}
+}
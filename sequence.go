@@ -0,0 +1,95 @@
+package gaestore
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// SequenceKind is the kind the next free ID of each Sequence is stored
+// under
+const SequenceKind = "GaestoreSequence"
+
+// Sequence hands out increasing IDs, starting from 1, for invoice numbers
+// and other identifiers that must be ordered and dense. IDs are allocated
+// in blocks by a transaction, then handed out from memory, so with a block
+// larger than 1 IDs are unique but only increasing within an instance, and
+// the rest of a block is skipped when the instance stops. A Sequence is
+// meant to be kept for the life of the instance.
+type Sequence struct {
+	name  string
+	block int64
+	s     *Client
+
+	mu        sync.Mutex
+	next, end int64
+}
+
+type sequenceEntity struct {
+	Next int64 `datastore:",noindex"`
+}
+
+// NewSequence returns the sequence called name, allocating block IDs at a
+// time, or one at a time for a strictly increasing sequence
+func (s *Client) NewSequence(name string, block int64) *Sequence {
+	if block <= 0 {
+		block = 1
+	}
+	return &Sequence{name: name, block: block, s: s}
+}
+
+// Next returns the next ID. Called in a transaction, a new block is
+// reserved as part of it, so the transaction must be cross group, and the
+// rest of the block is only handed out once it commits, as the block is
+// reserved again when it fails.
+func (q *Sequence) Next(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.next == q.end {
+		start, err := q.allocate(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if transactional(ctx) {
+			afterCommit(ctx, func(context.Context) {
+				q.mu.Lock()
+				defer q.mu.Unlock()
+				if q.next == q.end {
+					q.next, q.end = start+1, start+q.block
+				}
+			})
+			return start, nil
+		}
+		q.next, q.end = start, start+q.block
+	}
+	id := q.next
+	q.next++
+	return id, nil
+}
+
+// allocate reserves the next block of IDs, in the transaction ctx is in if
+// any, and returns the first
+func (q *Sequence) allocate(ctx context.Context) (int64, error) {
+	s := q.s
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	key := datastore.NewKey(ctx, SequenceKind, q.name, 0, nil)
+	var start int64
+	err = s.transact(ctx, func(tc context.Context) error {
+		e := sequenceEntity{Next: 1}
+		if err := s.datastoreGet(tc, key, &e); err != nil && !IsNotFound(err) {
+			return err
+		}
+		start = e.Next
+		e.Next += q.block
+		_, err := s.datastorePut(tc, key, &e)
+		return err
+	})
+	return start, err
+}
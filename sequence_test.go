@@ -0,0 +1,73 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+)
+
+func TestSequence(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	a := gaestoretest.NewStore(t, gaestore.WithBackend(b)).NewSequence("invoices", 3)
+	other := gaestoretest.NewStore(t, gaestore.WithBackend(b)).NewSequence("invoices", 3)
+
+	var ids []int64
+	for _, q := range []*gaestore.Sequence{a, a, other, a, a} {
+		id, err := q.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// The other instance allocates the second block and a the third
+	want := []int64{1, 2, 4, 3, 7}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("Expected [%v] but got [%v]", want, ids)
+		}
+	}
+}
+
+func TestSequenceInTransaction(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	q := s.NewSequence("invoices", 3)
+
+	// A block reserved by a transaction that fails is reserved again
+	errAbort := errors.New("abort")
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if _, err := q.Next(tc); err != nil {
+			return err
+		}
+		return errAbort
+	}, nil)
+	if err != errAbort {
+		t.Fatalf("Expected [%v] but got [%v]", errAbort, err)
+	}
+	var ids []int64
+	err = s.RunInTransaction(ctx, func(tc context.Context) error {
+		id, err := q.Next(tc)
+		ids = append(ids, id)
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		id, err := q.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	want := []int64{1, 2, 3}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("Expected [%v] but got [%v]", want, ids)
+		}
+	}
+}
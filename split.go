@@ -0,0 +1,197 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// ChunkKind is the kind the chunks of SplitBytes fields are stored under
+const ChunkKind = "GaestoreChunk"
+
+// maxChunkSize is the most data stored in one chunk, leaving room for the
+// key and property overhead within the datastore's 1MB entity limit
+const maxChunkSize = 1000 << 10
+
+// SplitBytes is a field holding a payload too large for an entity, which
+// is split into chunks stored as children of the entity, with only the
+// number of chunks saved in the entity itself. Chunks are written in a
+// transaction with the entity, and read back into Data whenever the
+// entity is read from the datastore. Cached entities hold the assembled
+// Data; entities too large for the cache are left uncached rather than
+// failing with ErrOversizedCacheItem.
+//
+//	type Report struct {
+//		ID  string `datastore:"-"`
+//		PDF gaestore.SplitBytes
+//	}
+//
+// As a transaction is limited to 10MB, payloads must stay below about 9MB;
+// larger ones belong in a BlobRef.
+type SplitBytes struct {
+	Data   []byte `datastore:"-"`
+	Chunks int    `datastore:",noindex"`
+}
+
+// chunk is one part of a SplitBytes payload
+type chunk struct {
+	Data []byte `datastore:",noindex"`
+}
+
+// splitField is a top level SplitBytes field
+type splitField struct {
+	index    int
+	property string
+}
+
+var splitBytesType = reflect.TypeOf(SplitBytes{})
+
+// splitFieldOf returns the split field of f, the field at index i, when
+// it's a SplitBytes
+func splitFieldOf(f reflect.StructField, i int) (splitField, bool) {
+	if f.Type != splitBytesType {
+		return splitField{}, false
+	}
+	name := strings.Split(f.Tag.Get("datastore"), ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return splitField{index: i, property: name}, true
+}
+
+// hasSplitFields reports whether the type of e has SplitBytes fields
+func hasSplitFields(e interface{}) bool {
+	info := structInfo(e)
+	return info != nil && len(info.splitFields) > 0
+}
+
+// chunkKey returns the key of chunk i of the field property of parent
+func chunkKey(parent *datastore.Key, property string, i int) (*datastore.Key, error) {
+	return appds.NewKey(parent.AppID(), parent.Namespace(), ChunkKind, property+"."+strconv.Itoa(i), 0, parent)
+}
+
+// storedChunks returns the keys of the chunks of the stored entity for
+// key, whose type is that of e
+func (s *Client) storedChunks(ctx context.Context, key *datastore.Key, e interface{}) ([]*datastore.Key, error) {
+	if !hasSplitFields(e) || key.Incomplete() {
+		return nil, nil
+	}
+	var props datastore.PropertyList
+	switch err := s.datastoreGet(ctx, key, &props); err {
+	case nil:
+	case datastore.ErrNoSuchEntity:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	var keys []*datastore.Key
+	for _, sf := range structInfo(e).splitFields {
+		for _, p := range props {
+			n, ok := p.Value.(int64)
+			if p.Name != sf.property+".Chunks" || !ok {
+				continue
+			}
+			for i := 0; i < int(n); i++ {
+				k, err := chunkKey(key, sf.property, i)
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// putSplit writes e with the chunks of its SplitBytes fields, deleting
+// the chunks of the stored entity it no longer needs. It must be called
+// in a transaction.
+func (s *Client) putSplit(ctx context.Context, key *datastore.Key, e Entity) (*datastore.Key, error) {
+	stale, err := s.storedChunks(ctx, key, e)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(e).Elem()
+	fields := structInfo(e).splitFields
+	for _, sf := range fields {
+		sb := v.Field(sf.index).Addr().Interface().(*SplitBytes)
+		sb.Chunks = (len(sb.Data) + maxChunkSize - 1) / maxChunkSize
+	}
+	k, err := s.datastorePut(ctx, key, e)
+	if err != nil {
+		return nil, err
+	}
+	written := make(map[string]bool)
+	for _, sf := range fields {
+		sb := v.Field(sf.index).Addr().Interface().(*SplitBytes)
+		for i := 0; i < sb.Chunks; i++ {
+			end := (i + 1) * maxChunkSize
+			if end > len(sb.Data) {
+				end = len(sb.Data)
+			}
+			ck, err := chunkKey(k, sf.property, i)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := s.datastorePut(ctx, ck, &chunk{Data: sb.Data[i*maxChunkSize : end]}); err != nil {
+				return nil, err
+			}
+			written[ck.Encode()] = true
+		}
+	}
+	for _, ck := range stale {
+		if written[ck.Encode()] {
+			continue
+		}
+		if err := s.datastoreDelete(ctx, ck); err != nil && err != datastore.ErrNoSuchEntity {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// loadChunks reads the chunks of the SplitBytes fields of dst, just read
+// for key, into their Data
+func (s *Client) loadChunks(ctx context.Context, key *datastore.Key, dst interface{}) error {
+	if _, ok := dst.(datastore.PropertyLoadSaver); ok || !hasSplitFields(dst) {
+		return nil
+	}
+	v := reflect.ValueOf(dst).Elem()
+	for _, sf := range structInfo(dst).splitFields {
+		sb := v.Field(sf.index).Addr().Interface().(*SplitBytes)
+		var data bytes.Buffer
+		for i := 0; i < sb.Chunks; i++ {
+			ck, err := chunkKey(key, sf.property, i)
+			if err != nil {
+				return err
+			}
+			var c chunk
+			if err := s.datastoreGet(ctx, ck, &c); err != nil {
+				return err
+			}
+			data.Write(c.Data)
+		}
+		sb.Data = nil
+		if sb.Chunks > 0 {
+			sb.Data = data.Bytes()
+		}
+	}
+	return nil
+}
+
+// deleteChunks deletes the chunks of a deleted entity. Failures are
+// logged and reported rather than returned, as the entity has been
+// deleted.
+func (s *Client) deleteChunks(ctx context.Context, key *datastore.Key, keys []*datastore.Key) {
+	for _, ck := range keys {
+		if err := s.datastoreDelete(ctx, ck); err != nil && err != datastore.ErrNoSuchEntity {
+			s.log().Errorf(ctx, "gaestore: unable to delete chunk [%v] of [%v]: %v", ck, key, err)
+			s.reportError(ctx, "DeleteChunk", key, err)
+		}
+	}
+}
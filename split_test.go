@@ -0,0 +1,103 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type report struct {
+	ID  string `datastore:"-"`
+	PDF SplitBytes
+}
+
+func (r *report) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "report", r.ID, 0, nil)
+}
+
+// chunkCount returns the number of chunks stored in backend
+func chunkCount(backend mapBackend) int {
+	n := 0
+	for k := range backend {
+		if key, _ := datastore.DecodeKey(k); key.Kind() == ChunkKind {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSplitBytes(t *testing.T) {
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	cacher := mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	data := bytes.Repeat([]byte("0123456789"), maxChunkSize/4)
+	r := &report{ID: "1", PDF: SplitBytes{Data: data}}
+	key, err := s.Put(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PDF.Chunks != 3 || chunkCount(backend) != 3 {
+		t.Fatalf("Expected [3] chunks but got [%d] with [%d] stored", r.PDF.Chunks, chunkCount(backend))
+	}
+	for _, p := range backend[key.Encode()] {
+		if b, ok := p.Value.([]byte); ok && len(b) > 0 {
+			t.Fatalf("Expected the data not to be stored in the entity")
+		}
+	}
+
+	got := &report{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.PDF.Data, data) {
+		t.Fatalf("Expected [%d] bytes but got [%d]", len(data), len(got.PDF.Data))
+	}
+
+	// Shrinking the data removes the chunks no longer needed
+	if _, err := s.Put(ctx, &report{ID: "1", PDF: SplitBytes{Data: []byte("small")}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := chunkCount(backend); n != 1 {
+		t.Fatalf("Expected [1] chunk but got [%d]", n)
+	}
+
+	// Small payloads are cached assembled
+	for k := range backend {
+		if key, _ := datastore.DecodeKey(k); key.Kind() == ChunkKind {
+			delete(backend, k)
+		}
+	}
+	got = &report{ID: "1"}
+	if err := s.Get(ctx, got); err != nil || string(got.PDF.Data) != "small" {
+		t.Fatalf("Expected [small] from the cache but got [%s] %v", got.PDF.Data, err)
+	}
+	if err := s.Delete(ctx, &report{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend) != 0 {
+		t.Fatalf("Expected the entity and its chunks to be deleted but got [%d] entities", len(backend))
+	}
+}
+
+func TestPurgeSplitBytes(t *testing.T) {
+	Register("report", &report{})
+	ctx := keyContext(t)
+	backend := mapBackend{}
+	s := NewStore(WithBackend(backend), WithLogger(nopLogger{}))
+
+	data := bytes.Repeat([]byte("0123456789"), maxChunkSize/4)
+	key, err := s.Put(ctx, &report{ID: "1", PDF: SplitBytes{Data: data}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.purge(ctx, []*datastore.Key{key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend) != 0 {
+		t.Fatalf("Expected the chunks to be purged but got [%d] stored", chunkCount(backend))
+	}
+}
@@ -0,0 +1,123 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// KindUsage is the size of a kind, read from the statistics the datastore
+// computes about once a day. Sizes are in bytes.
+type KindUsage struct {
+	Kind                string    `datastore:"kind_name"`
+	Count               int64     `datastore:"count"`
+	Bytes               int64     `datastore:"bytes"`
+	EntityBytes         int64     `datastore:"entity_bytes"`
+	BuiltinIndexBytes   int64     `datastore:"builtin_index_bytes"`
+	BuiltinIndexCount   int64     `datastore:"builtin_index_count"`
+	CompositeIndexBytes int64     `datastore:"composite_index_bytes"`
+	CompositeIndexCount int64     `datastore:"composite_index_count"`
+	Timestamp           time.Time `datastore:"timestamp"`
+}
+
+// IndexBytes returns the size of the kind's built-in and composite indexes
+func (s KindUsage) IndexBytes() int64 {
+	return s.BuiltinIndexBytes + s.CompositeIndexBytes
+}
+
+// TotalUsage is the size of every kind together
+type TotalUsage struct {
+	Count               int64     `datastore:"count"`
+	Bytes               int64     `datastore:"bytes"`
+	EntityBytes         int64     `datastore:"entity_bytes"`
+	BuiltinIndexBytes   int64     `datastore:"builtin_index_bytes"`
+	BuiltinIndexCount   int64     `datastore:"builtin_index_count"`
+	CompositeIndexBytes int64     `datastore:"composite_index_bytes"`
+	CompositeIndexCount int64     `datastore:"composite_index_count"`
+	Timestamp           time.Time `datastore:"timestamp"`
+}
+
+// DatastoreKindStat returns the statistics the datastore keeps of kind in
+// the store's namespace. It fails with ErrNoSuchEntity when they haven't
+// been computed yet, as for a new kind.
+func (s *Client) DatastoreKindStat(ctx context.Context, kind string) (*KindUsage, error) {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := datastore.NewQuery(statKind(ctx, "Kind")).Filter("kind_name =", kind).Limit(1)
+	stat := &KindUsage{}
+	ok, err := nextStat(s.ds().RunQuery(ctx, q), stat)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoSuchEntity
+	}
+	return stat, nil
+}
+
+// DatastoreKindStats returns the statistics the datastore keeps of every
+// kind in the store's namespace, largest first. They are unrelated to the
+// call counts kept by the KindStats metrics.
+func (s *Client) DatastoreKindStats(ctx context.Context) ([]KindUsage, error) {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	it := s.ds().RunQuery(ctx, datastore.NewQuery(statKind(ctx, "Kind")).Order("-bytes"))
+	var stats []KindUsage
+	for {
+		var stat KindUsage
+		ok, err := nextStat(it, &stat)
+		if !ok || err != nil {
+			return stats, err
+		}
+		stats = append(stats, stat)
+	}
+}
+
+// DatastoreTotalStat returns the statistics the datastore keeps of the
+// store's namespace. It fails with ErrNoSuchEntity when they haven't been
+// computed yet.
+func (s *Client) DatastoreTotalStat(ctx context.Context) (*TotalUsage, error) {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stat := &TotalUsage{}
+	q := datastore.NewQuery(statKind(ctx, "Total")).Limit(1)
+	ok, err := nextStat(s.ds().RunQuery(ctx, q), stat)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoSuchEntity
+	}
+	return stat, nil
+}
+
+// statKind returns the name of the statistics kind for the namespace of
+// ctx, which are prefixed by Ns outside the default namespace
+func statKind(ctx context.Context, name string) string {
+	if appds.Namespace(ctx) != "" {
+		return "__Stat_Ns_" + name + "__"
+	}
+	return "__Stat_" + name + "__"
+}
+
+// nextStat loads the next result of it into dst, reporting false when
+// there are no more. Properties added to the statistics since the types
+// were defined are ignored.
+func nextStat(it Iterator, dst interface{}) (bool, error) {
+	_, err := it.Next(dst)
+	switch {
+	case err == datastore.Done:
+		return false, nil
+	case err != nil && !IsFieldMismatch(err):
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package gaestore_test
+
+import (
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestDatastoreKindStat(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	b := gaestoretest.NewBackend()
+	s := gaestoretest.NewStore(t, gaestore.WithBackend(b))
+	if _, err := s.DatastoreKindStat(ctx, "exported"); err != gaestore.ErrNoSuchEntity {
+		t.Fatalf("Expected [%v] but got [%v]", gaestore.ErrNoSuchEntity, err)
+	}
+
+	for _, stat := range []gaestore.KindUsage{
+		{Kind: "exported", Count: 10, Bytes: 300, BuiltinIndexBytes: 100, CompositeIndexBytes: 50},
+		{Kind: "renamed", Count: 5, Bytes: 900},
+	} {
+		stat := stat
+		if _, err := b.Put(ctx, datastore.NewKey(ctx, "__Stat_Kind__", stat.Kind, 0, nil), &stat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stat, err := s.DatastoreKindStat(ctx, "exported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Count != 10 || stat.IndexBytes() != 150 {
+		t.Fatalf("Expected 10 entities with 150 index bytes but got [%+v]", stat)
+	}
+	stats, err := s.DatastoreKindStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Kind != "renamed" {
+		t.Fatalf("Expected the largest kind first but got [%+v]", stats)
+	}
+
+	// Namespaces have their own statistics kinds
+	nctx, err := appengine.Namespace(ctx, "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := &gaestore.TotalUsage{Count: 7}
+	if _, err := b.Put(nctx, datastore.NewKey(nctx, "__Stat_Ns_Total__", "total_entity_usage", 0, nil), total); err != nil {
+		t.Fatal(err)
+	}
+	s = gaestoretest.NewStore(t, gaestore.WithBackend(b), gaestore.WithNamespace("tenant"))
+	got, err := s.DatastoreTotalStat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 7 {
+		t.Fatalf("Expected [7] but got [%d]", got.Count)
+	}
+}
@@ -1,11 +1,15 @@
 package gaestore
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"reflect"
+	"sync"
+	"time"
 
-	"golang.org/x/net/context"
+	"github.com/floresj/gaestore/internal/appds"
 	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
 	"google.golang.org/appengine/memcache"
 )
 
@@ -27,56 +31,193 @@ type AfterGetter interface {
 	AfterGet(ctx context.Context, key *datastore.Key) error
 }
 
-type store struct {
-	useCache bool
+// Store is the set of operations provided by a Client. Accept a Store
+// rather than a *Client to allow the client to be wrapped or replaced in
+// tests.
+type Store interface {
+	Put(ctx context.Context, e Entity, opts ...CallOption) (*datastore.Key, error)
+	PutMulti(ctx context.Context, entities []Entity, opts ...CallOption) ([]*datastore.Key, error)
+	Get(ctx context.Context, e Entity, opts ...CallOption) error
+	Reload(ctx context.Context, e Entity, opts ...CallOption) error
+	GetByStringID(ctx context.Context, kind, id string, dst Entity, opts ...CallOption) error
+	GetByIntID(ctx context.Context, kind string, id int64, dst Entity, opts ...CallOption) error
+	Query(ctx context.Context, q *datastore.Query, entities interface{}, opts ...CallOption) (datastore.Cursor, error)
+	Delete(ctx context.Context, e Entity, opts ...CallOption) error
+	Touch(ctx context.Context, e Entity, opts ...CallOption) error
+	Increment(ctx context.Context, e Entity, field string, delta int64, opts ...CallOption) (int64, error)
+	PutIf(ctx context.Context, e Entity, field string, expected interface{}, opts ...CallOption) (*datastore.Key, error)
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error
+	HealthCheck(ctx context.Context) HealthStatus
 }
 
-func (s *store) Put(ctx context.Context, e Entity) (*datastore.Key, error) {
-	return put(ctx, e, s.useCache)
+var _ Store = (*Client)(nil)
+
+// Client stores entities in the datastore, optionally caching them in
+// memcache. It is safe for concurrent use once configured.
+type Client struct {
+	config
+
+	mu          sync.RWMutex
+	subscribers map[string][]func(Event)
+	mutations   []Mutation
+}
+
+// config holds the settings of a Client, which are copied to the stores
+// derived from it
+type config struct {
+	useCache   bool
+	readOnly   bool
+	dryRun     bool
+	allowPurge bool
+	cacheTTL   time.Duration
+	codec      memcache.Codec
+	namespace  string
+	middleware []Middleware
+	onError    ErrorHandler
+	logger     Logger
+	metrics    Metrics
+	tracer     Tracer
+
+	slowThreshold time.Duration
+	debug         bool
+	retryPolicy   RetryPolicy
+	breaker       *breaker
+
+	datastoreTimeout time.Duration
+	memcacheTimeout  time.Duration
+
+	auditUser  func(ctx context.Context) string
+	authorizer Authorizer
+	ownerUser  func(ctx context.Context) string
+	clock      Clock
+
+	searchIndexer SearchIndexer
+	asyncSearch   bool
+	tokenIndex    bool
+	changeFeed    ChangeFeed
+	changeLog     *Sequence
+	webhooks      []Webhook
+	blobBucket    BlobBucket
+	blobURLTTL    time.Duration
+
+	afterPutFuncs    map[string][]*delay.Function
+	afterDeleteFuncs map[string][]*delay.Function
+
+	concurrentCacheSet bool
+	waitCacheSet       bool
+	batchSize          int
+	maxResults         int
+	cacheChecksums     bool
+
+	backend Backend
+	cacher  Cacher
 }
 
-func (s *store) Get(ctx context.Context, e Entity) error {
-	return get(ctx, e, s.useCache)
+// defaultStore backs the package level functions
+var defaultStore = NewStoreWithCache()
+
+func (s *Client) Put(ctx context.Context, e Entity, opts ...CallOption) (*datastore.Key, error) {
+	op := &Operation{Name: OpPut, Entity: e, opts: newCallOptions(opts)}
+	err := s.run(ctx, op)
+	return op.Key, err
 }
 
-func (s *store) Query(ctx context.Context, q *datastore.Query, entities interface{}) (datastore.Cursor, error) {
-	return query(ctx, q, s.useCache, entities)
+func (s *Client) Get(ctx context.Context, e Entity, opts ...CallOption) error {
+	return s.run(ctx, &Operation{Name: OpGet, Entity: e, opts: newCallOptions(opts)})
 }
 
-func (s *store) Delete(ctx context.Context, e Entity) error {
-	return delete(ctx, e)
+func (s *Client) Query(ctx context.Context, q *datastore.Query, entities interface{}, opts ...CallOption) (datastore.Cursor, error) {
+	op := &Operation{Name: OpQuery, Query: q, Dst: entities, opts: newCallOptions(opts)}
+	err := s.run(ctx, op)
+	return op.Cursor, err
 }
 
-func NewStore() *store {
-	return &store{
-		useCache: false,
+// Reload reads e from the datastore even when it's cached, and replaces
+// the cached copy. Like Get, fields not stored in the datastore are left
+// unchanged rather than zeroed.
+func (s *Client) Reload(ctx context.Context, e Entity, opts ...CallOption) error {
+	return s.run(ctx, &Operation{Name: OpReload, Entity: e, opts: newCallOptions(opts)})
+}
+
+// GetByStringID loads the entity of kind with the string id into dst,
+// without needing dst to be populated for its Key method
+func (s *Client) GetByStringID(ctx context.Context, kind, id string, dst Entity, opts ...CallOption) error {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
 	}
+	return s.getKey(ctx, datastore.NewKey(ctx, kind, id, 0, nil), dst, opts)
 }
 
-func NewStoreWithCache() *store {
-	return &store{
-		useCache: true,
+// GetByIntID loads the entity of kind with the integer id into dst,
+// without needing dst to be populated for its Key method
+func (s *Client) GetByIntID(ctx context.Context, kind string, id int64, dst Entity, opts ...CallOption) error {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
 	}
+	return s.getKey(ctx, datastore.NewKey(ctx, kind, "", id, nil), dst, opts)
+}
+
+func (s *Client) getKey(ctx context.Context, key *datastore.Key, dst Entity, opts []CallOption) error {
+	return s.run(ctx, &Operation{Name: OpGet, Entity: dst, Key: key, opts: newCallOptions(opts)})
+}
+
+func (s *Client) Delete(ctx context.Context, e Entity, opts ...CallOption) error {
+	return s.run(ctx, &Operation{Name: OpDelete, Entity: e, opts: newCallOptions(opts)})
+}
+
+// NewStore returns a Client configured by opts. Without options entities
+// are read from and written to the datastore only.
+func NewStore(opts ...Option) *Client {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewStoreWithCache returns a Client that caches entities in memcache.
+//
+// Deprecated: Use NewStore(WithCache(0)).
+func NewStoreWithCache() *Client {
+	return NewStore(WithCache(0))
+}
+
+func Put(ctx context.Context, e Entity, opts ...CallOption) (*datastore.Key, error) {
+	return defaultStore.Put(ctx, e, opts...)
+}
+
+func Get(ctx context.Context, e Entity, opts ...CallOption) error {
+	return defaultStore.Get(ctx, e, opts...)
 }
 
-func Put(ctx context.Context, e Entity) (*datastore.Key, error) {
-	return put(ctx, e, true)
+func Query(ctx context.Context, q *datastore.Query, entities interface{}, opts ...CallOption) (datastore.Cursor, error) {
+	return defaultStore.Query(ctx, q, entities, opts...)
 }
 
-func Get(ctx context.Context, e Entity) error {
-	return get(ctx, e, true)
+func PutMulti(ctx context.Context, entities []Entity, opts ...CallOption) ([]*datastore.Key, error) {
+	return defaultStore.PutMulti(ctx, entities, opts...)
 }
 
-func Query(ctx context.Context, q *datastore.Query, entities interface{}) (datastore.Cursor, error) {
-	return query(ctx, q, true, entities)
+func Reload(ctx context.Context, e Entity, opts ...CallOption) error {
+	return defaultStore.Reload(ctx, e, opts...)
+}
+
+func GetByStringID(ctx context.Context, kind, id string, dst Entity, opts ...CallOption) error {
+	return defaultStore.GetByStringID(ctx, kind, id, dst, opts...)
+}
+
+func GetByIntID(ctx context.Context, kind string, id int64, dst Entity, opts ...CallOption) error {
+	return defaultStore.GetByIntID(ctx, kind, id, dst, opts...)
 }
 
 func Exists(ctx context.Context, e Entity) (bool, error) {
-	err := get(ctx, e, false)
-	switch err {
-	case nil:
+	err := defaultStore.get(ctx, e, false, callOptions{})
+	switch {
+	case err == nil:
 		return true, nil
-	case datastore.ErrNoSuchEntity:
+	case IsNotFound(err):
 		return false, nil
 	default:
 		return false, err
@@ -84,85 +225,164 @@ func Exists(ctx context.Context, e Entity) (bool, error) {
 }
 
 func PutCache(ctx context.Context, e Entity) error {
-	k := e.Key(ctx)
-	item := &memcache.Item{
-		Key:    k.Encode(),
-		Object: e,
-	}
-	return memcache.JSON.Set(ctx, item)
+	return defaultStore.cacheSet(ctx, e.Key(ctx), e)
 }
 
 func GetCache(ctx context.Context, e Entity) (*memcache.Item, error) {
-	key := e.Key(ctx)
-	return getCache(ctx, key.Encode(), e)
+	item, err := defaultStore.cacheGet(ctx, e.Key(ctx), e)
+	if err == errTombstone {
+		return nil, memcache.ErrCacheMiss
+	}
+	return item, err
 }
 
-func getCache(ctx context.Context, cacheKey string, dst Entity) (*memcache.Item, error) {
-	return memcache.JSON.Get(ctx, cacheKey, dst)
+func DeleteCache(ctx context.Context, e Entity) error {
+	return defaultStore.cacheDelete(ctx, e.Key(ctx))
 }
 
-func DeleteCache(ctx context.Context, e Entity) error {
-	key := e.Key(ctx)
-	return memcache.Delete(ctx, key.Encode())
+func RunInTransaction(ctx context.Context, fn func(ctx context.Context) error, opts *datastore.TransactionOptions) error {
+	return defaultStore.RunInTransaction(ctx, fn, opts)
+}
+
+func Delete(ctx context.Context, e Entity, opts ...CallOption) error {
+	return defaultStore.Delete(ctx, e, opts...)
+}
+
+func Touch(ctx context.Context, e Entity, opts ...CallOption) error {
+	return defaultStore.Touch(ctx, e, opts...)
 }
 
-func Delete(ctx context.Context, e Entity) error {
-	return delete(ctx, e)
+func Increment(ctx context.Context, e Entity, field string, delta int64, opts ...CallOption) (int64, error) {
+	return defaultStore.Increment(ctx, e, field, delta, opts...)
 }
 
-func beforePut(ctx context.Context, e Entity) error {
+func FlushIncrement(ctx context.Context, e Entity, field string) error {
+	return defaultStore.FlushIncrement(ctx, e, field)
+}
+
+func beforePut(ctx context.Context, e Entity) (err error) {
 	if putter, ok := e.(BeforePutter); ok {
+		defer recoverHook(ctx, "BeforePut", nil, e, &err)
 		return putter.BeforePut(ctx)
 	}
 	return nil
 }
 
-func afterGet(ctx context.Context, key *datastore.Key, e Entity) error {
+func afterGet(ctx context.Context, key *datastore.Key, e Entity) (err error) {
 	if getter, ok := e.(AfterGetter); ok {
+		defer recoverHook(ctx, "AfterGet", key, e, &err)
 		return getter.AfterGet(ctx, key)
 	}
 	return nil
 }
 
-func afterPut(ctx context.Context, key *datastore.Key, e Entity) error {
+func afterPut(ctx context.Context, key *datastore.Key, e Entity) (err error) {
 	if putter, ok := e.(AfterPutter); ok {
+		defer recoverHook(ctx, "AfterPut", key, e, &err)
 		return putter.AfterPut(ctx, key)
 	}
 	return nil
 }
 
-func put(ctx context.Context, e Entity, cache bool) (*datastore.Key, error) {
-	if err := beforePut(ctx, e); err != nil {
-		return nil, err
+// put writes e, whose key was key before its BeforePut hook ran
+func (s *Client) put(ctx context.Context, key *datastore.Key, e Entity, cache bool, opts callOptions) (*datastore.Key, error) {
+	if _, ok := e.(BeforePutter); ok && !opts.skipHooks {
+		if err := beforePut(ctx, e); err != nil {
+			return nil, err
+		}
+		// The hook may set the fields the key is built from
+		if key = e.Key(ctx); key == nil {
+			return nil, ErrNilKey
+		}
 	}
 
-	k, err := datastore.Put(ctx, e.Key(ctx), e)
+	if err := s.uploadBlobs(ctx, key, e); err != nil {
+		return nil, err
+	}
+	k, err := s.writeEntity(ctx, key, e)
 	if err != nil {
 		return nil, err
 	}
-	if err := afterPut(ctx, k, e); err != nil {
-		return k, err
+	var cached <-chan error
+	if cache && s.concurrentCacheSet {
+		cached = s.startCacheSet(ctx, k, e)
+	}
+	if !opts.skipHooks {
+		err = s.afterPutHooks(ctx, k, e)
 	}
-	if cache {
-		return k, PutCache(ctx, e)
+	switch {
+	case cached != nil && s.waitCacheSet:
+		if cerr := <-cached; cerr != nil && cerr != errCacheOpen && err == nil {
+			err = cerr
+		}
+	case cache && cached == nil && err == nil:
+		if err := s.cacheSet(ctx, k, e); err != nil && err != errCacheOpen {
+			return k, err
+		}
 	}
-	return k, nil
+	return k, err
 }
 
-func delete(ctx context.Context, e Entity) error {
-	key := e.Key(ctx)
-	err := datastore.Delete(ctx, key)
-	if err != nil {
+// afterPutHooks runs the hooks following a Put of e, returning the first
+// error
+func (s *Client) afterPutHooks(ctx context.Context, key *datastore.Key, e Entity) error {
+	if err := afterPut(ctx, key, e); err != nil {
 		return err
 	}
-	err = DeleteCache(ctx, e)
+	if err := enqueueAfterPut(ctx, key, e); err != nil {
+		return err
+	}
+	return enqueueHookTasks(ctx, s.afterPutFuncs, key, e)
+}
+
+// delete removes key from the datastore. The cached copy is replaced by a
+// tombstone first, or once the transaction ctx is in commits, which is
+// left to expire, so that reads made while the entity is deleted aren't
+// cached. When the tombstone can't be written the cached copy is deleted
+// afterwards instead.
+func (s *Client) delete(ctx context.Context, key *datastore.Key) error {
+	if transactional(ctx) {
+		if err := s.removeEntity(ctx, key); err != nil {
+			return err
+		}
+		if s.useCache {
+			s.evictAfterCommit(ctx, key)
+		}
+		return nil
+	}
+	tombstoned := s.useCache && s.cacheTombstone(ctx, key) == nil
+	err := s.removeEntity(ctx, key)
+	if err != nil || tombstoned {
+		return err
+	}
+	err = s.cacheDelete(ctx, key)
+	if err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+		s.log().Warningf(ctx, "gaestore: unable to delete [%v] from cache: %v", key, err)
+		s.reportError(ctx, "DeleteCache", key, err)
+	}
+	return nil
+}
+
+// Evict removes the cached copies of the entities for keys, for when they
+// were changed other than through the store
+func (s *Client) Evict(ctx context.Context, keys ...*datastore.Key) error {
+	if !s.useCache {
+		return nil
+	}
+	ctx, err := s.withNamespace(ctx)
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	for _, key := range keys {
+		err := s.cacheDelete(ctx, key)
+		if err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+			return err
+		}
 	}
 	return nil
 }
 
-func get(ctx context.Context, e Entity, useCache bool) error {
+func (s *Client) get(ctx context.Context, e Entity, useCache bool, opts callOptions) error {
 	k := e.Key(ctx)
 	//if useCache {
 	//_, err := GetCache(ctx, e)
@@ -181,81 +401,353 @@ func get(ctx context.Context, e Entity, useCache bool) error {
 	//return err
 	//}
 	//}
-	return getByKey(ctx, k, e, useCache)
+	return s.getByKey(ctx, k, e, useCache, opts)
 }
 
-func getByKey(ctx context.Context, key *datastore.Key, e Entity, useCache bool) error {
-	if useCache {
-		_, err := getCache(ctx, key.Encode(), e)
-		switch err {
-		case nil:
-			return nil
-		case memcache.ErrCacheMiss:
-			err := datastore.Get(ctx, key, e)
-			if err != nil {
-				return err
-			}
-			if err := afterGet(ctx, key, e); err != nil {
-				return err
-			}
-			err = PutCache(ctx, e)
-			if err != nil {
-				fmt.Printf("Unable to put into cache [%v]", err)
+// getByKey loads the entity for key into e, from cache when enabled, and
+// runs its AfterGet hook regardless of where it was loaded from
+func (s *Client) getByKey(ctx context.Context, key *datastore.Key, e Entity, useCache bool, opts callOptions) error {
+	if err := s.load(ctx, key, e, useCache); err != nil {
+		return err
+	}
+	if err := s.signBlobs(ctx, e); err != nil {
+		return err
+	}
+	if opts.skipHooks {
+		return nil
+	}
+	return afterGet(ctx, key, e)
+}
+
+func (s *Client) load(ctx context.Context, key *datastore.Key, e Entity, useCache bool) error {
+	// Reads in a transaction aren't isolated unless made from the datastore
+	if !useCache || transactional(ctx) {
+		recordCache(ctx, cacheBypass)
+		return s.datastoreGet(ctx, key, e)
+	}
+	decision, corrupt := s.lookupCache(ctx, key, e)
+	if decision == cacheHit {
+		return nil
+	}
+	if err := s.datastoreGet(ctx, key, e); err != nil {
+		if corrupt && IsNotFound(err) {
+			s.evictCorrupt(ctx, key)
+		}
+		return err
+	}
+	switch {
+	case corrupt:
+		// Set rather than add, to replace the undecodable value
+		s.refreshCache(ctx, key, e)
+	case decision == cacheMiss:
+		s.fillCache(ctx, key, e)
+	}
+	return nil
+}
+
+// lookupCache reads key from the cache into e, returning whether it was a
+// hit, a miss to be filled from the datastore, or bypassed because the
+// cache is failing. A value that can't be decoded is a miss, so that it's
+// replaced by the entity read from the datastore.
+func (s *Client) lookupCache(ctx context.Context, key *datastore.Key, e Entity) (decision int, corrupt bool) {
+	_, err := s.cacheGet(ctx, key, e)
+	var derr *cacheDecodeError
+	switch {
+	case err == nil:
+		decision = cacheHit
+	case err == memcache.ErrCacheMiss:
+		decision = cacheMiss
+	case err == errCacheOpen, err == errTombstone:
+		decision = cacheBypass
+	case errors.As(err, &derr):
+		decision, corrupt = cacheMiss, true
+		s.log().Warningf(ctx, "gaestore: unable to decode [%v] from cache, replacing it: %v", key, err)
+		s.reportError(ctx, "GetCache", key, err)
+	default:
+		// Treat a failing cache as a bypass and read from the datastore
+		decision = cacheBypass
+		s.log().Warningf(ctx, "gaestore: unable to get [%v] from cache: %v", key, err)
+		s.reportError(ctx, "GetCache", key, err)
+	}
+	recordCache(ctx, decision)
+	return decision, corrupt
+}
+
+// evictCorrupt deletes the cached value of an entity that no longer exists
+// and whose value couldn't be decoded
+func (s *Client) evictCorrupt(ctx context.Context, key *datastore.Key) {
+	if err := s.cacheDelete(ctx, key); err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+		s.log().Warningf(ctx, "gaestore: unable to delete [%v] from cache: %v", key, err)
+		s.reportError(ctx, "DeleteCache", key, err)
+	}
+}
+
+// reload reads key from the datastore into e, replacing or removing the
+// cached copy
+func (s *Client) reload(ctx context.Context, key *datastore.Key, e Entity, opts callOptions) error {
+	recordCache(ctx, cacheBypass)
+	if err := s.datastoreGet(ctx, key, e); err != nil {
+		if s.useCache && IsNotFound(err) {
+			if err := s.cacheDelete(ctx, key); err != nil && err != memcache.ErrCacheMiss && err != errCacheOpen {
+				s.log().Warningf(ctx, "gaestore: unable to delete [%v] from cache: %v", key, err)
+				s.reportError(ctx, "DeleteCache", key, err)
 			}
-			return nil
-		default:
-			fmt.Printf("Error getting from cache [%v]\n", err)
 		}
+		return err
+	}
+	if s.useCache {
+		s.refreshCache(ctx, key, e)
+	}
+	if opts.skipHooks {
+		return nil
+	}
+	return afterGet(ctx, key, e)
+}
+
+// refreshCache caches e after it was read from the datastore. Failures
+// are logged and reported rather than failing the read.
+func (s *Client) refreshCache(ctx context.Context, key *datastore.Key, e Entity) {
+	s.cacheDone(ctx, key, s.cacheSet(ctx, key, e))
+}
+
+// fillCache caches e after a cache miss, without replacing a value cached
+// since the miss
+func (s *Client) fillCache(ctx context.Context, key *datastore.Key, e Entity) {
+	s.cacheDone(ctx, key, s.cacheFill(ctx, key, e))
+}
+
+func (s *Client) cacheDone(ctx context.Context, key *datastore.Key, err error) {
+	if err != nil && err != errCacheOpen {
+		s.log().Warningf(ctx, "gaestore: unable to put [%v] into cache: %v", key, err)
+		s.reportError(ctx, "PutCache", key, err)
 	}
-	return datastore.Get(ctx, key, e)
 }
 
-func query(ctx context.Context, q *datastore.Query, useCache bool, entities interface{}) (c datastore.Cursor, err error) {
+func (s *Client) query(ctx context.Context, q *datastore.Query, useCache bool, entities interface{}, opts callOptions) (c datastore.Cursor, err error) {
 	var (
 		dv       reflect.Value
 		mat      multiArgType
 		elemType reflect.Type
 	)
 
-	q = q.KeysOnly()
-	t := q.Run(ctx)
+	if opts.strong {
+		if err := checkStrong(q); err != nil {
+			return c, err
+		}
+	}
 
 	dv = reflect.ValueOf(entities)
 	if dv.Kind() != reflect.Ptr || dv.IsNil() {
-		return c, fmt.Errorf("Invalid entity type for slice")
+		return c, ErrInvalidEntityType
 	}
 	dv = dv.Elem()
 	mat, elemType = checkMultiArg(dv)
 	if mat == multiArgTypeInvalid || mat == multiArgTypeInterface {
-		return c, fmt.Errorf("Invalid type")
+		return c, ErrInvalidEntityType
+	}
+	if !typeInfoOf(elemType).entity {
+		return c, ErrInvalidEntityType
+	}
+	t := s.runKeys(ctx, q)
+	defer t.close()
+	size := s.batchSize
+	if size < 1 {
+		size = 1
+	}
+	max := s.queryMax(opts)
+	if limit := appds.QueryLimit(q); limit > 0 {
+		growSlice(dv, int(limit))
+		if int(limit) <= max {
+			// The query's own limit is within the cap
+			max = 0
+		}
 	}
+	buf := queryBuffers.Get().(*queryBuffer)
+	if cap(buf.keys) < size {
+		buf.keys = make([]*datastore.Key, 0, size)
+	}
+	keys := buf.keys[:0]
+	defer buf.release()
+	// The keys of the results are kept to merge the request's writes into
+	var (
+		start      = dv.Len()
+		resultKeys []*datastore.Key
+		merge      = writeSetFromContext(ctx) != nil
+	)
+	var scratch reflect.Value
+	// flush reads the batched results and appends them to entities
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		var block reflect.Value
+		if mat == multiArgTypeStructPtr {
+			// Entities keep pointers into the block, so each batch needs
+			// its own
+			block = reflect.MakeSlice(reflect.SliceOf(elemType), len(keys), len(keys))
+		} else {
+			// Values are copied into entities, so one block is reused
+			if !scratch.IsValid() {
+				scratch = reflect.MakeSlice(reflect.SliceOf(elemType), size, size)
+			}
+			block = scratch.Slice(0, len(keys))
+			zero := reflect.Zero(elemType)
+			for i := 0; i < block.Len(); i++ {
+				block.Index(i).Set(zero)
+			}
+		}
+		dst := buf.dst[:0]
+		for i := range keys {
+			dst = append(dst, block.Index(i).Addr().Interface().(Entity))
+		}
+		buf.dst = dst
+		var errs []error
+		if len(keys) == 1 {
+			errs = []error{s.getByKey(ctx, keys[0], dst[0], useCache, opts)}
+		} else {
+			errs = s.getBatch(ctx, keys, dst, useCache, opts)
+		}
+		if err := ctx.Err(); err != nil {
+			// Stop rather than appending empty results for every remaining key
+			return err
+		}
+		for i, err := range errs {
+			if err != nil {
+				s.log().Errorf(ctx, "gaestore: unable to get [%v]: %v", keys[i], err)
+				s.reportError(ctx, OpGet, keys[i], err)
+			}
+		}
+		if mat == multiArgTypeStructPtr {
+			for i := range keys {
+				dv.Set(reflect.Append(dv, block.Index(i).Addr()))
+			}
+		} else {
+			dv.Set(reflect.AppendSlice(dv, block))
+		}
+		if merge {
+			resultKeys = append(resultKeys, keys...)
+		}
+		keys = keys[:0]
+		return nil
+	}
+	var (
+		fetched   int
+		truncated error
+	)
 	for {
-		key, err := t.Next(nil)
-		if err == datastore.Done {
+		if max > 0 && fetched == max {
+			// The cursor is taken before looking for one more result, so
+			// it resumes right after the last result returned
+			cursor, err := t.Cursor()
+			if err != nil {
+				return c, err
+			}
+			if _, err := t.Next(); err != datastore.Done {
+				c, truncated = cursor, &ErrResultTruncated{Max: max, Cursor: cursor}
+				s.log().Warningf(ctx, "gaestore: query of [%v] truncated at [%d] results", reflect.PtrTo(elemType), max)
+			}
 			break
 		}
-		if err != nil {
-			fmt.Printf("Error fetching %v\n", err)
+		key, err := t.Next()
+		if err == datastore.Done {
 			break
 		}
-		ev := reflect.New(elemType)
-		entity, ok := ev.Interface().(Entity)
-		if !ok {
-			fmt.Println("Not an Entity type")
+		if err != nil {
+			s.log().Errorf(ctx, "gaestore: unable to fetch query results: %v", err)
+			s.reportError(ctx, OpQuery, nil, err)
 			break
 		}
-		err = getByKey(ctx, key, entity, useCache)
-		if err != nil {
-			fmt.Println(err)
+		fetched++
+		if keys = append(keys, key); len(keys) == size {
+			if err := flush(); err != nil {
+				return c, err
+			}
 		}
-		if mat != multiArgTypeStructPtr {
-			ev = ev.Elem()
+	}
+	if err := flush(); err != nil {
+		return c, err
+	}
+	if merge {
+		if err := s.mergeWrites(ctx, q, dv, start, resultKeys, mat, elemType, useCache, opts); err != nil {
+			return c, err
 		}
-		dv.Set(reflect.Append(dv, ev))
+	}
+	if truncated != nil {
+		return c, truncated
 	}
 	return t.Cursor()
 }
 
+// queryMax returns the most results a query made with opts may read, or
+// 0 when they are unbounded
+func (s *Client) queryMax(opts callOptions) int {
+	max := opts.maxResults
+	if max == 0 {
+		max = s.maxResults
+	}
+	switch {
+	case max == 0:
+		return DefaultMaxResults
+	case max < 0:
+		return 0
+	}
+	return max
+}
+
+// checkStrong returns ErrInconsistentQuery unless q is strongly consistent,
+// which needs an ancestor
+func checkStrong(q *datastore.Query) error {
+	pq, err := appds.ParseQuery(q)
+	if err != nil {
+		return err
+	}
+	if pq.Ancestor == nil || pq.Eventual {
+		return ErrInconsistentQuery
+	}
+	return nil
+}
+
+// maxPresize bounds the room made for query results up front, as large
+// limits are more often upper bounds than the expected number of results
+const maxPresize = 1000
+
+// growSlice makes room in the slice v for n more elements
+func growSlice(v reflect.Value, n int) {
+	if n > maxPresize {
+		n = maxPresize
+	}
+	if v.Cap()-v.Len() >= n {
+		return
+	}
+	grown := reflect.MakeSlice(v.Type(), v.Len(), v.Len()+n)
+	reflect.Copy(grown, v)
+	v.Set(grown)
+}
+
+// queryBuffer holds the keys and entities of a batch of query results,
+// reused between queries
+type queryBuffer struct {
+	keys []*datastore.Key
+	dst  []Entity
+}
+
+var queryBuffers = sync.Pool{
+	New: func() interface{} { return new(queryBuffer) },
+}
+
+// release clears b, so it doesn't keep results alive, and returns it to
+// the pool
+func (b *queryBuffer) release() {
+	keys, dst := b.keys[:cap(b.keys)], b.dst[:cap(b.dst)]
+	for i := range keys {
+		keys[i] = nil
+	}
+	for i := range dst {
+		dst[i] = nil
+	}
+	queryBuffers.Put(b)
+}
+
 type multiArgType int
 
 const (
@@ -1,11 +1,12 @@
 package gaestore
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"testing"
-	"time"
 
-	"golang.org/x/net/context"
+	"google.golang.org/appengine"
 	"google.golang.org/appengine/aetest"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/memcache"
@@ -19,12 +20,24 @@ type object struct {
 func (o object) Key(ctx context.Context) *datastore.Key {
 	return datastore.NewKey(ctx, "object", o.ID, 0, nil)
 }
-func TestQuery(t *testing.T) {
-	ctx, done, err := aetest.NewContext()
+
+// newStrongContext returns a context for a dev server whose datastore is
+// strongly consistent, so queries see writes as soon as they're made
+func newStrongContext(t *testing.T) (context.Context, func()) {
+	inst, err := aetest.NewInstance(&aetest.Options{StronglyConsistentDatastore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := inst.NewRequest(http.MethodGet, "/", nil)
 	if err != nil {
+		inst.Close()
 		t.Fatal(err)
 	}
+	return appengine.NewContext(r), func() { inst.Close() }
+}
 
+func TestQuery(t *testing.T) {
+	ctx, done := newStrongContext(t)
 	defer done()
 	tests := []struct {
 		Entities []*object
@@ -54,8 +67,6 @@ func TestQuery(t *testing.T) {
 				t.Fatal(err)
 			}
 		}
-		// Hack to deal with eventual consistency
-		time.Sleep(2 * time.Second)
 		q := datastore.NewQuery("object")
 		var entities []object
 		_, err := Query(ctx, q, &entities)
@@ -130,7 +141,7 @@ func TestCrud(t *testing.T) {
 		}
 		err = s.Delete(ctx, o)
 		if err != nil {
-			t.Fatal("Unable to delete entity from datastore [%v]", err)
+			t.Fatalf("Unable to delete entity from datastore [%v]", err)
 		}
 
 		o = object{}
@@ -236,6 +247,22 @@ func TestCrud(t *testing.T) {
 	}
 }
 
+// keyContext returns a context that can be used to build keys without
+// starting the dev server
+func keyContext(t *testing.T) context.Context {
+	t.Setenv("GAE_APPLICATION", "testapp")
+	return context.Background()
+}
+
+// nopLogger discards everything logged by a store used outside of the
+// dev server
+type nopLogger struct{}
+
+func (nopLogger) Debugf(ctx context.Context, format string, args ...interface{})   {}
+func (nopLogger) Infof(ctx context.Context, format string, args ...interface{})    {}
+func (nopLogger) Warningf(ctx context.Context, format string, args ...interface{}) {}
+func (nopLogger) Errorf(ctx context.Context, format string, args ...interface{})   {}
+
 func compare(o1, o2 *object) error {
 	if o1.ID != o2.ID {
 		return fmt.Errorf("Expected o1.ID to be [%s] but got [%s]", o1.ID, o2.ID)
@@ -245,3 +272,146 @@ func compare(o1, o2 *object) error {
 	}
 	return nil
 }
+
+type hookObject struct {
+	ID       string
+	Name     string
+	AfterGot int `datastore:"-" json:"-"`
+}
+
+func (o *hookObject) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "hookObject", o.ID, 0, nil)
+}
+
+func (o *hookObject) AfterGet(ctx context.Context, key *datastore.Key) error {
+	o.AfterGot++
+	return nil
+}
+
+func TestAfterGet(t *testing.T) {
+	ctx, done := newStrongContext(t)
+	defer done()
+
+	entity := &hookObject{ID: "1", Name: "John"}
+	if _, err := NewStore().Put(ctx, entity); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name  string
+		Store *Client
+	}{
+		{"No cache", NewStore()},
+		{"Cache miss", NewStoreWithCache()},
+		{"Cache hit", NewStoreWithCache()},
+	}
+	for _, test := range tests {
+		o := &hookObject{ID: entity.ID}
+		if err := test.Store.Get(ctx, o); err != nil {
+			t.Fatalf("%s: %v", test.Name, err)
+		}
+		if o.AfterGot != 1 {
+			t.Fatalf("%s: Expected AfterGet to run once but ran [%v] times", test.Name, o.AfterGot)
+		}
+	}
+
+	o := &hookObject{ID: entity.ID}
+	if err := NewStore().Get(ctx, o, SkipHooks); err != nil {
+		t.Fatal(err)
+	}
+	if o.AfterGot != 0 {
+		t.Fatalf("SkipHooks: Expected AfterGet not to run but ran [%v] times", o.AfterGot)
+	}
+
+	for _, useCache := range []bool{true, false} {
+		if err := memcache.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		q := datastore.NewQuery("hookObject")
+		var entities []*hookObject
+		s := NewStore()
+		if useCache {
+			s = NewStoreWithCache()
+		}
+		if _, err := s.Query(ctx, q, &entities); err != nil {
+			t.Fatal(err)
+		}
+		if len(entities) != 1 {
+			t.Fatalf("Query: Expected to find [1] entity but got [%v]", len(entities))
+		}
+		for _, o := range entities {
+			if o.AfterGot != 1 {
+				t.Fatalf("Query: Expected AfterGet to run once but ran [%v] times", o.AfterGot)
+			}
+		}
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	o := &object{ID: "1", Name: "John"}
+	if _, err := Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	var got object
+	if err := GetByStringID(ctx, "object", o.ID, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := compare(o, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetByIntID(ctx, "object", 1, &got); !IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	o := &object{ID: "1", Name: "John"}
+	key, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the entity behind the cache's back
+	props, _ := datastore.SaveStruct(&object{ID: "1", Name: "Jane"})
+	backend[key.Encode()] = props
+
+	got := &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "John" {
+		t.Fatalf("Expected the cached name [John] but got [%v]", got.Name)
+	}
+	if err := s.Reload(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Jane" {
+		t.Fatalf("Expected the reloaded name [Jane] but got [%v]", got.Name)
+	}
+	got = &object{ID: "1"}
+	if err := s.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Jane" {
+		t.Fatalf("Expected the cache to be refreshed with [Jane] but got [%v]", got.Name)
+	}
+
+	// A reload of a deleted entity removes the cached copy
+	delete(backend, key.Encode())
+	if err := s.Reload(ctx, got); !IsNotFound(err) {
+		t.Fatalf("Expected entity not to be found but got [%v]", err)
+	}
+	if _, ok := cacher[key.Encode()]; ok {
+		t.Fatalf("Expected [%v] to be removed from the cache", key)
+	}
+}
@@ -0,0 +1,27 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+)
+
+// SetTimeouts sets the default timeouts applied to each datastore and
+// memcache call the store makes, so a hung call fails in time for the
+// request to recover, for example by reading from the datastore after a
+// memcache timeout. Queries are not subject to the datastore timeout. A
+// zero timeout leaves calls bound only by the request's deadline.
+func (s *Client) SetTimeouts(datastore, memcache time.Duration) {
+	s.datastoreTimeout = datastore
+	s.memcacheTimeout = memcache
+}
+
+func (s *Client) withTimeout(ctx context.Context, rpc string) (context.Context, context.CancelFunc) {
+	d := s.datastoreTimeout
+	if isMemcacheRPC(rpc) {
+		d = s.memcacheTimeout
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
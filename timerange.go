@@ -0,0 +1,79 @@
+package gaestore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// Between adds filters to q matching values of the property field from
+// from, inclusive, to to, exclusive. It fails with ErrInvalidRange when to
+// is before from, or when the filters would break the datastore's rules
+// for inequality filters as checked by CheckQuery.
+func Between(q *datastore.Query, field string, from, to time.Time) (*datastore.Query, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: [%v] is before [%v]", ErrInvalidRange, to, from)
+	}
+	q = q.Filter(field+" >=", from).Filter(field+" <", to)
+	if err := CheckQuery(q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// OnDay adds filters to q matching values of the property field on the
+// day of t, in t's location
+func OnDay(q *datastore.Query, field string, t time.Time) (*datastore.Query, error) {
+	from, to := DayRange(t)
+	return Between(q, field, from, to)
+}
+
+// InWeek adds filters to q matching values of the property field in the
+// week of t, in t's location
+func InWeek(q *datastore.Query, field string, t time.Time) (*datastore.Query, error) {
+	from, to := WeekRange(t)
+	return Between(q, field, from, to)
+}
+
+// DayRange returns the start of the day of t, in t's location, and the
+// start of the next day
+func DayRange(t time.Time) (from, to time.Time) {
+	from = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return from, from.AddDate(0, 0, 1)
+}
+
+// WeekRange returns the start of the week of t, the Monday before or of t
+// in t's location, and the start of the next week
+func WeekRange(t time.Time) (from, to time.Time) {
+	day, _ := DayRange(t)
+	from = day.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	return from, from.AddDate(0, 0, 7)
+}
+
+// CheckQuery reports whether q breaks the datastore's rules for inequality
+// filters, which may only be on one property that must also be the first
+// the results are ordered by. It returns an error wrapping
+// ErrInequalityFilter or ErrInequalityOrder when they're broken, rather
+// than the query failing once it's run.
+func CheckQuery(q *datastore.Query) error {
+	pq, err := appds.ParseQuery(q)
+	if err != nil {
+		return err
+	}
+	var inequality string
+	for _, f := range pq.Filters {
+		if f.Op == "=" {
+			continue
+		}
+		if inequality != "" && f.Field != inequality {
+			return fmt.Errorf("%w: [%s] and [%s]", ErrInequalityFilter, inequality, f.Field)
+		}
+		inequality = f.Field
+	}
+	if inequality != "" && len(pq.Orders) > 0 && pq.Orders[0].Field != inequality {
+		return fmt.Errorf("%w: filtered on [%s] but ordered by [%s]", ErrInequalityOrder, inequality, pq.Orders[0].Field)
+	}
+	return nil
+}
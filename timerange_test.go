@@ -0,0 +1,82 @@
+package gaestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type visit struct {
+	ID   int64 `datastore:"-"`
+	At   time.Time
+	Page string
+}
+
+func (v *visit) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "visit", "", v.ID, nil)
+}
+
+func TestTimeRanges(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+
+	// 2021-03-03 is a Wednesday
+	day := time.Date(2021, 3, 3, 15, 30, 0, 0, time.UTC)
+	for i, at := range []time.Time{
+		day.Add(-24 * time.Hour),
+		day,
+		day.Add(8 * time.Hour),
+		day.Add(-3 * 24 * time.Hour),
+		day.Add(5 * 24 * time.Hour),
+	} {
+		if _, err := s.Put(ctx, &visit{ID: int64(i + 1), At: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(q *datastore.Query, err error) int {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var visits []*visit
+		if _, err := s.Query(ctx, q, &visits); err != nil {
+			t.Fatal(err)
+		}
+		return len(visits)
+	}
+	q := datastore.NewQuery("visit")
+	if n := count(gaestore.OnDay(q, "At", day)); n != 2 {
+		t.Fatalf("Expected [2] visits on the day but got [%d]", n)
+	}
+	if n := count(gaestore.InWeek(q, "At", day)); n != 3 {
+		t.Fatalf("Expected [3] visits in the week but got [%d]", n)
+	}
+	if n := count(gaestore.Between(q.Order("-At"), "At", day, day.Add(time.Hour))); n != 1 {
+		t.Fatalf("Expected [1] visit in the hour but got [%d]", n)
+	}
+
+	from, to := gaestore.WeekRange(day)
+	if !from.Equal(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected the week of Monday 2021-03-01 but got [%v] to [%v]", from, to)
+	}
+
+	tests := []struct {
+		q        *datastore.Query
+		from, to time.Time
+		expected error
+	}{
+		{q, day, day.Add(-time.Second), gaestore.ErrInvalidRange},
+		{q.Filter("Page >", "a"), day, day, gaestore.ErrInequalityFilter},
+		{q.Order("Page"), day, day, gaestore.ErrInequalityOrder},
+		{q.Filter("Page =", "a").Order("At"), day, day, nil},
+	}
+	for _, test := range tests {
+		if _, err := gaestore.Between(test.q, "At", test.from, test.to); !errors.Is(err, test.expected) {
+			t.Fatalf("Expected error [%v] but got [%v]", test.expected, err)
+		}
+	}
+}
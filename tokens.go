@@ -0,0 +1,243 @@
+package gaestore
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/floresj/gaestore/internal/appds"
+	"google.golang.org/appengine/datastore"
+)
+
+// TokenKind is the kind the token indexes of entities are stored under
+const TokenKind = "GaestoreTokens"
+
+// tokenTag tags the string and []string fields whose words are indexed
+// for SearchKind
+const tokenTag = "search"
+
+// tokenIndex holds the words of an entity's search fields, stored as a
+// child of the entity. Words are prefixed with the entity's kind, so a
+// single indexed property serves every kind.
+type tokenIndex struct {
+	Tokens []string
+}
+
+// WithTokenIndex keeps a token index of the words in the string and
+// []string fields tagged `gaestore:"search"`, updated after each Put and
+// Delete, for SearchKind to look entities up by keyword. It's meant for
+// simple lookups where the Search API can't be used.
+func WithTokenIndex() Option {
+	return func(c *Client) {
+		c.tokenIndex = true
+	}
+}
+
+// tokenFieldsOf returns the indexes of the fields of t tagged to be
+// searched
+func tokenFieldsOf(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("gaestore") != tokenTag {
+			continue
+		}
+		if f.Type.Kind() == reflect.String || f.Type == reflect.TypeOf([]string(nil)) {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// tokenize returns the lower cased words of text, split at anything other
+// than a letter or digit
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// entityTokens returns the distinct words of the search fields of e, each
+// prefixed with kind
+func entityTokens(kind string, e Entity) []string {
+	info := structInfo(e)
+	if info == nil {
+		return nil
+	}
+	var (
+		tokens []string
+		seen   = make(map[string]bool)
+	)
+	v := reflect.ValueOf(e).Elem()
+	for _, i := range info.tokenFields {
+		var text []string
+		if f := v.Field(i); f.Kind() == reflect.String {
+			text = []string{f.String()}
+		} else {
+			text = f.Interface().([]string)
+		}
+		for _, t := range text {
+			for _, w := range tokenize(t) {
+				if token := kind + ":" + w; !seen[token] {
+					seen[token] = true
+					tokens = append(tokens, token)
+				}
+			}
+		}
+	}
+	return tokens
+}
+
+func tokenKey(key *datastore.Key) (*datastore.Key, error) {
+	return appds.NewKey(key.AppID(), key.Namespace(), TokenKind, "tokens", 0, key)
+}
+
+// syncTokens updates the token index of a written entity. Failures are
+// logged and reported rather than returned, as the write has been made.
+func (s *Client) syncTokens(ctx context.Context, t EventType, key *datastore.Key, e Entity) {
+	if !s.tokenIndex || e == nil {
+		return
+	}
+	info := structInfo(e)
+	if info == nil || len(info.tokenFields) == 0 {
+		return
+	}
+	tk, err := tokenKey(key)
+	if err == nil {
+		if tokens := entityTokens(key.Kind(), e); t == EventDelete || len(tokens) == 0 {
+			err = s.datastoreDelete(ctx, tk)
+		} else {
+			_, err = s.datastorePut(ctx, tk, &tokenIndex{Tokens: tokens})
+		}
+	}
+	if err != nil {
+		s.log().Errorf(ctx, "gaestore: unable to index tokens of [%v]: %v", key, err)
+		s.reportError(ctx, "Tokens", key, err)
+	}
+}
+
+// SearchKind loads the entities of kind whose search fields have every
+// word of terms into dst, a pointer to a slice of entities. A word ending
+// with '*' matches words starting with it. Entities are looked up in the
+// token index kept with WithTokenIndex, which like other queries is
+// eventually consistent, and loaded like Get.
+func (s *Client) SearchKind(ctx context.Context, kind, terms string, dst interface{}, opts ...CallOption) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return ErrInvalidEntityType
+	}
+	dv = dv.Elem()
+	mat, elemType := checkMultiArg(dv)
+	if mat != multiArgTypeStruct && mat != multiArgTypeStructPtr {
+		return ErrInvalidEntityType
+	}
+	nctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := s.searchTokens(nctx, kind, terms)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		e, ok := reflect.New(elemType).Interface().(Entity)
+		if !ok {
+			return ErrInvalidEntityType
+		}
+		op := &Operation{Name: OpGet, Entity: e, Key: key, opts: newCallOptions(opts)}
+		if err := s.run(ctx, op); IsNotFound(err) {
+			// The index is behind a delete
+			continue
+		} else if err != nil {
+			return err
+		}
+		if mat == multiArgTypeStructPtr {
+			dv.Set(reflect.Append(dv, reflect.ValueOf(e)))
+		} else {
+			dv.Set(reflect.Append(dv, reflect.ValueOf(e).Elem()))
+		}
+	}
+	return nil
+}
+
+// searchTerm is a word of a search, matching words starting with it when
+// it's a prefix
+type searchTerm struct {
+	token  string
+	prefix bool
+}
+
+// parseTerms returns the words of terms, prefixed with kind
+func parseTerms(kind, terms string) []searchTerm {
+	var parsed []searchTerm
+	for _, f := range strings.Fields(terms) {
+		words := tokenize(f)
+		for i, w := range words {
+			prefix := i == len(words)-1 && strings.HasSuffix(f, "*")
+			parsed = append(parsed, searchTerm{token: kind + ":" + w, prefix: prefix})
+		}
+	}
+	return parsed
+}
+
+// searchTokens returns the keys of the entities of kind matching every
+// word of terms, in the order of the matches of the first word
+func (s *Client) searchTokens(ctx context.Context, kind, terms string) ([]*datastore.Key, error) {
+	var keys []*datastore.Key
+	for i, t := range parseTerms(kind, terms) {
+		q := datastore.NewQuery(TokenKind)
+		if t.prefix {
+			q = q.Filter("Tokens >=", t.token).Filter("Tokens <", t.token+"\U0010ffff")
+		} else {
+			q = q.Filter("Tokens =", t.token)
+		}
+		found, err := s.tokenMatches(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			keys = found
+			continue
+		}
+		matched := make(map[string]bool, len(found))
+		for _, key := range found {
+			matched[key.Encode()] = true
+		}
+		var both []*datastore.Key
+		for _, key := range keys {
+			if matched[key.Encode()] {
+				both = append(both, key)
+			}
+		}
+		if keys = both; len(keys) == 0 {
+			break
+		}
+	}
+	return keys, nil
+}
+
+// tokenMatches returns the distinct keys of the entities whose token
+// indexes match q
+func (s *Client) tokenMatches(ctx context.Context, q *datastore.Query) ([]*datastore.Key, error) {
+	t := s.runKeys(ctx, q)
+	defer t.close()
+	var (
+		keys []*datastore.Key
+		seen = make(map[string]bool)
+	)
+	for {
+		key, err := t.Next()
+		if err == datastore.Done {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A prefix may match several words of the same entity
+		if parent := key.Parent(); parent != nil && !seen[parent.Encode()] {
+			seen[parent.Encode()] = true
+			keys = append(keys, parent)
+		}
+	}
+}
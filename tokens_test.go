@@ -0,0 +1,88 @@
+package gaestore_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type recipe struct {
+	ID    string   `datastore:"-"`
+	Title string   `gaestore:"search"`
+	Tags  []string `gaestore:"search"`
+	Notes string
+}
+
+func (r *recipe) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "recipe", r.ID, 0, nil)
+}
+
+func (r *recipe) AfterGet(ctx context.Context, key *datastore.Key) error {
+	r.ID = key.StringID()
+	return nil
+}
+
+func TestSearchKind(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t, gaestore.WithTokenIndex())
+
+	for _, r := range []*recipe{
+		{ID: "1", Title: "Tomato Soup", Tags: []string{"vegetarian", "Quick"}},
+		{ID: "2", Title: "Chicken soup", Tags: []string{"quick"}, Notes: "tomato"},
+		{ID: "3", Title: "Tomato-basil pasta"},
+	} {
+		if _, err := s.Put(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(terms string) []string {
+		var found []recipe
+		if err := s.SearchKind(ctx, "recipe", terms, &found); err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, r := range found {
+			ids = append(ids, r.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	tests := []struct {
+		terms    string
+		expected []string
+	}{
+		{"tomato", []string{"1", "3"}},
+		{"SOUP quick", []string{"1", "2"}},
+		{"tom* soup", []string{"1"}},
+		{"veg*", []string{"1"}},
+		{"basil", []string{"3"}},
+		{"notes", []string{}},
+		{"tomat", []string{}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		if got := search(test.terms); !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("Expected [%v] for [%s] but got [%v]", test.expected, test.terms, got)
+		}
+	}
+
+	// The index follows updates and deletes
+	if _, err := s.Put(ctx, &recipe{ID: "1", Title: "Gazpacho"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, &recipe{ID: "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("tomato"); len(got) != 0 {
+		t.Fatalf("Expected no results but got [%v]", got)
+	}
+	if got := search("gazpacho"); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("Expected [[1]] but got [%v]", got)
+	}
+}
@@ -0,0 +1,57 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// Toucher is implemented by entities with fields to bump when they're
+// touched, such as an UpdatedAt time or an expiration
+type Toucher interface {
+	Touch(now time.Time)
+}
+
+// Touch bumps the fields of e through its Touch method and writes it back,
+// reading and writing in a transaction, joining the one ctx is in if any,
+// so the rest of the entity is left as stored. e only needs to be populated for its Key method and is loaded
+// with the touched entity. The cached copy is replaced, restarting its
+// expiration, which is all Touch does for entities that aren't Touchers.
+// Put hooks are not run.
+func (s *Client) Touch(ctx context.Context, e Entity, opts ...CallOption) error {
+	return s.run(ctx, &Operation{Name: OpTouch, Entity: e, opts: newCallOptions(opts)})
+}
+
+func (s *Client) touch(ctx context.Context, key *datastore.Key, e Entity, opts callOptions) error {
+	t, ok := e.(Toucher)
+	if !ok {
+		if err := s.load(ctx, key, e, s.useCache); err != nil {
+			return err
+		}
+	} else {
+		err := s.transact(ctx, func(tc context.Context) error {
+			if err := s.datastoreGet(tc, key, e); err != nil {
+				return err
+			}
+			t.Touch(s.now())
+			if _, err := s.datastorePut(tc, key, e); err != nil {
+				return err
+			}
+			if s.auditUser != nil {
+				return s.putAuditEntry(tc, OpTouch, key, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if s.useCache {
+		s.refreshCache(ctx, key, e)
+	}
+	if opts.skipHooks {
+		return nil
+	}
+	return afterGet(ctx, key, e)
+}
@@ -0,0 +1,105 @@
+package gaestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+type session struct {
+	ID        string `datastore:"-"`
+	User      string
+	UpdatedAt time.Time
+}
+
+func (s *session) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "session", s.ID, 0, nil)
+}
+
+func (s *session) Touch(now time.Time) {
+	s.UpdatedAt = now
+}
+
+func TestTouch(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(backend), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+
+	key, err := s.Put(ctx, &session{ID: "1", User: "john"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &session{ID: "1"}
+	if err := s.Touch(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.User != "john" || got.UpdatedAt.IsZero() {
+		t.Fatalf("Expected the touched session to be loaded but got [%+v]", got)
+	}
+	stored := &session{}
+	if err := backend.Get(ctx, key, stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.UpdatedAt.Equal(got.UpdatedAt) || stored.User != "john" {
+		t.Fatalf("Expected [%+v] to be stored but got [%+v]", got, stored)
+	}
+	cached := &session{ID: "1"}
+	if _, err := s.cacheGet(ctx, key, cached); err != nil {
+		t.Fatal(err)
+	}
+	if !cached.UpdatedAt.Equal(got.UpdatedAt) {
+		t.Fatalf("Expected the cache to be refreshed with [%v] but got [%v]", got.UpdatedAt, cached.UpdatedAt)
+	}
+
+	// Entities that aren't Touchers are only recached
+	o := &object{ID: "1", Name: "John"}
+	okey, err := s.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(cacher, okey.Encode())
+	if err := s.Touch(ctx, &object{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cacher[okey.Encode()]; !ok {
+		t.Fatalf("Expected [%v] to be cached", okey)
+	}
+
+	ro := s.With(WithReadOnly())
+	if err := ro.Touch(ctx, &session{ID: "1"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Expected error [%v] but got [%v]", ErrReadOnly, err)
+	}
+}
+
+func TestTouchInTransaction(t *testing.T) {
+	ctx := keyContext(t)
+	backend, cacher := mapBackend{}, mapCacher{}
+	s := NewStore(WithBackend(flatBackend{backend}), WithCache(0), WithCacher(cacher), WithLogger(nopLogger{}))
+	e := &session{ID: "1", User: "ann"}
+	if _, err := s.Put(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	key := e.Key(ctx).Encode()
+	cached := string(cacher[key])
+
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		if err := s.Touch(tc, &session{ID: "1"}); err != nil {
+			return err
+		}
+		if string(cacher[key]) != cached {
+			t.Fatal("Expected the cache not to change before the transaction commits")
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &session{ID: "1"}
+	if err := s.Get(ctx, got); err != nil || got.UpdatedAt.IsZero() {
+		t.Fatalf("Expected the session to be touched but got [%+v] %v", got, err)
+	}
+}
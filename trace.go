@@ -0,0 +1,35 @@
+package gaestore
+
+import (
+	"context"
+)
+
+// Tracer starts the spans a store records. Each operation made through a
+// store gets a span named after the operation, for example gaestore.Get,
+// with a child span for every datastore and memcache call it makes, named
+// after the RPC, for example memcache.Get.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. End is called once with the result
+// of the traced call.
+type Span interface {
+	End(err error)
+}
+
+// SetTracer sets the Tracer used to trace the store's operations
+func (s *Client) SetTracer(t Tracer) {
+	s.tracer = t
+}
+
+type nopSpan struct{}
+
+func (nopSpan) End(error) {}
+
+func (s *Client) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if s.tracer == nil {
+		return ctx, nopSpan{}
+	}
+	return s.tracer.StartSpan(ctx, name)
+}
@@ -0,0 +1,67 @@
+package gaestore
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// ExpiresProperty is the indexed time property Sweep finds expired
+// entities by
+const ExpiresProperty = "Expires"
+
+// Expirer is implemented by entities that expire, which must save when as
+// an indexed ExpiresProperty. Expired entities are read as normal until
+// they're deleted by Sweep, but aren't cached past their expiry.
+//
+//	type Session struct {
+//		ID      string `datastore:"-"`
+//		Expires time.Time
+//	}
+//
+//	func (s *Session) ExpiresAt() time.Time { return s.Expires }
+type Expirer interface {
+	ExpiresAt() time.Time
+}
+
+// Sweep deletes the entities of kind that have expired, batchSize at a
+// time, and removes them from the cache, returning the number deleted. It
+// suits a cron handler; entities without an ExpiresProperty never expire.
+// Hooks aren't run, no events are published and deletes aren't audited.
+func (s *Client) Sweep(ctx context.Context, kind string, batchSize int) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	if batchSize <= 0 || batchSize > purgeBatchSize {
+		batchSize = purgeBatchSize
+	}
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return 0, err
+	}
+	q := datastore.NewQuery(kind).Filter(ExpiresProperty+" <", s.now()).KeysOnly()
+	n, err := s.deleteMatching(ctx, q, batchSize)
+	if n > 0 {
+		s.log().Infof(ctx, "gaestore: swept %d expired entities of [%s]", n, kind)
+	}
+	return n, err
+}
+
+// itemTTL returns how long src may be cached, shortened for an Expirer to
+// when it expires
+func (s *Client) itemTTL(src interface{}) time.Duration {
+	x, ok := src.(Expirer)
+	if !ok || x.ExpiresAt().IsZero() {
+		return s.cacheTTL
+	}
+	ttl := x.ExpiresAt().Sub(s.now())
+	if ttl < time.Second {
+		// Shorter times are ignored by memcache
+		ttl = time.Second
+	}
+	if s.cacheTTL > 0 && s.cacheTTL < ttl {
+		return s.cacheTTL
+	}
+	return ttl
+}
@@ -0,0 +1,76 @@
+package gaestore_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type expiring struct {
+	ID      string `datastore:"-"`
+	Expires time.Time
+}
+
+func (e *expiring) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "expiring", e.ID, 0, nil)
+}
+
+func (e *expiring) ExpiresAt() time.Time {
+	return e.Expires
+}
+
+var _ gaestore.Expirer = (*expiring)(nil)
+
+func TestSweep(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		e := &expiring{ID: fmt.Sprint(i), Expires: now.Add(-time.Hour)}
+		if i == 0 {
+			e.Expires = now.Add(time.Hour)
+		}
+		if _, err := s.Put(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := s.Sweep(ctx, "expiring", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatalf("Expected [4] expired entities to be deleted but got [%d]", n)
+	}
+	if err := s.Get(ctx, &expiring{ID: "1"}); !gaestore.IsNotFound(err) {
+		t.Fatalf("Expected the expired entity to be deleted but got [%v]", err)
+	}
+	if err := s.Get(ctx, &expiring{ID: "0"}); err != nil {
+		t.Fatalf("Expected the unexpired entity to be kept but got [%v]", err)
+	}
+}
+
+func TestExpirerCacheTTL(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	c := gaestoretest.NewCacher()
+	s := gaestoretest.NewStore(t, gaestore.WithCacher(c))
+	e := &expiring{ID: "1", Expires: time.Now().Add(-time.Hour)}
+	if _, err := s.Put(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(ctx, &expiring{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Expected the entity to be cached but got [%d] items", c.Len())
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := c.Get(ctx, e.Key(ctx).Encode()); err == nil {
+		t.Fatal("Expected the cached copy to expire with the entity")
+	}
+}
@@ -0,0 +1,55 @@
+//go:build go1.18
+// +build go1.18
+
+package gaestore
+
+import (
+	"context"
+
+	"google.golang.org/appengine/datastore"
+)
+
+// TypedStore wraps a Client for a single entity type T, where *T is the
+// Entity. Query results are returned as a []*T rather than filling a
+// slice through reflection, so passing the wrong destination type is a
+// compile time error.
+//
+//	users := gaestore.NewTypedStore[User](client)
+//	u, err := users.Get(ctx, key)
+type TypedStore[T any, PT interface {
+	*T
+	Entity
+}] struct {
+	client *Client
+}
+
+// NewTypedStore returns a TypedStore for T backed by client
+func NewTypedStore[T any, PT interface {
+	*T
+	Entity
+}](client *Client) *TypedStore[T, PT] {
+	return &TypedStore[T, PT]{client: client}
+}
+
+func (s *TypedStore[T, PT]) Put(ctx context.Context, e *T, opts ...CallOption) (*datastore.Key, error) {
+	return s.client.Put(ctx, PT(e), opts...)
+}
+
+// Get loads the entity stored under key
+func (s *TypedStore[T, PT]) Get(ctx context.Context, key *datastore.Key, opts ...CallOption) (*T, error) {
+	e := new(T)
+	if err := s.client.getKey(ctx, key, PT(e), opts); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *TypedStore[T, PT]) Query(ctx context.Context, q *datastore.Query, opts ...CallOption) ([]*T, datastore.Cursor, error) {
+	var entities []*T
+	c, err := s.client.Query(ctx, q, &entities, opts...)
+	return entities, c, err
+}
+
+func (s *TypedStore[T, PT]) Delete(ctx context.Context, e *T, opts ...CallOption) error {
+	return s.client.Delete(ctx, PT(e), opts...)
+}
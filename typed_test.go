@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package gaestore
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+)
+
+func TestTypedStore(t *testing.T) {
+	ctx, done, err := aetest.NewContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	objects := NewTypedStore[object](NewStore(WithCache(0)))
+	o := &object{ID: "1", Name: "John"}
+	k, err := objects.Put(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := objects.Get(ctx, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compare(o, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := objects.Delete(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := objects.Get(ctx, k); !IsNotFound(err) {
+		t.Fatalf("Expected entity to be deleted but got [%v]", err)
+	}
+}
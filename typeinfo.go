@@ -0,0 +1,130 @@
+package gaestore
+
+import (
+	"reflect"
+	"sync"
+)
+
+// typeInfo holds what the store needs to know about a struct type, found
+// with reflection once per type rather than on every operation
+type typeInfo struct {
+	// entity reports whether a pointer to the type is an Entity
+	entity bool
+
+	// fields indexes the exported fields by name
+	fields map[string][]int
+
+	// blobRefs are the top level BlobRef and *BlobRef fields
+	blobRefs []blobField
+
+	// pii are the fields with pii tags, including those of nested structs,
+	// which are sensitive
+	pii []piiField
+
+	// jsonFields are the top level JSONProperty fields
+	jsonFields []int
+
+	// splitFields are the top level SplitBytes fields
+	splitFields []splitField
+
+	// locations are the top level Location fields
+	locations []locationField
+
+	// tokenFields are the top level fields tagged to be searched
+	tokenFields []int
+
+	// normalized are the property names of the top level fields tagged to
+	// be normalized
+	normalized map[string]bool
+
+	// compressFields are the top level fields tagged to be compressed
+	compressFields []int
+
+	// owner is the field tagged `gaestore:"owner"`, or nil
+	owner *ownerField
+}
+
+type blobField struct {
+	index int
+	ptr   bool
+}
+
+type piiField struct {
+	index []int
+	tag   string
+}
+
+var (
+	typeInfos  sync.Map
+	entityType = reflect.TypeOf((*Entity)(nil)).Elem()
+)
+
+// typeInfoOf returns the info of the struct type t
+func typeInfoOf(t reflect.Type) *typeInfo {
+	if info, ok := typeInfos.Load(t); ok {
+		return info.(*typeInfo)
+	}
+	info := &typeInfo{
+		entity: reflect.PtrTo(t).Implements(entityType),
+		fields: make(map[string][]int),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		info.fields[f.Name] = f.Index
+		switch f.Type {
+		case blobRefType:
+			info.blobRefs = append(info.blobRefs, blobField{index: i})
+		case reflect.PtrTo(blobRefType):
+			info.blobRefs = append(info.blobRefs, blobField{index: i, ptr: true})
+		}
+		if reflect.PtrTo(f.Type).Implements(jsonValueType) {
+			info.jsonFields = append(info.jsonFields, i)
+		}
+		if sf, ok := splitFieldOf(f, i); ok {
+			info.splitFields = append(info.splitFields, sf)
+		}
+		if lf, ok := locationFieldOf(f, i); ok {
+			info.locations = append(info.locations, lf)
+		}
+	}
+	info.pii = piiFields(t, nil)
+	info.owner = ownerFieldOf(t)
+	info.compressFields = compressFieldsOf(t)
+	info.tokenFields = tokenFieldsOf(t)
+	info.normalized = normalizedFieldsOf(t)
+	actual, _ := typeInfos.LoadOrStore(t, info)
+	return actual.(*typeInfo)
+}
+
+// structInfo returns the info of the type e points to, or nil if e isn't
+// a pointer to a struct
+func structInfo(e interface{}) *typeInfo {
+	t := reflect.TypeOf(e)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return typeInfoOf(t.Elem())
+}
+
+// piiFields returns the settable fields of t with pii tags, looking into
+// nested structs without tags
+func piiFields(t reflect.Type, index []int) []piiField {
+	var fields []piiField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fi := append(append([]int(nil), index...), i)
+		switch tag := f.Tag.Get("pii"); {
+		case tag == "redact" || tag == "sensitive" || tag == "hash":
+			fields = append(fields, piiField{index: fi, tag: tag})
+		case f.Type.Kind() == reflect.Struct:
+			fields = append(fields, piiFields(f.Type, fi)...)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,40 @@
+package gaestore
+
+import (
+	"reflect"
+	"testing"
+)
+
+type infoAddress struct {
+	Street string `pii:"redact"`
+	City   string
+}
+
+type infoEntity struct {
+	object
+	Email   string `pii:"hash"`
+	Home    infoAddress
+	File    BlobRef
+	Backup  *BlobRef
+	private string `pii:"redact"`
+}
+
+func TestTypeInfo(t *testing.T) {
+	info := typeInfoOf(reflect.TypeOf(infoEntity{}))
+	if typeInfoOf(reflect.TypeOf(infoEntity{})) != info {
+		t.Fatalf("Expected the info to be cached")
+	}
+	if !info.entity {
+		t.Fatalf("Expected [infoEntity] to be an Entity")
+	}
+	if want := []blobField{{index: 3}, {index: 4, ptr: true}}; !reflect.DeepEqual(info.blobRefs, want) {
+		t.Fatalf("Expected [%v] but got [%v]", want, info.blobRefs)
+	}
+	want := []piiField{{index: []int{1}, tag: "hash"}, {index: []int{2, 0}, tag: "redact"}}
+	if !reflect.DeepEqual(info.pii, want) {
+		t.Fatalf("Expected [%v] but got [%v]", want, info.pii)
+	}
+	if index := info.fields["Email"]; !reflect.DeepEqual(index, []int{1}) {
+		t.Fatalf("Expected field [Email] at [1] but got [%v]", index)
+	}
+}
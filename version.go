@@ -0,0 +1,109 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"time"
+
+	"google.golang.org/appengine/memcache"
+)
+
+// Versioned is implemented by entities carrying a version that increases
+// with every write, such as a counter incremented by BeforePut or an
+// UpdatedAt time in nanoseconds. Their cached values hold the version,
+// and are never replaced by a value with an older version, so that an
+// entity read before a concurrent update can't overwrite the update's
+// cached value.
+type Versioned interface {
+	Version() int64
+}
+
+// Swapper is implemented by Cachers that can replace a value only if it
+// hasn't changed since it was read. Versions of cached values are then
+// compared and replaced atomically, rather than with a separate read and
+// write.
+type Swapper interface {
+	// GetForSwap returns the value of key and a token for CompareAndSwap,
+	// or memcache.ErrCacheMiss
+	GetForSwap(ctx context.Context, key string) (value []byte, token interface{}, err error)
+
+	// CompareAndSwap stores value under key if it hasn't been written since
+	// token was returned, otherwise returning memcache.ErrCASConflict, or
+	// memcache.ErrNotStored when key is no longer cached
+	CompareAndSwap(ctx context.Context, key string, value []byte, ttl time.Duration, token interface{}) error
+}
+
+// versionHeader starts cached values holding a version, which follows it
+// as 8 big endian bytes
+var versionHeader = []byte("\x00gaestore:v:")
+
+const versionLen = 8
+
+// withVersion returns value prefixed with version
+func withVersion(value []byte, version int64) []byte {
+	v := make([]byte, len(versionHeader)+versionLen, len(versionHeader)+versionLen+len(value))
+	copy(v, versionHeader)
+	binary.BigEndian.PutUint64(v[len(versionHeader):], uint64(version))
+	return append(v, value...)
+}
+
+// splitVersion returns the version of a cached value and the encoded
+// entity, with ok false if the value has no version
+func splitVersion(value []byte) (version int64, entity []byte, ok bool) {
+	n := len(versionHeader) + versionLen
+	if len(value) < n || !bytes.HasPrefix(value, versionHeader) {
+		return 0, value, false
+	}
+	return int64(binary.BigEndian.Uint64(value[len(versionHeader):n])), value[n:], true
+}
+
+// maxVersionAttempts bounds the writes of a versioned value racing other
+// writes, after which the other writes are left in place
+const maxVersionAttempts = 3
+
+// setVersioned caches value, holding version, unless a newer version is
+// cached. A fill also leaves an equal version or a tombstone in place.
+func (s *Client) setVersioned(ctx context.Context, key string, value []byte, ttl time.Duration, version int64, fill bool) error {
+	sw, swaps := s.mc().(Swapper)
+	for i := 0; i < maxVersionAttempts; i++ {
+		var (
+			current []byte
+			token   interface{}
+			err     error
+		)
+		if swaps {
+			current, token, err = sw.GetForSwap(ctx, key)
+		} else {
+			current, err = s.mc().Get(ctx, key)
+		}
+		if err == memcache.ErrCacheMiss {
+			adder, ok := s.mc().(Adder)
+			if !ok {
+				return s.mc().Set(ctx, key, value, ttl)
+			}
+			if err = adder.Add(ctx, key, value, ttl); err == memcache.ErrNotStored {
+				// Written since the read, so compare again
+				continue
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if fill && bytes.Equal(current, tombstone) {
+			return nil
+		}
+		if cached, _, ok := splitVersion(current); ok && (cached > version || fill && cached == version) {
+			return nil
+		}
+		if !swaps {
+			return s.mc().Set(ctx, key, value, ttl)
+		}
+		err = sw.CompareAndSwap(ctx, key, value, ttl, token)
+		if err != memcache.ErrCASConflict && err != memcache.ErrNotStored {
+			return err
+		}
+	}
+	return nil
+}
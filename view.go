@@ -0,0 +1,151 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/log"
+)
+
+// View keeps denormalized summary entities, such as counts per user or
+// lists of the latest entities, up to date with the creates, updates and
+// deletes of entities of a source kind made through a store. Each change
+// updates a single summary, read, changed by Update and written back in a
+// transaction. A change made in a transaction updates the summary as part
+// of it, so unless the view is Async the transaction must be cross group.
+//
+//	s.AddView(&gaestore.View{
+//		Name: "postCounts",
+//		Kind: "Post",
+//		Summary: func(ctx context.Context, ev gaestore.Event) gaestore.Entity {
+//			return &PostCount{User: ev.Entity.(*Post).Author}
+//		},
+//		Update: func(ctx context.Context, summary gaestore.Entity, ev gaestore.Event) error {
+//			switch ev.Type {
+//			case gaestore.EventCreate:
+//				summary.(*PostCount).Posts++
+//			case gaestore.EventDelete:
+//				summary.(*PostCount).Posts--
+//			}
+//			return nil
+//		},
+//	})
+//
+// The Entity of a delete is the one passed to Delete, so views depending
+// on the fields of deleted entities need them to be deleted with their
+// fields loaded.
+type View struct {
+	// Name identifies the view among the views of every store
+	Name string
+
+	// Kind is the kind of the source entities
+	Kind string
+
+	// Summary returns the summary entity updated by ev, which is loaded
+	// into it when it exists, or nil when ev doesn't change a summary
+	Summary func(ctx context.Context, ev Event) Entity
+
+	// Update applies ev to summary
+	Update func(ctx context.Context, summary Entity, ev Event) error
+
+	// Async updates summaries from a task queue task, retried until it
+	// succeeds, instead of during the write, which also suits writes made
+	// within transactions. The source kind must be registered with
+	// Register and the view added from init.
+	Async bool
+}
+
+type addedView struct {
+	v *View
+	s *Client
+}
+
+// views holds the views added to any store by name, for their tasks
+var views = struct {
+	sync.RWMutex
+	m map[string]addedView
+}{m: make(map[string]addedView)}
+
+var viewUpdateFunc = delay.Func("gaestore.ViewUpdate", runViewUpdate)
+
+// AddView keeps the summaries of v up to date with the changes made
+// through the store, and the stores derived from it once v is added.
+// Failing updates are logged and reported rather than failing the change.
+func (s *Client) AddView(v *View) {
+	views.Lock()
+	views.m[v.Name] = addedView{v: v, s: s}
+	views.Unlock()
+	s.Subscribe(v.Kind, func(ev Event) {
+		var err error
+		if v.Async {
+			err = s.enqueueViewUpdate(ev.Context, v, ev)
+		} else {
+			err = s.updateView(ev.Context, v, ev)
+		}
+		if err != nil {
+			s.log().Errorf(ev.Context, "gaestore: unable to update view [%s] for [%v]: %v", v.Name, ev.Key, err)
+			s.reportError(ev.Context, "View", ev.Key, err)
+		}
+	})
+}
+
+// updateView applies ev to its summary of v in a transaction, which is
+// that of the change when it was made in one
+func (s *Client) updateView(ctx context.Context, v *View, ev Event) error {
+	ctx, err := s.withNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.transact(ctx, func(tc context.Context) error {
+		summary := v.Summary(tc, ev)
+		if summary == nil {
+			return nil
+		}
+		if err := s.Get(tc, summary); err != nil && !IsNotFound(err) {
+			return err
+		}
+		if err := v.Update(tc, summary, ev); err != nil {
+			return err
+		}
+		_, err := s.Put(tc, summary)
+		return err
+	})
+}
+
+func (s *Client) enqueueViewUpdate(ctx context.Context, v *View, ev Event) error {
+	snapshot, err := json.Marshal(ev.Entity)
+	if err != nil {
+		return err
+	}
+	return viewUpdateFunc.Call(ctx, v.Name, int(ev.Type), ev.Kind, ev.Key.Encode(), snapshot)
+}
+
+func runViewUpdate(ctx context.Context, name string, t int, kind, encodedKey string, snapshot []byte) error {
+	views.RLock()
+	av, ok := views.m[name]
+	views.RUnlock()
+	if !ok {
+		log.Errorf(ctx, "gaestore: dropping ViewUpdate, no view [%s]", name)
+		return nil
+	}
+	key, err := datastore.DecodeKey(encodedKey)
+	if err != nil {
+		// Retrying will never decode the key so drop the task
+		log.Errorf(ctx, "gaestore: dropping ViewUpdate, invalid key [%v]", err)
+		return nil
+	}
+	e, err := newEntity(kind)
+	if err != nil {
+		log.Errorf(ctx, "gaestore: dropping ViewUpdate for [%v]: %v", key, err)
+		return nil
+	}
+	if err := json.Unmarshal(snapshot, e); err != nil {
+		log.Errorf(ctx, "gaestore: dropping ViewUpdate for [%v]: %v", key, err)
+		return nil
+	}
+	ev := Event{Type: EventType(t), Kind: kind, Key: key, Entity: e, Context: ctx}
+	return av.s.updateView(ctx, av.v, ev)
+}
@@ -0,0 +1,82 @@
+package gaestore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/floresj/gaestore"
+	"github.com/floresj/gaestore/gaestoretest"
+	"google.golang.org/appengine/datastore"
+)
+
+type comment struct {
+	ID     string `datastore:"-"`
+	Author string
+}
+
+func (c *comment) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "comment", c.ID, 0, nil)
+}
+
+type commentCount struct {
+	Author   string `datastore:"-"`
+	Comments int
+}
+
+func (c *commentCount) Key(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, "commentCount", c.Author, 0, nil)
+}
+
+func TestView(t *testing.T) {
+	ctx := gaestoretest.NewContext()
+	s := gaestoretest.NewStore(t)
+	s.AddView(&gaestore.View{
+		Name: "commentCounts",
+		Kind: "comment",
+		Summary: func(ctx context.Context, ev gaestore.Event) gaestore.Entity {
+			return &commentCount{Author: ev.Entity.(*comment).Author}
+		},
+		Update: func(ctx context.Context, summary gaestore.Entity, ev gaestore.Event) error {
+			switch ev.Type {
+			case gaestore.EventCreate:
+				summary.(*commentCount).Comments++
+			case gaestore.EventDelete:
+				summary.(*commentCount).Comments--
+			}
+			return nil
+		},
+	})
+
+	comments := []*comment{{ID: "1", Author: "ann"}, {ID: "2", Author: "ann"}, {ID: "3", Author: "bob"}}
+	for _, c := range comments {
+		if _, err := s.Put(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Updates and deletes are applied to the summary
+	if _, err := s.Put(ctx, comments[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, comments[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes made in a transaction update the summary as part of it
+	err := s.RunInTransaction(ctx, func(tc context.Context) error {
+		_, err := s.Put(tc, &comment{ID: "4", Author: "bob"})
+		return err
+	}, &datastore.TransactionOptions{XG: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for author, expected := range map[string]int{"ann": 2, "bob": 1} {
+		count := &commentCount{Author: author}
+		if err := s.Get(ctx, count); err != nil {
+			t.Fatal(err)
+		}
+		if count.Comments != expected {
+			t.Fatalf("Expected [%d] comments by [%s] but got [%d]", expected, author, count.Comments)
+		}
+	}
+}
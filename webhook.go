@@ -0,0 +1,157 @@
+package gaestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/urlfetch"
+)
+
+// WebhookSignatureHeader is the header of webhook requests holding the
+// hex encoded HMAC-SHA256 of the body, keyed with the webhook's secret
+const WebhookSignatureHeader = "X-Gaestore-Signature"
+
+// Webhook is a URL notified of the changes made to entities through a
+// store
+type Webhook struct {
+	URL string
+
+	// Secret keys the signature of each request, for the receiver to
+	// check it came from the store
+	Secret []byte
+
+	// Kinds limits the notifications to changes of entities of the given
+	// kinds, when set
+	Kinds []string
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks
+type WebhookPayload struct {
+	// Type is "Create", "Update" or "Delete"
+	Type string `json:"type"`
+	Kind string `json:"kind"`
+
+	// Key is the encoded key of the entity
+	Key string `json:"key"`
+
+	// Entity is a JSON snapshot of the entity written, or of the entity
+	// passed to Delete, without the fields tagged as sensitive
+	Entity json.RawMessage `json:"entity"`
+	Time   time.Time       `json:"time"`
+}
+
+// WithWebhook POSTs a signed WebhookPayload to w's URL after every
+// change made through the store to an entity of w's kinds. Requests are
+// made with URL Fetch from a task queue task, which is retried until the
+// webhook responds with a 2xx status, so receivers may see a change more
+// than once and out of order.
+func WithWebhook(w Webhook) Option {
+	return func(c *Client) {
+		c.webhooks = append(c.webhooks[:len(c.webhooks):len(c.webhooks)], w)
+	}
+}
+
+func (w *Webhook) matches(kind string) bool {
+	if len(w.Kinds) == 0 {
+		return true
+	}
+	for _, k := range w.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// sign returns the signature of body
+func (w *Webhook) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.Secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var webhookFunc = delay.Func("gaestore.Webhook", runWebhook)
+
+// callWebhookTask adds a task posting body to url, and is replaced in
+// tests
+var callWebhookTask = func(ctx context.Context, url string, body []byte, signature string) error {
+	return webhookFunc.Call(ctx, url, body, signature)
+}
+
+// webhookClient returns the client making webhook requests, and is
+// replaced in tests
+var webhookClient = func(ctx context.Context) *http.Client {
+	return urlfetch.Client(ctx)
+}
+
+// notifyWebhooks adds a task for each webhook of the kind of key. Failures
+// are logged and reported rather than returned, as the change has been
+// made.
+func (s *Client) notifyWebhooks(ctx context.Context, t EventType, key *datastore.Key, e Entity) {
+	if len(s.webhooks) == 0 {
+		return
+	}
+	var body []byte
+	for i := range s.webhooks {
+		w := &s.webhooks[i]
+		if !w.matches(key.Kind()) {
+			continue
+		}
+		err := func() (err error) {
+			if body == nil {
+				if body, err = s.webhookBody(t, key, e); err != nil {
+					return err
+				}
+			}
+			return callWebhookTask(ctx, w.URL, body, w.sign(body))
+		}()
+		if err != nil {
+			s.log().Errorf(ctx, "gaestore: unable to notify webhook [%s] of [%v]: %v", w.URL, key, err)
+			s.reportError(ctx, "Webhook", key, err)
+		}
+	}
+}
+
+func (s *Client) webhookBody(t EventType, key *datastore.Key, e Entity) ([]byte, error) {
+	snapshot, err := json.Marshal(redacted(e))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&WebhookPayload{
+		Type:   t.String(),
+		Kind:   key.Kind(),
+		Key:    key.Encode(),
+		Entity: snapshot,
+		Time:   s.now(),
+	})
+}
+
+func runWebhook(ctx context.Context, url string, body []byte, signature string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		// Retrying will never build the request so drop the task
+		log.Errorf(ctx, "gaestore: dropping Webhook to [%s]: %v", url, err)
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, signature)
+	resp, err := webhookClient(ctx).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("gaestore: webhook [%s] responded [%s]", url, resp.Status)
+	}
+	return nil
+}
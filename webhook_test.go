@@ -0,0 +1,72 @@
+package gaestore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var received []WebhookPayload
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hook := Webhook{Secret: []byte("secret")}
+		if r.Header.Get(WebhookSignatureHeader) != hook.sign(body) {
+			t.Errorf("Expected a valid signature but got [%s]", r.Header.Get(WebhookSignatureHeader))
+		}
+		var p WebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Error(err)
+		}
+		received = append(received, p)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	var failed []error
+	defer func(orig func(context.Context, string, []byte, string) error) {
+		callWebhookTask = orig
+	}(callWebhookTask)
+	callWebhookTask = func(ctx context.Context, url string, body []byte, signature string) error {
+		if err := runWebhook(ctx, url, body, signature); err != nil {
+			failed = append(failed, err)
+		}
+		return nil
+	}
+	defer func(orig func(context.Context) *http.Client) {
+		webhookClient = orig
+	}(webhookClient)
+	webhookClient = func(ctx context.Context) *http.Client {
+		return srv.Client()
+	}
+
+	ctx := keyContext(t)
+	s := NewStore(WithBackend(mapBackend{}), WithLogger(nopLogger{}),
+		WithWebhook(Webhook{URL: srv.URL, Secret: []byte("secret"), Kinds: []string{"object"}}))
+	o := &object{ID: "1", Name: "one"}
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[0].Type != "Update" || received[1].Type != "Delete" {
+		t.Fatalf("Expected an update and a delete but got [%+v]", received)
+	}
+	if p := received[0]; p.Kind != "object" || p.Key != o.Key(ctx).Encode() || string(p.Entity) != `{"ID":"1","Name":"one"}` {
+		t.Fatalf("Expected the entity's snapshot but got [%+v]", p)
+	}
+
+	// Failing responses are retried by the task queue
+	status = http.StatusInternalServerError
+	if _, err := s.Put(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 {
+		t.Fatalf("Expected the request to fail but got [%v]", failed)
+	}
+}